@@ -1,41 +1,141 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 
+	"task-api/internal/clock"
+	"task-api/internal/events"
 	"task-api/internal/handlers"
+	"task-api/internal/models"
+	"task-api/internal/scheduler"
 	"task-api/internal/storage"
+	"task-api/internal/webhooks"
 )
 
 func main() {
-	// Initialize storage
-	taskStorage := storage.NewTaskStorage()
-	log.Println("Storage initialized successfully")
+	// Validation limits shared by every handler
+	limits, err := validationLimits()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	models.SetLimits(limits)
+
+	// Status workflow, loaded before storage so migrations map legacy statuses onto it
+	workflow, err := loadWorkflow()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	models.SetWorkflow(workflow)
+
+	// Scheduler settings, read before anything needs releasing
+	schedulerConfig, err := loadSchedulerConfig()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	replaySize, err := eventsReplaySize()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	webhookConfig, err := loadWebhookConfig()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	notifiers, err := loadNotifiers()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	// Initialize storage; the scheduler reads the same clock and every change feeds the event bus
+	systemClock := clock.System()
+	bus := events.NewBus(replaySize)
+	taskStorage, err := storage.NewTaskStorage(storage.WithClock(systemClock), storage.WithObserver(bus.Publish))
+	if err != nil {
+		closeNotifiers(notifiers)
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+	log.Printf("Storage initialized successfully (backend: %s)", storage.AutoDetectBackend())
+
+	// Watch due dates in the background until the server stops
+	stopScheduler := func() {}
+	if schedulerConfig.Interval > 0 {
+		stopScheduler = startBackground(scheduler.New(taskStorage, systemClock, schedulerConfig, notifiers...).Run)
+		log.Printf("Scheduler started (interval: %s, reminder lead: %s, notifiers: %d)",
+			schedulerConfig.Interval, schedulerConfig.ReminderLead, len(notifiers))
+	}
+
+	// Deliver task events to the registered webhooks until the server stops
+	hooks := webhooks.New(bus, systemClock, webhookConfig, nil)
+	stopWebhooks := startBackground(hooks.Run)
+	log.Printf("Webhook delivery started (attempts: %d, first retry after: %s)", webhookConfig.MaxAttempts, webhookConfig.Backoff)
 
 	// Initialize handlers
 	taskHandler := handlers.NewTaskHandler(taskStorage)
+	eventsHandler := handlers.NewEventsHandler(bus)
+	socketHandler := handlers.NewSocketHandler(taskStorage, bus)
+	webhookHandler := handlers.NewWebhookHandler(hooks)
 
 	// Setup router
 	r := chi.NewRouter()
 
 	// Middleware
-	r.Use(middleware.Logger)                    // Request logging
-	r.Use(middleware.Recoverer)                 // Panic recovery
-	r.Use(middleware.Timeout(60 * time.Second)) // Request timeout
+	r.Use(middleware.Logger)    // Request logging
+	r.Use(middleware.Recoverer) // Panic recovery
+
+	// Errors outside the handlers are problem documents too
+	r.NotFound(handlers.NotFound)
+	r.MethodNotAllowed(handlers.MethodNotAllowed)
+
+	// Streams and sockets last as long as their clients stay, so they have no request timeout
+	r.Get("/tasks/events", eventsHandler.StreamEvents)
+	r.Get("/ws", socketHandler.Connect)
 
 	// Routes
-	r.Get("/health", healthHandler)
-	r.Route("/tasks", func(r chi.Router) {
-		r.Get("/", taskHandler.GetAllTasks)
-		r.Post("/", taskHandler.CreateTask)
-		r.Put("/{id}", taskHandler.UpdateTask)
-		r.Delete("/{id}", taskHandler.DeleteTask)
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.Timeout(60 * time.Second)) // Request timeout
+
+		r.Get("/health", healthHandler)
+		r.Get("/workflow", taskHandler.GetWorkflow)
+		r.Route("/tasks", func(r chi.Router) {
+			r.Get("/", taskHandler.GetAllTasks)
+			r.Post("/", taskHandler.CreateTask)
+			r.Get("/search", taskHandler.SearchTasks)
+			r.Get("/plan", taskHandler.GetPlan)
+			r.Post("/bulk", taskHandler.CreateTasksBulk)
+			r.Patch("/bulk", taskHandler.UpdateTasksBulk)
+			r.Delete("/bulk", taskHandler.DeleteTasksBulk)
+			r.Get("/{id}", taskHandler.GetTask)
+			r.Put("/{id}", taskHandler.UpdateTask)
+			r.Patch("/{id}", taskHandler.PatchTask)
+			r.Delete("/{id}", taskHandler.DeleteTask)
+			r.Get("/{id}/children", taskHandler.GetChildren)
+			r.Get("/{id}/tree", taskHandler.GetTaskTree)
+			r.Get("/{id}/dependencies", taskHandler.GetDependencies)
+			r.Post("/{id}/dependencies", taskHandler.AddDependency)
+			r.Delete("/{id}/dependencies/{blockerID}", taskHandler.RemoveDependency)
+		})
+		r.Route("/webhooks", func(r chi.Router) {
+			r.Get("/", webhookHandler.GetWebhooks)
+			r.Post("/", webhookHandler.CreateWebhook)
+			r.Get("/dead-letters", webhookHandler.GetDeadLetters)
+			r.Post("/dead-letters/{id}/redeliver", webhookHandler.Redeliver)
+			r.Get("/{id}", webhookHandler.GetWebhook)
+			r.Delete("/{id}", webhookHandler.DeleteWebhook)
+			r.Get("/{id}/deliveries", webhookHandler.GetDeliveries)
+		})
 	})
 
 	// Get port from environment or use default
@@ -46,23 +146,252 @@ func main() {
 
 	log.Printf("Starting server on port %s", port)
 	log.Printf("Available endpoints:")
-	log.Printf("  GET    /health       - Health check")
-	log.Printf("  GET    /tasks        - Get all tasks")
-	log.Printf("  POST   /tasks        - Create new task")
-	log.Printf("  PUT    /tasks/{id}   - Update task")
-	log.Printf("  DELETE /tasks/{id}   - Delete task")
+	log.Printf("  GET    /health                               - Health check")
+	log.Printf("  GET    /workflow                             - List task states and transitions")
+	log.Printf("  GET    /ws                                   - Subscribe to task changes and send commands (WebSocket)")
+	log.Printf("  GET    /tasks                                - Get all tasks")
+	log.Printf("  POST   /tasks                                - Create new task")
+	log.Printf("  GET    /tasks/events                         - Stream task changes (Server-Sent Events)")
+	log.Printf("  GET    /tasks/search                         - Search tasks (?q=)")
+	log.Printf("  GET    /tasks/plan                           - Get execution order and critical path of open tasks")
+	log.Printf("  POST   /tasks/bulk                           - Create many tasks")
+	log.Printf("  PATCH  /tasks/bulk                           - Update many tasks")
+	log.Printf("  DELETE /tasks/bulk                           - Delete many tasks")
+	log.Printf("  GET    /tasks/{id}                           - Get task")
+	log.Printf("  PUT    /tasks/{id}                           - Update task")
+	log.Printf("  PATCH  /tasks/{id}                           - Partially update task")
+	log.Printf("  DELETE /tasks/{id}                           - Delete task (?children=cascade deletes subtasks)")
+	log.Printf("  GET    /tasks/{id}/children                  - List subtasks")
+	log.Printf("  GET    /tasks/{id}/tree                      - Get task with all subtasks")
+	log.Printf("  GET    /tasks/{id}/dependencies              - List blocking tasks")
+	log.Printf("  POST   /tasks/{id}/dependencies              - Add blocking task")
+	log.Printf("  DELETE /tasks/{id}/dependencies/{blockerID}  - Remove blocking task")
+	log.Printf("  GET    /webhooks                             - List webhooks")
+	log.Printf("  POST   /webhooks                             - Register webhook")
+	log.Printf("  GET    /webhooks/dead-letters                - List failed deliveries")
+	log.Printf("  POST   /webhooks/dead-letters/{id}/redeliver - Redeliver failed delivery")
+	log.Printf("  GET    /webhooks/{id}                        - Get webhook")
+	log.Printf("  DELETE /webhooks/{id}                        - Delete webhook")
+	log.Printf("  GET    /webhooks/{id}/deliveries             - List recent deliveries")
 
 	// Create HTTP server with proper timeouts for security
 	server := &http.Server{
 		Addr:         ":" + port,
 		Handler:      r,
 		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
+		WriteTimeout: 15 * time.Second, // Lifted by event streams
 		IdleTimeout:  60 * time.Second,
 	}
+	// End event streams and sockets, which would otherwise hold up the shutdown
+	server.RegisterOnShutdown(bus.Close)
+
+	// Start serving in the background so we can wait for shutdown signals
+	serverErr := make(chan error, 1)
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+		close(serverErr)
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+
+	select {
+	case err := <-serverErr:
+		if err != nil {
+			stopScheduler()
+			stopWebhooks()
+			closeNotifiers(notifiers)
+			closeStorage(taskStorage)
+			log.Fatal("Server failed to start:", err)
+		}
+	case sig := <-stop:
+		log.Printf("Received %s, shutting down", sig)
+	}
+
+	// Give in-flight requests a chance to finish and stop the scheduler and webhook deliveries before releasing storage
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Error during server shutdown: %v", err)
+	}
+	stopScheduler()
+	stopWebhooks()
+	closeNotifiers(notifiers)
+	closeStorage(taskStorage)
+	log.Println("Server stopped")
+}
+
+// validationLimits reads the task validation limits from the environment,
+// falling back to models.DefaultLimits for anything not set
+func validationLimits() (models.Limits, error) {
+	limits := models.DefaultLimits
+	for _, setting := range []struct {
+		env   string
+		limit *int
+	}{
+		{"TASK_NAME_MAX_LENGTH", &limits.MaxNameLength},
+		{"TASK_DESCRIPTION_MAX_LENGTH", &limits.MaxDescriptionLength},
+	} {
+		if raw := os.Getenv(setting.env); raw != "" {
+			value, err := strconv.Atoi(raw)
+			if err != nil || value < 1 {
+				return limits, fmt.Errorf("%s must be a positive integer, got %q", setting.env, raw)
+			}
+			*setting.limit = value
+		}
+	}
+	return limits, nil
+}
+
+// loadWorkflow reads the task workflow from the JSON file named by
+// TASK_WORKFLOW_FILE, falling back to models.BasicWorkflow when it is not set
+func loadWorkflow() (*models.Workflow, error) {
+	path := os.Getenv("TASK_WORKFLOW_FILE")
+	if path == "" {
+		return models.BasicWorkflow(), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("TASK_WORKFLOW_FILE: %w", err)
+	}
+	workflow, err := models.ParseWorkflow(data)
+	if err != nil {
+		return nil, fmt.Errorf("TASK_WORKFLOW_FILE %s: %w", path, err)
+	}
+	return workflow, nil
+}
+
+// loadSchedulerConfig reads the scheduler settings from the environment:
+// SCHEDULER_INTERVAL between scans (0 disables the scheduler) and REMINDER_LEAD
+// before due dates (0 disables reminders), both durations such as 30s or 1h,
+// falling back to scheduler.DefaultConfig for anything not set
+func loadSchedulerConfig() (scheduler.Config, error) {
+	config := scheduler.DefaultConfig
+	for _, setting := range []struct {
+		env      string
+		duration *time.Duration
+	}{
+		{"SCHEDULER_INTERVAL", &config.Interval},
+		{"REMINDER_LEAD", &config.ReminderLead},
+	} {
+		if raw := os.Getenv(setting.env); raw != "" {
+			value, err := time.ParseDuration(raw)
+			if err != nil || value < 0 {
+				return config, fmt.Errorf("%s must be a non-negative duration such as 30s or 1h, got %q", setting.env, raw)
+			}
+			*setting.duration = value
+		}
+	}
+	return config, nil
+}
+
+// eventsReplaySize reads from EVENTS_REPLAY_SIZE how many events are kept for
+// clients resuming the event stream, falling back to events.DefaultReplaySize
+func eventsReplaySize() (int, error) {
+	raw := os.Getenv("EVENTS_REPLAY_SIZE")
+	if raw == "" {
+		return events.DefaultReplaySize, nil
+	}
+	size, err := strconv.Atoi(raw)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("EVENTS_REPLAY_SIZE must be a non-negative integer, got %q", raw)
+	}
+	return size, nil
+}
 
-	if err := server.ListenAndServe(); err != nil {
-		log.Fatal("Server failed to start:", err)
+// loadWebhookConfig reads the webhook delivery settings from the environment:
+// WEBHOOK_MAX_ATTEMPTS before a delivery becomes a dead letter and
+// WEBHOOK_RETRY_DELAY before the first retry, doubling with every further one,
+// and WEBHOOK_ALLOW_PRIVATE to let webhooks point at private addresses,
+// falling back to webhooks.DefaultConfig for anything not set
+func loadWebhookConfig() (webhooks.Config, error) {
+	config := webhooks.DefaultConfig
+	if raw := os.Getenv("WEBHOOK_MAX_ATTEMPTS"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 1 {
+			return config, fmt.Errorf("WEBHOOK_MAX_ATTEMPTS must be a positive integer, got %q", raw)
+		}
+		config.MaxAttempts = value
+	}
+	if raw := os.Getenv("WEBHOOK_RETRY_DELAY"); raw != "" {
+		value, err := time.ParseDuration(raw)
+		if err != nil || value <= 0 {
+			return config, fmt.Errorf("WEBHOOK_RETRY_DELAY must be a positive duration such as 30s or 1h, got %q", raw)
+		}
+		config.Backoff = value
+		config.MaxBackoff = max(config.MaxBackoff, value)
+	}
+	if raw := os.Getenv("WEBHOOK_ALLOW_PRIVATE"); raw != "" {
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return config, fmt.Errorf("WEBHOOK_ALLOW_PRIVATE must be true or false, got %q", raw)
+		}
+		config.AllowPrivate = value
+	}
+	return config, nil
+}
+
+// loadNotifiers returns the notifiers scheduler events go to: the log, always,
+// plus a webhook if NOTIFY_WEBHOOK_URL is set and a file of JSON lines if
+// NOTIFY_FILE is
+func loadNotifiers() ([]scheduler.Notifier, error) {
+	notifiers := []scheduler.Notifier{scheduler.NewLogNotifier(nil)}
+	if raw := os.Getenv("NOTIFY_WEBHOOK_URL"); raw != "" {
+		webhook, err := url.Parse(raw)
+		if err != nil || (webhook.Scheme != "http" && webhook.Scheme != "https") || webhook.Host == "" {
+			return nil, fmt.Errorf("NOTIFY_WEBHOOK_URL must be an http or https URL, got %q", raw)
+		}
+		notifiers = append(notifiers, scheduler.NewWebhookNotifier(raw, nil))
+	}
+	if path := os.Getenv("NOTIFY_FILE"); path != "" {
+		file, err := scheduler.NewFileNotifier(path)
+		if err != nil {
+			return nil, fmt.Errorf("NOTIFY_FILE: %w", err)
+		}
+		notifiers = append(notifiers, file)
+	}
+	return notifiers, nil
+}
+
+// startBackground runs run in the background, such as the scheduler or the
+// webhook deliveries, and returns a function stopping it, which returns once
+// the work in progress has given up
+func startBackground(run func(context.Context)) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		run(ctx)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// closeNotifiers releases notifier resources for notifiers that hold them (e.g. open files)
+func closeNotifiers(notifiers []scheduler.Notifier) {
+	for _, notifier := range notifiers {
+		closer, ok := notifier.(io.Closer)
+		if !ok {
+			continue
+		}
+		if err := closer.Close(); err != nil {
+			log.Printf("Error closing notifier: %v", err)
+		}
+	}
+}
+
+// closeStorage releases storage resources for backends that hold them (e.g. database connections)
+func closeStorage(taskStorage storage.TaskStorage) {
+	closer, ok := taskStorage.(io.Closer)
+	if !ok {
+		return
+	}
+	if err := closer.Close(); err != nil {
+		log.Printf("Error closing storage: %v", err)
 	}
 }
 
@@ -0,0 +1,61 @@
+// Package clock abstracts reading the current time, so code that stamps or
+// schedules by time can be tested deterministically.
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the current time.
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock reads the operating system clock.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// System returns the clock of the operating system.
+func System() Clock {
+	return systemClock{}
+}
+
+// Manual is a clock that only moves when told to, for tests.
+// It is safe for concurrent use.
+type Manual struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+// NewManual returns a manual clock set to now.
+func NewManual(now time.Time) *Manual {
+	return &Manual{now: now}
+}
+
+// Now returns the time the clock was last set to.
+func (c *Manual) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.now
+}
+
+// Set moves the clock to now.
+func (c *Manual) Set(now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.now = now
+}
+
+// Advance moves the clock forward by d.
+func (c *Manual) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.now = c.now.Add(d)
+}
@@ -0,0 +1,36 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+// TestManual tests that a manual clock only moves when told to
+func TestManual(t *testing.T) {
+	start := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewManual(start)
+
+	if now := c.Now(); !now.Equal(start) {
+		t.Errorf("Expected %v, got %v", start, now)
+	}
+
+	c.Advance(90 * time.Second)
+	if expected := start.Add(90 * time.Second); !c.Now().Equal(expected) {
+		t.Errorf("Expected %v after Advance, got %v", expected, c.Now())
+	}
+
+	later := start.AddDate(1, 0, 0)
+	c.Set(later)
+	if !c.Now().Equal(later) {
+		t.Errorf("Expected %v after Set, got %v", later, c.Now())
+	}
+}
+
+// TestSystem tests that the system clock follows the wall clock
+func TestSystem(t *testing.T) {
+	before := time.Now()
+	now := System().Now()
+	if now.Before(before) || now.After(time.Now()) {
+		t.Errorf("Expected the system clock to read the current time, got %v", now)
+	}
+}
@@ -0,0 +1,191 @@
+// Package events broadcasts the changes made to tasks to any number of
+// subscribers, such as the clients of GET /tasks/events. Every event gets an
+// ID one higher than the event before, and the latest events are kept in
+// memory so a subscriber that lost its connection can resume where it left off.
+package events
+
+import (
+	"sync"
+	"task-api/internal/models"
+	"task-api/internal/storage"
+)
+
+// Type names what happened to a task.
+type Type string
+
+const (
+	TaskCreated Type = "task.created"
+	TaskUpdated Type = "task.updated"
+	TaskDeleted Type = "task.deleted"
+)
+
+// changeTypes maps storage changes onto event types
+var changeTypes = map[storage.ChangeKind]Type{
+	storage.ChangeCreated: TaskCreated,
+	storage.ChangeUpdated: TaskUpdated,
+	storage.ChangeDeleted: TaskDeleted,
+}
+
+// Event is a change to one task. Subscribers share events, so they must not
+// modify them.
+type Event struct {
+	ID     uint64       `json:"id"`             // Position in the feed, starting at 1
+	Type   Type         `json:"type"`           // What happened
+	TaskID int          `json:"task_id"`        // The task it happened to
+	Task   *models.Task `json:"task,omitempty"` // The task after the change, nil if it was deleted
+}
+
+// DefaultReplaySize is the number of events kept for resuming subscribers by default.
+const DefaultReplaySize = 1000
+
+// subscriberBuffer is how many events a subscriber may fall behind by before
+// it is dropped; it can then resume from the replay buffer.
+const subscriberBuffer = 256
+
+// Bus numbers the changes published to it and hands them to its subscribers.
+// It is safe for concurrent use.
+type Bus struct {
+	mutex       sync.Mutex
+	lastID      uint64                     // ID of the latest event, 0 before the first
+	replay      []Event                    // The latest events, a ring of at most replaySize starting at first
+	first       int                        // Index of the oldest event in replay
+	replaySize  int                        // Events kept for resuming subscribers
+	subscribers map[*Subscription]struct{} // Open subscriptions
+	closed      bool
+}
+
+// NewBus creates a bus keeping the latest replaySize events for subscribers
+// resuming after a lost connection.
+func NewBus(replaySize int) *Bus {
+	return &Bus{replaySize: max(replaySize, 0), subscribers: make(map[*Subscription]struct{})}
+}
+
+// Publish turns the changes of a write into events, in order, and hands them
+// to every subscriber. It never blocks: a subscriber too far behind is
+// dropped instead. Publish is meant to be a storage observer (see
+// storage.WithObserver); it does not keep the tasks of changes beyond copying them.
+func (b *Bus) Publish(changes []storage.Change) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.closed {
+		return
+	}
+	for _, change := range changes {
+		b.lastID++
+		event := Event{ID: b.lastID, Type: changeTypes[change.Kind], TaskID: change.ID, Task: change.Task.Clone()}
+		b.remember(event)
+
+		for subscription := range b.subscribers {
+			select {
+			case subscription.events <- event:
+			default:
+				b.dropLocked(subscription)
+			}
+		}
+	}
+}
+
+// remember adds event to the replay buffer, forgetting the oldest event once it is full.
+// The caller must hold the mutex.
+func (b *Bus) remember(event Event) {
+	switch {
+	case b.replaySize == 0:
+	case len(b.replay) < b.replaySize:
+		b.replay = append(b.replay, event)
+	default:
+		b.replay[b.first] = event
+		b.first = (b.first + 1) % b.replaySize
+	}
+}
+
+// Subscription receives the events published after it started.
+type Subscription struct {
+	events chan Event
+	bus    *Bus
+
+	// Start is the ID of the latest event published before the subscription started.
+	Start uint64
+}
+
+// Events returns the channel events are delivered on. It is closed once the
+// subscription ends: when it is closed, falls behind or the bus is closed.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Close ends the subscription.
+func (s *Subscription) Close() {
+	s.bus.mutex.Lock()
+	defer s.bus.mutex.Unlock()
+
+	s.bus.dropLocked(s)
+}
+
+// Subscribe starts a subscription to the events published from now on.
+// With resume, the events published after the one with ID after are returned
+// as well, from the replay buffer, for the subscriber to handle before the
+// new ones. complete is false if some of them are no longer buffered, or if
+// after is not an event of this bus, such as one from before a restart; the
+// subscriber has then missed changes and has to catch up another way.
+// Subscribing to a closed bus returns a subscription that has already ended.
+func (b *Bus) Subscribe(after uint64, resume bool) (subscription *Subscription, missed []Event, complete bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	subscription = &Subscription{events: make(chan Event, subscriberBuffer), bus: b, Start: b.lastID}
+	if b.closed {
+		close(subscription.events)
+		return subscription, nil, true
+	}
+	b.subscribers[subscription] = struct{}{}
+
+	if !resume {
+		return subscription, nil, true
+	}
+	if after > b.lastID {
+		return subscription, nil, false
+	}
+
+	// The oldest buffered event must directly follow the one resumed after
+	oldest := b.lastID + 1
+	if len(b.replay) > 0 {
+		oldest = b.replay[b.first].ID
+	}
+	complete = after+1 >= oldest
+	for i := range b.replay {
+		if event := b.replay[(b.first+i)%len(b.replay)]; event.ID > after {
+			missed = append(missed, event)
+		}
+	}
+	return subscription, missed, complete
+}
+
+// Close ends every subscription and stops publishing, so streams end and a
+// server can shut down.
+func (b *Bus) Close() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.closed = true
+	for subscription := range b.subscribers {
+		b.dropLocked(subscription)
+	}
+}
+
+// Closed reports whether the bus was closed. A subscriber whose events ended
+// on an open bus fell behind, and may resume.
+func (b *Bus) Closed() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.closed
+}
+
+// dropLocked ends subscription if it has not ended yet. The caller must hold the mutex.
+func (b *Bus) dropLocked(subscription *Subscription) {
+	if _, ok := b.subscribers[subscription]; ok {
+		delete(b.subscribers, subscription)
+		close(subscription.events)
+	}
+}
@@ -0,0 +1,159 @@
+package events
+
+import (
+	"reflect"
+	"task-api/internal/models"
+	"task-api/internal/storage"
+	"testing"
+)
+
+// publish publishes one write changing the tasks with the given IDs, updating
+// tasks with positive IDs and deleting the others
+func publish(b *Bus, ids ...int) {
+	changes := make([]storage.Change, len(ids))
+	for i, id := range ids {
+		if id > 0 {
+			changes[i] = storage.Change{Kind: storage.ChangeUpdated, ID: id, Task: &models.Task{ID: id, Name: "Task"}}
+		} else {
+			changes[i] = storage.Change{Kind: storage.ChangeDeleted, ID: -id}
+		}
+	}
+	b.Publish(changes)
+}
+
+// eventIDs returns the IDs of events in order
+func eventIDs(events []Event) []uint64 {
+	ids := make([]uint64, len(events))
+	for i, event := range events {
+		ids[i] = event.ID
+	}
+	return ids
+}
+
+// receive returns the events waiting for subscription without blocking
+func receive(subscription *Subscription) []Event {
+	var received []Event
+	for {
+		select {
+		case event, ok := <-subscription.Events():
+			if !ok {
+				return received
+			}
+			received = append(received, event)
+		default:
+			return received
+		}
+	}
+}
+
+// TestBus_Publish tests that events are numbered in order and delivered to every subscriber
+func TestBus_Publish(t *testing.T) {
+	b := NewBus(10)
+	first, _, _ := b.Subscribe(0, false)
+	second, _, _ := b.Subscribe(0, false)
+
+	publish(b, 3, -4)
+	publish(b, 5)
+
+	expected := []Event{
+		{ID: 1, Type: TaskUpdated, TaskID: 3, Task: &models.Task{ID: 3, Name: "Task"}},
+		{ID: 2, Type: TaskDeleted, TaskID: 4},
+		{ID: 3, Type: TaskUpdated, TaskID: 5, Task: &models.Task{ID: 5, Name: "Task"}},
+	}
+	for name, subscription := range map[string]*Subscription{"first": first, "second": second} {
+		if received := receive(subscription); !reflect.DeepEqual(received, expected) {
+			t.Errorf("Expected %s subscriber to receive %+v, got %+v", name, expected, received)
+		}
+	}
+
+	// A closed subscription receives nothing more
+	first.Close()
+	publish(b, 6)
+	if received := receive(first); len(received) != 0 {
+		t.Errorf("Expected nothing after Close, got %+v", received)
+	}
+	if received := eventIDs(receive(second)); !reflect.DeepEqual(received, []uint64{4}) {
+		t.Errorf("Expected event 4, got %v", received)
+	}
+}
+
+// TestBus_Subscribe tests resuming subscriptions from the replay buffer
+func TestBus_Subscribe(t *testing.T) {
+	b := NewBus(3)
+	publish(b, 1, 2, 3, 4, 5) // Events 3 to 5 are kept
+
+	tests := []struct {
+		name     string
+		after    uint64
+		resume   bool
+		missed   []uint64
+		complete bool
+	}{
+		{name: "new", after: 0, resume: false, complete: true},
+		{name: "up to date", after: 5, resume: true, complete: true},
+		{name: "behind", after: 3, resume: true, missed: []uint64{4, 5}, complete: true},
+		{name: "oldest kept", after: 2, resume: true, missed: []uint64{3, 4, 5}, complete: true},
+		{name: "too far behind", after: 1, resume: true, missed: []uint64{3, 4, 5}, complete: false},
+		{name: "from the start", after: 0, resume: true, missed: []uint64{3, 4, 5}, complete: false},
+		{name: "unknown event", after: 9, resume: true, complete: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subscription, missed, complete := b.Subscribe(tt.after, tt.resume)
+			defer subscription.Close()
+
+			if ids := eventIDs(missed); !reflect.DeepEqual(ids, append([]uint64{}, tt.missed...)) {
+				t.Errorf("Expected missed events %v, got %v", tt.missed, ids)
+			}
+			if complete != tt.complete {
+				t.Errorf("Expected complete %v, got %v", tt.complete, complete)
+			}
+			if subscription.Start != 5 {
+				t.Errorf("Expected the subscription to start after event 5, got %d", subscription.Start)
+			}
+		})
+	}
+}
+
+// TestBus_SlowSubscriber tests that a subscriber falling too far behind is
+// dropped without holding up publishing
+func TestBus_SlowSubscriber(t *testing.T) {
+	b := NewBus(0)
+	slow, _, _ := b.Subscribe(0, false)
+
+	for id := 1; id <= subscriberBuffer+1; id++ {
+		publish(b, id)
+	}
+
+	received := receive(slow)
+	if len(received) != subscriberBuffer {
+		t.Errorf("Expected %d events before the subscriber was dropped, got %d", subscriberBuffer, len(received))
+	}
+	if _, ok := <-slow.Events(); ok {
+		t.Error("Expected the events of a dropped subscriber to be closed")
+	}
+	if b.Closed() {
+		t.Error("Expected the bus to stay open")
+	}
+	slow.Close() // Closing again is harmless
+}
+
+// TestBus_Close tests that closing the bus ends every subscription
+func TestBus_Close(t *testing.T) {
+	b := NewBus(10)
+	open, _, _ := b.Subscribe(0, false)
+
+	b.Close()
+	if !b.Closed() {
+		t.Error("Expected the bus to be closed")
+	}
+	if _, ok := <-open.Events(); ok {
+		t.Error("Expected open subscriptions to end")
+	}
+	late, _, _ := b.Subscribe(0, false)
+	if _, ok := <-late.Events(); ok {
+		t.Error("Expected subscriptions to a closed bus to end right away")
+	}
+	publish(b, 1) // Ignored
+}
@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"task-api/internal/models"
+	"task-api/internal/storage"
+)
+
+// maxBulkItems caps the number of items in one bulk request
+const maxBulkItems = 1000
+
+// bulkItemResult is the outcome of one item of a bulk request
+type bulkItemResult struct {
+	Index  int                 `json:"index"`            // Position of the item in the request
+	Status int                 `json:"status"`           // HTTP status the item would have had as a single request
+	ID     int                 `json:"id,omitempty"`     // Task ID, if known
+	Task   *models.Task        `json:"task,omitempty"`   // Created or updated task
+	Error  string              `json:"error,omitempty"`  // Why the item failed
+	Errors []models.FieldError `json:"errors,omitempty"` // Invalid fields of the item
+}
+
+// fail records err as the reason the item failed
+func (result *bulkItemResult) fail(err ErrorResponse) {
+	result.Status, result.Error, result.Errors = err.Code, err.Message, err.Fields
+	if err.Detail != "" {
+		result.Error = err.Detail
+	}
+}
+
+// bulkResponse is the body of every bulk endpoint
+type bulkResponse struct {
+	Atomic    bool             `json:"atomic"`
+	Succeeded int              `json:"succeeded"`
+	Failed    int              `json:"failed"`
+	Results   []bulkItemResult `json:"results"`
+}
+
+// bulkItem is an item of a bulk request after validation: either an operation
+// to hand to storage or a result explaining why it was rejected up front
+type bulkItem struct {
+	op       storage.BatchOp
+	rejected *bulkItemResult
+}
+
+// rejectItem builds a bulkItem rejected before reaching storage
+func rejectItem(index, id int, err ErrorResponse) bulkItem {
+	result := &bulkItemResult{Index: index, ID: id}
+	result.fail(err)
+	return bulkItem{rejected: result}
+}
+
+// decodeBulkItems decodes a JSON array body into its raw items, checking the item count
+func decodeBulkItems(w http.ResponseWriter, r *http.Request) ([]json.RawMessage, bool) {
+	var items []json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&items); err != nil {
+		writeErrorResponse(w, r, ErrInvalidJSON)
+		return nil, false
+	}
+	if len(items) == 0 || len(items) > maxBulkItems {
+		writeErrorResponse(w, r, ErrBulkSize)
+		return nil, false
+	}
+	return items, true
+}
+
+// CreateTasksBulk handles POST /tasks/bulk - create many tasks at once.
+// The body is an array of tasks as accepted by POST /tasks.
+func (h *TaskHandler) CreateTasksBulk(w http.ResponseWriter, r *http.Request) {
+	raw, ok := decodeBulkItems(w, r)
+	if !ok {
+		return
+	}
+
+	items := make([]bulkItem, len(raw))
+	for i, data := range raw {
+		var input models.Task
+		if err := json.Unmarshal(data, &input); err != nil {
+			items[i] = rejectItem(i, 0, ErrInvalidJSON)
+			continue
+		}
+		task, err := newTaskFromInput(input)
+		if err != nil {
+			items[i] = rejectItem(i, 0, validationErrorResponse(err))
+			continue
+		}
+		items[i] = bulkItem{op: storage.BatchOp{Kind: storage.BatchCreate, Task: task}}
+	}
+
+	h.runBulk(w, r, items, http.StatusCreated)
+}
+
+// UpdateTasksBulk handles PATCH /tasks/bulk - update many tasks at once.
+// Each item names a task by id and carries the fields to change; omitted
+// fields keep their current value and null clears a detail. A version, if
+// given, must be current.
+func (h *TaskHandler) UpdateTasksBulk(w http.ResponseWriter, r *http.Request) {
+	raw, ok := decodeBulkItems(w, r)
+	if !ok {
+		return
+	}
+
+	items := make([]bulkItem, len(raw))
+	for i, data := range raw {
+		var input partialUpdate
+		if err := json.Unmarshal(data, &input); err != nil {
+			items[i] = rejectItem(i, 0, ErrInvalidJSON)
+			continue
+		}
+		task, rejection := input.load(r.Context(), h.storage)
+		if rejection != nil {
+			items[i] = rejectItem(i, input.ID, *rejection)
+			continue
+		}
+		items[i] = bulkItem{op: storage.BatchOp{Kind: storage.BatchUpdate, Task: task}}
+	}
+
+	h.runBulk(w, r, items, http.StatusOK)
+}
+
+// partialUpdate is a change to some fields of the task with ID: omitted fields
+// keep their current value and null clears a detail. A version, if given,
+// must be current.
+type partialUpdate struct {
+	ID      int     `json:"id"`
+	Name    *string `json:"name"`
+	Status  *int    `json:"status"`
+	State   string  `json:"state"`
+	Version *int    `json:"version"`
+	detailsInput
+}
+
+// load fetches the task to update and applies the update to it, returning
+// the problem to report if the update cannot be made
+func (in partialUpdate) load(ctx context.Context, s storage.TaskStorage) (*models.Task, *ErrorResponse) {
+	fail := func(err ErrorResponse) (*models.Task, *ErrorResponse) {
+		return nil, &err
+	}
+	if in.ID <= 0 {
+		return fail(ErrInvalidTaskID)
+	}
+
+	task, err := s.GetByID(ctx, in.ID)
+	if err != nil {
+		return fail(storageErrorResponse(err))
+	}
+	if in.Version != nil && *in.Version != task.Version {
+		return fail(ErrTaskConflict)
+	}
+
+	name, status := task.Name, task.Status
+	if in.Name != nil {
+		name = *in.Name
+	}
+	if in.Status != nil {
+		status = *in.Status
+	}
+	if err := updateTaskFromInput(task, name, status, in.State, in.apply(task.Details)); err != nil {
+		return fail(validationErrorResponse(err))
+	}
+	return task, nil
+}
+
+// DeleteTasksBulk handles DELETE /tasks/bulk - delete many tasks at once.
+// The body is an array of task IDs.
+func (h *TaskHandler) DeleteTasksBulk(w http.ResponseWriter, r *http.Request) {
+	raw, ok := decodeBulkItems(w, r)
+	if !ok {
+		return
+	}
+
+	items := make([]bulkItem, len(raw))
+	for i, data := range raw {
+		var id int
+		if err := json.Unmarshal(data, &id); err != nil || id <= 0 {
+			items[i] = rejectItem(i, 0, ErrInvalidTaskID)
+			continue
+		}
+		items[i] = bulkItem{op: storage.BatchOp{Kind: storage.BatchDelete, ID: id}}
+	}
+
+	h.runBulk(w, r, items, http.StatusNoContent)
+}
+
+// runBulk hands the valid items to storage as one batch and writes the per-item results.
+//
+// With ?atomic=true either every item is applied or none is: a single invalid
+// item aborts the batch before storage is touched, and storage rolls back if any
+// operation fails. The response then carries the status of the first failed item
+// and marks every other item 424 Failed Dependency. Without atomic, valid items
+// are applied even if others fail, and the response is 200 OK.
+func (h *TaskHandler) runBulk(w http.ResponseWriter, r *http.Request, items []bulkItem, successStatus int) {
+	atomic := false
+	if raw := r.URL.Query().Get("atomic"); raw != "" {
+		var err error
+		if atomic, err = strconv.ParseBool(raw); err != nil {
+			writeErrorResponse(w, r, ErrInvalidQuery.withDetail(errors.New("atomic must be true or false")))
+			return
+		}
+	}
+
+	response := bulkResponse{Atomic: atomic, Results: make([]bulkItemResult, len(items))}
+
+	// Collect the operations that passed validation, remembering their position
+	var ops []storage.BatchOp
+	var positions []int
+	rejected := false
+	for i, item := range items {
+		if item.rejected != nil {
+			response.Results[i] = *item.rejected
+			rejected = true
+			continue
+		}
+		ops = append(ops, item.op)
+		positions = append(positions, i)
+	}
+
+	if !(atomic && rejected) && len(ops) > 0 {
+		results, err := h.storage.Batch(r.Context(), ops, atomic)
+		if err != nil {
+			writeErrorResponse(w, r, storageErrorResponse(err))
+			return
+		}
+
+		for j, result := range results {
+			i := positions[j]
+			itemResult := bulkItemResult{Index: i, ID: ops[j].ID, Task: result.Task}
+			switch {
+			case result.Task != nil:
+				itemResult.ID = result.Task.ID
+			case ops[j].Task != nil:
+				itemResult.ID = ops[j].Task.ID
+			}
+
+			switch {
+			case result.Applied:
+				itemResult.Status = successStatus
+			case result.Err != nil:
+				itemResult.fail(storageErrorResponse(result.Err))
+			}
+			response.Results[i] = itemResult
+		}
+	}
+
+	// Items an atomic batch did not apply because another item failed
+	status := http.StatusOK
+	for i := range response.Results {
+		result := &response.Results[i]
+		result.Index = i
+		switch {
+		case result.Status == 0:
+			result.Status = http.StatusFailedDependency
+			result.Error = "Not applied because another item of the atomic request failed"
+			result.Task = nil
+			response.Failed++
+		case result.Status >= http.StatusBadRequest:
+			if atomic && status == http.StatusOK {
+				status = result.Status
+			}
+			response.Failed++
+		default:
+			response.Succeeded++
+		}
+	}
+
+	if err := writeJSONResponse(w, response, status); err != nil {
+		writeErrorResponse(w, r, ErrInternalServer)
+		return
+	}
+}
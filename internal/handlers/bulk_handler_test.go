@@ -0,0 +1,319 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"task-api/internal/models"
+	"task-api/internal/storage"
+	"testing"
+)
+
+// newBulkRequest builds a bulk request with a JSON body
+func newBulkRequest(method, target string, body interface{}) *http.Request {
+	data, _ := json.Marshal(body)
+	req := httptest.NewRequest(method, target, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+// decodeBulkResponse decodes a bulk response body or fails the test
+func decodeBulkResponse(t *testing.T, w *httptest.ResponseRecorder) bulkResponse {
+	t.Helper()
+
+	var response bulkResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return response
+}
+
+// itemStatuses returns the per-item statuses of a bulk response in order
+func itemStatuses(response bulkResponse) []int {
+	statuses := make([]int, len(response.Results))
+	for i, result := range response.Results {
+		statuses[i] = result.Status
+	}
+	return statuses
+}
+
+// seedTasks stores tasks with the given names and returns them
+func seedTasks(t *testing.T, handler *TaskHandler, names ...string) []*models.Task {
+	t.Helper()
+
+	tasks := make([]*models.Task, 0, len(names))
+	for _, name := range names {
+		task, _ := models.NewTask(name, 0)
+		created, err := handler.storage.Create(context.Background(), task)
+		if err != nil {
+			t.Fatalf("Failed to create task: %v", err)
+		}
+		tasks = append(tasks, created)
+	}
+	return tasks
+}
+
+// TestTaskHandler_CreateTasksBulk tests bulk creation with and without atomic
+func TestTaskHandler_CreateTasksBulk(t *testing.T) {
+	items := []interface{}{
+		map[string]interface{}{"name": "First", "status": 0},
+		map[string]interface{}{"name": "", "status": 0},
+		map[string]interface{}{"name": "Third", "status": 1},
+	}
+
+	tests := []struct {
+		name             string
+		target           string
+		items            []interface{}
+		expectedStatus   int
+		expectedStatuses []int
+		expectedStored   int
+	}{
+		{
+			name:             "all valid",
+			target:           "/tasks/bulk",
+			items:            []interface{}{items[0], items[2]},
+			expectedStatus:   http.StatusOK,
+			expectedStatuses: []int{http.StatusCreated, http.StatusCreated},
+			expectedStored:   2,
+		},
+		{
+			name:             "partial success",
+			target:           "/tasks/bulk",
+			items:            items,
+			expectedStatus:   http.StatusOK,
+			expectedStatuses: []int{http.StatusCreated, http.StatusBadRequest, http.StatusCreated},
+			expectedStored:   2,
+		},
+		{
+			name:             "atomic rejects everything",
+			target:           "/tasks/bulk?atomic=true",
+			items:            items,
+			expectedStatus:   http.StatusBadRequest,
+			expectedStatuses: []int{http.StatusFailedDependency, http.StatusBadRequest, http.StatusFailedDependency},
+			expectedStored:   0,
+		},
+		{
+			name:             "atomic success",
+			target:           "/tasks/bulk?atomic=true",
+			items:            []interface{}{items[0], items[2]},
+			expectedStatus:   http.StatusOK,
+			expectedStatuses: []int{http.StatusCreated, http.StatusCreated},
+			expectedStored:   2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := setupTestHandler()
+			w := httptest.NewRecorder()
+
+			handler.CreateTasksBulk(w, newBulkRequest(http.MethodPost, tt.target, tt.items))
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			response := decodeBulkResponse(t, w)
+			got := itemStatuses(response)
+			if len(got) != len(tt.expectedStatuses) {
+				t.Fatalf("Expected item statuses %v, got %v", tt.expectedStatuses, got)
+			}
+			for i := range got {
+				if got[i] != tt.expectedStatuses[i] {
+					t.Errorf("Expected item statuses %v, got %v", tt.expectedStatuses, got)
+					break
+				}
+			}
+			for _, result := range response.Results {
+				if result.Status == http.StatusCreated && (result.Task == nil || result.ID == 0) {
+					t.Errorf("Expected created item %d to carry the task and its ID", result.Index)
+				}
+			}
+
+			stored, _ := handler.storage.GetAll(context.Background())
+			if len(stored) != tt.expectedStored {
+				t.Errorf("Expected %d stored tasks, got %d", tt.expectedStored, len(stored))
+			}
+		})
+	}
+}
+
+// TestTaskHandler_UpdateTasksBulk tests bulk updates, including version
+// checks and rolling back an atomic batch
+func TestTaskHandler_UpdateTasksBulk(t *testing.T) {
+	t.Run("partial success", func(t *testing.T) {
+		handler := setupTestHandler()
+		tasks := seedTasks(t, handler, "One", "Two")
+
+		w := httptest.NewRecorder()
+		handler.UpdateTasksBulk(w, newBulkRequest(http.MethodPatch, "/tasks/bulk", []interface{}{
+			map[string]interface{}{"id": tasks[0].ID, "status": 1},
+			map[string]interface{}{"id": tasks[1].ID, "name": "Renamed", "version": 99},
+			map[string]interface{}{"id": 999, "status": 1},
+			map[string]interface{}{"id": tasks[1].ID, "status": 7},
+		}))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", w.Code)
+		}
+		response := decodeBulkResponse(t, w)
+		expected := []int{http.StatusOK, http.StatusConflict, http.StatusNotFound, http.StatusBadRequest}
+		for i, status := range itemStatuses(response) {
+			if status != expected[i] {
+				t.Errorf("Item %d: expected status %d, got %d", i, expected[i], status)
+			}
+		}
+		if response.Succeeded != 1 || response.Failed != 3 {
+			t.Errorf("Expected 1 succeeded and 3 failed, got %d and %d", response.Succeeded, response.Failed)
+		}
+
+		updated, _ := handler.storage.GetByID(context.Background(), tasks[0].ID)
+		if updated.Status != 1 || updated.Name != "One" {
+			t.Errorf("Expected only the status to change, got %+v", updated)
+		}
+		if response.Results[0].Task == nil || response.Results[0].Task.Version != updated.Version {
+			t.Errorf("Expected the updated task in the result, got %+v", response.Results[0].Task)
+		}
+	})
+
+	t.Run("atomic rollback", func(t *testing.T) {
+		handler := setupTestHandler()
+		tasks := seedTasks(t, handler, "One", "Two")
+
+		// Both items pass validation, but the same task is updated twice
+		// with the same version: the second update conflicts in storage
+		w := httptest.NewRecorder()
+		handler.UpdateTasksBulk(w, newBulkRequest(http.MethodPatch, "/tasks/bulk?atomic=true", []interface{}{
+			map[string]interface{}{"id": tasks[0].ID, "name": "First edit"},
+			map[string]interface{}{"id": tasks[0].ID, "name": "Second edit"},
+		}))
+
+		if w.Code != http.StatusConflict {
+			t.Errorf("Expected status 409, got %d", w.Code)
+		}
+		response := decodeBulkResponse(t, w)
+		expected := []int{http.StatusFailedDependency, http.StatusConflict}
+		for i, status := range itemStatuses(response) {
+			if status != expected[i] {
+				t.Errorf("Item %d: expected status %d, got %d", i, expected[i], status)
+			}
+		}
+		if response.Results[0].Task != nil {
+			t.Error("Expected no task for an item that was rolled back")
+		}
+
+		stored, _ := handler.storage.GetByID(context.Background(), tasks[0].ID)
+		if stored.Name != "One" || stored.Version != tasks[0].Version {
+			t.Errorf("Expected the task to be unchanged after rollback, got %+v", stored)
+		}
+	})
+}
+
+// TestTaskHandler_DeleteTasksBulk tests bulk deletion
+func TestTaskHandler_DeleteTasksBulk(t *testing.T) {
+	tests := []struct {
+		name             string
+		target           string
+		expectedStatus   int
+		expectedStatuses []int
+		expectedLeft     int
+	}{
+		{
+			name:             "partial success",
+			target:           "/tasks/bulk",
+			expectedStatus:   http.StatusOK,
+			expectedStatuses: []int{http.StatusNoContent, http.StatusNotFound, http.StatusBadRequest},
+			expectedLeft:     1,
+		},
+		{
+			name:             "atomic",
+			target:           "/tasks/bulk?atomic=1",
+			expectedStatus:   http.StatusBadRequest,
+			expectedStatuses: []int{http.StatusFailedDependency, http.StatusFailedDependency, http.StatusBadRequest},
+			expectedLeft:     2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := setupTestHandler()
+			tasks := seedTasks(t, handler, "One", "Two")
+
+			w := httptest.NewRecorder()
+			handler.DeleteTasksBulk(w, newBulkRequest(http.MethodDelete, tt.target, []interface{}{tasks[0].ID, 999, "abc"}))
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			response := decodeBulkResponse(t, w)
+			for i, status := range itemStatuses(response) {
+				if status != tt.expectedStatuses[i] {
+					t.Errorf("Item %d: expected status %d, got %d", i, tt.expectedStatuses[i], status)
+				}
+			}
+
+			left, _ := handler.storage.GetAll(context.Background())
+			if len(left) != tt.expectedLeft {
+				t.Errorf("Expected %d tasks left, got %d", tt.expectedLeft, len(left))
+			}
+		})
+	}
+}
+
+// TestTaskHandler_Bulk_InvalidRequests tests requests rejected as a whole
+func TestTaskHandler_Bulk_InvalidRequests(t *testing.T) {
+	tooMany := make([]int, maxBulkItems+1)
+
+	tests := []struct {
+		name           string
+		target         string
+		body           string
+		expectedStatus int
+	}{
+		{"invalid JSON", "/tasks/bulk", "{invalid", http.StatusBadRequest},
+		{"not an array", "/tasks/bulk", `{"id": 1}`, http.StatusBadRequest},
+		{"empty array", "/tasks/bulk", `[]`, http.StatusBadRequest},
+		{"too many items", "/tasks/bulk", string(mustMarshal(t, tooMany)), http.StatusBadRequest},
+		{"invalid atomic", "/tasks/bulk?atomic=maybe", `[1]`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := setupTestHandler()
+			req := httptest.NewRequest(http.MethodDelete, tt.target, strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+
+			handler.DeleteTasksBulk(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+// TestTaskHandler_Bulk_StorageError tests that a failing batch maps to an error response
+func TestTaskHandler_Bulk_StorageError(t *testing.T) {
+	handler := setupTestHandlerWithError(storage.ErrUnavailable)
+
+	w := httptest.NewRecorder()
+	handler.DeleteTasksBulk(w, newBulkRequest(http.MethodDelete, "/tasks/bulk", []int{1, 2}))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %d", w.Code)
+	}
+}
+
+// mustMarshal encodes v as JSON or fails the test
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	t.Helper()
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+	return data
+}
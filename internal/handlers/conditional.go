@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"task-api/internal/models"
+	"time"
+)
+
+// taskETag returns the strong entity tag for the current version of a task.
+// The version changes on every update, so it identifies the representation.
+func taskETag(task *models.Task) string {
+	return `"` + strconv.Itoa(task.Version) + `"`
+}
+
+// etagListMatches reports whether an If-Match / If-None-Match header value
+// lists etag. "*" matches any current representation. Strong comparison
+// (used by If-Match) never matches weak tags; weak comparison ignores the W/ prefix.
+func etagListMatches(header, etag string, strong bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strong {
+			if candidate == etag && !strings.HasPrefix(etag, "W/") {
+				return true
+			}
+			continue
+		}
+		if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// setValidators sets the ETag and Last-Modified headers describing the current
+// representation of a task.
+func setValidators(w http.ResponseWriter, task *models.Task) {
+	w.Header().Set("ETag", taskETag(task))
+	if !task.UpdatedAt.IsZero() {
+		w.Header().Set("Last-Modified", task.UpdatedAt.UTC().Format(http.TimeFormat))
+	}
+}
+
+// modifiedSince reports whether the task changed after the HTTP date in header.
+// HTTP dates have whole-second precision, so the update time is truncated to
+// seconds first. An unparsable date or an unknown update time counts as modified.
+func modifiedSince(task *models.Task, header string) bool {
+	since, err := http.ParseTime(header)
+	if err != nil || task.UpdatedAt.IsZero() {
+		return true
+	}
+	return task.UpdatedAt.Truncate(time.Second).After(since)
+}
+
+// checkPreconditions evaluates If-Match, If-Unmodified-Since, If-None-Match and
+// If-Modified-Since against the current representation of a task, in the order
+// of RFC 9110 section 13.2.2. The date conditions are only evaluated when the
+// client did not send the corresponding entity tag condition.
+// Returns false after writing the response when the request must not proceed:
+// 304 Not Modified for GET/HEAD whose copy is still current, 412 Precondition Failed otherwise.
+func checkPreconditions(w http.ResponseWriter, r *http.Request, task *models.Task) bool {
+	etag := taskETag(task)
+
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		if !etagListMatches(ifMatch, etag, true) {
+			writeErrorResponse(w, r, ErrPreconditionFailed)
+			return false
+		}
+	} else if since := r.Header.Get("If-Unmodified-Since"); since != "" && modifiedSince(task, since) {
+		writeErrorResponse(w, r, ErrPreconditionFailed)
+		return false
+	}
+
+	readOnly := r.Method == http.MethodGet || r.Method == http.MethodHead
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		if etagListMatches(ifNoneMatch, etag, false) {
+			if readOnly {
+				setValidators(w, task)
+				w.WriteHeader(http.StatusNotModified)
+				return false
+			}
+			writeErrorResponse(w, r, ErrPreconditionFailed)
+			return false
+		}
+	} else if since := r.Header.Get("If-Modified-Since"); since != "" && readOnly && !modifiedSince(task, since) {
+		setValidators(w, task)
+		w.WriteHeader(http.StatusNotModified)
+		return false
+	}
+
+	return true
+}
+
+// hasWritePreconditions reports whether a write is conditional on the version
+// of the task the client has seen, so that a concurrent change to the task
+// means the precondition no longer holds
+func hasWritePreconditions(r *http.Request) bool {
+	return r.Header.Get("If-Match") != "" || r.Header.Get("If-Unmodified-Since") != ""
+}
+
+// writeCacheableJSON writes data as JSON with a weak ETag derived from its content,
+// answering 304 Not Modified when the client already holds that representation
+func writeCacheableJSON(w http.ResponseWriter, r *http.Request, data interface{}, statusCode int) error {
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(data); err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(body.Bytes())
+	etag := `W/"` + hex.EncodeToString(sum[:8]) + `"`
+	w.Header().Set("ETag", etag)
+
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" && etagListMatches(ifNoneMatch, etag, false) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_, err := w.Write(body.Bytes())
+	return err
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"task-api/internal/models"
+	"testing"
+	"time"
+)
+
+// TestEtagListMatches tests strong and weak comparison of entity tag lists
+func TestEtagListMatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		etag     string
+		strong   bool
+		expected bool
+	}{
+		{"exact strong match", `"3"`, `"3"`, true, true},
+		{"strong mismatch", `"2"`, `"3"`, true, false},
+		{"match in list", `"1", "3"`, `"3"`, true, true},
+		{"wildcard", `*`, `"3"`, true, true},
+		{"weak candidate never matches strongly", `W/"3"`, `"3"`, true, false},
+		{"weak candidate matches weakly", `W/"3"`, `"3"`, false, true},
+		{"weak etag matches weakly", `"abc"`, `W/"abc"`, false, true},
+		{"weak mismatch", `W/"abc"`, `W/"def"`, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := etagListMatches(tt.header, tt.etag, tt.strong); got != tt.expected {
+				t.Errorf("etagListMatches(%q, %q, %v) = %v, expected %v", tt.header, tt.etag, tt.strong, got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestCheckPreconditions tests the outcome of the entity tag and date
+// preconditions for each method
+func TestCheckPreconditions(t *testing.T) {
+	updatedAt := time.Date(2030, 6, 1, 12, 0, 0, 500000000, time.UTC)
+	task := &models.Task{ID: 1, Version: 2, Timestamps: models.Timestamps{UpdatedAt: updatedAt}}
+	lastModified := updatedAt.Format(http.TimeFormat)
+	earlier := updatedAt.Add(-time.Minute).Format(http.TimeFormat)
+
+	tests := []struct {
+		name           string
+		method         string
+		headers        map[string]string
+		expectedOK     bool
+		expectedStatus int
+	}{
+		{"no preconditions", http.MethodPut, nil, true, http.StatusOK},
+		{"If-Match current", http.MethodPut, map[string]string{"If-Match": `"2"`}, true, http.StatusOK},
+		{"If-Match stale", http.MethodPut, map[string]string{"If-Match": `"1"`}, false, http.StatusPreconditionFailed},
+		{"If-None-Match on GET", http.MethodGet, map[string]string{"If-None-Match": `"2"`}, false, http.StatusNotModified},
+		{"If-None-Match on PUT", http.MethodPut, map[string]string{"If-None-Match": `*`}, false, http.StatusPreconditionFailed},
+		{"If-None-Match other version", http.MethodGet, map[string]string{"If-None-Match": `"1"`}, true, http.StatusOK},
+		{"If-Modified-Since last modification", http.MethodGet, map[string]string{"If-Modified-Since": lastModified}, false, http.StatusNotModified},
+		{"If-Modified-Since earlier", http.MethodGet, map[string]string{"If-Modified-Since": earlier}, true, http.StatusOK},
+		{"If-Modified-Since unparsable", http.MethodGet, map[string]string{"If-Modified-Since": "yesterday"}, true, http.StatusOK},
+		{"If-Modified-Since ignored on PUT", http.MethodPut, map[string]string{"If-Modified-Since": lastModified}, true, http.StatusOK},
+		{"If-Modified-Since ignored with If-None-Match", http.MethodGet,
+			map[string]string{"If-None-Match": `"1"`, "If-Modified-Since": lastModified}, true, http.StatusOK},
+		{"If-Unmodified-Since last modification", http.MethodPut, map[string]string{"If-Unmodified-Since": lastModified}, true, http.StatusOK},
+		{"If-Unmodified-Since earlier", http.MethodPut, map[string]string{"If-Unmodified-Since": earlier}, false, http.StatusPreconditionFailed},
+		{"If-Unmodified-Since ignored with If-Match", http.MethodPut,
+			map[string]string{"If-Match": `"2"`, "If-Unmodified-Since": earlier}, true, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/tasks/1", nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			w := httptest.NewRecorder()
+
+			ok := checkPreconditions(w, req, task)
+
+			if ok != tt.expectedOK {
+				t.Errorf("Expected ok=%v, got %v", tt.expectedOK, ok)
+			}
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if w.Code == http.StatusNotModified && w.Header().Get("Last-Modified") != lastModified {
+				t.Errorf("Expected Last-Modified %q on 304, got %q", lastModified, w.Header().Get("Last-Modified"))
+			}
+		})
+	}
+}
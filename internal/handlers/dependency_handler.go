@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"task-api/internal/models"
+	"task-api/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ErrDependencyNotFound is returned when removing a blocker the task does not have
+var ErrDependencyNotFound = ErrorResponse{Type: "dependency-not-found", Message: "Task is not blocked by the given task", Code: http.StatusNotFound}
+
+// GetDependencies handles GET /tasks/{id}/dependencies - list the tasks
+// blocking a task in ID order, read as one consistent snapshot.
+func (h *TaskHandler) GetDependencies(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeErrorResponse(w, r, ErrInvalidTaskID)
+		return
+	}
+
+	var blockers []*models.Task
+	err = h.storage.View(r.Context(), func(tx storage.TaskStorage) error {
+		task, err := tx.GetByID(r.Context(), id)
+		if err != nil {
+			return err
+		}
+		blockers = make([]*models.Task, 0, len(task.BlockedBy))
+		for _, blockerID := range task.BlockedBy {
+			blocker, err := tx.GetByID(r.Context(), blockerID)
+			if err != nil {
+				return err
+			}
+			blockers = append(blockers, blocker)
+		}
+		return nil
+	})
+	if err != nil {
+		writeErrorResponse(w, r, storageErrorResponse(err))
+		return
+	}
+
+	if err := writeCacheableJSON(w, r, blockers, http.StatusOK); err != nil {
+		writeErrorResponse(w, r, ErrInternalServer)
+		return
+	}
+}
+
+// AddDependency handles POST /tasks/{id}/dependencies - make the task given
+// by task_id in the body block the task. Adding a blocker the task already has
+// is not an error. Responds with the updated task like PUT /tasks/{id}.
+func (h *TaskHandler) AddDependency(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeErrorResponse(w, r, ErrInvalidTaskID)
+		return
+	}
+
+	var input struct {
+		TaskID *int `json:"task_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeErrorResponse(w, r, ErrInvalidJSON)
+		return
+	}
+	if input.TaskID == nil {
+		writeErrorResponse(w, r, ErrInvalidJSON.withDetail(errors.New("task_id is required")))
+		return
+	}
+
+	h.changeBlockers(w, r, id, func(blockedBy []int) ([]int, error) {
+		return append(blockedBy, *input.TaskID), nil
+	})
+}
+
+// RemoveDependency handles DELETE /tasks/{id}/dependencies/{blockerID} - stop
+// the given task from blocking the task. Responds with the updated task like
+// PUT /tasks/{id}.
+func (h *TaskHandler) RemoveDependency(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeErrorResponse(w, r, ErrInvalidTaskID)
+		return
+	}
+	blockerID, err := strconv.Atoi(chi.URLParam(r, "blockerID"))
+	if err != nil {
+		writeErrorResponse(w, r, ErrInvalidTaskID)
+		return
+	}
+
+	h.changeBlockers(w, r, id, func(blockedBy []int) ([]int, error) {
+		if !slices.Contains(blockedBy, blockerID) {
+			return nil, ErrDependencyNotFound
+		}
+		return slices.DeleteFunc(blockedBy, func(id int) bool { return id == blockerID }), nil
+	})
+}
+
+// changeBlockers replaces the blockers of a task with the result of change,
+// following the fetch-modify-save pattern of UpdateTask. change reports why
+// the blockers cannot change as an ErrorResponse.
+func (h *TaskHandler) changeBlockers(w http.ResponseWriter, r *http.Request, id int, change func(blockedBy []int) ([]int, error)) {
+	task, err := h.storage.GetByID(r.Context(), id)
+	if err != nil {
+		writeErrorResponse(w, r, storageErrorResponse(err))
+		return
+	}
+
+	// Honor the preconditions against the version being changed
+	if !checkPreconditions(w, r, task) {
+		return
+	}
+
+	details := task.Details
+	if details.BlockedBy, err = change(slices.Clone(task.BlockedBy)); err != nil {
+		var response ErrorResponse
+		if !errors.As(err, &response) {
+			response = ErrInternalServer
+		}
+		writeErrorResponse(w, r, response)
+		return
+	}
+
+	// The state is kept as is; only the blockers change
+	if err := updateTaskFromInput(task, task.Name, task.Status, task.State, details); err != nil {
+		writeErrorResponse(w, r, validationErrorResponse(err))
+		return
+	}
+
+	h.saveTask(w, r, task)
+}
+
+// GetPlan handles GET /tasks/plan - order the incomplete tasks so that each
+// comes after the tasks blocking it, together with the critical path: the
+// longest chain of tasks that have to be done one after the other. The tasks
+// are read as one consistent snapshot.
+func (h *TaskHandler) GetPlan(w http.ResponseWriter, r *http.Request) {
+	incomplete := 0
+	var page *storage.TaskPage
+	err := h.storage.View(r.Context(), func(tx storage.TaskStorage) error {
+		var err error
+		page, err = tx.Query(r.Context(), storage.TaskQuery{Status: &incomplete})
+		return err
+	})
+	if err != nil {
+		writeErrorResponse(w, r, storageErrorResponse(err))
+		return
+	}
+
+	// Completed blockers no longer hold anything up, so only open tasks are planned
+	plan, err := models.PlanExecution(page.Tasks)
+	if err != nil {
+		response := ErrInternalServer
+		response.Err = err
+		writeErrorResponse(w, r, response)
+		return
+	}
+
+	if err := writeCacheableJSON(w, r, plan, http.StatusOK); err != nil {
+		writeErrorResponse(w, r, ErrInternalServer)
+		return
+	}
+}
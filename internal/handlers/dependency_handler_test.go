@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"task-api/internal/models"
+	"task-api/internal/storage"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// newDependencyRequest builds a request for /tasks/{id}/dependencies, or for
+// /tasks/{id}/dependencies/{blockerID} when blockerID is not empty, with the
+// chi URL parameters set
+func newDependencyRequest(method string, id int, blockerID, body string) *http.Request {
+	target := fmt.Sprintf("/tasks/%d/dependencies", id)
+	if blockerID != "" {
+		target += "/" + blockerID
+	}
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", fmt.Sprint(id))
+	if blockerID != "" {
+		rctx.URLParams.Add("blockerID", blockerID)
+	}
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+}
+
+// decodeFieldErrors reads the invalid fields of a validation problem
+func decodeFieldErrors(t *testing.T, w *httptest.ResponseRecorder) []models.FieldError {
+	t.Helper()
+
+	var problem struct {
+		Errors []models.FieldError `json:"errors"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return problem.Errors
+}
+
+// TestTaskHandler_Dependencies tests adding, listing and removing blockers,
+// rejecting invalid ones and refusing to complete a blocked task
+func TestTaskHandler_Dependencies(t *testing.T) {
+	handler := setupTestHandler()
+	design := createSubtask(t, handler, "Design", 0)
+	build := createSubtask(t, handler, "Build", 0)
+
+	addBlocker := func(t *testing.T, id int, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		handler.AddDependency(w, newDependencyRequest(http.MethodPost, id, "", body))
+		return w
+	}
+
+	t.Run("add", func(t *testing.T) {
+		for _, attempt := range []string{"first", "repeated"} {
+			w := addBlocker(t, build.ID, fmt.Sprintf(`{"task_id": %d}`, design.ID))
+			if w.Code != http.StatusOK {
+				t.Fatalf("%s: expected status 200, got %d: %s", attempt, w.Code, w.Body.String())
+			}
+			var task models.Task
+			if err := json.NewDecoder(w.Body).Decode(&task); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if !reflect.DeepEqual(task.BlockedBy, []int{design.ID}) {
+				t.Errorf("%s: expected blockers %v, got %v", attempt, []int{design.ID}, task.BlockedBy)
+			}
+			if w.Header().Get("ETag") == "" {
+				t.Errorf("%s: expected an ETag on the updated task", attempt)
+			}
+		}
+	})
+
+	t.Run("invalid blockers", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			id       int
+			body     string
+			expected int
+			rule     string
+		}{
+			{"missing task_id", build.ID, `{}`, http.StatusBadRequest, ""},
+			{"invalid JSON", build.ID, `{"task_id": "one"}`, http.StatusBadRequest, ""},
+			{"missing blocker", build.ID, `{"task_id": 999}`, http.StatusBadRequest, models.RuleBlocker},
+			{"itself", design.ID, fmt.Sprintf(`{"task_id": %d}`, design.ID), http.StatusBadRequest, models.RuleBlocker},
+			{"cycle", design.ID, fmt.Sprintf(`{"task_id": %d}`, build.ID), http.StatusBadRequest, models.RuleBlocker},
+			{"missing task", 999, fmt.Sprintf(`{"task_id": %d}`, design.ID), http.StatusNotFound, ""},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				w := addBlocker(t, tt.id, tt.body)
+				if w.Code != tt.expected {
+					t.Fatalf("Expected status %d, got %d: %s", tt.expected, w.Code, w.Body.String())
+				}
+				if tt.rule == "" {
+					return
+				}
+				if errs := decodeFieldErrors(t, w); len(errs) != 1 || errs[0].Field != "blocked_by" || errs[0].Rule != tt.rule {
+					t.Errorf("Expected a %s error on blocked_by, got %+v", tt.rule, errs)
+				}
+			})
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.GetDependencies(w, newDependencyRequest(http.MethodGet, build.ID, "", ""))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", w.Code)
+		}
+		var blockers []models.Task
+		if err := json.NewDecoder(w.Body).Decode(&blockers); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if len(blockers) != 1 || blockers[0].ID != design.ID || blockers[0].Name != "Design" {
+			t.Errorf("Expected Design as the only blocker, got %+v", blockers)
+		}
+	})
+
+	t.Run("complete while blocked", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.PatchTask(w, newPatchRequest(fmt.Sprint(build.ID), "application/merge-patch+json", `{"status": 1}`))
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("Expected status 400, got %d: %s", w.Code, w.Body.String())
+		}
+		if errs := decodeFieldErrors(t, w); len(errs) != 1 || errs[0].Field != "status" || errs[0].Rule != models.RuleBlocked {
+			t.Errorf("Expected a %s error on status, got %+v", models.RuleBlocked, errs)
+		}
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.RemoveDependency(w, newDependencyRequest(http.MethodDelete, build.ID, "999", ""))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404 for a task that is not a blocker, got %d", w.Code)
+		}
+
+		w = httptest.NewRecorder()
+		handler.RemoveDependency(w, newDependencyRequest(http.MethodDelete, build.ID, fmt.Sprint(design.ID), ""))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var task models.Task
+		if err := json.NewDecoder(w.Body).Decode(&task); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if task.BlockedBy != nil {
+			t.Errorf("Expected no blockers left, got %v", task.BlockedBy)
+		}
+	})
+}
+
+// TestTaskHandler_GetPlan tests the execution order and critical path of the open tasks
+func TestTaskHandler_GetPlan(t *testing.T) {
+	handler := setupTestHandler()
+	create := func(body string) *models.Task {
+		t.Helper()
+		w := httptest.NewRecorder()
+		handler.CreateTask(w, httptest.NewRequest(http.MethodPost, "/tasks", bytes.NewBufferString(body)))
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Failed to create task: %d %s", w.Code, w.Body.String())
+		}
+		var task models.Task
+		if err := json.NewDecoder(w.Body).Decode(&task); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return &task
+	}
+	done := create(`{"name": "Done", "status": 1}`)
+	ship := create(`{"name": "Ship"}`)
+	build := create(fmt.Sprintf(`{"name": "Build", "blocked_by": [%d]}`, done.ID))
+	docs := create(`{"name": "Docs"}`)
+	w := httptest.NewRecorder()
+	handler.PatchTask(w, newPatchRequest(fmt.Sprint(ship.ID), "application/merge-patch+json",
+		fmt.Sprintf(`{"blocked_by": [%d, %d]}`, build.ID, docs.ID)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Failed to block task: %d %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	handler.GetPlan(w, httptest.NewRequest(http.MethodGet, "/tasks/plan", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if w.Header().Get("ETag") == "" {
+		t.Error("Expected an ETag on the plan")
+	}
+	var plan struct {
+		Order        []models.Task `json:"order"`
+		CriticalPath []int         `json:"critical_path"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&plan); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	// The completed task is left out; Build and Docs both hold up Ship
+	order := make([]int, len(plan.Order))
+	for i, task := range plan.Order {
+		order[i] = task.ID
+	}
+	if expected := []int{build.ID, docs.ID, ship.ID}; !reflect.DeepEqual(order, expected) {
+		t.Errorf("Expected order %v, got %v", expected, order)
+	}
+	if expected := []int{build.ID, ship.ID}; !reflect.DeepEqual(plan.CriticalPath, expected) {
+		t.Errorf("Expected critical path %v, got %v", expected, plan.CriticalPath)
+	}
+}
+
+// noTxStorage fails every transaction, so only reads through views succeed
+type noTxStorage struct {
+	storage.TaskStorage
+}
+
+func (s noTxStorage) WithTx(ctx context.Context, fn func(tx storage.TaskStorage) error) error {
+	return errors.New("reads must not take the write lock")
+}
+
+// TestTaskHandler_ReadsUseViews tests that the endpoints reading several
+// tasks as one snapshot do so through a view rather than a transaction
+func TestTaskHandler_ReadsUseViews(t *testing.T) {
+	s := storage.NewInMemoryStorage()
+	parent, _ := models.NewTask("Parent", 0)
+	parent, _ = s.Create(context.Background(), parent)
+	child, _ := models.NewTaskWithDetails("Child", 0, models.Details{ParentID: parent.ID, BlockedBy: []int{parent.ID}})
+	child, _ = s.Create(context.Background(), child)
+	handler := NewTaskHandler(noTxStorage{s})
+
+	tests := []struct {
+		name   string
+		handle http.HandlerFunc
+		target string
+		id     int
+	}{
+		{name: "children", handle: handler.GetChildren, target: "/tasks/%d/children", id: parent.ID},
+		{name: "tree", handle: handler.GetTaskTree, target: "/tasks/%d/tree", id: parent.ID},
+		{name: "dependencies", handle: handler.GetDependencies, target: "/tasks/%d/dependencies", id: child.ID},
+		{name: "plan", handle: handler.GetPlan, target: "/tasks/plan", id: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, id := tt.target, ""
+			if tt.id != 0 {
+				target, id = fmt.Sprintf(tt.target, tt.id), fmt.Sprint(tt.id)
+			}
+			w := httptest.NewRecorder()
+			tt.handle(w, newWebhookRequest(http.MethodGet, target, id, ""))
+
+			if w.Code != http.StatusOK {
+				t.Errorf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+		})
+	}
+}
@@ -2,15 +2,28 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"task-api/internal/models"
+	"task-api/internal/patch"
+	"task-api/internal/storage"
 )
 
-// ErrorResponse represents a structured API error response
+// problemContentType is the media type of error responses (RFC 7807)
+const problemContentType = "application/problem+json"
+
+// problemTypeBase prefixes the problem type of every ErrorResponse with a Type
+const problemTypeBase = "/problems/"
+
+// ErrorResponse represents a structured API error. It is written to clients as
+// an RFC 7807 problem details document, see writeErrorResponse.
 type ErrorResponse struct {
-	Status  string `json:"error"`
-	Message string `json:"message,omitempty"`
-	Code    int    `json:"code"`
-	Err     error  `json:"-"` // Internal error (not exposed to client)
+	Type    string              // Problem type below problemTypeBase, empty for a plain HTTP error
+	Message string              // Short summary of the problem type, written as its title
+	Detail  string              // Explanation specific to this occurrence, optional
+	Code    int                 // HTTP status code
+	Fields  []models.FieldError // Invalid fields, for validation problems
+	Err     error               // Internal error (not exposed to client)
 }
 
 func (e ErrorResponse) Error() string {
@@ -20,24 +33,147 @@ func (e ErrorResponse) Error() string {
 	return e.Message
 }
 
+// problem is the RFC 7807 problem details document written for an ErrorResponse
+type problem struct {
+	Type     string              `json:"type"`
+	Title    string              `json:"title"`
+	Status   int                 `json:"status"`
+	Detail   string              `json:"detail,omitempty"`
+	Instance string              `json:"instance,omitempty"`
+	Errors   []models.FieldError `json:"errors,omitempty"`
+}
+
 var (
-	ErrInvalidJSON      = ErrorResponse{Message: "Invalid JSON in request body", Code: http.StatusBadRequest}
-	ErrTaskNotFound     = ErrorResponse{Message: "Task not found", Code: http.StatusNotFound}
-	ErrInvalidTaskID    = ErrorResponse{Message: "Invalid task ID", Code: http.StatusBadRequest}
-	ErrMethodNotAllowed = ErrorResponse{Message: "Method not allowed", Code: http.StatusMethodNotAllowed}
-	ErrInternalServer   = ErrorResponse{Message: "Internal server error", Code: http.StatusInternalServerError}
+	ErrInvalidJSON        = ErrorResponse{Type: "invalid-json", Message: "Invalid JSON in request body", Code: http.StatusBadRequest}
+	ErrTaskNotFound       = ErrorResponse{Type: "task-not-found", Message: "Task not found", Code: http.StatusNotFound}
+	ErrInvalidTaskID      = ErrorResponse{Type: "invalid-task-id", Message: "Invalid task ID", Code: http.StatusBadRequest}
+	ErrMethodNotAllowed   = ErrorResponse{Message: "Method not allowed", Code: http.StatusMethodNotAllowed}
+	ErrRouteNotFound      = ErrorResponse{Message: "Not found", Code: http.StatusNotFound}
+	ErrInternalServer     = ErrorResponse{Message: "Internal server error", Code: http.StatusInternalServerError}
+	ErrTaskConflict       = ErrorResponse{Type: "task-conflict", Message: "Task was modified concurrently", Code: http.StatusConflict}
+	ErrInvalidTask        = ErrorResponse{Type: "invalid-task", Message: "Invalid task", Code: http.StatusBadRequest}
+	ErrValidation         = ErrorResponse{Type: "validation-failed", Message: "Task failed validation", Code: http.StatusBadRequest}
+	ErrUnavailable        = ErrorResponse{Type: "storage-unavailable", Message: "Storage temporarily unavailable", Code: http.StatusServiceUnavailable}
+	ErrPreconditionFailed = ErrorResponse{Type: "precondition-failed", Message: "Task does not match the given precondition", Code: http.StatusPreconditionFailed}
+	ErrUnsupportedPatch   = ErrorResponse{Type: "unsupported-patch", Message: "Unsupported patch format", Code: http.StatusUnsupportedMediaType}
+	ErrInvalidPatch       = ErrorResponse{Type: "invalid-patch", Message: "Invalid patch document", Code: http.StatusBadRequest}
+	ErrPatchTestFailed    = ErrorResponse{Type: "patch-test-failed", Message: "Patch test operation failed", Code: http.StatusConflict}
+	ErrUnprocessablePatch = ErrorResponse{Type: "unprocessable-patch", Message: "Patch cannot be applied to task", Code: http.StatusUnprocessableEntity}
+	ErrTaskIDChanged      = ErrorResponse{Type: "task-id-changed", Message: "Task ID cannot be changed", Code: http.StatusBadRequest}
+	ErrInvalidQuery       = ErrorResponse{Type: "invalid-query", Message: "Invalid query parameter", Code: http.StatusBadRequest}
+	ErrMissingSearchQuery = ErrorResponse{Type: "invalid-query", Message: "Search query parameter q is required", Code: http.StatusBadRequest}
+	ErrBulkSize           = ErrorResponse{Type: "invalid-bulk-size", Message: "Bulk requests must contain between 1 and 1000 items", Code: http.StatusBadRequest}
+	ErrInvalidMessage     = ErrorResponse{Type: "invalid-message", Message: "Invalid WebSocket message", Code: http.StatusBadRequest}
 )
 
-// writeErrorResponse writes a structured error response to the client
-func writeErrorResponse(w http.ResponseWriter, err ErrorResponse) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(err.Code)
+// withDetail returns a copy of e explaining this occurrence with err
+func (e ErrorResponse) withDetail(err error) ErrorResponse {
+	e.Detail = err.Error()
+	e.Err = err
+	return e
+}
 
-	response := ErrorResponse{
-		Status:  http.StatusText(err.Code),
-		Message: err.Message,
-		Code:    err.Code,
+// validationErrorResponse maps a failed model validation onto ErrValidation,
+// listing every invalid field
+func validationErrorResponse(err error) ErrorResponse {
+	response := ErrValidation.withDetail(err)
+	var validationErr *models.ValidationError
+	if errors.As(err, &validationErr) {
+		response.Fields = validationErr.Errors
 	}
+	return response
+}
+
+// storageErrorResponse maps a storage error onto the matching API error,
+// keeping the original error for internal use
+func storageErrorResponse(err error) ErrorResponse {
+	var response ErrorResponse
+	var validationErr *models.ValidationError
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		response = ErrTaskNotFound
+	case errors.Is(err, storage.ErrConflict):
+		response = ErrTaskConflict
+	case errors.Is(err, storage.ErrParentNotFound):
+		return fieldErrorResponse(err, "parent_id", models.RuleParent, "parent task does not exist")
+	case errors.Is(err, storage.ErrParentCycle):
+		return fieldErrorResponse(err, "parent_id", models.RuleParent, "parent task cannot be the task itself or one of its subtasks")
+	case errors.Is(err, storage.ErrBlockerNotFound):
+		return fieldErrorResponse(err, "blocked_by", models.RuleBlocker, "blocking task does not exist")
+	case errors.Is(err, storage.ErrDependencyCycle):
+		return fieldErrorResponse(err, "blocked_by", models.RuleBlocker, "task cannot be blocked by itself or by a task waiting for it")
+	case errors.As(err, &validationErr):
+		// Rules that need other tasks, like blockers having to be completed first
+		response = validationErrorResponse(validationErr)
+	case errors.Is(err, storage.ErrInvalid):
+		response = ErrInvalidTask
+	case errors.Is(err, storage.ErrUnavailable):
+		response = ErrUnavailable
+	default:
+		response = ErrInternalServer
+	}
+	response.Err = err
+	return response
+}
+
+// fieldErrorResponse reports a relation to another task rejected by storage,
+// such as a parent, as a validation problem on field, like the checks models
+// can make on their own
+func fieldErrorResponse(err error, field, rule, message string) ErrorResponse {
+	response := validationErrorResponse(&models.ValidationError{Errors: []models.FieldError{
+		{Field: field, Rule: rule, Message: message},
+	}})
+	response.Err = err
+	return response
+}
+
+// patchErrorResponse maps an error from applying a patch onto the matching API error
+func patchErrorResponse(err error) ErrorResponse {
+	var response ErrorResponse
+	switch {
+	case errors.Is(err, patch.ErrInvalidPatch):
+		response = ErrInvalidPatch
+	case errors.Is(err, patch.ErrTestFailed):
+		response = ErrPatchTestFailed
+	case errors.Is(err, patch.ErrPathNotFound):
+		response = ErrUnprocessablePatch
+	default:
+		response = ErrInternalServer
+	}
+	response.Err = err
+	return response
+}
+
+// newProblem builds the problem details document reporting err
+func newProblem(err ErrorResponse, instance string) problem {
+	response := problem{
+		Type:     "about:blank",
+		Title:    err.Message,
+		Status:   err.Code,
+		Detail:   err.Detail,
+		Instance: instance,
+		Errors:   err.Fields,
+	}
+	if err.Type != "" {
+		response.Type = problemTypeBase + err.Type
+	}
+	if response.Title == "" {
+		response.Title = http.StatusText(err.Code)
+	}
+	return response
+}
+
+// writeErrorResponse writes err as an RFC 7807 problem details document.
+// The request path is reported as the instance of the problem.
+func writeErrorResponse(w http.ResponseWriter, r *http.Request, err ErrorResponse) {
+	var instance string
+	if r != nil {
+		instance = r.URL.Path
+	}
+	response := newProblem(err, instance)
+
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(err.Code)
 
 	// If JSON encoding fails, fall back to http.Error
 	if encodeErr := json.NewEncoder(w).Encode(response); encodeErr != nil {
@@ -45,6 +181,16 @@ func writeErrorResponse(w http.ResponseWriter, err ErrorResponse) {
 	}
 }
 
+// NotFound answers requests for unknown routes with a problem document
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	writeErrorResponse(w, r, ErrRouteNotFound)
+}
+
+// MethodNotAllowed answers requests with an unsupported method with a problem document
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	writeErrorResponse(w, r, ErrMethodNotAllowed)
+}
+
 // writeJSONResponse writes a successful JSON response
 func writeJSONResponse(w http.ResponseWriter, data interface{}, statusCode int) error {
 	w.Header().Set("Content-Type", "application/json")
@@ -2,9 +2,12 @@ package handlers
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"task-api/internal/models"
+	"task-api/internal/storage"
 	"testing"
 )
 
@@ -58,38 +61,51 @@ func TestWriteErrorResponse(t *testing.T) {
 		expectedBody   string
 	}{
 		{
-			name: "Standard error response",
-			err: ErrorResponse{
-				Message: "Task not found",
-				Code:    http.StatusNotFound,
-			},
+			name:           "Problem type",
+			err:            ErrTaskNotFound,
 			expectedStatus: http.StatusNotFound,
-			expectedBody:   `{"error":"Not Found","message":"Task not found","code":404}`,
+			expectedBody:   `{"type":"/problems/task-not-found","title":"Task not found","status":404,"instance":"/tasks/7"}`,
 		},
 		{
-			name: "Bad request error",
+			name: "Plain HTTP error",
 			err: ErrorResponse{
 				Message: "Invalid input",
 				Code:    http.StatusBadRequest,
 			},
 			expectedStatus: http.StatusBadRequest,
-			expectedBody:   `{"error":"Bad Request","message":"Invalid input","code":400}`,
+			expectedBody:   `{"type":"about:blank","title":"Invalid input","status":400,"instance":"/tasks/7"}`,
 		},
 		{
-			name: "Internal server error",
-			err: ErrorResponse{
-				Message: "Something went wrong",
-				Code:    http.StatusInternalServerError,
-			},
+			name:           "Detail",
+			err:            ErrInvalidQuery.withDetail(errors.New("invalid sort field")),
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"type":"/problems/invalid-query","title":"Invalid query parameter","status":400,"detail":"invalid sort field","instance":"/tasks/7"}`,
+		},
+		{
+			name:           "Internal server error",
+			err:            ErrorResponse{Code: http.StatusInternalServerError, Err: errors.New("secret")},
 			expectedStatus: http.StatusInternalServerError,
-			expectedBody:   `{"error":"Internal Server Error","message":"Something went wrong","code":500}`,
+			expectedBody:   `{"type":"about:blank","title":"Internal Server Error","status":500,"instance":"/tasks/7"}`,
+		},
+		{
+			name: "Validation errors",
+			err: validationErrorResponse(&models.ValidationError{Errors: []models.FieldError{
+				{Field: "name", Rule: models.RuleRequired, Message: "task name cannot be empty"},
+				{Field: "status", Rule: models.RuleOneOf, Message: "status must be 0 (incomplete) or 1 (completed)"},
+			}}),
+			expectedStatus: http.StatusBadRequest,
+			expectedBody: `{"type":"/problems/validation-failed","title":"Task failed validation","status":400,` +
+				`"detail":"task name cannot be empty; status must be 0 (incomplete) or 1 (completed)","instance":"/tasks/7",` +
+				`"errors":[{"field":"name","rule":"required","message":"task name cannot be empty"},` +
+				`{"field":"status","rule":"oneof","message":"status must be 0 (incomplete) or 1 (completed)"}]}`,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, "/tasks/7?ignored=1", nil)
 			w := httptest.NewRecorder()
-			writeErrorResponse(w, tt.err)
+			writeErrorResponse(w, req, tt.err)
 
 			// Check status code
 			if w.Code != tt.expectedStatus {
@@ -97,7 +113,7 @@ func TestWriteErrorResponse(t *testing.T) {
 			}
 
 			// Check content type
-			expectedContentType := "application/json"
+			expectedContentType := "application/problem+json"
 			if contentType := w.Header().Get("Content-Type"); contentType != expectedContentType {
 				t.Errorf("Expected Content-Type %s, got %s", expectedContentType, contentType)
 			}
@@ -180,3 +196,33 @@ func TestWriteJSONResponse(t *testing.T) {
 		})
 	}
 }
+
+func TestStorageErrorResponse(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          error
+		expectedCode int
+	}{
+		{"not found", &storage.Error{Op: "get", ID: 1, Kind: storage.ErrNotFound}, http.StatusNotFound},
+		{"conflict", &storage.Error{Op: "update", ID: 1, Kind: storage.ErrConflict}, http.StatusConflict},
+		{"invalid", &storage.Error{Op: "create", Kind: storage.ErrInvalid}, http.StatusBadRequest},
+		{"invalid parent", &storage.Error{Op: "create", Kind: storage.ErrInvalid, Err: storage.ErrParentCycle}, http.StatusBadRequest},
+		{"invalid blocker", &storage.Error{Op: "update", ID: 1, Kind: storage.ErrInvalid, Err: storage.ErrBlockerNotFound}, http.StatusBadRequest},
+		{"blocked", &storage.Error{Op: "update", ID: 1, Kind: storage.ErrInvalid, Err: &models.ValidationError{}}, http.StatusBadRequest},
+		{"unavailable", &storage.Error{Op: "list", Kind: storage.ErrUnavailable}, http.StatusServiceUnavailable},
+		{"wrapped not found", fmt.Errorf("lookup: %w", storage.ErrNotFound), http.StatusNotFound},
+		{"unknown", errors.New("boom"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := storageErrorResponse(tt.err)
+			if response.Code != tt.expectedCode {
+				t.Errorf("Expected code %d, got %d", tt.expectedCode, response.Code)
+			}
+			if !errors.Is(response.Err, tt.err) {
+				t.Errorf("Expected original error to be kept, got %v", response.Err)
+			}
+		})
+	}
+}
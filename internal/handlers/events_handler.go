@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"task-api/internal/events"
+	"time"
+)
+
+// eventRetry is how long clients should wait before reconnecting to a stream that ended
+const eventRetry = 3 * time.Second
+
+// eventHeartbeat is how often an idle stream sends a comment, so proxies keep the connection open
+const eventHeartbeat = 15 * time.Second
+
+// eventReset is the event telling a resuming client that it missed changes no
+// longer kept for replay, so it has to reload the tasks
+const eventReset = "reset"
+
+// EventsHandler streams the changes made to tasks.
+type EventsHandler struct {
+	bus       *events.Bus
+	heartbeat time.Duration
+}
+
+// NewEventsHandler creates a handler streaming the events published to bus.
+func NewEventsHandler(bus *events.Bus) *EventsHandler {
+	return &EventsHandler{bus: bus, heartbeat: eventHeartbeat}
+}
+
+// StreamEvents handles GET /tasks/events - stream task changes as Server-Sent
+// Events (task.created, task.updated and task.deleted) until the client
+// disconnects. A client reconnecting with Last-Event-ID, or ?last_event_id=,
+// first receives the events it missed; if they are no longer kept it receives
+// a reset event instead and should reload the tasks.
+func (h *EventsHandler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	raw := r.Header.Get("Last-Event-ID")
+	if raw == "" {
+		raw = r.URL.Query().Get("last_event_id")
+	}
+	var after uint64
+	if raw != "" {
+		var err error
+		if after, err = strconv.ParseUint(raw, 10, 64); err != nil {
+			writeErrorResponse(w, r, ErrInvalidQuery.withDetail(fmt.Errorf("invalid last event ID %q", raw)))
+			return
+		}
+	}
+
+	// Streams outlive the server's write timeout
+	controller := http.NewResponseController(w)
+	if err := controller.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		writeErrorResponse(w, r, ErrInternalServer)
+		return
+	}
+
+	subscription, missed, complete := h.bus.Subscribe(after, raw != "")
+	defer subscription.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // Keep nginx from buffering the stream
+	w.WriteHeader(http.StatusOK)
+	if _, err := fmt.Fprintf(w, "retry: %d\n\n", eventRetry.Milliseconds()); err != nil {
+		return
+	}
+
+	if !complete {
+		missed = nil
+		if err := writeEvent(w, subscription.Start, eventReset, struct{}{}); err != nil {
+			return
+		}
+	}
+	for _, event := range missed {
+		if err := writeEvent(w, event.ID, string(event.Type), event); err != nil {
+			return
+		}
+	}
+	if err := controller.Flush(); err != nil {
+		log.Printf("Error flushing event stream: %v", err)
+		return
+	}
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-subscription.Events():
+			if !ok {
+				// Fell behind or the server is shutting down; the client reconnects
+				return
+			}
+			if err := writeEvent(w, event.ID, string(event.Type), event); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := io.WriteString(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+		if err := controller.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// writeEvent writes one Server-Sent Event with data encoded as JSON on a single line
+func writeEvent(w io.Writer, id uint64, name string, data interface{}) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", id, name, encoded)
+	return err
+}
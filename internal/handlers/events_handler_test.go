@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"task-api/internal/events"
+	"task-api/internal/models"
+	"task-api/internal/storage"
+	"testing"
+	"time"
+)
+
+// sseEvent is one event read from a stream
+type sseEvent struct {
+	id, name, data string
+}
+
+// openStream connects to the event stream of server, resuming after lastEventID unless it is empty
+func openStream(t *testing.T, server *httptest.Server, lastEventID string) *bufio.Reader {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Errorf("Expected Content-Type text/event-stream, got %q", contentType)
+	}
+
+	stream := bufio.NewReader(resp.Body)
+	if retry := readEvent(t, stream); retry != (sseEvent{}) {
+		t.Errorf("Expected the stream to start with the retry delay, got %+v", retry)
+	}
+	return stream
+}
+
+// readEvent reads the next event from stream, skipping comments
+func readEvent(t *testing.T, stream *bufio.Reader) sseEvent {
+	t.Helper()
+	var event sseEvent
+	for {
+		line, err := stream.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Failed to read event: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		field, value, _ := strings.Cut(line, ": ")
+		switch field {
+		case "":
+			return event
+		case "id":
+			event.id = value
+		case "event":
+			event.name = value
+		case "data":
+			event.data = value
+		}
+	}
+}
+
+// TestEventsHandler_StreamEvents tests streaming, resuming and resetting the change feed
+func TestEventsHandler_StreamEvents(t *testing.T) {
+	bus := events.NewBus(2)
+	s := storage.NewInMemoryStorage(storage.WithObserver(bus.Publish))
+	server := httptest.NewServer(http.HandlerFunc(NewEventsHandler(bus).StreamEvents))
+	defer server.Close()
+	defer bus.Close() // Ends the streams, so the server can close
+	ctx := context.Background()
+
+	live := openStream(t, server, "")
+
+	task, err := s.Create(ctx, &models.Task{Name: "Stream me"})
+	if err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+	task.Status = 1
+	if _, err := s.Update(ctx, task); err != nil {
+		t.Fatalf("Failed to update task: %v", err)
+	}
+	if err := s.Delete(ctx, task.ID); err != nil {
+		t.Fatalf("Failed to delete task: %v", err)
+	}
+
+	for i, expected := range []struct {
+		name   string
+		status int
+	}{{"task.created", 0}, {"task.updated", 1}, {"task.deleted", 0}} {
+		event := readEvent(t, live)
+		if event.name != expected.name {
+			t.Errorf("Expected event %s, got %+v", expected.name, event)
+		}
+		var decoded events.Event
+		if err := json.Unmarshal([]byte(event.data), &decoded); err != nil {
+			t.Fatalf("Failed to decode event data %q: %v", event.data, err)
+		}
+		if event.id != strconv.Itoa(i+1) || decoded.ID != uint64(i+1) || decoded.TaskID != task.ID {
+			t.Errorf("Expected event %d for task %d, got %+v", i+1, task.ID, event)
+		}
+		if (decoded.Task == nil) != (expected.name == "task.deleted") || (decoded.Task != nil && decoded.Task.Status != expected.status) {
+			t.Errorf("Unexpected task in event %+v", event)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		lastEventID string
+		expected    []sseEvent
+	}{
+		{name: "replay", lastEventID: "1", expected: []sseEvent{{id: "2", name: "task.updated"}, {id: "3", name: "task.deleted"}}},
+		{name: "up to date", lastEventID: "3"},
+		{name: "no longer kept", lastEventID: "0", expected: []sseEvent{{id: "3", name: "reset", data: "{}"}}},
+		{name: "unknown event", lastEventID: "42", expected: []sseEvent{{id: "3", name: "reset", data: "{}"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := openStream(t, server, tt.lastEventID)
+			for _, expected := range tt.expected {
+				event := readEvent(t, stream)
+				if event.id != expected.id || event.name != expected.name || (expected.data != "" && event.data != expected.data) {
+					t.Errorf("Expected event %+v, got %+v", expected, event)
+				}
+			}
+		})
+	}
+}
+
+// TestEventsHandler_StreamEvents_InvalidLastEventID tests rejecting a malformed resume position
+func TestEventsHandler_StreamEvents_InvalidLastEventID(t *testing.T) {
+	handler := NewEventsHandler(events.NewBus(10))
+
+	tests := []struct {
+		name   string
+		target string
+		header string
+	}{
+		{name: "header", target: "/tasks/events", header: "abc"},
+		{name: "negative", target: "/tasks/events", header: "-1"},
+		{name: "query", target: "/tasks/events?last_event_id=1.5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.header != "" {
+				req.Header.Set("Last-Event-ID", tt.header)
+			}
+			w := httptest.NewRecorder()
+
+			handler.StreamEvents(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+			}
+		})
+	}
+}
+
+// TestEventsHandler_StreamEvents_Heartbeat tests that idle streams send comments and end with the bus
+func TestEventsHandler_StreamEvents_Heartbeat(t *testing.T) {
+	bus := events.NewBus(10)
+	handler := NewEventsHandler(bus)
+	handler.heartbeat = 10 * time.Millisecond
+	server := httptest.NewServer(http.HandlerFunc(handler.StreamEvents))
+	defer server.Close()
+
+	stream := openStream(t, server, "")
+	line, err := stream.ReadString('\n')
+	if err != nil || line != ": heartbeat\n" {
+		t.Fatalf("Expected a heartbeat, got %q (%v)", line, err)
+	}
+
+	bus.Close()
+	for {
+		if _, err := stream.ReadString('\n'); err != nil {
+			break // The stream ended
+		}
+	}
+}
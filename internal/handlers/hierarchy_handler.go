@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"task-api/internal/models"
+	"task-api/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Ways of handling the subtasks of a deleted task, chosen with ?children=
+const (
+	deleteReparent = "reparent" // Subtasks move up to the deleted task's parent (default)
+	deleteCascade  = "cascade"  // Subtasks are deleted with it, all the way down
+)
+
+// taskNode is a task together with its subtasks, as returned by GET /tasks/{id}/tree
+type taskNode struct {
+	*models.Task
+	Children []*taskNode `json:"children"`
+}
+
+// GetChildren handles GET /tasks/{id}/children - list the direct subtasks of a task.
+// It accepts the filtering, sorting and paging parameters of GET /tasks.
+func (h *TaskHandler) GetChildren(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeErrorResponse(w, r, ErrInvalidTaskID)
+		return
+	}
+
+	query, err := parseTaskQuery(r.URL.Query())
+	if err != nil {
+		writeErrorResponse(w, r, ErrInvalidQuery.withDetail(err))
+		return
+	}
+	query.Parent = &id
+
+	// The parent is looked up in the same view, so a missing parent is told
+	// apart from one without subtasks
+	var page *storage.TaskPage
+	err = h.storage.View(r.Context(), func(tx storage.TaskStorage) error {
+		if _, err := tx.GetByID(r.Context(), id); err != nil {
+			return err
+		}
+		page, err = tx.Query(r.Context(), query)
+		return err
+	})
+	if err != nil {
+		writeErrorResponse(w, r, storageErrorResponse(err))
+		return
+	}
+
+	setPaginationHeaders(w, r, query, page)
+	if err := writeCacheableJSON(w, r, page.Tasks, http.StatusOK); err != nil {
+		writeErrorResponse(w, r, ErrInternalServer)
+		return
+	}
+}
+
+// GetTaskTree handles GET /tasks/{id}/tree - retrieve a task with all of its
+// subtasks, nested below it in ID order, read as one consistent snapshot.
+func (h *TaskHandler) GetTaskTree(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeErrorResponse(w, r, ErrInvalidTaskID)
+		return
+	}
+
+	var root *taskNode
+	err = h.storage.View(r.Context(), func(tx storage.TaskStorage) error {
+		task, err := tx.GetByID(r.Context(), id)
+		if err != nil {
+			return err
+		}
+		root = &taskNode{Task: task}
+
+		// Breadth first, so every task is visited once its parent is in the tree
+		for queue := []*taskNode{root}; len(queue) > 0; queue = queue[1:] {
+			node := queue[0]
+			children, err := subtasks(r.Context(), tx, node.ID)
+			if err != nil {
+				return err
+			}
+			node.Children = make([]*taskNode, len(children))
+			for i, child := range children {
+				node.Children[i] = &taskNode{Task: child}
+			}
+			queue = append(queue, node.Children...)
+		}
+		return nil
+	})
+	if err != nil {
+		writeErrorResponse(w, r, storageErrorResponse(err))
+		return
+	}
+
+	if err := writeCacheableJSON(w, r, root, http.StatusOK); err != nil {
+		writeErrorResponse(w, r, ErrInternalServer)
+		return
+	}
+}
+
+// subtasks returns the direct subtasks of a task ordered by ID
+func subtasks(ctx context.Context, s storage.TaskStorage, id int) ([]*models.Task, error) {
+	page, err := s.Query(ctx, storage.TaskQuery{Parent: &id})
+	if err != nil {
+		return nil, err
+	}
+	return page.Tasks, nil
+}
+
+// parseDeleteMode reads how DeleteTask treats subtasks from ?children=
+func parseDeleteMode(r *http.Request) (string, error) {
+	switch mode := r.URL.Query().Get("children"); mode {
+	case "", deleteReparent:
+		return deleteReparent, nil
+	case deleteCascade:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid children %q (expected %s or %s)", mode, deleteReparent, deleteCascade)
+	}
+}
+
+// deleteSubtree deletes a task and all of its subtasks in one transaction,
+// deepest first, so none of them is moved up before it is deleted. Unless
+// version is 0, the task must still have it, otherwise ErrConflict is returned.
+func (h *TaskHandler) deleteSubtree(ctx context.Context, id, version int) error {
+	return h.storage.WithTx(ctx, func(tx storage.TaskStorage) error {
+		// Checked first: deleting the subtasks may complete the task, changing its version
+		task, err := tx.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if version != 0 && task.Version != version {
+			return fmt.Errorf("%w: task %d is at version %d, not %d", storage.ErrConflict, id, task.Version, version)
+		}
+
+		ids := []int{id}
+		for i := 0; i < len(ids); i++ {
+			children, err := subtasks(ctx, tx, ids[i])
+			if err != nil {
+				return err
+			}
+			for _, child := range children {
+				ids = append(ids, child.ID)
+			}
+		}
+
+		for i := len(ids) - 1; i >= 0; i-- {
+			if err := tx.Delete(ctx, ids[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"task-api/internal/models"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// newTaskRequest builds a request for a route below /tasks/{id} with the chi URL parameter set
+func newTaskRequest(method, target string, id int) *http.Request {
+	req := httptest.NewRequest(method, target, nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", fmt.Sprint(id))
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+}
+
+// createSubtask creates a task below parentID (0 for a top-level task) through the handler
+func createSubtask(t *testing.T, handler *TaskHandler, name string, parentID int) *models.Task {
+	t.Helper()
+
+	body := fmt.Sprintf(`{"name": %q, "parent_id": %d}`, name, parentID)
+	w := httptest.NewRecorder()
+	handler.CreateTask(w, httptest.NewRequest(http.MethodPost, "/tasks", bytes.NewBufferString(body)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Failed to create task %q: %d %s", name, w.Code, w.Body.String())
+	}
+	var task models.Task
+	if err := json.NewDecoder(w.Body).Decode(&task); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return &task
+}
+
+// TestTaskHandler_Subtasks tests creating subtasks, listing them, rejecting
+// invalid parents and rolling completion up to the parent
+func TestTaskHandler_Subtasks(t *testing.T) {
+	handler := setupTestHandler()
+	epic := createSubtask(t, handler, "Epic", 0)
+	design := createSubtask(t, handler, "Design", epic.ID)
+	build := createSubtask(t, handler, "Build", epic.ID)
+	if design.ParentID != epic.ID {
+		t.Errorf("Expected parent_id %d, got %d", epic.ID, design.ParentID)
+	}
+
+	t.Run("children", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.GetChildren(w, newTaskRequest(http.MethodGet, fmt.Sprintf("/tasks/%d/children?sort=name", epic.ID), epic.ID))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", w.Code)
+		}
+		var children []models.Task
+		if err := json.NewDecoder(w.Body).Decode(&children); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if len(children) != 2 || children[0].ID != build.ID || children[1].ID != design.ID {
+			t.Errorf("Expected Build and Design, got %+v", children)
+		}
+		if w.Header().Get("X-Total-Count") != "2" {
+			t.Errorf("Expected X-Total-Count 2, got %q", w.Header().Get("X-Total-Count"))
+		}
+	})
+
+	t.Run("children of a missing task", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.GetChildren(w, newTaskRequest(http.MethodGet, "/tasks/999/children", 999))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404, got %d", w.Code)
+		}
+	})
+
+	t.Run("invalid parents", func(t *testing.T) {
+		for _, tt := range []struct {
+			name   string
+			handle http.HandlerFunc
+			req    *http.Request
+		}{
+			{"missing parent", handler.CreateTask,
+				httptest.NewRequest(http.MethodPost, "/tasks", bytes.NewBufferString(`{"name": "Orphan", "parent_id": 999}`))},
+			{"cycle", handler.PatchTask,
+				newPatchRequest(fmt.Sprint(epic.ID), "application/merge-patch+json", fmt.Sprintf(`{"parent_id": %d}`, design.ID))},
+		} {
+			w := httptest.NewRecorder()
+			tt.handle(w, tt.req)
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("%s: expected status 400, got %d", tt.name, w.Code)
+			}
+			var problem struct {
+				Errors []models.FieldError `json:"errors"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if len(problem.Errors) != 1 || problem.Errors[0].Field != "parent_id" || problem.Errors[0].Rule != models.RuleParent {
+				t.Errorf("%s: expected a %s error on parent_id, got %+v", tt.name, models.RuleParent, problem.Errors)
+			}
+		}
+	})
+
+	t.Run("completion rollup", func(t *testing.T) {
+		for _, child := range []*models.Task{design, build} {
+			w := httptest.NewRecorder()
+			handler.PatchTask(w, newPatchRequest(fmt.Sprint(child.ID), "application/merge-patch+json", `{"status": 1}`))
+			if w.Code != http.StatusOK {
+				t.Fatalf("Failed to complete task: %d %s", w.Code, w.Body.String())
+			}
+		}
+
+		w := httptest.NewRecorder()
+		handler.GetTask(w, newTaskRequest(http.MethodGet, fmt.Sprintf("/tasks/%d", epic.ID), epic.ID))
+		var parent models.Task
+		if err := json.NewDecoder(w.Body).Decode(&parent); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if parent.Status != 1 || parent.State != "done" {
+			t.Errorf("Expected the epic to be completed with its subtasks, got status %d in %q", parent.Status, parent.State)
+		}
+	})
+}
+
+// TestTaskHandler_GetTaskTree tests retrieving a task with its nested subtasks
+func TestTaskHandler_GetTaskTree(t *testing.T) {
+	handler := setupTestHandler()
+	epic := createSubtask(t, handler, "Epic", 0)
+	story := createSubtask(t, handler, "Story", epic.ID)
+	subtask := createSubtask(t, handler, "Subtask", story.ID)
+	chore := createSubtask(t, handler, "Chore", epic.ID)
+	createSubtask(t, handler, "Unrelated", 0)
+
+	w := httptest.NewRecorder()
+	handler.GetTaskTree(w, newTaskRequest(http.MethodGet, fmt.Sprintf("/tasks/%d/tree", epic.ID), epic.ID))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if w.Header().Get("ETag") == "" {
+		t.Error("Expected an ETag on the tree")
+	}
+
+	type node struct {
+		ID       int    `json:"id"`
+		Name     string `json:"name"`
+		Children []node `json:"children"`
+	}
+	var tree node
+	if err := json.NewDecoder(w.Body).Decode(&tree); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	expected := node{ID: epic.ID, Name: "Epic", Children: []node{
+		{ID: story.ID, Name: "Story", Children: []node{{ID: subtask.ID, Name: "Subtask", Children: []node{}}}},
+		{ID: chore.ID, Name: "Chore", Children: []node{}},
+	}}
+	if !reflect.DeepEqual(tree, expected) {
+		t.Errorf("Expected tree %+v, got %+v", expected, tree)
+	}
+
+	w = httptest.NewRecorder()
+	handler.GetTaskTree(w, newTaskRequest(http.MethodGet, "/tasks/999/tree", 999))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a missing task, got %d", w.Code)
+	}
+}
+
+// TestTaskHandler_DeleteTask_Children tests moving subtasks up or deleting
+// them along with their parent
+func TestTaskHandler_DeleteTask_Children(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedParent int // Of the grandchild afterwards, -1 if it is deleted
+	}{
+		{"default reparents", "", http.StatusNoContent, 1},
+		{"reparent", "?children=reparent", http.StatusNoContent, 1},
+		{"cascade", "?children=cascade", http.StatusNoContent, -1},
+		{"invalid mode", "?children=orphan", http.StatusBadRequest, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := setupTestHandler()
+			epic := createSubtask(t, handler, "Epic", 0)
+			story := createSubtask(t, handler, "Story", epic.ID)
+			subtask := createSubtask(t, handler, "Subtask", story.ID)
+
+			w := httptest.NewRecorder()
+			handler.DeleteTask(w, newTaskRequest(http.MethodDelete, fmt.Sprintf("/tasks/%d%s", story.ID, tt.query), story.ID))
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+
+			remaining, err := handler.storage.GetByID(context.Background(), subtask.ID)
+			switch {
+			case tt.expectedParent < 0 && err == nil:
+				t.Errorf("Expected the subtask to be deleted, got %+v", remaining)
+			case tt.expectedParent >= 0 && err != nil:
+				t.Errorf("Expected the subtask to remain, got %v", err)
+			case tt.expectedParent >= 0 && remaining.ParentID != tt.expectedParent:
+				t.Errorf("Expected the subtask below %d, got %d", tt.expectedParent, remaining.ParentID)
+			}
+			if _, err := handler.storage.GetByID(context.Background(), epic.ID); err != nil {
+				t.Errorf("Expected the parent of the deleted task to remain, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"task-api/internal/models"
+	"task-api/internal/storage"
+	"time"
+)
+
+// maxPageSize caps the limit a client may request in one page
+const maxPageSize = 1000
+
+// pageCursor is the decoded form of the opaque cursor handed to clients.
+// It remembers the sort order it was issued for, so it cannot be replayed
+// against a differently ordered query.
+type pageCursor struct {
+	Sort   storage.SortField `json:"s"`
+	Desc   bool              `json:"d,omitempty"`
+	ID     int               `json:"i"`
+	Name   string            `json:"n,omitempty"`
+	Status int               `json:"t,omitempty"`
+	Time   *time.Time        `json:"m,omitempty"` // Creation or update time, for sorting by them
+}
+
+// encodeCursor turns a storage cursor into an opaque URL-safe token
+func encodeCursor(q storage.TaskQuery, c *storage.Cursor) string {
+	cursor := pageCursor{Sort: q.SortBy, Desc: q.Descending, ID: c.ID, Name: c.Name, Status: c.Status}
+	switch q.SortBy {
+	case storage.SortByCreatedAt:
+		cursor.Time = &c.CreatedAt
+	case storage.SortByUpdatedAt:
+		cursor.Time = &c.UpdatedAt
+	}
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses a token produced by encodeCursor for the same sort order
+func decodeCursor(q storage.TaskQuery, token string) (*storage.Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	var c pageCursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	if c.Sort != q.SortBy || c.Desc != q.Descending {
+		return nil, errors.New("cursor does not match the requested sort order")
+	}
+	cursor := &storage.Cursor{ID: c.ID, Name: c.Name, Status: c.Status}
+	if c.Time != nil {
+		cursor.CreatedAt, cursor.UpdatedAt = *c.Time, *c.Time
+	}
+	return cursor, nil
+}
+
+// parseTaskQuery builds a storage query from the GET /tasks query parameters:
+// status, state, name (substring), priority, tag (repeatable, all must match),
+// assignee (empty for unassigned tasks), parent_id (0 for top-level tasks), overdue (true or false), due_, created_, updated_ and
+// completed_ before and after (RFC 3339), sort (id, name, status, created_at
+// or updated_at), order (asc or desc), limit, offset and cursor
+func parseTaskQuery(values url.Values) (storage.TaskQuery, error) {
+	var q storage.TaskQuery
+
+	if raw := values.Get("status"); raw != "" {
+		status, err := strconv.Atoi(raw)
+		if err != nil {
+			return q, fmt.Errorf("invalid status %q", raw)
+		}
+		q.Status = &status
+	}
+
+	if raw := values.Get("state"); raw != "" {
+		if _, ok := models.DefaultWorkflow().State(raw); !ok {
+			return q, fmt.Errorf("unknown state %q", raw)
+		}
+		q.State = raw
+	}
+
+	q.NameContains = values.Get("name")
+
+	if raw := values.Get("priority"); raw != "" {
+		priority, err := strconv.Atoi(raw)
+		if err != nil {
+			return q, fmt.Errorf("invalid priority %q", raw)
+		}
+		q.Priority = &priority
+	}
+
+	for _, tag := range values["tag"] {
+		if tag = models.NormalizeTag(tag); tag != "" {
+			q.Tags = append(q.Tags, tag)
+		}
+	}
+
+	if values.Has("assignee") {
+		assignee := models.NormalizeAssignee(values.Get("assignee"))
+		q.Assignee = &assignee
+	}
+
+	if raw := values.Get("parent_id"); raw != "" {
+		parent, err := strconv.Atoi(raw)
+		if err != nil || parent < 0 {
+			return q, fmt.Errorf("invalid parent_id %q", raw)
+		}
+		q.Parent = &parent
+	}
+
+	if raw := values.Get("overdue"); raw != "" {
+		overdue, err := strconv.ParseBool(raw)
+		if err != nil {
+			return q, fmt.Errorf("invalid overdue %q (expected true or false)", raw)
+		}
+		q.Overdue = &overdue
+	}
+
+	for _, timeRange := range []struct {
+		prefix string
+		r      *storage.TimeRange
+	}{{"due", &q.Due}, {"created", &q.Created}, {"updated", &q.Updated}, {"completed", &q.Completed}} {
+		for _, bound := range []struct {
+			param string
+			value **time.Time
+		}{{timeRange.prefix + "_before", &timeRange.r.Before}, {timeRange.prefix + "_after", &timeRange.r.After}} {
+			if raw := values.Get(bound.param); raw != "" {
+				at, err := time.Parse(time.RFC3339, raw)
+				if err != nil {
+					return q, fmt.Errorf("invalid %s %q (expected an RFC 3339 time)", bound.param, raw)
+				}
+				*bound.value = &at
+			}
+		}
+	}
+
+	switch sort := storage.SortField(values.Get("sort")); sort {
+	case "", storage.SortByID:
+		q.SortBy = storage.SortByID
+	case storage.SortByName, storage.SortByStatus, storage.SortByCreatedAt, storage.SortByUpdatedAt:
+		q.SortBy = sort
+	default:
+		return q, fmt.Errorf("invalid sort field %q (expected id, name, status, created_at or updated_at)", sort)
+	}
+
+	switch order := strings.ToLower(values.Get("order")); order {
+	case "", "asc":
+	case "desc":
+		q.Descending = true
+	default:
+		return q, fmt.Errorf("invalid order %q (expected asc or desc)", order)
+	}
+
+	if raw := values.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			return q, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+		}
+		q.Limit = limit
+	}
+
+	if raw := values.Get("offset"); raw != "" {
+		offset, err := strconv.Atoi(raw)
+		if err != nil || offset < 0 {
+			return q, errors.New("offset must be a non-negative integer")
+		}
+		q.Offset = offset
+	}
+
+	if token := values.Get("cursor"); token != "" {
+		if q.Offset > 0 {
+			return q, errors.New("offset and cursor cannot be combined")
+		}
+		cursor, err := decodeCursor(q, token)
+		if err != nil {
+			return q, err
+		}
+		q.After = cursor
+	}
+
+	return q, nil
+}
+
+// setPaginationHeaders reports the total number of matching tasks in
+// X-Total-Count and links to the first and neighboring pages (RFC 8288).
+// Offset-based requests get offset links; all others get cursor links.
+func setPaginationHeaders(w http.ResponseWriter, r *http.Request, q storage.TaskQuery, page *storage.TaskPage) {
+	w.Header().Set("X-Total-Count", strconv.Itoa(page.Total))
+	if q.Limit == 0 {
+		return // Everything fits on one page
+	}
+
+	link := func(rel string, set map[string]string) string {
+		values := r.URL.Query()
+		values.Del("cursor")
+		values.Del("offset")
+		for key, value := range set {
+			values.Set(key, value)
+		}
+		u := url.URL{Path: r.URL.Path, RawQuery: values.Encode()}
+		return fmt.Sprintf(`<%s>; rel="%s"`, u.String(), rel)
+	}
+
+	links := []string{link("first", nil)}
+	if page.HasMore && len(page.Tasks) > 0 {
+		if q.Offset > 0 || r.URL.Query().Has("offset") {
+			links = append(links, link("next", map[string]string{"offset": strconv.Itoa(q.Offset + q.Limit)}))
+		} else {
+			last := page.Tasks[len(page.Tasks)-1]
+			links = append(links, link("next", map[string]string{"cursor": encodeCursor(q, storage.CursorAfter(last))}))
+		}
+	}
+	if q.Offset > 0 {
+		prev := q.Offset - q.Limit
+		if prev < 0 {
+			prev = 0
+		}
+		links = append(links, link("prev", map[string]string{"offset": strconv.Itoa(prev)}))
+	}
+
+	w.Header().Set("Link", strings.Join(links, ", "))
+}
@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"task-api/internal/models"
+	"task-api/internal/storage"
+	"testing"
+	"time"
+)
+
+// TestParseTaskQuery tests mapping query parameters onto a storage query
+func TestParseTaskQuery(t *testing.T) {
+	nameCursor := encodeCursor(storage.TaskQuery{SortBy: storage.SortByName}, &storage.Cursor{ID: 4, Name: "b"})
+	updated := time.Date(2030, 6, 1, 12, 0, 0, 123456789, time.UTC)
+	updatedCursor := encodeCursor(storage.TaskQuery{SortBy: storage.SortByUpdatedAt, Descending: true},
+		&storage.Cursor{ID: 7, CreatedAt: updated.Add(-time.Hour), UpdatedAt: updated})
+
+	tests := []struct {
+		name        string
+		rawQuery    string
+		wantErr     bool
+		checkResult func(t *testing.T, q storage.TaskQuery)
+	}{
+		{
+			name:     "defaults",
+			rawQuery: "",
+			checkResult: func(t *testing.T, q storage.TaskQuery) {
+				if q.SortBy != storage.SortByID || q.Descending || q.Limit != 0 || q.Status != nil {
+					t.Errorf("Unexpected default query %+v", q)
+				}
+			},
+		},
+		{
+			name:     "filters and sort",
+			rawQuery: "status=1&name=milk&sort=name&order=DESC&limit=10&offset=20",
+			checkResult: func(t *testing.T, q storage.TaskQuery) {
+				if q.Status == nil || *q.Status != 1 || q.NameContains != "milk" || q.SortBy != storage.SortByName ||
+					!q.Descending || q.Limit != 10 || q.Offset != 20 {
+					t.Errorf("Unexpected query %+v", q)
+				}
+			},
+		},
+		{
+			name:     "detail filters",
+			rawQuery: "priority=3&tag=Bug&tag=%20backend&assignee=alice&due_before=2030-01-02T00:00:00Z&due_after=2029-12-31T23:00:00-01:00",
+			checkResult: func(t *testing.T, q storage.TaskQuery) {
+				if q.Priority == nil || *q.Priority != 3 || fmt.Sprint(q.Tags) != "[bug backend]" ||
+					q.Assignee == nil || *q.Assignee != "alice" {
+					t.Errorf("Unexpected query %+v", q)
+				}
+				if q.Due.Before == nil || !q.Due.Before.Equal(time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)) ||
+					q.Due.After == nil || !q.Due.After.Equal(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)) {
+					t.Errorf("Unexpected due date bounds %v and %v", q.Due.Before, q.Due.After)
+				}
+			},
+		},
+		{
+			name:     "state",
+			rawQuery: "state=done",
+			checkResult: func(t *testing.T, q storage.TaskQuery) {
+				if q.State != "done" {
+					t.Errorf("Expected a filter on state done, got %q", q.State)
+				}
+			},
+		},
+		{
+			name:     "unassigned",
+			rawQuery: "assignee=",
+			checkResult: func(t *testing.T, q storage.TaskQuery) {
+				if q.Assignee == nil || *q.Assignee != "" {
+					t.Errorf("Expected a filter for unassigned tasks, got %v", q.Assignee)
+				}
+			},
+		},
+		{
+			name:     "time filters and sort",
+			rawQuery: "created_after=2030-01-01T00:00:00Z&updated_before=2030-02-01T00:00:00Z&completed_after=2030-01-15T00:00:00Z&sort=created_at",
+			checkResult: func(t *testing.T, q storage.TaskQuery) {
+				if q.SortBy != storage.SortByCreatedAt {
+					t.Errorf("Expected sort by created_at, got %q", q.SortBy)
+				}
+				if q.Created.After == nil || q.Created.Before != nil || q.Updated.Before == nil || q.Updated.After != nil ||
+					q.Completed.After == nil || !q.Completed.After.Equal(time.Date(2030, 1, 15, 0, 0, 0, 0, time.UTC)) {
+					t.Errorf("Unexpected time ranges %+v, %+v and %+v", q.Created, q.Updated, q.Completed)
+				}
+			},
+		},
+		{
+			name:     "update time cursor",
+			rawQuery: "sort=updated_at&order=desc&cursor=" + updatedCursor,
+			checkResult: func(t *testing.T, q storage.TaskQuery) {
+				if q.After == nil || q.After.ID != 7 || !q.After.UpdatedAt.Equal(updated) {
+					t.Errorf("Unexpected cursor %+v", q.After)
+				}
+			},
+		},
+		{
+			name:     "top-level tasks",
+			rawQuery: "parent_id=0",
+			checkResult: func(t *testing.T, q storage.TaskQuery) {
+				if q.Parent == nil || *q.Parent != 0 {
+					t.Errorf("Unexpected parent filter %v", q.Parent)
+				}
+			},
+		},
+		{
+			name:     "overdue tasks",
+			rawQuery: "overdue=true",
+			checkResult: func(t *testing.T, q storage.TaskQuery) {
+				if q.Overdue == nil || !*q.Overdue {
+					t.Errorf("Unexpected overdue filter %v", q.Overdue)
+				}
+			},
+		},
+		{
+			name:     "cursor",
+			rawQuery: "sort=name&cursor=" + nameCursor,
+			checkResult: func(t *testing.T, q storage.TaskQuery) {
+				if q.After == nil || q.After.ID != 4 || q.After.Name != "b" {
+					t.Errorf("Unexpected cursor %+v", q.After)
+				}
+			},
+		},
+		{name: "invalid status", rawQuery: "status=done", wantErr: true},
+		{name: "unknown state", rawQuery: "state=blocked", wantErr: true},
+		{name: "invalid sort", rawQuery: "sort=priority", wantErr: true},
+		{name: "invalid priority", rawQuery: "priority=high", wantErr: true},
+		{name: "invalid parent", rawQuery: "parent_id=-1", wantErr: true},
+		{name: "invalid overdue", rawQuery: "overdue=soon", wantErr: true},
+		{name: "invalid due date", rawQuery: "due_before=tomorrow", wantErr: true},
+		{name: "invalid creation time", rawQuery: "created_after=2030-01-01", wantErr: true},
+		{name: "invalid order", rawQuery: "order=up", wantErr: true},
+		{name: "zero limit", rawQuery: "limit=0", wantErr: true},
+		{name: "limit too large", rawQuery: "limit=1001", wantErr: true},
+		{name: "negative offset", rawQuery: "offset=-1", wantErr: true},
+		{name: "garbage cursor", rawQuery: "cursor=not-a-cursor", wantErr: true},
+		{name: "cursor for another sort order", rawQuery: "sort=status&cursor=" + nameCursor, wantErr: true},
+		{name: "offset with cursor", rawQuery: "sort=name&offset=2&cursor=" + nameCursor, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, _ := url.ParseQuery(tt.rawQuery)
+			q, err := parseTaskQuery(values)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if tt.checkResult != nil {
+				tt.checkResult(t, q)
+			}
+		})
+	}
+}
+
+var nextLinkPattern = regexp.MustCompile(`<([^>]*)>; rel="next"`)
+
+// TestTaskHandler_GetAllTasks_Pagination tests following Link headers through every page
+func TestTaskHandler_GetAllTasks_Pagination(t *testing.T) {
+	handler := setupTestHandler()
+	for _, name := range []string{"e", "a", "d", "b", "c"} {
+		task, _ := models.NewTask(name, 0)
+		handler.storage.Create(context.Background(), task)
+	}
+
+	for _, start := range []string{"/tasks?sort=name&limit=2", "/tasks?sort=name&limit=2&offset=0"} {
+		t.Run(start, func(t *testing.T) {
+			var names []string
+			target := start
+			for pages := 0; target != ""; pages++ {
+				if pages > 5 {
+					t.Fatal("Pagination does not terminate")
+				}
+
+				w := httptest.NewRecorder()
+				handler.GetAllTasks(w, httptest.NewRequest(http.MethodGet, target, nil))
+				if w.Code != http.StatusOK {
+					t.Fatalf("Expected status 200 for %s, got %d", target, w.Code)
+				}
+				if total := w.Header().Get("X-Total-Count"); total != "5" {
+					t.Errorf("Expected X-Total-Count 5, got %q", total)
+				}
+
+				var tasks []*models.Task
+				if err := json.NewDecoder(w.Body).Decode(&tasks); err != nil {
+					t.Fatalf("Failed to decode response: %v", err)
+				}
+				for _, task := range tasks {
+					names = append(names, task.Name)
+				}
+
+				target = ""
+				if match := nextLinkPattern.FindStringSubmatch(w.Header().Get("Link")); match != nil {
+					target = match[1]
+				}
+			}
+
+			if got := fmt.Sprint(names); got != "[a b c d e]" {
+				t.Errorf("Expected to page through [a b c d e], got %s", got)
+			}
+		})
+	}
+}
+
+// TestTaskHandler_GetAllTasks_Filters tests filtering and invalid parameters
+func TestTaskHandler_GetAllTasks_Filters(t *testing.T) {
+	handler := setupTestHandler()
+	for i, name := range []string{"Buy milk", "Write report", "buy bread"} {
+		task, _ := models.NewTask(name, i%2)
+		handler.storage.Create(context.Background(), task)
+	}
+
+	tests := []struct {
+		name           string
+		target         string
+		expectedStatus int
+		expectedNames  []string
+	}{
+		{"name filter", "/tasks?name=BUY", http.StatusOK, []string{"Buy milk", "buy bread"}},
+		{"status filter", "/tasks?status=1", http.StatusOK, []string{"Write report"}},
+		{"descending", "/tasks?sort=name&order=desc", http.StatusOK, []string{"buy bread", "Write report", "Buy milk"}},
+		{"invalid limit", "/tasks?limit=abc", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.GetAllTasks(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var tasks []*models.Task
+			if err := json.NewDecoder(w.Body).Decode(&tasks); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			var names []string
+			for _, task := range tasks {
+				names = append(names, task.Name)
+			}
+			if fmt.Sprint(names) != fmt.Sprint(tt.expectedNames) {
+				t.Errorf("Expected %v, got %v", tt.expectedNames, names)
+			}
+			if w.Header().Get("Link") != "" {
+				t.Error("Expected no Link header without a limit")
+			}
+		})
+	}
+}
@@ -0,0 +1,377 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"sync"
+	"task-api/internal/events"
+	"task-api/internal/models"
+	"task-api/internal/storage"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	socketPingPeriod = 30 * time.Second // How often the server pings an idle client; a client not answering within two periods is gone
+	socketWriteWait  = 10 * time.Second // Longest a single message may take to write
+	socketCloseWait  = time.Second      // Longest the goodbye may take, so stuck clients are dropped quickly
+	socketMaxMessage = 1 << 20          // Largest message a client may send, in bytes
+	socketQueue      = 256              // Messages that may wait to be written before a client counts as too slow
+)
+
+// socketPath is reported as the instance of the problems sent over sockets
+const socketPath = "/ws"
+
+// SocketHandler serves the WebSocket API: over one connection a client
+// subscribes to filtered streams of task changes and sends create and update
+// commands.
+type SocketHandler struct {
+	storage    storage.TaskStorage
+	bus        *events.Bus
+	upgrader   websocket.Upgrader
+	pingPeriod time.Duration
+}
+
+// NewSocketHandler creates a handler running commands against storage and
+// streaming the events published to bus.
+func NewSocketHandler(storage storage.TaskStorage, bus *events.Bus) *SocketHandler {
+	return &SocketHandler{
+		storage: storage,
+		bus:     bus,
+		upgrader: websocket.Upgrader{
+			Error: func(w http.ResponseWriter, r *http.Request, status int, reason error) {
+				writeErrorResponse(w, r, ErrorResponse{Code: status}.withDetail(reason))
+			},
+		},
+		pingPeriod: socketPingPeriod,
+	}
+}
+
+// socketRequest is a message from a client
+type socketRequest struct {
+	Type   string          `json:"type"`   // subscribe, unsubscribe, create or update
+	ID     string          `json:"id"`     // Names the subscription, or is echoed in the reply to a command
+	Filter socketFilter    `json:"filter"` // For subscribe: the tasks to hear about
+	Task   json.RawMessage `json:"task"`   // For create and update: the task as sent to POST /tasks or PATCH /tasks/bulk
+}
+
+// socketReply is a message to a client
+type socketReply struct {
+	Type          string         `json:"type"`                    // subscribed, unsubscribed, result, error or event
+	ID            string         `json:"id,omitempty"`            // The subscription or command replied to
+	Status        int            `json:"status,omitempty"`        // For result: the HTTP status of the equivalent request
+	Task          *models.Task   `json:"task,omitempty"`          // For result: the created or updated task
+	Tasks         []*models.Task `json:"tasks,omitempty"`         // For subscribed: the tasks matching the filter now
+	Subscriptions []string       `json:"subscriptions,omitempty"` // For event: the subscriptions it concerns
+	Event         *events.Event  `json:"event,omitempty"`         // For event: the change, as streamed by GET /tasks/events
+	Error         *problem       `json:"error,omitempty"`         // For error: what went wrong
+}
+
+// socketFilter selects the tasks a subscription hears about. Empty fields match every task.
+type socketFilter struct {
+	Status *int     `json:"status"` // Only tasks with this status
+	Tags   []string `json:"tags"`   // Only tasks carrying every one of these tags
+	IDs    []int    `json:"ids"`    // Only these tasks
+}
+
+// socketSubscription is a filtered stream of changes on a connection
+type socketSubscription struct {
+	query   storage.TaskQuery
+	ids     []int
+	members map[int]bool // Tasks that matched the filter when last reported
+}
+
+// matches reports whether task passes the subscription's filter
+func (s *socketSubscription) matches(task *models.Task) bool {
+	return s.query.Matches(task) && (len(s.ids) == 0 || slices.Contains(s.ids, task.ID))
+}
+
+// follow reports whether the subscription hears of event: it does when the
+// task matches the filter after the change or did before it, so that clients
+// see tasks leave the filter as well as enter it.
+func (s *socketSubscription) follow(event events.Event) bool {
+	matched := s.members[event.TaskID]
+	matches := event.Task != nil && s.matches(event.Task)
+	if matches {
+		s.members[event.TaskID] = true
+	} else {
+		delete(s.members, event.TaskID)
+	}
+	return matched || matches
+}
+
+// socketConn is a client connection. One goroutine reads and handles the
+// client's messages, one writes the queued replies and pings, and one routes
+// the bus events to the subscriptions.
+type socketConn struct {
+	ws        *websocket.Conn
+	send      chan socketReply
+	done      chan struct{} // Closed once the connection ends
+	closeOnce sync.Once
+
+	mutex         sync.Mutex // Guards subscriptions and orders replies about them before their events
+	subscriptions map[string]*socketSubscription
+}
+
+// Connect handles GET /ws - upgrade to a WebSocket connection and serve the
+// client until it disconnects. Events are routed to a connection without
+// ever waiting for it: a client that falls behind by too many messages is
+// disconnected, so slow clients cannot hold up writes to tasks.
+func (h *SocketHandler) Connect(w http.ResponseWriter, r *http.Request) {
+	ws, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // The upgrader has answered with an error
+	}
+
+	c := &socketConn{
+		ws:            ws,
+		send:          make(chan socketReply, socketQueue),
+		done:          make(chan struct{}),
+		subscriptions: make(map[string]*socketSubscription),
+	}
+	subscription, _, _ := h.bus.Subscribe(0, false)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		c.write(h.pingPeriod)
+	}()
+	go func() {
+		defer wg.Done()
+		c.route(subscription)
+	}()
+
+	h.read(r.Context(), c)
+	subscription.Close()
+	wg.Wait()
+}
+
+// read handles the client's messages, in order, until the connection ends
+func (h *SocketHandler) read(ctx context.Context, c *socketConn) {
+	timeout := 2 * h.pingPeriod
+	c.ws.SetReadLimit(socketMaxMessage)
+	c.ws.SetPongHandler(func(string) error {
+		return c.ws.SetReadDeadline(time.Now().Add(timeout))
+	})
+
+	for {
+		if err := c.ws.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+			break
+		}
+		_, data, err := c.ws.ReadMessage()
+		if err != nil {
+			break
+		}
+		if !h.handle(ctx, c, data) {
+			break
+		}
+	}
+	c.close(websocket.CloseNormalClosure, "")
+}
+
+// handle answers one message, returning false once the connection has ended
+func (h *SocketHandler) handle(ctx context.Context, c *socketConn, data []byte) bool {
+	var request socketRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		return c.reply(failure("", ErrInvalidMessage.withDetail(err)))
+	}
+
+	switch request.Type {
+	case "subscribe":
+		return h.subscribe(ctx, c, request)
+	case "unsubscribe":
+		return c.unsubscribe(request.ID)
+	case "create":
+		return c.reply(h.create(ctx, request))
+	case "update":
+		return c.reply(h.update(ctx, request))
+	default:
+		return c.reply(failure(request.ID, ErrInvalidMessage.withDetail(fmt.Errorf("unknown message type %q", request.Type))))
+	}
+}
+
+// subscribe starts the subscription a client asked for, replying with the tasks
+// matching its filter now
+func (h *SocketHandler) subscribe(ctx context.Context, c *socketConn, request socketRequest) bool {
+	if request.ID == "" {
+		return c.reply(failure("", ErrInvalidMessage.withDetail(errors.New("subscription id is required"))))
+	}
+	filter := request.Filter
+	if filter.Status != nil && *filter.Status != 0 && *filter.Status != 1 {
+		return c.reply(failure(request.ID, ErrInvalidMessage.withDetail(fmt.Errorf("invalid status %d", *filter.Status))))
+	}
+	subscription := &socketSubscription{
+		query:   storage.TaskQuery{Status: filter.Status, Tags: models.NormalizeTags(filter.Tags)},
+		ids:     filter.IDs,
+		members: make(map[int]bool),
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if _, ok := c.subscriptions[request.ID]; ok {
+		return c.reply(failure(request.ID, ErrInvalidMessage.withDetail(fmt.Errorf("subscription %q already exists", request.ID))))
+	}
+	page, err := h.storage.Query(ctx, subscription.query)
+	if err != nil {
+		return c.reply(failure(request.ID, storageErrorResponse(err)))
+	}
+
+	reply := socketReply{Type: "subscribed", ID: request.ID}
+	for _, task := range page.Tasks {
+		if subscription.matches(task) {
+			subscription.members[task.ID] = true
+			reply.Tasks = append(reply.Tasks, task)
+		}
+	}
+	c.subscriptions[request.ID] = subscription
+	return c.reply(reply)
+}
+
+// unsubscribe ends the subscription with id
+func (c *socketConn) unsubscribe(id string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if _, ok := c.subscriptions[id]; !ok {
+		return c.reply(failure(id, ErrInvalidMessage.withDetail(fmt.Errorf("unknown subscription %q", id))))
+	}
+	delete(c.subscriptions, id)
+	return c.reply(socketReply{Type: "unsubscribed", ID: id})
+}
+
+// create runs a create command, taking the task as POST /tasks does
+func (h *SocketHandler) create(ctx context.Context, request socketRequest) socketReply {
+	var input models.Task
+	if err := json.Unmarshal(request.Task, &input); err != nil {
+		return failure(request.ID, ErrInvalidJSON)
+	}
+	task, err := newTaskFromInput(input)
+	if err != nil {
+		return failure(request.ID, validationErrorResponse(err))
+	}
+	created, err := h.storage.Create(ctx, task)
+	if err != nil {
+		return failure(request.ID, storageErrorResponse(err))
+	}
+	return socketReply{Type: "result", ID: request.ID, Status: http.StatusCreated, Task: created}
+}
+
+// update runs an update command, taking the task as an item of PATCH /tasks/bulk
+func (h *SocketHandler) update(ctx context.Context, request socketRequest) socketReply {
+	var input partialUpdate
+	if err := json.Unmarshal(request.Task, &input); err != nil {
+		return failure(request.ID, ErrInvalidJSON)
+	}
+	task, rejection := input.load(ctx, h.storage)
+	if rejection != nil {
+		return failure(request.ID, *rejection)
+	}
+	updated, err := h.storage.Update(ctx, task)
+	if err != nil {
+		return failure(request.ID, storageErrorResponse(err))
+	}
+	return socketReply{Type: "result", ID: request.ID, Status: http.StatusOK, Task: updated}
+}
+
+// failure builds the error reply to the message with id
+func failure(id string, err ErrorResponse) socketReply {
+	report := newProblem(err, socketPath)
+	return socketReply{Type: "error", ID: id, Error: &report}
+}
+
+// reply queues a reply to the client, waiting while the queue is full.
+// It returns false once the connection has ended.
+func (c *socketConn) reply(reply socketReply) bool {
+	select {
+	case c.send <- reply:
+		return true
+	case <-c.done:
+		return false
+	}
+}
+
+// route queues the events of subscription concerning the client's
+// subscriptions. Unlike replies, events never wait: a client too far behind
+// is disconnected and has to subscribe again.
+func (c *socketConn) route(subscription *events.Subscription) {
+	for event := range subscription.Events() {
+		reply, ok := c.event(event)
+		if !ok {
+			continue
+		}
+		select {
+		case c.send <- reply:
+		case <-c.done:
+			return
+		default:
+			c.close(websocket.ClosePolicyViolation, "client too slow")
+			return
+		}
+	}
+	// The bus is shutting down, or dropped the connection for falling behind
+	c.close(websocket.CloseTryAgainLater, "event stream ended")
+}
+
+// event builds the message reporting event to the subscriptions following it;
+// ok is false if none does
+func (c *socketConn) event(event events.Event) (reply socketReply, ok bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var names []string
+	for name, subscription := range c.subscriptions {
+		if subscription.follow(event) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return socketReply{}, false
+	}
+	sort.Strings(names)
+	return socketReply{Type: "event", Subscriptions: names, Event: &event}, true
+}
+
+// write sends the queued replies and pings the client every pingPeriod until
+// the connection ends
+func (c *socketConn) write(pingPeriod time.Duration) {
+	ping := time.NewTicker(pingPeriod)
+	defer ping.Stop()
+
+	for {
+		var err error
+		select {
+		case <-c.done:
+			return
+		case reply := <-c.send:
+			if err = c.ws.SetWriteDeadline(time.Now().Add(socketWriteWait)); err == nil {
+				err = c.ws.WriteJSON(reply)
+			}
+		case <-ping.C:
+			err = c.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(socketWriteWait))
+		}
+		if err != nil {
+			c.close(0, "") // The client is gone; there is nobody to say goodbye to
+			return
+		}
+	}
+}
+
+// close ends the connection, telling the client why with a close frame unless code is 0
+func (c *socketConn) close(code int, text string) {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		if code != 0 {
+			message := websocket.FormatCloseMessage(code, text)
+			_ = c.ws.WriteControl(websocket.CloseMessage, message, time.Now().Add(socketCloseWait))
+		}
+		c.ws.Close()
+	})
+}
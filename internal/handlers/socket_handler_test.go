@@ -0,0 +1,367 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"task-api/internal/events"
+	"task-api/internal/models"
+	"task-api/internal/storage"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// setupSocket serves a socket handler over storage publishing to a new bus
+func setupSocket(t *testing.T) (storage.TaskStorage, *httptest.Server) {
+	t.Helper()
+	bus := events.NewBus(events.DefaultReplaySize)
+	s := storage.NewInMemoryStorage(storage.WithObserver(bus.Publish))
+	server := httptest.NewServer(http.HandlerFunc(NewSocketHandler(s, bus).Connect))
+	t.Cleanup(server.Close)
+	t.Cleanup(bus.Close) // Ends the connections, so the server can close
+	return s, server
+}
+
+// dialSocket connects to the socket served by server
+func dialSocket(t *testing.T, server *httptest.Server) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// exchange sends a message and returns the next message received
+func exchange(t *testing.T, conn *websocket.Conn, message string) socketReply {
+	t.Helper()
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(message)); err != nil {
+		t.Fatalf("Failed to send %s: %v", message, err)
+	}
+	return receiveReply(t, conn)
+}
+
+// receiveReply returns the next message received
+func receiveReply(t *testing.T, conn *websocket.Conn) socketReply {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var reply socketReply
+	if err := conn.ReadJSON(&reply); err != nil {
+		t.Fatalf("Failed to receive a message: %v", err)
+	}
+	return reply
+}
+
+// taskIDs returns the IDs of tasks in order
+func taskIDs(tasks []*models.Task) []int {
+	var ids []int
+	for _, task := range tasks {
+		ids = append(ids, task.ID)
+	}
+	return ids
+}
+
+// TestSocketHandler_Subscribe tests filtered subscriptions to task changes
+func TestSocketHandler_Subscribe(t *testing.T) {
+	s, server := setupSocket(t)
+	conn := dialSocket(t, server)
+	ctx := context.Background()
+
+	urgent, _ := s.Create(ctx, &models.Task{Name: "Urgent", Details: models.Details{Tags: []string{"urgent"}}})
+	done, _ := s.Create(ctx, &models.Task{Name: "Done", Status: 1})
+
+	subscriptions := []struct {
+		message  string
+		id       string
+		expected []int
+	}{
+		{message: `{"type": "subscribe", "id": "open", "filter": {"status": 0}}`, id: "open", expected: []int{urgent.ID}},
+		{message: `{"type": "subscribe", "id": "urgent", "filter": {"tags": ["Urgent"]}}`, id: "urgent", expected: []int{urgent.ID}},
+		{message: `{"type": "subscribe", "id": "done", "filter": {"ids": [` + strconv.Itoa(done.ID) + `, 99]}}`, id: "done", expected: []int{done.ID}},
+		{message: `{"type": "subscribe", "id": "none", "filter": {"status": 1, "tags": ["urgent"]}}`, id: "none"},
+	}
+	for _, subscription := range subscriptions {
+		reply := exchange(t, conn, subscription.message)
+		if reply.Type != "subscribed" || reply.ID != subscription.id || !reflect.DeepEqual(taskIDs(reply.Tasks), subscription.expected) {
+			t.Errorf("Expected subscription %s with tasks %v, got %+v", subscription.id, subscription.expected, reply)
+		}
+	}
+
+	// Each change is reported once, naming the subscriptions concerned
+	urgent.Status = 1
+	if _, err := s.Update(ctx, urgent); err != nil {
+		t.Fatalf("Failed to update task: %v", err)
+	}
+	plain, err := s.Create(ctx, &models.Task{Name: "Plain"})
+	if err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+	if err := s.Delete(ctx, done.ID); err != nil {
+		t.Fatalf("Failed to delete task: %v", err)
+	}
+
+	expected := []struct {
+		eventType     events.Type
+		taskID        int
+		subscriptions []string
+	}{
+		{eventType: events.TaskUpdated, taskID: urgent.ID, subscriptions: []string{"none", "open", "urgent"}}, // Leaves open, enters none
+		{eventType: events.TaskCreated, taskID: plain.ID, subscriptions: []string{"open"}},
+		{eventType: events.TaskDeleted, taskID: done.ID, subscriptions: []string{"done"}},
+	}
+	for _, e := range expected {
+		reply := receiveReply(t, conn)
+		if reply.Type != "event" || reply.Event == nil || reply.Event.Type != e.eventType || reply.Event.TaskID != e.taskID {
+			t.Fatalf("Expected %s event for task %d, got %+v", e.eventType, e.taskID, reply)
+		}
+		if !reflect.DeepEqual(reply.Subscriptions, e.subscriptions) {
+			t.Errorf("Expected %s event for subscriptions %v, got %v", e.eventType, e.subscriptions, reply.Subscriptions)
+		}
+	}
+
+	// An ended subscription hears of nothing more
+	if reply := exchange(t, conn, `{"type": "unsubscribe", "id": "open"}`); reply.Type != "unsubscribed" || reply.ID != "open" {
+		t.Errorf("Expected to unsubscribe, got %+v", reply)
+	}
+	for _, id := range []int{plain.ID, urgent.ID} {
+		task := mustGetTask(t, s, id)
+		task.Name += " again"
+		if _, err := s.Update(ctx, task); err != nil {
+			t.Fatalf("Failed to update task: %v", err)
+		}
+	}
+	reply := receiveReply(t, conn)
+	if reply.Event == nil || reply.Event.TaskID != urgent.ID || !reflect.DeepEqual(reply.Subscriptions, []string{"none", "urgent"}) {
+		t.Errorf("Expected only the urgent task to be reported, got %+v", reply)
+	}
+}
+
+// TestSocketHandler_Commands tests creating and updating tasks over a socket
+func TestSocketHandler_Commands(t *testing.T) {
+	s, server := setupSocket(t)
+	conn := dialSocket(t, server)
+	existing, _ := s.Create(context.Background(), &models.Task{Name: "Existing"})
+
+	tests := []struct {
+		name          string
+		message       string
+		expectedType  string
+		expectedID    string
+		status        int
+		expectedName  string
+		expectedError string
+	}{
+		{
+			name:         "create",
+			message:      `{"type": "create", "id": "c1", "task": {"name": "Socket task", "tags": ["Live"]}}`,
+			expectedType: "result", expectedID: "c1", status: http.StatusCreated, expectedName: "Socket task",
+		},
+		{
+			name:         "create invalid",
+			message:      `{"type": "create", "id": "c2", "task": {"name": ""}}`,
+			expectedType: "error", expectedID: "c2", status: http.StatusBadRequest, expectedError: "/problems/validation-failed",
+		},
+		{
+			name:         "create without task",
+			message:      `{"type": "create", "id": "c3"}`,
+			expectedType: "error", expectedID: "c3", status: http.StatusBadRequest, expectedError: "/problems/invalid-json",
+		},
+		{
+			name:         "update",
+			message:      `{"type": "update", "id": "u1", "task": {"id": ` + strconv.Itoa(existing.ID) + `, "name": "Renamed", "version": 1}}`,
+			expectedType: "result", expectedID: "u1", status: http.StatusOK, expectedName: "Renamed",
+		},
+		{
+			name:         "update stale",
+			message:      `{"type": "update", "id": "u2", "task": {"id": ` + strconv.Itoa(existing.ID) + `, "status": 1, "version": 1}}`,
+			expectedType: "error", expectedID: "u2", status: http.StatusConflict, expectedError: "/problems/task-conflict",
+		},
+		{
+			name:         "update missing",
+			message:      `{"type": "update", "id": "u3", "task": {"id": 999, "name": "Ghost"}}`,
+			expectedType: "error", expectedID: "u3", status: http.StatusNotFound, expectedError: "/problems/task-not-found",
+		},
+		{
+			name:         "update without ID",
+			message:      `{"type": "update", "id": "u4", "task": {"name": "Nobody"}}`,
+			expectedType: "error", expectedID: "u4", status: http.StatusBadRequest, expectedError: "/problems/invalid-task-id",
+		},
+		{
+			name:         "unknown type",
+			message:      `{"type": "delete", "id": "d1"}`,
+			expectedType: "error", expectedID: "d1", status: http.StatusBadRequest, expectedError: "/problems/invalid-message",
+		},
+		{
+			name:         "invalid JSON",
+			message:      `{"type":`,
+			expectedType: "error", status: http.StatusBadRequest, expectedError: "/problems/invalid-message",
+		},
+		{
+			name:         "subscribe without ID",
+			message:      `{"type": "subscribe"}`,
+			expectedType: "error", status: http.StatusBadRequest, expectedError: "/problems/invalid-message",
+		},
+		{
+			name:         "subscribe with invalid status",
+			message:      `{"type": "subscribe", "id": "s1", "filter": {"status": 2}}`,
+			expectedType: "error", expectedID: "s1", status: http.StatusBadRequest, expectedError: "/problems/invalid-message",
+		},
+		{
+			name:         "unsubscribe unknown",
+			message:      `{"type": "unsubscribe", "id": "s2"}`,
+			expectedType: "error", expectedID: "s2", status: http.StatusBadRequest, expectedError: "/problems/invalid-message",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reply := exchange(t, conn, tt.message)
+
+			if reply.Type != tt.expectedType || reply.ID != tt.expectedID {
+				t.Fatalf("Expected %s reply to %q, got %+v", tt.expectedType, tt.expectedID, reply)
+			}
+			switch tt.expectedType {
+			case "result":
+				if reply.Status != tt.status || reply.Task == nil || reply.Task.Name != tt.expectedName {
+					t.Errorf("Expected status %d with task %q, got %+v", tt.status, tt.expectedName, reply)
+				}
+			case "error":
+				if reply.Error == nil || reply.Error.Status != tt.status || reply.Error.Type != tt.expectedError || reply.Error.Instance != "/ws" {
+					t.Errorf("Expected %s problem with status %d, got %+v", tt.expectedError, tt.status, reply.Error)
+				}
+			}
+		})
+	}
+
+	// Commands are reported to subscriptions like any other change
+	if reply := exchange(t, conn, `{"type": "subscribe", "id": "all"}`); reply.Type != "subscribed" || len(reply.Tasks) != 2 {
+		t.Fatalf("Expected to subscribe to both tasks, got %+v", reply)
+	}
+	if reply := exchange(t, conn, `{"type": "create", "id": "c4", "task": {"name": "Announced"}}`); reply.Type != "result" {
+		t.Fatalf("Expected the task to be created, got %+v", reply)
+	}
+	if reply := receiveReply(t, conn); reply.Type != "event" || reply.Event.Type != events.TaskCreated || reply.Event.Task.Name != "Announced" {
+		t.Errorf("Expected the creation to be reported, got %+v", reply)
+	}
+}
+
+// TestSocketHandler_SlowClient tests that a client not reading is dropped
+// without holding up writes
+func TestSocketHandler_SlowClient(t *testing.T) {
+	s, server := setupSocket(t)
+	conn := dialSocket(t, server)
+	ctx := context.Background()
+
+	if reply := exchange(t, conn, `{"type": "subscribe", "id": "all"}`); reply.Type != "subscribed" {
+		t.Fatalf("Expected to subscribe, got %+v", reply)
+	}
+
+	// Far more than fits in the queue and the network buffers
+	const writes = 3000
+	description := strings.Repeat("x", 10000)
+	for i := 0; i < writes; i++ {
+		if _, err := s.Create(ctx, &models.Task{Name: "Flood", Details: models.Details{Description: description}}); err != nil {
+			t.Fatalf("Failed to create task: %v", err)
+		}
+	}
+
+	received := 0
+	for {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if _, _, err := conn.ReadMessage(); err != nil {
+			var timeout interface{ Timeout() bool }
+			if errors.As(err, &timeout) && timeout.Timeout() {
+				t.Fatalf("Expected the slow client to be disconnected, still connected after %d events", received)
+			}
+			break
+		}
+		received++
+	}
+	if received >= writes {
+		t.Errorf("Expected the slow client to miss events, got all %d", received)
+	}
+}
+
+// TestSocketHandler_Heartbeat tests that idle clients are pinged and told when the server goes away
+func TestSocketHandler_Heartbeat(t *testing.T) {
+	bus := events.NewBus(10)
+	handler := NewSocketHandler(storage.NewInMemoryStorage(), bus)
+	handler.pingPeriod = 10 * time.Millisecond
+	server := httptest.NewServer(http.HandlerFunc(handler.Connect))
+	defer server.Close()
+	conn := dialSocket(t, server)
+
+	pinged := make(chan struct{}, 1)
+	conn.SetPingHandler(func(data string) error {
+		select {
+		case pinged <- struct{}{}:
+		default:
+		}
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+	})
+	closed := make(chan error, 1)
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				closed <- err
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-pinged:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected a ping")
+	}
+	// Several ping periods pass without the client being dropped
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case err := <-closed:
+		t.Fatalf("Expected the client answering pings to stay connected, got %v", err)
+	default:
+	}
+
+	bus.Close()
+	select {
+	case err := <-closed:
+		if !websocket.IsCloseError(err, websocket.CloseTryAgainLater) {
+			t.Errorf("Expected the connection to close with code %d, got %v", websocket.CloseTryAgainLater, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the connection to close with the bus")
+	}
+}
+
+// TestSocketHandler_Connect_NotWebSocket tests that plain requests are refused with a problem document
+func TestSocketHandler_Connect_NotWebSocket(t *testing.T) {
+	handler := NewSocketHandler(storage.NewInMemoryStorage(), events.NewBus(10))
+	req := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	w := httptest.NewRecorder()
+
+	handler.Connect(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != problemContentType {
+		t.Errorf("Expected Content-Type %s, got %s", problemContentType, contentType)
+	}
+}
+
+// mustGetTask returns the stored task with id
+func mustGetTask(t *testing.T, s storage.TaskStorage, id int) *models.Task {
+	t.Helper()
+	task, err := s.GetByID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("Failed to get task %d: %v", id, err)
+	}
+	return task
+}
@@ -1,11 +1,19 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
 	"strconv"
+	"strings"
 	"task-api/internal/models"
+	"task-api/internal/patch"
 	"task-api/internal/storage"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -22,16 +30,101 @@ func NewTaskHandler(storage storage.TaskStorage) *TaskHandler {
 	}
 }
 
-// GetAllTasks handles GET /tasks - retrieve all tasks
+// GetAllTasks handles GET /tasks - retrieve tasks, optionally filtered, sorted and paginated.
+// Without query parameters every task is returned ordered by ID.
 func (h *TaskHandler) GetAllTasks(w http.ResponseWriter, r *http.Request) {
-	tasks, err := h.storage.GetAll()
+	query, err := parseTaskQuery(r.URL.Query())
 	if err != nil {
-		writeErrorResponse(w, ErrInternalServer)
+		writeErrorResponse(w, r, ErrInvalidQuery.withDetail(err))
 		return
 	}
 
-	if err := writeJSONResponse(w, tasks, http.StatusOK); err != nil {
-		writeErrorResponse(w, ErrInternalServer)
+	page, err := h.storage.Query(r.Context(), query)
+	if err != nil {
+		writeErrorResponse(w, r, storageErrorResponse(err))
+		return
+	}
+
+	setPaginationHeaders(w, r, query, page)
+
+	// Weak ETag over the list lets pollers revalidate with If-None-Match
+	if err := writeCacheableJSON(w, r, page.Tasks, http.StatusOK); err != nil {
+		writeErrorResponse(w, r, ErrInternalServer)
+		return
+	}
+}
+
+// defaultSearchLimit and maxSearchLimit bound the number of search results
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// searchHit is a task in search results together with its relevance score
+type searchHit struct {
+	*models.Task
+	Score float64 `json:"score"`
+}
+
+// SearchTasks handles GET /tasks/search?q= - full-text search over tasks.
+// Results are ranked by relevance, best first; limit caps their number.
+func (h *TaskHandler) SearchTasks(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		writeErrorResponse(w, r, ErrMissingSearchQuery)
+		return
+	}
+
+	limit := defaultSearchLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		var err error
+		if limit, err = strconv.Atoi(raw); err != nil || limit < 1 || limit > maxSearchLimit {
+			writeErrorResponse(w, r, ErrInvalidQuery.withDetail(fmt.Errorf("limit must be between 1 and %d", maxSearchLimit)))
+			return
+		}
+	}
+
+	results, err := h.storage.Search(r.Context(), query, limit)
+	if err != nil {
+		writeErrorResponse(w, r, storageErrorResponse(err))
+		return
+	}
+
+	hits := make([]searchHit, 0, len(results))
+	for _, result := range results {
+		hits = append(hits, searchHit{Task: result.Task, Score: result.Score})
+	}
+
+	if err := writeJSONResponse(w, hits, http.StatusOK); err != nil {
+		writeErrorResponse(w, r, ErrInternalServer)
+		return
+	}
+}
+
+// GetTask handles GET /tasks/{id} - retrieve a single task
+func (h *TaskHandler) GetTask(w http.ResponseWriter, r *http.Request) {
+	// Extract ID from URL path using chi
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		writeErrorResponse(w, r, ErrInvalidTaskID)
+		return
+	}
+
+	task, err := h.storage.GetByID(r.Context(), id)
+	if err != nil {
+		writeErrorResponse(w, r, storageErrorResponse(err))
+		return
+	}
+
+	// Answer If-None-Match / If-Modified-Since with 304 when the client already has this version
+	if !checkPreconditions(w, r, task) {
+		return
+	}
+
+	setValidators(w, task)
+	if err := writeJSONResponse(w, task, http.StatusOK); err != nil {
+		writeErrorResponse(w, r, ErrInternalServer)
 		return
 	}
 }
@@ -40,104 +133,326 @@ func (h *TaskHandler) GetAllTasks(w http.ResponseWriter, r *http.Request) {
 func (h *TaskHandler) CreateTask(w http.ResponseWriter, r *http.Request) {
 	var task models.Task
 	if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
-		writeErrorResponse(w, ErrInvalidJSON)
+		writeErrorResponse(w, r, ErrInvalidJSON)
 		return
 	}
 
-	newTask, err := models.NewTask(task.Name, task.Status)
+	newTask, err := newTaskFromInput(task)
 	if err != nil {
-		writeErrorResponse(w, ErrorResponse{
-			Err:     err,
-			Message: err.Error(),
-			Code:    http.StatusBadRequest,
-		})
+		writeErrorResponse(w, r, validationErrorResponse(err))
 		return
 	}
 
 	// Create task in storage
-	createdTask, err := h.storage.Create(newTask)
+	createdTask, err := h.storage.Create(r.Context(), newTask)
 	if err != nil {
-		writeErrorResponse(w, ErrInternalServer)
+		writeErrorResponse(w, r, storageErrorResponse(err))
 		return
 	}
 
+	setValidators(w, createdTask)
 	if err := writeJSONResponse(w, createdTask, http.StatusCreated); err != nil {
-		writeErrorResponse(w, ErrInternalServer)
+		writeErrorResponse(w, r, ErrInternalServer)
 		return
 	}
 }
 
-// UpdateTask handles PUT /tasks/{id} - update an existing task
+// newTaskFromInput creates a task from a request body. A task with a state
+// starts in that workflow state and its status is ignored; otherwise it starts
+// in the state matching its legacy status.
+func newTaskFromInput(input models.Task) (*models.Task, error) {
+	if input.State != "" {
+		return models.NewTaskInState(input.Name, input.State, input.Details)
+	}
+	return models.NewTaskWithDetails(input.Name, input.Status, input.Details)
+}
+
+// updateTaskFromInput applies an update to task, moving it to state or, when
+// state is empty, to the state matching the legacy status.
+func updateTaskFromInput(task *models.Task, name string, status int, state string, details models.Details) error {
+	if state != "" {
+		return task.UpdateInState(name, state, details)
+	}
+	return task.UpdateWithDetails(name, status, details)
+}
+
+// optional is a JSON field that remembers whether it was present in the
+// body, so an explicit null can be told apart from an omitted field.
+type optional[T any] struct {
+	Set   bool
+	Value T
+}
+
+func (o *optional[T]) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	return json.Unmarshal(data, &o.Value)
+}
+
+// detailsInput carries the task details of an update request.
+// Fields omitted from the body keep their current value, so clients written
+// before the details existed do not erase them; null or an empty value clears a field.
+type detailsInput struct {
+	Description optional[string]     `json:"description"`
+	DueAt       optional[*time.Time] `json:"due_at"`
+	Priority    optional[int]        `json:"priority"`
+	Tags        optional[[]string]   `json:"tags"`
+	Assignee    optional[string]     `json:"assignee"`
+	ParentID    optional[int]        `json:"parent_id"`
+	BlockedBy   optional[[]int]      `json:"blocked_by"`
+	Recurrence  optional[string]     `json:"recurrence"`
+}
+
+// apply returns details with the fields present in the input replaced
+func (in detailsInput) apply(details models.Details) models.Details {
+	if in.Description.Set {
+		details.Description = in.Description.Value
+	}
+	if in.DueAt.Set {
+		details.DueAt = in.DueAt.Value
+	}
+	if in.Priority.Set {
+		details.Priority = in.Priority.Value
+	}
+	if in.Tags.Set {
+		details.Tags = in.Tags.Value
+	}
+	if in.Assignee.Set {
+		details.Assignee = in.Assignee.Value
+	}
+	if in.ParentID.Set {
+		details.ParentID = in.ParentID.Value
+	}
+	if in.BlockedBy.Set {
+		details.BlockedBy = in.BlockedBy.Value
+	}
+	if in.Recurrence.Set {
+		details.Recurrence = in.Recurrence.Value
+	}
+	return details
+}
+
+// UpdateTask handles PUT /tasks/{id} - update an existing task.
+// Name and status (or state, which takes precedence) are always replaced;
+// details omitted from the body are kept.
 func (h *TaskHandler) UpdateTask(w http.ResponseWriter, r *http.Request) {
 	// Extract ID from URL path using chi
 	idStr := chi.URLParam(r, "id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		writeErrorResponse(w, ErrInvalidTaskID)
+		writeErrorResponse(w, r, ErrInvalidTaskID)
 		return
 	}
 
 	// Fetch existing task
-	existingTask, err := h.storage.GetByID(id)
+	existingTask, err := h.storage.GetByID(r.Context(), id)
 	if err != nil {
-		writeErrorResponse(w, ErrTaskNotFound)
+		writeErrorResponse(w, r, storageErrorResponse(err))
+		return
+	}
+
+	// Honor the preconditions against the version being replaced
+	if !checkPreconditions(w, r, existingTask) {
 		return
 	}
 
 	// Parse input
 	var input struct {
-		Name   string `json:"name"`
-		Status int    `json:"status"`
+		Name    string `json:"name"`
+		Status  int    `json:"status"`
+		State   string `json:"state"`
+		Version *int   `json:"version"` // Optional alternative to If-Match
+		detailsInput
 	}
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		writeErrorResponse(w, ErrInvalidJSON)
+		writeErrorResponse(w, r, ErrInvalidJSON)
+		return
+	}
+
+	// A version in the body must match the version being replaced
+	if input.Version != nil && *input.Version != existingTask.Version {
+		writeErrorResponse(w, r, ErrTaskConflict)
+		return
+	}
+
+	// Apply changes with validation
+	if err := updateTaskFromInput(existingTask, input.Name, input.Status, input.State, input.apply(existingTask.Details)); err != nil {
+		writeErrorResponse(w, r, validationErrorResponse(err))
+		return
+	}
+
+	h.saveTask(w, r, existingTask)
+}
+
+// PatchTask handles PATCH /tasks/{id} - partially update an existing task.
+// The body is a JSON Merge Patch (application/merge-patch+json) or a
+// JSON Patch (application/json-patch+json) applied to the task's JSON form;
+// the result goes through the same validation as a full update.
+func (h *TaskHandler) PatchTask(w http.ResponseWriter, r *http.Request) {
+	// Extract ID from URL path using chi
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		writeErrorResponse(w, r, ErrInvalidTaskID)
+		return
+	}
+
+	// Pick the patch format from the content type
+	var applyPatch func(doc, patchDoc []byte) ([]byte, error)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case patch.MergePatchType:
+		applyPatch = patch.MergePatch
+	case patch.JSONPatchType:
+		applyPatch = patch.JSONPatch
+	default:
+		w.Header().Set("Accept-Patch", patch.MergePatchType+", "+patch.JSONPatchType)
+		writeErrorResponse(w, r, ErrUnsupportedPatch)
+		return
+	}
+
+	patchDoc, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeErrorResponse(w, r, ErrInvalidJSON)
+		return
+	}
+
+	// Fetch existing task
+	existingTask, err := h.storage.GetByID(r.Context(), id)
+	if err != nil {
+		writeErrorResponse(w, r, storageErrorResponse(err))
+		return
+	}
+
+	// Honor the preconditions against the version being patched
+	if !checkPreconditions(w, r, existingTask) {
+		return
+	}
+
+	// Apply the patch to the current representation
+	doc, err := json.Marshal(existingTask)
+	if err != nil {
+		writeErrorResponse(w, r, ErrInternalServer)
+		return
+	}
+	patchedDoc, err := applyPatch(doc, patchDoc)
+	if err != nil {
+		writeErrorResponse(w, r, patchErrorResponse(err))
+		return
+	}
+
+	var patched models.Task
+	if err := json.Unmarshal(patchedDoc, &patched); err != nil {
+		writeErrorResponse(w, r, ErrUnprocessablePatch.withDetail(err))
 		return
 	}
 
+	// ID is read-only; a changed version means the patch was written against another version
+	if patched.ID != existingTask.ID {
+		writeErrorResponse(w, r, ErrTaskIDChanged)
+		return
+	}
+	if patched.Version != existingTask.Version {
+		writeErrorResponse(w, r, ErrTaskConflict)
+		return
+	}
+
+	// A patched status only counts when the state was left alone, so legacy
+	// clients can keep patching the status alone
+	state := patched.State
+	if state == existingTask.State && patched.Status != existingTask.Status {
+		state = ""
+	}
+
 	// Apply changes with validation
-	if err := existingTask.Update(input.Name, input.Status); err != nil {
-		writeErrorResponse(w, ErrorResponse{
-			Err:     err,
-			Message: err.Error(),
-			Code:    http.StatusBadRequest,
-		})
+	if err := updateTaskFromInput(existingTask, patched.Name, patched.Status, state, patched.Details); err != nil {
+		writeErrorResponse(w, r, validationErrorResponse(err))
 		return
 	}
 
-	// Save updated task
-	if err := h.storage.Update(existingTask); err != nil {
-		writeErrorResponse(w, ErrInternalServer)
+	h.saveTask(w, r, existingTask)
+}
+
+// saveTask stores a modified task and writes it back with its new ETag and Last-Modified.
+// Storage rejects the task if someone else saved first; when the client sent
+// If-Match or If-Unmodified-Since that is reported as a failed precondition.
+func (h *TaskHandler) saveTask(w http.ResponseWriter, r *http.Request, task *models.Task) {
+	updatedTask, err := h.storage.Update(r.Context(), task)
+	if err != nil {
+		if errors.Is(err, storage.ErrConflict) && hasWritePreconditions(r) {
+			// The client's precondition no longer holds
+			writeErrorResponse(w, r, ErrPreconditionFailed)
+			return
+		}
+		writeErrorResponse(w, r, storageErrorResponse(err))
 		return
 	}
 
-	if err := writeJSONResponse(w, existingTask, http.StatusOK); err != nil {
-		writeErrorResponse(w, ErrInternalServer)
+	setValidators(w, updatedTask)
+	if err := writeJSONResponse(w, updatedTask, http.StatusOK); err != nil {
+		writeErrorResponse(w, r, ErrInternalServer)
 		return
 	}
 }
 
-// DeleteTask handles DELETE /tasks/{id} - delete a task
+// DeleteTask handles DELETE /tasks/{id} - delete a task.
+// Its subtasks move up to its parent, or are deleted too with ?children=cascade.
+// Honors If-Match and If-Unmodified-Since like UpdateTask.
 func (h *TaskHandler) DeleteTask(w http.ResponseWriter, r *http.Request) {
 	// Extract ID from URL path using chi
 	idStr := chi.URLParam(r, "id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		writeErrorResponse(w, ErrInvalidTaskID)
+		writeErrorResponse(w, r, ErrInvalidTaskID)
+		return
+	}
+
+	mode, err := parseDeleteMode(r)
+	if err != nil {
+		writeErrorResponse(w, r, ErrInvalidQuery.withDetail(err))
 		return
 	}
 
-	// Check if task exists
-	_, err = h.storage.GetByID(id)
+	task, err := h.storage.GetByID(r.Context(), id)
 	if err != nil {
-		writeErrorResponse(w, ErrTaskNotFound)
+		writeErrorResponse(w, r, storageErrorResponse(err))
+		return
+	}
+	if !checkPreconditions(w, r, task) {
 		return
 	}
 
-	// Delete task from storage
-	if err := h.storage.Delete(id); err != nil {
-		writeErrorResponse(w, ErrInternalServer)
+	// A conditional delete only removes the version the preconditions were checked against
+	var version int
+	if hasWritePreconditions(r) {
+		version = task.Version
+	}
+	if mode == deleteCascade {
+		err = h.deleteSubtree(r.Context(), id, version)
+	} else {
+		err = h.deleteTask(r.Context(), id, version)
+	}
+	if err != nil {
+		if errors.Is(err, storage.ErrConflict) && version != 0 {
+			// Changed since the preconditions were checked
+			writeErrorResponse(w, r, ErrPreconditionFailed)
+			return
+		}
+		writeErrorResponse(w, r, storageErrorResponse(err))
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// deleteTask deletes a task, unless version is not 0 and the task no longer
+// has it, in which case ErrConflict is returned
+func (h *TaskHandler) deleteTask(ctx context.Context, id, version int) error {
+	if version == 0 {
+		return h.storage.Delete(ctx, id)
+	}
+	results, err := h.storage.Batch(ctx, []storage.BatchOp{{Kind: storage.BatchDelete, ID: id, Version: version}}, true)
+	if err != nil {
+		return err
+	}
+	return results[0].Err
+}
@@ -5,36 +5,75 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"sync"
+	"task-api/internal/clock"
 	"task-api/internal/models"
 	"task-api/internal/storage"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 )
 
 // Mock implementation right in the test file
-type mockTaskStorage struct{}
+// Each method fails with err when set, or with a generic (unclassified) error otherwise
+type mockTaskStorage struct {
+	err error
+}
+
+func (m *mockTaskStorage) fail(message string) error {
+	if m.err != nil {
+		return m.err
+	}
+	return errors.New(message)
+}
+
+func (m *mockTaskStorage) Create(ctx context.Context, task *models.Task) (*models.Task, error) {
+	return nil, m.fail("storage create failed")
+}
+
+func (m *mockTaskStorage) GetAll(ctx context.Context) ([]*models.Task, error) {
+	return nil, m.fail("storage getall failed")
+}
+
+func (m *mockTaskStorage) Query(ctx context.Context, q storage.TaskQuery) (*storage.TaskPage, error) {
+	return nil, m.fail("storage query failed")
+}
+
+func (m *mockTaskStorage) Search(ctx context.Context, query string, limit int) ([]*storage.SearchResult, error) {
+	return nil, m.fail("storage search failed")
+}
+
+func (m *mockTaskStorage) GetByID(ctx context.Context, id int) (*models.Task, error) {
+	return nil, m.fail("storage getbyid failed")
+}
+
+func (m *mockTaskStorage) Update(ctx context.Context, task *models.Task) (*models.Task, error) {
+	return nil, m.fail("storage update failed")
+}
 
-func (m *mockTaskStorage) Create(task *models.Task) (*models.Task, error) {
-	return nil, errors.New("storage create failed")
+func (m *mockTaskStorage) MarkAlerts(ctx context.Context, id, version int, alerts models.Alerts) (*models.Task, error) {
+	return nil, m.fail("storage mark failed")
 }
 
-func (m *mockTaskStorage) GetAll() ([]*models.Task, error) {
-	return nil, errors.New("storage getall failed")
+func (m *mockTaskStorage) Delete(ctx context.Context, id int) error {
+	return m.fail("storage delete failed")
 }
 
-func (m *mockTaskStorage) GetByID(id int) (*models.Task, error) {
-	return nil, errors.New("storage getbyid failed")
+func (m *mockTaskStorage) Batch(ctx context.Context, ops []storage.BatchOp, atomic bool) ([]storage.BatchResult, error) {
+	return nil, m.fail("storage batch failed")
 }
 
-func (m *mockTaskStorage) Update(task *models.Task) error {
-	return errors.New("storage update failed")
+func (m *mockTaskStorage) WithTx(ctx context.Context, fn func(tx storage.TaskStorage) error) error {
+	return m.fail("storage transaction failed")
 }
 
-func (m *mockTaskStorage) Delete(id int) error {
-	return errors.New("storage delete failed")
+func (m *mockTaskStorage) View(ctx context.Context, fn func(tx storage.TaskStorage) error) error {
+	return m.fail("storage view failed")
 }
 
 // setupTestHandler creates a handler with in-memory storage for testing
@@ -49,6 +88,11 @@ func setupTestHandlerWithMock() *TaskHandler {
 	return NewTaskHandler(testStorage)
 }
 
+// setupTestHandlerWithError creates a handler whose storage always fails with err
+func setupTestHandlerWithError(err error) *TaskHandler {
+	return NewTaskHandler(&mockTaskStorage{err: err})
+}
+
 // TestTaskHandler_GetAllTasks_EmptyStorage tests retrieving tasks from empty storage
 func TestTaskHandler_GetAllTasks_EmptyStorage(t *testing.T) {
 	handler := setupTestHandler()
@@ -78,12 +122,12 @@ func TestTaskHandler_GetAllTasks(t *testing.T) {
 
 	// Add some test tasks
 	task1, _ := models.NewTask("Task 1", 0)
-	_, err := handler.storage.Create(task1)
+	_, err := handler.storage.Create(context.Background(), task1)
 	if err != nil {
 		t.Fatalf("Failed to create task1: %v", err)
 	}
 	task2, _ := models.NewTask("Task 2", 1)
-	_, err = handler.storage.Create(task2)
+	_, err = handler.storage.Create(context.Background(), task2)
 	if err != nil {
 		t.Fatalf("Failed to create task2: %v", err)
 	}
@@ -176,13 +220,98 @@ func TestTaskHandler_CreateTask_InvalidJSON(t *testing.T) {
 	}
 }
 
+// TestTaskHandler_GetTask tests retrieving a single task
+func TestTaskHandler_GetTask(t *testing.T) {
+	handler := setupTestHandler()
+
+	// Create a task first
+	task, _ := models.NewTask("Task to Fetch", 1)
+	createdTask, _ := handler.storage.Create(context.Background(), task)
+
+	tests := []struct {
+		name           string
+		taskID         string
+		ifNoneMatch    string
+		expectedStatus int
+	}{
+		{
+			name:           "existing task",
+			taskID:         "1",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "non-existent task",
+			taskID:         "999",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "invalid task ID",
+			taskID:         "abc",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "current ETag",
+			taskID:         "1",
+			ifNoneMatch:    `"1"`,
+			expectedStatus: http.StatusNotModified,
+		},
+		{
+			name:           "outdated ETag",
+			taskID:         "1",
+			ifNoneMatch:    `"0"`,
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/tasks/"+tt.taskID, nil)
+			if tt.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+
+			// Add chi URL parameter to route context
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tt.taskID)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			w := httptest.NewRecorder()
+
+			handler.GetTask(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+
+			switch tt.expectedStatus {
+			case http.StatusOK:
+				if etag := w.Header().Get("ETag"); etag != `"1"` {
+					t.Errorf("Expected ETag \"1\", got %s", etag)
+				}
+
+				var fetched models.Task
+				if err := json.NewDecoder(w.Body).Decode(&fetched); err != nil {
+					t.Fatalf("Failed to decode response: %v", err)
+				}
+				if !reflect.DeepEqual(&fetched, createdTask) {
+					t.Errorf("Expected %+v, got %+v", *createdTask, fetched)
+				}
+			case http.StatusNotModified:
+				if w.Body.Len() != 0 {
+					t.Errorf("Expected empty body for 304, got %q", w.Body.String())
+				}
+			}
+		})
+	}
+}
+
 // TestTaskHandler_UpdateTask tests updating an existing task
 func TestTaskHandler_UpdateTask(t *testing.T) {
 	handler := setupTestHandler()
 
 	// Create a task first
 	task, _ := models.NewTask("Original Task", 0)
-	createdTask, _ := handler.storage.Create(task)
+	createdTask, _ := handler.storage.Create(context.Background(), task)
 
 	tests := []struct {
 		name           string
@@ -255,7 +384,7 @@ func TestTaskHandler_DeleteTask(t *testing.T) {
 
 	// Create a task first
 	task, _ := models.NewTask("Task to Delete", 0)
-	createdTask, _ := handler.storage.Create(task)
+	createdTask, _ := handler.storage.Create(context.Background(), task)
 
 	tests := []struct {
 		name           string
@@ -298,7 +427,7 @@ func TestTaskHandler_DeleteTask(t *testing.T) {
 
 			// Verify task is deleted for successful deletion
 			if tt.expectedStatus == http.StatusNoContent {
-				_, err := handler.storage.GetByID(createdTask.ID)
+				_, err := handler.storage.GetByID(context.Background(), createdTask.ID)
 				if err == nil {
 					t.Error("Task should be deleted but still exists")
 				}
@@ -325,8 +454,8 @@ func TestTaskHandler_GetAllTasks_StorageError(t *testing.T) {
 		t.Fatalf("Failed to decode error response: %v", err)
 	}
 
-	if errorResponse["message"] != "Internal server error" {
-		t.Errorf("Expected 'Internal server error', got %v", errorResponse["message"])
+	if errorResponse["title"] != "Internal server error" {
+		t.Errorf("Expected 'Internal server error', got %v", errorResponse["title"])
 	}
 }
 
@@ -351,47 +480,69 @@ func TestTaskHandler_CreateTask_StorageError(t *testing.T) {
 		t.Fatalf("Failed to decode error response: %v", err)
 	}
 
-	if errorResponse["message"] != "Internal server error" {
-		t.Errorf("Expected 'Internal server error', got %v", errorResponse["message"])
+	if errorResponse["title"] != "Internal server error" {
+		t.Errorf("Expected 'Internal server error', got %v", errorResponse["title"])
 	}
 }
 
+// storageErrorCases lists how each kind of storage failure must surface to clients
+var storageErrorCases = []struct {
+	name           string
+	err            error
+	expectedStatus int
+	expectedError  string
+}{
+	{
+		name:           "task not found",
+		err:            &storage.Error{Op: "get", ID: 1, Kind: storage.ErrNotFound},
+		expectedStatus: http.StatusNotFound,
+		expectedError:  "Task not found",
+	},
+	{
+		name:           "conflict",
+		err:            &storage.Error{Op: "update", ID: 1, Kind: storage.ErrConflict},
+		expectedStatus: http.StatusConflict,
+		expectedError:  "Task was modified concurrently",
+	},
+	{
+		name:           "invalid",
+		err:            &storage.Error{Op: "update", ID: 1, Kind: storage.ErrInvalid},
+		expectedStatus: http.StatusBadRequest,
+		expectedError:  "Invalid task",
+	},
+	{
+		name:           "database outage",
+		err:            &storage.Error{Op: "get", ID: 1, Kind: storage.ErrUnavailable, Err: errors.New("connection refused")},
+		expectedStatus: http.StatusServiceUnavailable,
+		expectedError:  "Storage temporarily unavailable",
+	},
+	{
+		name:           "deadline exceeded",
+		err:            &storage.Error{Op: "get", ID: 1, Kind: storage.ErrUnavailable, Err: context.DeadlineExceeded},
+		expectedStatus: http.StatusServiceUnavailable,
+		expectedError:  "Storage temporarily unavailable",
+	},
+	{
+		name:           "unclassified error",
+		err:            errors.New("storage getbyid failed"),
+		expectedStatus: http.StatusInternalServerError,
+		expectedError:  "Internal server error",
+	},
+}
+
 // TestTaskHandler_UpdateTask_StorageErrors tests UpdateTask with various storage failures
 func TestTaskHandler_UpdateTask_StorageErrors(t *testing.T) {
-	handler := setupTestHandlerWithMock()
-
-	tests := []struct {
-		name           string
-		taskID         string
-		requestBody    interface{}
-		expectedStatus int
-		expectedError  string
-	}{
-		{
-			name:           "storage GetByID fails",
-			taskID:         "1",
-			requestBody:    map[string]interface{}{"name": "Updated Task", "status": 1},
-			expectedStatus: http.StatusNotFound,
-			expectedError:  "Task not found",
-		},
-		{
-			name:           "storage Update fails after successful GetByID",
-			taskID:         "1",
-			requestBody:    map[string]interface{}{"name": "Updated Task", "status": 1},
-			expectedStatus: http.StatusNotFound, // GetByID fails first
-			expectedError:  "Task not found",
-		},
-	}
-
-	for _, tt := range tests {
+	for _, tt := range storageErrorCases {
 		t.Run(tt.name, func(t *testing.T) {
-			body, _ := json.Marshal(tt.requestBody)
-			req := httptest.NewRequest(http.MethodPut, "/tasks/"+tt.taskID, bytes.NewBuffer(body))
+			handler := setupTestHandlerWithError(tt.err)
+
+			body, _ := json.Marshal(map[string]interface{}{"name": "Updated Task", "status": 1})
+			req := httptest.NewRequest(http.MethodPut, "/tasks/1", bytes.NewBuffer(body))
 			req.Header.Set("Content-Type", "application/json")
 
 			// Add chi URL parameter to route context
 			rctx := chi.NewRouteContext()
-			rctx.URLParams.Add("id", tt.taskID)
+			rctx.URLParams.Add("id", "1")
 			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
 
 			w := httptest.NewRecorder()
@@ -407,8 +558,8 @@ func TestTaskHandler_UpdateTask_StorageErrors(t *testing.T) {
 				t.Fatalf("Failed to decode error response: %v", err)
 			}
 
-			if errorResponse["message"] != tt.expectedError {
-				t.Errorf("Expected error '%s', got %v", tt.expectedError, errorResponse["message"])
+			if errorResponse["title"] != tt.expectedError {
+				t.Errorf("Expected error '%s', got %v", tt.expectedError, errorResponse["title"])
 			}
 		})
 	}
@@ -416,53 +567,1005 @@ func TestTaskHandler_UpdateTask_StorageErrors(t *testing.T) {
 
 // TestTaskHandler_DeleteTask_StorageErrors tests DeleteTask with various storage failures
 func TestTaskHandler_DeleteTask_StorageErrors(t *testing.T) {
-	handler := setupTestHandlerWithMock()
+	for _, tt := range storageErrorCases {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := setupTestHandlerWithError(tt.err)
+
+			req := httptest.NewRequest(http.MethodDelete, "/tasks/1", nil)
+
+			// Add chi URL parameter to route context
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", "1")
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			w := httptest.NewRecorder()
+
+			handler.DeleteTask(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+
+			var errorResponse map[string]interface{}
+			if err := json.NewDecoder(w.Body).Decode(&errorResponse); err != nil {
+				t.Fatalf("Failed to decode error response: %v", err)
+			}
 
+			if errorResponse["title"] != tt.expectedError {
+				t.Errorf("Expected error '%s', got %v", tt.expectedError, errorResponse["title"])
+			}
+		})
+	}
+}
+
+// TestTaskHandler_GetAllTasks_StorageUnavailable tests that outages are not reported as internal errors
+func TestTaskHandler_GetAllTasks_StorageUnavailable(t *testing.T) {
+	handler := setupTestHandlerWithError(&storage.Error{Op: "list", Kind: storage.ErrUnavailable, Err: errors.New("connection reset")})
+
+	req := httptest.NewRequest(http.MethodGet, "/tasks", nil)
+	w := httptest.NewRecorder()
+
+	handler.GetAllTasks(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d, got %d", http.StatusServiceUnavailable, w.Code)
+	}
+}
+
+// ctxKey is a private context key used to trace request contexts into storage
+type ctxKey struct{}
+
+// contextSpyStorage records the context of every storage call
+type contextSpyStorage struct {
+	storage.TaskStorage
+	seen []context.Context
+}
+
+func (s *contextSpyStorage) Create(ctx context.Context, task *models.Task) (*models.Task, error) {
+	s.seen = append(s.seen, ctx)
+	return s.TaskStorage.Create(ctx, task)
+}
+
+func (s *contextSpyStorage) GetAll(ctx context.Context) ([]*models.Task, error) {
+	s.seen = append(s.seen, ctx)
+	return s.TaskStorage.GetAll(ctx)
+}
+
+func (s *contextSpyStorage) Query(ctx context.Context, q storage.TaskQuery) (*storage.TaskPage, error) {
+	s.seen = append(s.seen, ctx)
+	return s.TaskStorage.Query(ctx, q)
+}
+
+func (s *contextSpyStorage) Search(ctx context.Context, query string, limit int) ([]*storage.SearchResult, error) {
+	s.seen = append(s.seen, ctx)
+	return s.TaskStorage.Search(ctx, query, limit)
+}
+
+func (s *contextSpyStorage) GetByID(ctx context.Context, id int) (*models.Task, error) {
+	s.seen = append(s.seen, ctx)
+	return s.TaskStorage.GetByID(ctx, id)
+}
+
+func (s *contextSpyStorage) Update(ctx context.Context, task *models.Task) (*models.Task, error) {
+	s.seen = append(s.seen, ctx)
+	return s.TaskStorage.Update(ctx, task)
+}
+
+func (s *contextSpyStorage) Delete(ctx context.Context, id int) error {
+	s.seen = append(s.seen, ctx)
+	return s.TaskStorage.Delete(ctx, id)
+}
+
+// TestTaskHandler_PassesRequestContext tests that every handler hands the request context to storage
+func TestTaskHandler_PassesRequestContext(t *testing.T) {
+	spy := &contextSpyStorage{TaskStorage: storage.NewInMemoryStorage()}
+	handler := NewTaskHandler(spy)
+
+	withRequestContext := func(req *http.Request, id string) *http.Request {
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", id)
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+		ctx = context.WithValue(ctx, ctxKey{}, "request-scoped")
+		return req.WithContext(ctx)
+	}
+
+	requests := []struct {
+		name    string
+		handler http.HandlerFunc
+		req     *http.Request
+	}{
+		{"create", handler.CreateTask, httptest.NewRequest(http.MethodPost, "/tasks", bytes.NewBufferString(`{"name":"Task","status":0}`))},
+		{"get all", handler.GetAllTasks, httptest.NewRequest(http.MethodGet, "/tasks", nil)},
+		{"update", handler.UpdateTask, httptest.NewRequest(http.MethodPut, "/tasks/1", bytes.NewBufferString(`{"name":"Task","status":1}`))},
+		{"delete", handler.DeleteTask, httptest.NewRequest(http.MethodDelete, "/tasks/1", nil)},
+	}
+
+	for _, rr := range requests {
+		w := httptest.NewRecorder()
+		rr.handler(w, withRequestContext(rr.req, "1"))
+		if w.Code >= http.StatusBadRequest {
+			t.Fatalf("%s: unexpected status %d", rr.name, w.Code)
+		}
+	}
+
+	if len(spy.seen) == 0 {
+		t.Fatal("Expected storage to be called")
+	}
+	for i, ctx := range spy.seen {
+		if ctx.Value(ctxKey{}) != "request-scoped" {
+			t.Errorf("Storage call %d did not receive the request context", i)
+		}
+	}
+}
+
+// TestTaskHandler_CanceledRequest tests that a canceled request does not reach storage as a success
+func TestTaskHandler_CanceledRequest(t *testing.T) {
+	handler := setupTestHandler()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	body := bytes.NewBufferString(`{"name":"Task","status":0}`)
+	req := httptest.NewRequest(http.MethodPost, "/tasks", body).WithContext(ctx)
+	w := httptest.NewRecorder()
+
+	handler.CreateTask(w, req)
+
+	if w.Code == http.StatusCreated {
+		t.Error("Expected canceled request not to create a task")
+	}
+	tasks, _ := handler.storage.GetAll(context.Background())
+	if len(tasks) != 0 {
+		t.Errorf("Expected no tasks after canceled request, got %d", len(tasks))
+	}
+}
+
+// newUpdateRequest builds a PUT /tasks/{id} request with the chi URL parameter set
+func newUpdateRequest(taskID string, body interface{}) *http.Request {
+	data, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPut, "/tasks/"+taskID, bytes.NewBuffer(data))
+	req.Header.Set("Content-Type", "application/json")
+
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", taskID)
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+}
+
+// TestTaskHandler_UpdateTask_Versioning tests ETag, If-Match and body version handling
+func TestTaskHandler_UpdateTask_Versioning(t *testing.T) {
 	tests := []struct {
-		name           string
-		taskID         string
-		expectedStatus int
-		expectedError  string
+		name            string
+		ifMatch         string
+		body            map[string]interface{}
+		expectedStatus  int
+		expectedETag    string
+		expectedVersion int
 	}{
 		{
-			name:           "storage GetByID fails",
+			name:            "no precondition",
+			body:            map[string]interface{}{"name": "Updated", "status": 1},
+			expectedStatus:  http.StatusOK,
+			expectedETag:    `"2"`,
+			expectedVersion: 2,
+		},
+		{
+			name:            "matching If-Match",
+			ifMatch:         `"1"`,
+			body:            map[string]interface{}{"name": "Updated", "status": 1},
+			expectedStatus:  http.StatusOK,
+			expectedETag:    `"2"`,
+			expectedVersion: 2,
+		},
+		{
+			name:           "stale If-Match",
+			ifMatch:        `"7"`,
+			body:           map[string]interface{}{"name": "Updated", "status": 1},
+			expectedStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:            "matching body version",
+			body:            map[string]interface{}{"name": "Updated", "status": 1, "version": 1},
+			expectedStatus:  http.StatusOK,
+			expectedETag:    `"2"`,
+			expectedVersion: 2,
+		},
+		{
+			name:           "stale body version",
+			body:           map[string]interface{}{"name": "Updated", "status": 1, "version": 7},
+			expectedStatus: http.StatusConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := setupTestHandler()
+			task, _ := models.NewTask("Original Task", 0)
+			if _, err := handler.storage.Create(context.Background(), task); err != nil {
+				t.Fatalf("Failed to create task: %v", err)
+			}
+
+			req := newUpdateRequest("1", tt.body)
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			w := httptest.NewRecorder()
+
+			handler.UpdateTask(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				// A rejected update must leave the stored task untouched
+				stored, _ := handler.storage.GetByID(context.Background(), 1)
+				if stored.Version != 1 || stored.Name != "Original Task" {
+					t.Errorf("Expected task to be unchanged, got %+v", stored)
+				}
+				return
+			}
+
+			if etag := w.Header().Get("ETag"); etag != tt.expectedETag {
+				t.Errorf("Expected ETag %s, got %s", tt.expectedETag, etag)
+			}
+			var updated models.Task
+			if err := json.NewDecoder(w.Body).Decode(&updated); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if updated.Version != tt.expectedVersion {
+				t.Errorf("Expected version %d, got %d", tt.expectedVersion, updated.Version)
+			}
+		})
+	}
+}
+
+// TestTaskHandler_UpdateTask_LostUpdate tests that a second writer holding the old ETag is rejected
+func TestTaskHandler_UpdateTask_LostUpdate(t *testing.T) {
+	handler := setupTestHandler()
+	task, _ := models.NewTask("Original Task", 0)
+	created, _ := handler.storage.Create(context.Background(), task)
+	etag := taskETag(created)
+
+	first := newUpdateRequest("1", map[string]interface{}{"name": "First", "status": 1})
+	first.Header.Set("If-Match", etag)
+	w := httptest.NewRecorder()
+	handler.UpdateTask(w, first)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected first update to succeed, got %d", w.Code)
+	}
+
+	second := newUpdateRequest("1", map[string]interface{}{"name": "Second", "status": 2})
+	second.Header.Set("If-Match", etag)
+	w = httptest.NewRecorder()
+	handler.UpdateTask(w, second)
+	if w.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected status 412 for stale ETag, got %d", w.Code)
+	}
+
+	stored, _ := handler.storage.GetByID(context.Background(), 1)
+	if stored.Name != "First" {
+		t.Errorf("Expected first update to survive, got name %q", stored.Name)
+	}
+}
+
+// TestTaskHandler_CreateTask_ETag tests that a created task carries its ETag
+func TestTaskHandler_CreateTask_ETag(t *testing.T) {
+	handler := setupTestHandler()
+
+	body, _ := json.Marshal(map[string]interface{}{"name": "New Task", "status": 0})
+	req := httptest.NewRequest(http.MethodPost, "/tasks", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	handler.CreateTask(w, req)
+
+	if etag := w.Header().Get("ETag"); etag != `"1"` {
+		t.Errorf("Expected ETag \"1\", got %s", etag)
+	}
+}
+
+// TestTaskHandler_LastModified tests that single-task responses carry the update
+// time as Last-Modified and that GET revalidates with If-Modified-Since
+func TestTaskHandler_LastModified(t *testing.T) {
+	c := clock.NewManual(time.Date(2030, 6, 1, 12, 0, 0, 250000000, time.UTC))
+	handler := NewTaskHandler(storage.NewInMemoryStorage(storage.WithClock(c)))
+	created := c.Now().Format(http.TimeFormat)
+
+	body, _ := json.Marshal(map[string]interface{}{"name": "New Task", "status": 0})
+	w := httptest.NewRecorder()
+	handler.CreateTask(w, httptest.NewRequest(http.MethodPost, "/tasks", bytes.NewBuffer(body)))
+	if got := w.Header().Get("Last-Modified"); got != created {
+		t.Errorf("Expected Last-Modified %q on create, got %q", created, got)
+	}
+
+	get := func(ifModifiedSince string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/tasks/1", nil)
+		req.Header.Set("If-Modified-Since", ifModifiedSince)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", "1")
+		w := httptest.NewRecorder()
+		handler.GetTask(w, req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx)))
+		return w
+	}
+
+	if w := get(created); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("Expected 304 with empty body for an unchanged task, got %d %q", w.Code, w.Body.String())
+	}
+
+	c.Advance(time.Hour)
+	updated := c.Now().Format(http.TimeFormat)
+	w = httptest.NewRecorder()
+	handler.UpdateTask(w, newUpdateRequest("1", map[string]interface{}{"name": "Changed", "status": 1}))
+	if got := w.Header().Get("Last-Modified"); got != updated {
+		t.Errorf("Expected Last-Modified %q on update, got %q", updated, got)
+	}
+
+	w = get(created)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 for a changed task, got %d", w.Code)
+	}
+	if got := w.Header().Get("Last-Modified"); got != updated {
+		t.Errorf("Expected Last-Modified %q, got %q", updated, got)
+	}
+}
+
+// TestTaskHandler_GetAllTasks_NotModified tests revalidating the task list with If-None-Match
+func TestTaskHandler_GetAllTasks_NotModified(t *testing.T) {
+	handler := setupTestHandler()
+	task, _ := models.NewTask("Task", 0)
+	handler.storage.Create(context.Background(), task)
+
+	w := httptest.NewRecorder()
+	handler.GetAllTasks(w, httptest.NewRequest(http.MethodGet, "/tasks", nil))
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("Expected ETag on task list")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/tasks", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	handler.GetAllTasks(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Expected status 304, got %d", w.Code)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("Expected empty body for 304, got %q", w.Body.String())
+	}
+
+	// Any change to the list produces a new ETag
+	task2, _ := models.NewTask("Another", 0)
+	handler.storage.Create(context.Background(), task2)
+	w = httptest.NewRecorder()
+	handler.GetAllTasks(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 after change, got %d", w.Code)
+	}
+}
+
+// TestTaskHandler_ConcurrentRequests tests that updates and reads of the same task
+// can run in parallel. UpdateTask modifies the task it fetched before saving it,
+// which must never be visible to concurrent readers; run with -race to verify.
+func TestTaskHandler_ConcurrentRequests(t *testing.T) {
+	handler := setupTestHandler()
+	task, _ := models.NewTask("Shared Task", 0)
+	handler.storage.Create(context.Background(), task)
+
+	const workers = 4
+	const iterations = 25
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(2)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				rec := httptest.NewRecorder()
+				handler.GetAllTasks(rec, httptest.NewRequest(http.MethodGet, "/tasks", nil))
+				if rec.Code != http.StatusOK {
+					t.Errorf("Expected status 200 from GET, got %d", rec.Code)
+				}
+			}
+		}(w)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				req := newUpdateRequest("1", map[string]interface{}{"name": fmt.Sprintf("Worker %d update %d", w, i), "status": i % 2})
+				rec := httptest.NewRecorder()
+				handler.UpdateTask(rec, req)
+				// Losing the race to another writer is expected
+				if rec.Code != http.StatusOK && rec.Code != http.StatusConflict {
+					t.Errorf("Expected status 200 or 409 from PUT, got %d", rec.Code)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+}
+
+// newPatchRequest builds a PATCH /tasks/{id} request with the chi URL parameter set
+func newPatchRequest(taskID, contentType, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPatch, "/tasks/"+taskID, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", contentType)
+
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", taskID)
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+}
+
+// TestTaskHandler_PatchTask tests partial updates with merge patch and JSON patch
+func TestTaskHandler_PatchTask(t *testing.T) {
+	const mergePatch = "application/merge-patch+json"
+	const jsonPatch = "application/json-patch+json"
+
+	tests := []struct {
+		name               string
+		taskID             string
+		contentType        string
+		body               string
+		expectedStatus     int
+		expectedName       string
+		expectedTaskStatus int
+	}{
+		{
+			name:               "merge patch status only keeps name",
+			taskID:             "1",
+			contentType:        mergePatch,
+			body:               `{"status": 1}`,
+			expectedStatus:     http.StatusOK,
+			expectedName:       "Original Task",
+			expectedTaskStatus: 1,
+		},
+		{
+			name:               "merge patch name only keeps status",
+			taskID:             "1",
+			contentType:        mergePatch + "; charset=utf-8",
+			body:               `{"name": "Renamed"}`,
+			expectedStatus:     http.StatusOK,
+			expectedName:       "Renamed",
+			expectedTaskStatus: 1,
+		},
+		{
+			name:               "json patch replace",
+			taskID:             "1",
+			contentType:        jsonPatch,
+			body:               `[{"op": "replace", "path": "/status", "value": 0}]`,
+			expectedStatus:     http.StatusOK,
+			expectedName:       "Original Task",
+			expectedTaskStatus: 0,
+		},
+		{
+			name:               "json patch with passing version test",
+			taskID:             "1",
+			contentType:        jsonPatch,
+			body:               `[{"op": "test", "path": "/version", "value": 1}, {"op": "replace", "path": "/name", "value": "Tested"}]`,
+			expectedStatus:     http.StatusOK,
+			expectedName:       "Tested",
+			expectedTaskStatus: 1,
+		},
+		{
+			name:           "json patch with failing version test",
 			taskID:         "1",
-			expectedStatus: http.StatusNotFound,
-			expectedError:  "Task not found",
+			contentType:    jsonPatch,
+			body:           `[{"op": "test", "path": "/version", "value": 5}, {"op": "replace", "path": "/name", "value": "Tested"}]`,
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "merge patch with stale version",
+			taskID:         "1",
+			contentType:    mergePatch,
+			body:           `{"name": "Stale", "version": 5}`,
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "merge patch removing name fails validation",
+			taskID:         "1",
+			contentType:    mergePatch,
+			body:           `{"name": null}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid status",
+			taskID:         "1",
+			contentType:    mergePatch,
+			body:           `{"status": 7}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "changing the ID",
+			taskID:         "1",
+			contentType:    jsonPatch,
+			body:           `[{"op": "replace", "path": "/id", "value": 2}]`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "wrong type for name",
+			taskID:         "1",
+			contentType:    mergePatch,
+			body:           `{"name": 42}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "json patch on missing path",
+			taskID:         "1",
+			contentType:    jsonPatch,
+			body:           `[{"op": "remove", "path": "/missing"}]`,
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "malformed json patch",
+			taskID:         "1",
+			contentType:    jsonPatch,
+			body:           `{"op": "remove"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "malformed merge patch",
+			taskID:         "1",
+			contentType:    mergePatch,
+			body:           `{invalid`,
+			expectedStatus: http.StatusBadRequest,
 		},
 		{
-			name:           "storage Delete fails after successful GetByID",
+			name:           "unsupported content type",
 			taskID:         "1",
-			expectedStatus: http.StatusNotFound, // GetByID fails first
-			expectedError:  "Task not found",
+			contentType:    "application/json",
+			body:           `{"status": 1}`,
+			expectedStatus: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:           "non-existent task",
+			taskID:         "999",
+			contentType:    mergePatch,
+			body:           `{"status": 1}`,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "invalid task ID",
+			taskID:         "abc",
+			contentType:    mergePatch,
+			body:           `{"status": 1}`,
+			expectedStatus: http.StatusBadRequest,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			req := httptest.NewRequest(http.MethodDelete, "/tasks/"+tt.taskID, nil)
+			handler := setupTestHandler()
+			task, _ := models.NewTask("Original Task", 1)
+			if _, err := handler.storage.Create(context.Background(), task); err != nil {
+				t.Fatalf("Failed to create task: %v", err)
+			}
 
-			// Add chi URL parameter to route context
+			w := httptest.NewRecorder()
+			handler.PatchTask(w, newPatchRequest(tt.taskID, tt.contentType, tt.body))
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+
+			stored, _ := handler.storage.GetByID(context.Background(), 1)
+			if tt.expectedStatus != http.StatusOK {
+				// A rejected patch must leave the stored task untouched
+				if stored.Version != 1 || stored.Name != "Original Task" || stored.Status != 1 {
+					t.Errorf("Expected task to be unchanged, got %+v", stored)
+				}
+				return
+			}
+
+			if stored.Name != tt.expectedName || stored.Status != tt.expectedTaskStatus {
+				t.Errorf("Expected {%s %d}, got {%s %d}", tt.expectedName, tt.expectedTaskStatus, stored.Name, stored.Status)
+			}
+			if etag := w.Header().Get("ETag"); etag != `"2"` {
+				t.Errorf("Expected ETag \"2\", got %s", etag)
+			}
+		})
+	}
+}
+
+// TestTaskHandler_PatchTask_IfMatch tests that PATCH honors If-Match
+func TestTaskHandler_PatchTask_IfMatch(t *testing.T) {
+	handler := setupTestHandler()
+	task, _ := models.NewTask("Original Task", 0)
+	handler.storage.Create(context.Background(), task)
+
+	req := newPatchRequest("1", "application/merge-patch+json", `{"status": 1}`)
+	req.Header.Set("If-Match", `"3"`)
+	w := httptest.NewRecorder()
+	handler.PatchTask(w, req)
+	if w.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected status 412, got %d", w.Code)
+	}
+
+	req = newPatchRequest("1", "application/merge-patch+json", `{"status": 1}`)
+	req.Header.Set("If-Match", `"1"`)
+	w = httptest.NewRecorder()
+	handler.PatchTask(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+}
+
+// staleReadStorage serves the first version of every task, as if it was
+// updated right after being read
+type staleReadStorage struct {
+	storage.TaskStorage
+}
+
+func (s staleReadStorage) GetByID(ctx context.Context, id int) (*models.Task, error) {
+	task, err := s.TaskStorage.GetByID(ctx, id)
+	if err == nil {
+		task.Version = 1
+	}
+	return task, err
+}
+
+// TestTaskHandler_DeleteTask_Preconditions tests that DELETE honors If-Match
+// and If-Unmodified-Since, also against a change made after checking them
+func TestTaskHandler_DeleteTask_Preconditions(t *testing.T) {
+	earlier := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+
+	tests := []struct {
+		name           string
+		query          string
+		headers        map[string]string
+		staleRead      bool
+		expectedStatus int
+	}{
+		{name: "current If-Match", headers: map[string]string{"If-Match": `"2"`}, expectedStatus: http.StatusNoContent},
+		{name: "stale If-Match", headers: map[string]string{"If-Match": `"1"`}, expectedStatus: http.StatusPreconditionFailed},
+		{name: "stale If-Match with cascade", query: "?children=cascade", headers: map[string]string{"If-Match": `"1"`}, expectedStatus: http.StatusPreconditionFailed},
+		{name: "If-Unmodified-Since earlier", headers: map[string]string{"If-Unmodified-Since": earlier}, expectedStatus: http.StatusPreconditionFailed},
+		{name: "updated after the check", headers: map[string]string{"If-Match": `"1"`}, staleRead: true, expectedStatus: http.StatusPreconditionFailed},
+		{name: "updated after the check with cascade", query: "?children=cascade", headers: map[string]string{"If-Match": `"1"`}, staleRead: true, expectedStatus: http.StatusPreconditionFailed},
+		{name: "unconditional", expectedStatus: http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := storage.NewInMemoryStorage()
+			task, _ := models.NewTask("Guarded", 0)
+			created, _ := s.Create(context.Background(), task)
+			created.Name = "Changed by someone else"
+			if _, err := s.Update(context.Background(), created); err != nil {
+				t.Fatalf("Failed to update task: %v", err)
+			}
+			handler := NewTaskHandler(s)
+			if tt.staleRead {
+				handler = NewTaskHandler(staleReadStorage{s})
+			}
+
+			req := httptest.NewRequest(http.MethodDelete, "/tasks/1"+tt.query, nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
 			rctx := chi.NewRouteContext()
-			rctx.URLParams.Add("id", tt.taskID)
+			rctx.URLParams.Add("id", "1")
 			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
-
 			w := httptest.NewRecorder()
 
 			handler.DeleteTask(w, req)
 
 			if w.Code != tt.expectedStatus {
-				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
 			}
+			_, err := s.GetByID(context.Background(), 1)
+			if deleted := errors.Is(err, storage.ErrNotFound); deleted != (tt.expectedStatus == http.StatusNoContent) {
+				t.Errorf("Expected the task deleted only on success, got %v", err)
+			}
+		})
+	}
+}
 
-			var errorResponse map[string]interface{}
-			if err := json.NewDecoder(w.Body).Decode(&errorResponse); err != nil {
-				t.Fatalf("Failed to decode error response: %v", err)
+// TestTaskHandler_PatchTask_UnsupportedMediaType tests that the supported formats are advertised
+func TestTaskHandler_PatchTask_UnsupportedMediaType(t *testing.T) {
+	handler := setupTestHandler()
+
+	w := httptest.NewRecorder()
+	handler.PatchTask(w, newPatchRequest("1", "text/plain", "status=1"))
+
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("Expected status 415, got %d", w.Code)
+	}
+	if accept := w.Header().Get("Accept-Patch"); accept != "application/merge-patch+json, application/json-patch+json" {
+		t.Errorf("Unexpected Accept-Patch header %q", accept)
+	}
+}
+
+// TestTaskHandler_SearchTasks tests full-text search over tasks
+func TestTaskHandler_SearchTasks(t *testing.T) {
+	handler := setupTestHandler()
+	for _, name := range []string{"Buy milk", "Write report", "Write the report, write it well"} {
+		task, _ := models.NewTask(name, 0)
+		handler.storage.Create(context.Background(), task)
+	}
+
+	tests := []struct {
+		name           string
+		target         string
+		expectedStatus int
+		expectedIDs    []int
+	}{
+		{"ranked results", "/tasks/search?q=write+REPORT", http.StatusOK, []int{3, 2}},
+		{"limit", "/tasks/search?q=write&limit=1", http.StatusOK, []int{3}},
+		{"no match", "/tasks/search?q=groceries", http.StatusOK, []int{}},
+		{"missing query", "/tasks/search", http.StatusBadRequest, nil},
+		{"blank query", "/tasks/search?q=++", http.StatusBadRequest, nil},
+		{"invalid limit", "/tasks/search?q=milk&limit=500", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.SearchTasks(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var hits []struct {
+				ID    int     `json:"id"`
+				Name  string  `json:"name"`
+				Score float64 `json:"score"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&hits); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			ids := []int{}
+			for _, hit := range hits {
+				ids = append(ids, hit.ID)
+				if hit.Name == "" || hit.Score <= 0 {
+					t.Errorf("Expected task fields and a positive score, got %+v", hit)
+				}
+			}
+			if fmt.Sprint(ids) != fmt.Sprint(tt.expectedIDs) {
+				t.Errorf("Expected IDs %v, got %v", tt.expectedIDs, ids)
+			}
+		})
+	}
+}
+
+// TestTaskHandler_SearchTasks_StorageError tests that search failures are reported
+func TestTaskHandler_SearchTasks_StorageError(t *testing.T) {
+	handler := setupTestHandlerWithError(&storage.Error{Op: "search", Kind: storage.ErrUnavailable})
+
+	w := httptest.NewRecorder()
+	handler.SearchTasks(w, httptest.NewRequest(http.MethodGet, "/tasks/search?q=milk", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %d", w.Code)
+	}
+}
+
+// TestTaskHandler_ValidationProblem tests that invalid input is reported as a
+// problem document listing every invalid field
+func TestTaskHandler_ValidationProblem(t *testing.T) {
+	handler := setupTestHandler()
+	created := seedTasks(t, handler, "Existing")[0]
+
+	tests := []struct {
+		name string
+		req  *http.Request
+		call func(w http.ResponseWriter, r *http.Request)
+	}{
+		{
+			name: "create",
+			req:  httptest.NewRequest(http.MethodPost, "/tasks", bytes.NewBufferString(`{"name": " ", "status": 3}`)),
+			call: handler.CreateTask,
+		},
+		{
+			name: "update",
+			req:  newUpdateRequest(fmt.Sprint(created.ID), map[string]interface{}{"name": "", "status": -1}),
+			call: handler.UpdateTask,
+		},
+		{
+			name: "patch",
+			req:  newPatchRequest(fmt.Sprint(created.ID), "application/merge-patch+json", `{"name": "", "status": 9}`),
+			call: handler.PatchTask,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tt.call(w, tt.req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("Expected status 400, got %d", w.Code)
+			}
+			if contentType := w.Header().Get("Content-Type"); contentType != "application/problem+json" {
+				t.Errorf("Expected Content-Type application/problem+json, got %s", contentType)
 			}
 
-			if errorResponse["message"] != tt.expectedError {
-				t.Errorf("Expected error '%s', got %v", tt.expectedError, errorResponse["message"])
+			var body struct {
+				Type     string              `json:"type"`
+				Status   int                 `json:"status"`
+				Instance string              `json:"instance"`
+				Errors   []models.FieldError `json:"errors"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if body.Type != "/problems/validation-failed" || body.Status != http.StatusBadRequest {
+				t.Errorf("Expected a validation problem, got type %q status %d", body.Type, body.Status)
+			}
+			if body.Instance != tt.req.URL.Path {
+				t.Errorf("Expected instance %q, got %q", tt.req.URL.Path, body.Instance)
+			}
+			var fields []string
+			for _, fieldErr := range body.Errors {
+				fields = append(fields, fieldErr.Field+":"+fieldErr.Rule)
+			}
+			if expected := []string{"name:required", "status:oneof"}; fmt.Sprint(fields) != fmt.Sprint(expected) {
+				t.Errorf("Expected field errors %v, got %v", expected, fields)
 			}
 		})
 	}
 }
+
+// TestTaskHandler_TaskDetails tests creating tasks with details and how
+// updates, patches and older clients treat them
+func TestTaskHandler_TaskDetails(t *testing.T) {
+	handler := setupTestHandler()
+
+	// decodeTask decodes a task response, failing unless the status is 200 or 201
+	decodeTask := func(t *testing.T, w *httptest.ResponseRecorder) models.Task {
+		t.Helper()
+		if w.Code != http.StatusOK && w.Code != http.StatusCreated {
+			t.Fatalf("Expected success, got %d: %s", w.Code, w.Body.String())
+		}
+		var task models.Task
+		if err := json.NewDecoder(w.Body).Decode(&task); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return task
+	}
+
+	w := httptest.NewRecorder()
+	handler.CreateTask(w, httptest.NewRequest(http.MethodPost, "/tasks", bytes.NewBufferString(`{
+		"name": "Ship release", "status": 0, "description": "- tag\n- publish",
+		"due_at": "2030-01-02T10:00:00+02:00", "priority": 3, "tags": ["Release", "release", " ops "],
+		"assignee": " alice "
+	}`)))
+	created := decodeTask(t, w)
+	dueAt := time.Date(2030, 1, 2, 8, 0, 0, 0, time.UTC)
+	expected := models.Details{
+		Description: "- tag\n- publish", DueAt: &dueAt, Priority: models.PriorityHigh,
+		Tags: []string{"release", "ops"}, Assignee: "alice",
+	}
+	if !reflect.DeepEqual(created.Details, expected) {
+		t.Fatalf("Expected normalized details %+v, got %+v", expected, created.Details)
+	}
+
+	t.Run("put without details keeps them", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.UpdateTask(w, newUpdateRequest(fmt.Sprint(created.ID), map[string]interface{}{"name": "Ship it", "status": 1}))
+		updated := decodeTask(t, w)
+		if updated.Name != "Ship it" || !reflect.DeepEqual(updated.Details, expected) {
+			t.Errorf("Expected the details to be kept, got %+v", updated)
+		}
+	})
+
+	t.Run("put with null and empty values clears them", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.UpdateTask(w, newUpdateRequest(fmt.Sprint(created.ID), map[string]interface{}{
+			"name": "Ship it", "status": 1, "due_at": nil, "tags": []string{}, "assignee": "", "priority": 1,
+		}))
+		updated := decodeTask(t, w)
+		if updated.DueAt != nil || updated.Tags != nil || updated.Assignee != "" || updated.Priority != models.PriorityLow {
+			t.Errorf("Expected cleared details, got %+v", updated.Details)
+		}
+		if updated.Description != expected.Description {
+			t.Errorf("Expected the omitted description to be kept, got %q", updated.Description)
+		}
+	})
+
+	t.Run("merge patch", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.PatchTask(w, newPatchRequest(fmt.Sprint(created.ID), "application/merge-patch+json",
+			`{"description": null, "tags": ["docs"], "due_at": "2031-05-06T07:08:09Z"}`))
+		patched := decodeTask(t, w)
+		if patched.Description != "" || !reflect.DeepEqual(patched.Tags, []string{"docs"}) ||
+			patched.DueAt == nil || !patched.DueAt.Equal(time.Date(2031, 5, 6, 7, 8, 9, 0, time.UTC)) {
+			t.Errorf("Expected patched details, got %+v", patched.Details)
+		}
+	})
+
+	t.Run("task without details omits them", func(t *testing.T) {
+		task := seedTasks(t, handler, "Plain")[0]
+		var fields map[string]interface{}
+		if err := json.Unmarshal(mustMarshal(t, task), &fields); err != nil {
+			t.Fatalf("Failed to decode task: %v", err)
+		}
+		for _, key := range []string{"description", "due_at", "priority", "tags", "assignee", "completed_at"} {
+			if _, ok := fields[key]; ok {
+				t.Errorf("Expected %q to be omitted, got %v", key, fields)
+			}
+		}
+	})
+
+	t.Run("invalid details", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.CreateTask(w, httptest.NewRequest(http.MethodPost, "/tasks", bytes.NewBufferString(
+			`{"name": "Bad", "priority": 7, "tags": ["ok", "bad\u0000tag", ""]}`)))
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("Expected status 400, got %d", w.Code)
+		}
+		var body struct {
+			Errors []models.FieldError `json:"errors"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		var fields []string
+		for _, fieldErr := range body.Errors {
+			fields = append(fields, fieldErr.Field+":"+fieldErr.Rule)
+		}
+		if expected := []string{"priority:oneof", "tags[1]:nocontrol", "tags[2]:required"}; fmt.Sprint(fields) != fmt.Sprint(expected) {
+			t.Errorf("Expected field errors %v, got %v", expected, fields)
+		}
+	})
+}
+
+// TestTaskHandler_RecurringTask tests that completing a recurring task through
+// UpdateTask creates its next occurrence
+func TestTaskHandler_RecurringTask(t *testing.T) {
+	handler := setupTestHandler()
+
+	w := httptest.NewRecorder()
+	handler.CreateTask(w, httptest.NewRequest(http.MethodPost, "/tasks", bytes.NewBufferString(
+		`{"name": "Rotate logs", "due_at": "2030-01-07T09:00:00Z", "recurrence": "freq=weekly;byday=mo"}`)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var created models.Task
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if created.Recurrence != "FREQ=WEEKLY;BYDAY=MO" {
+		t.Errorf("Expected the canonical rule, got %q", created.Recurrence)
+	}
+
+	w = httptest.NewRecorder()
+	handler.UpdateTask(w, newUpdateRequest(fmt.Sprint(created.ID), map[string]interface{}{"name": "Rotate logs", "status": 1}))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var completed models.Task
+	if err := json.NewDecoder(w.Body).Decode(&completed); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if completed.Status != 1 || completed.Recurrence != "" {
+		t.Errorf("Expected a completed task without recurrence, got %+v", completed)
+	}
+
+	tasks, err := handler.storage.GetAll(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to retrieve tasks: %v", err)
+	}
+	if len(tasks) != 2 {
+		t.Fatalf("Expected the next occurrence to be created, got %d tasks", len(tasks))
+	}
+	next := tasks[1]
+	if next.Status != 0 || next.Recurrence != "FREQ=WEEKLY;BYDAY=MO" || next.DueAt == nil ||
+		!next.DueAt.After(time.Now()) || next.DueAt.Weekday() != time.Monday {
+		t.Errorf("Expected an open task due on a future Monday, got %+v", next)
+	}
+
+	w = httptest.NewRecorder()
+	handler.CreateTask(w, httptest.NewRequest(http.MethodPost, "/tasks", bytes.NewBufferString(
+		`{"name": "Yearly", "recurrence": "FREQ=YEARLY"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", w.Code)
+	}
+	var problem struct {
+		Errors []models.FieldError `json:"errors"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(problem.Errors) != 1 || problem.Errors[0].Field != "recurrence" || problem.Errors[0].Rule != models.RuleRRule {
+		t.Errorf("Expected an rrule error on recurrence, got %+v", problem.Errors)
+	}
+}
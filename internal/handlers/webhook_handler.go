@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"task-api/internal/events"
+	"task-api/internal/models"
+	"task-api/internal/webhooks"
+
+	"github.com/go-chi/chi/v5"
+)
+
+var (
+	ErrInvalidWebhook    = ErrorResponse{Type: "invalid-webhook", Message: "Invalid webhook", Code: http.StatusBadRequest}
+	ErrInvalidWebhookID  = ErrorResponse{Type: "invalid-webhook-id", Message: "Invalid webhook ID", Code: http.StatusBadRequest}
+	ErrWebhookNotFound   = ErrorResponse{Type: "webhook-not-found", Message: "Webhook not found", Code: http.StatusNotFound}
+	ErrInvalidDeliveryID = ErrorResponse{Type: "invalid-delivery-id", Message: "Invalid delivery ID", Code: http.StatusBadRequest}
+	ErrDeliveryNotFound  = ErrorResponse{Type: "delivery-not-found", Message: "Delivery not found", Code: http.StatusNotFound}
+	ErrDeliveryNotFailed = ErrorResponse{Type: "delivery-not-failed", Message: "Only failed deliveries can be redelivered", Code: http.StatusConflict}
+)
+
+// WebhookHandler manages the webhooks told about task changes.
+type WebhookHandler struct {
+	hooks *webhooks.Service
+}
+
+// NewWebhookHandler creates a handler managing the webhooks of hooks.
+func NewWebhookHandler(hooks *webhooks.Service) *WebhookHandler {
+	return &WebhookHandler{hooks: hooks}
+}
+
+// CreateWebhook handles POST /webhooks - register a URL for task events, all
+// of them unless events lists some. Responds with the webhook and its secret,
+// made up unless one is given, which is not shown again.
+func (h *WebhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		URL    string        `json:"url"`
+		Events []events.Type `json:"events"`
+		Secret string        `json:"secret"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeErrorResponse(w, r, ErrInvalidJSON)
+		return
+	}
+
+	webhook, err := h.hooks.Register(input.URL, input.Events, input.Secret)
+	if err != nil {
+		writeErrorResponse(w, r, webhookErrorResponse(err))
+		return
+	}
+
+	w.Header().Set("Location", "/webhooks/"+strconv.Itoa(webhook.ID))
+	if err := writeJSONResponse(w, webhook, http.StatusCreated); err != nil {
+		writeErrorResponse(w, r, ErrInternalServer)
+		return
+	}
+}
+
+// GetWebhooks handles GET /webhooks - list the webhooks in ID order.
+func (h *WebhookHandler) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	if err := writeJSONResponse(w, h.hooks.List(), http.StatusOK); err != nil {
+		writeErrorResponse(w, r, ErrInternalServer)
+		return
+	}
+}
+
+// GetWebhook handles GET /webhooks/{id} - get a webhook.
+func (h *WebhookHandler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeErrorResponse(w, r, ErrInvalidWebhookID)
+		return
+	}
+
+	webhook, err := h.hooks.Get(id)
+	if err != nil {
+		writeErrorResponse(w, r, webhookErrorResponse(err))
+		return
+	}
+
+	if err := writeJSONResponse(w, webhook, http.StatusOK); err != nil {
+		writeErrorResponse(w, r, ErrInternalServer)
+		return
+	}
+}
+
+// DeleteWebhook handles DELETE /webhooks/{id} - remove a webhook, dropping the
+// deliveries still waiting for it.
+func (h *WebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeErrorResponse(w, r, ErrInvalidWebhookID)
+		return
+	}
+
+	if err := h.hooks.Delete(id); err != nil {
+		writeErrorResponse(w, r, webhookErrorResponse(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetDeliveries handles GET /webhooks/{id}/deliveries - list the recent
+// deliveries to a webhook with their attempts, newest first.
+func (h *WebhookHandler) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeErrorResponse(w, r, ErrInvalidWebhookID)
+		return
+	}
+
+	deliveries, err := h.hooks.Deliveries(id)
+	if err != nil {
+		writeErrorResponse(w, r, webhookErrorResponse(err))
+		return
+	}
+
+	if err := writeJSONResponse(w, deliveries, http.StatusOK); err != nil {
+		writeErrorResponse(w, r, ErrInternalServer)
+		return
+	}
+}
+
+// GetDeadLetters handles GET /webhooks/dead-letters - list the deliveries that
+// failed every attempt, newest first.
+func (h *WebhookHandler) GetDeadLetters(w http.ResponseWriter, r *http.Request) {
+	if err := writeJSONResponse(w, h.hooks.DeadLetters(), http.StatusOK); err != nil {
+		writeErrorResponse(w, r, ErrInternalServer)
+		return
+	}
+}
+
+// Redeliver handles POST /webhooks/dead-letters/{id}/redeliver - queue the
+// event of a dead letter again. Responds with the new delivery.
+func (h *WebhookHandler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeErrorResponse(w, r, ErrInvalidDeliveryID)
+		return
+	}
+
+	delivery, err := h.hooks.Redeliver(id)
+	if err != nil {
+		writeErrorResponse(w, r, webhookErrorResponse(err))
+		return
+	}
+
+	if err := writeJSONResponse(w, delivery, http.StatusAccepted); err != nil {
+		writeErrorResponse(w, r, ErrInternalServer)
+		return
+	}
+}
+
+// webhookErrorResponse maps a webhooks error onto the matching API error,
+// keeping the original error for internal use
+func webhookErrorResponse(err error) ErrorResponse {
+	var response ErrorResponse
+	var validationErr *models.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		response = ErrInvalidWebhook.withDetail(err)
+		response.Fields = validationErr.Errors
+	case errors.Is(err, webhooks.ErrNotFound):
+		response = ErrWebhookNotFound
+	case errors.Is(err, webhooks.ErrDeliveryNotFound):
+		response = ErrDeliveryNotFound
+	case errors.Is(err, webhooks.ErrNotDeadLetter):
+		response = ErrDeliveryNotFailed
+	default:
+		response = ErrInternalServer
+	}
+	response.Err = err
+	return response
+}
@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"task-api/internal/clock"
+	"task-api/internal/events"
+	"task-api/internal/models"
+	"task-api/internal/storage"
+	"task-api/internal/webhooks"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// newWebhookRequest builds a request for target with the chi URL parameter id
+// set, unless it is empty
+func newWebhookRequest(method, target, id, body string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	rctx := chi.NewRouteContext()
+	if id != "" {
+		rctx.URLParams.Add("id", id)
+	}
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+}
+
+// decodeProblemType reads the type of a problem document
+func decodeProblemType(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+
+	var problem struct {
+		Type string `json:"type"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return problem.Type
+}
+
+// TestWebhookHandler_CreateWebhook tests registering webhooks and rejecting invalid ones
+func TestWebhookHandler_CreateWebhook(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedType   string
+		expectedField  string
+	}{
+		{name: "every event", body: `{"url": "https://example.com/hook"}`, expectedStatus: http.StatusCreated},
+		{name: "some events", body: `{"url": "https://example.com/hook", "events": ["task.deleted"], "secret": "s3cret"}`, expectedStatus: http.StatusCreated},
+		{name: "invalid JSON", body: `{"url": 1}`, expectedStatus: http.StatusBadRequest, expectedType: "/problems/invalid-json"},
+		{name: "missing url", body: `{}`, expectedStatus: http.StatusBadRequest, expectedType: "/problems/invalid-webhook", expectedField: "url"},
+		{name: "unknown event", body: `{"url": "https://example.com/hook", "events": ["task.moved"]}`, expectedStatus: http.StatusBadRequest, expectedType: "/problems/invalid-webhook", expectedField: "events"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewWebhookHandler(webhooks.New(events.NewBus(0), clock.System(), webhooks.DefaultConfig, nil))
+			w := httptest.NewRecorder()
+
+			handler.CreateWebhook(w, newWebhookRequest(http.MethodPost, "/webhooks", "", tt.body))
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedType != "" {
+				var problem struct {
+					Type   string              `json:"type"`
+					Errors []models.FieldError `json:"errors"`
+				}
+				if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+					t.Fatalf("Failed to decode response: %v", err)
+				}
+				if problem.Type != tt.expectedType {
+					t.Errorf("Expected problem type %s, got %s", tt.expectedType, problem.Type)
+				}
+				if tt.expectedField != "" && (len(problem.Errors) != 1 || problem.Errors[0].Field != tt.expectedField) {
+					t.Errorf("Expected an error on %s, got %+v", tt.expectedField, problem.Errors)
+				}
+				return
+			}
+
+			var webhook webhooks.Webhook
+			if err := json.NewDecoder(w.Body).Decode(&webhook); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if webhook.ID != 1 || webhook.Secret == "" || w.Header().Get("Location") != "/webhooks/1" {
+				t.Errorf("Expected webhook 1 with its secret and location, got %+v (%s)", webhook, w.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+// TestWebhookHandler_Webhooks tests listing, getting and deleting webhooks
+func TestWebhookHandler_Webhooks(t *testing.T) {
+	hooks := webhooks.New(events.NewBus(0), clock.System(), webhooks.DefaultConfig, nil)
+	handler := NewWebhookHandler(hooks)
+	webhook, _ := hooks.Register("https://example.com/hook", nil, "s3cret")
+
+	w := httptest.NewRecorder()
+	handler.GetWebhooks(w, newWebhookRequest(http.MethodGet, "/webhooks", "", ""))
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), "s3cret") {
+		t.Fatalf("Expected the webhooks without secrets, got %d: %s", w.Code, w.Body.String())
+	}
+	var list []webhooks.Webhook
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil || len(list) != 1 || list[0].ID != webhook.ID {
+		t.Fatalf("Expected the webhook listed, got %+v (%v)", list, err)
+	}
+
+	tests := []struct {
+		name           string
+		handle         http.HandlerFunc
+		method         string
+		id             string
+		expectedStatus int
+		expectedType   string
+	}{
+		{name: "get", handle: handler.GetWebhook, method: http.MethodGet, id: "1", expectedStatus: http.StatusOK},
+		{name: "deliveries", handle: handler.GetDeliveries, method: http.MethodGet, id: "1", expectedStatus: http.StatusOK},
+		{name: "invalid ID", handle: handler.GetWebhook, method: http.MethodGet, id: "one", expectedStatus: http.StatusBadRequest, expectedType: "/problems/invalid-webhook-id"},
+		{name: "delete", handle: handler.DeleteWebhook, method: http.MethodDelete, id: "1", expectedStatus: http.StatusNoContent},
+		{name: "get deleted", handle: handler.GetWebhook, method: http.MethodGet, id: "1", expectedStatus: http.StatusNotFound, expectedType: "/problems/webhook-not-found"},
+		{name: "delete again", handle: handler.DeleteWebhook, method: http.MethodDelete, id: "1", expectedStatus: http.StatusNotFound, expectedType: "/problems/webhook-not-found"},
+		{name: "deliveries of deleted", handle: handler.GetDeliveries, method: http.MethodGet, id: "1", expectedStatus: http.StatusNotFound, expectedType: "/problems/webhook-not-found"},
+	}
+
+	// In order: the later cases see the webhook deleted
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tt.handle(w, newWebhookRequest(tt.method, "/webhooks/"+tt.id, tt.id, ""))
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedType != "" {
+				if problemType := decodeProblemType(t, w); problemType != tt.expectedType {
+					t.Errorf("Expected problem type %s, got %s", tt.expectedType, problemType)
+				}
+			}
+		})
+	}
+}
+
+// TestWebhookHandler_Redeliver tests listing dead letters and redelivering them
+func TestWebhookHandler_Redeliver(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusInternalServerError)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer receiver.Close()
+
+	bus := events.NewBus(events.DefaultReplaySize)
+	config := webhooks.Config{MaxAttempts: 1, Backoff: time.Millisecond, MaxBackoff: time.Millisecond, Timeout: time.Second, Workers: 1, AllowPrivate: true}
+	hooks := webhooks.New(bus, clock.System(), config, nil)
+	handler := NewWebhookHandler(hooks)
+	webhook, _ := hooks.Register(receiver.URL, nil, "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		hooks.Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// Run subscribes in the background, so publish until a delivery shows up
+	// and wait for the attempt to fail
+	var failed webhooks.Delivery
+	waitForDelivery(t, func(deliveries []webhooks.Delivery) bool {
+		if len(deliveries) == 0 {
+			bus.Publish([]storage.Change{{Kind: storage.ChangeDeleted, ID: 1}})
+		}
+		i := slices.IndexFunc(deliveries, func(d webhooks.Delivery) bool { return d.Status == webhooks.DeliveryFailed })
+		if i < 0 {
+			return false
+		}
+		failed = deliveries[i]
+		return true
+	}, hooks, webhook.ID)
+
+	w := httptest.NewRecorder()
+	handler.GetDeadLetters(w, newWebhookRequest(http.MethodGet, "/webhooks/dead-letters", "", ""))
+	var deadLetters []webhooks.Delivery
+	if err := json.NewDecoder(w.Body).Decode(&deadLetters); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !slices.ContainsFunc(deadLetters, func(d webhooks.Delivery) bool { return d.ID == failed.ID }) {
+		t.Fatalf("Expected delivery %d among the dead letters, got %+v", failed.ID, deadLetters)
+	}
+
+	status.Store(http.StatusOK)
+	failedID := strconv.Itoa(failed.ID)
+	tests := []struct {
+		name           string
+		id             string
+		expectedStatus int
+		expectedType   string
+	}{
+		{name: "dead letter", id: failedID, expectedStatus: http.StatusAccepted},
+		{name: "redelivered already", id: failedID, expectedStatus: http.StatusConflict, expectedType: "/problems/delivery-not-failed"},
+		{name: "unknown", id: "999", expectedStatus: http.StatusNotFound, expectedType: "/problems/delivery-not-found"},
+		{name: "invalid ID", id: "one", expectedStatus: http.StatusBadRequest, expectedType: "/problems/invalid-delivery-id"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.Redeliver(w, newWebhookRequest(http.MethodPost, "/webhooks/dead-letters/"+tt.id+"/redeliver", tt.id, ""))
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedType != "" {
+				if problemType := decodeProblemType(t, w); problemType != tt.expectedType {
+					t.Errorf("Expected problem type %s, got %s", tt.expectedType, problemType)
+				}
+			}
+		})
+	}
+
+	// The redelivery reaches the webhook, which answers again
+	waitForDelivery(t, func(deliveries []webhooks.Delivery) bool {
+		return slices.ContainsFunc(deliveries, func(d webhooks.Delivery) bool {
+			return d.ID != failed.ID && d.EventID == failed.EventID && d.Status == webhooks.DeliveryDelivered
+		})
+	}, hooks, webhook.ID)
+}
+
+// waitForDelivery polls the deliveries of a webhook until done accepts them,
+// failing the test after a while
+func waitForDelivery(t *testing.T, done func([]webhooks.Delivery) bool, hooks *webhooks.Service, webhookID int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		deliveries, err := hooks.Deliveries(webhookID)
+		if err != nil {
+			t.Fatalf("Failed to list deliveries: %v", err)
+		}
+		if done(deliveries) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for deliveries, got %+v", deliveries)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
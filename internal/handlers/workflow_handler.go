@@ -0,0 +1,15 @@
+package handlers
+
+import (
+	"net/http"
+	"task-api/internal/models"
+)
+
+// GetWorkflow handles GET /workflow - list the workflow states tasks move
+// through, which of them count as done and the transitions allowed between them.
+func (h *TaskHandler) GetWorkflow(w http.ResponseWriter, r *http.Request) {
+	if err := writeCacheableJSON(w, r, models.DefaultWorkflow(), http.StatusOK); err != nil {
+		writeErrorResponse(w, r, ErrInternalServer)
+		return
+	}
+}
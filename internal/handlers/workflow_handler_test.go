@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"task-api/internal/models"
+	"testing"
+)
+
+// useReviewWorkflow configures a todo → in_progress → review → done workflow
+// for the duration of the test
+func useReviewWorkflow(t *testing.T) {
+	t.Helper()
+
+	workflow, err := models.ParseWorkflow([]byte(`{
+		"initial": "todo",
+		"states": [
+			{"name": "todo", "transitions": ["in_progress"]},
+			{"name": "in_progress", "transitions": ["todo", "review"]},
+			{"name": "review", "transitions": ["in_progress", "done"]},
+			{"name": "done", "done": true}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("Failed to parse workflow: %v", err)
+	}
+	t.Cleanup(func() { models.SetWorkflow(models.BasicWorkflow()) })
+	models.SetWorkflow(workflow)
+}
+
+// TestTaskHandler_GetWorkflow tests listing the configured states
+func TestTaskHandler_GetWorkflow(t *testing.T) {
+	useReviewWorkflow(t)
+	handler := setupTestHandler()
+
+	w := httptest.NewRecorder()
+	handler.GetWorkflow(w, httptest.NewRequest(http.MethodGet, "/workflow", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if w.Header().Get("ETag") == "" {
+		t.Error("Expected an ETag on the workflow")
+	}
+	var body struct {
+		Initial string         `json:"initial"`
+		States  []models.State `json:"states"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if body.Initial != "todo" || len(body.States) != 4 || body.States[3].Name != "done" || !body.States[3].Done {
+		t.Errorf("Unexpected workflow %+v", body)
+	}
+}
+
+// TestTaskHandler_Workflow tests moving tasks through the workflow by state and
+// by legacy status, and that illegal transitions are rejected
+func TestTaskHandler_Workflow(t *testing.T) {
+	useReviewWorkflow(t)
+	handler := setupTestHandler()
+
+	decode := func(t *testing.T, w *httptest.ResponseRecorder) models.Task {
+		t.Helper()
+		if w.Code >= http.StatusBadRequest {
+			t.Fatalf("Unexpected status %d: %s", w.Code, w.Body.String())
+		}
+		var task models.Task
+		if err := json.NewDecoder(w.Body).Decode(&task); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return task
+	}
+
+	t.Run("create in a state", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.CreateTask(w, httptest.NewRequest(http.MethodPost, "/tasks",
+			bytes.NewBufferString(`{"name": "Started", "state": "in_progress"}`)))
+		if task := decode(t, w); task.State != "in_progress" || task.Status != 0 {
+			t.Errorf("Expected in_progress with status 0, got %q with status %d", task.State, task.Status)
+		}
+	})
+
+	t.Run("legacy client", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.CreateTask(w, httptest.NewRequest(http.MethodPost, "/tasks",
+			bytes.NewBufferString(`{"name": "Legacy", "status": 0}`)))
+		created := decode(t, w)
+		if created.State != "todo" {
+			t.Errorf("Expected the initial state, got %q", created.State)
+		}
+
+		// Completing from todo is not an allowed transition in this workflow
+		w = httptest.NewRecorder()
+		handler.UpdateTask(w, newUpdateRequest(fmt.Sprint(created.ID), map[string]interface{}{"name": "Legacy", "status": 1}))
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("Expected status 400, got %d", w.Code)
+		}
+		var problem struct {
+			Errors []models.FieldError `json:"errors"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if len(problem.Errors) != 1 || problem.Errors[0].Field != "state" || problem.Errors[0].Rule != models.RuleTransition {
+			t.Errorf("Expected a transition error on state, got %+v", problem.Errors)
+		}
+	})
+
+	t.Run("update and patch the state", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.CreateTask(w, httptest.NewRequest(http.MethodPost, "/tasks",
+			bytes.NewBufferString(`{"name": "Feature", "state": "in_progress"}`)))
+		id := fmt.Sprint(decode(t, w).ID)
+
+		// The state wins over a status sent along with it
+		w = httptest.NewRecorder()
+		handler.UpdateTask(w, newUpdateRequest(id, map[string]interface{}{"name": "Feature", "status": 1, "state": "review"}))
+		if task := decode(t, w); task.State != "review" || task.Status != 0 {
+			t.Errorf("Expected review with status 0, got %q with status %d", task.State, task.Status)
+		}
+
+		// A legacy status patch completes the reviewed task
+		w = httptest.NewRecorder()
+		handler.PatchTask(w, newPatchRequest(id, "application/merge-patch+json", `{"status": 1}`))
+		if task := decode(t, w); task.State != "done" || task.Status != 1 || task.CompletedAt == nil {
+			t.Errorf("Expected a completed task in done, got %q with status %d", task.State, task.Status)
+		}
+
+		w = httptest.NewRecorder()
+		handler.PatchTask(w, newPatchRequest(id, "application/merge-patch+json", `{"state": "in_progress"}`))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected leaving done to be rejected with 400, got %d", w.Code)
+		}
+	})
+}
@@ -0,0 +1,107 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// CheckBlockers reports a *ValidationError if the task is completed while any
+// of blockers, the tasks listed in its BlockedBy, is not. Only storage knows the
+// blocking tasks, so it makes this check on top of the validation of the task
+// itself.
+func (t *Task) CheckBlockers(blockers []*Task) error {
+	if t.Status != 1 {
+		return nil
+	}
+	var open []string
+	for _, blocker := range blockers {
+		if blocker.Status != 1 {
+			open = append(open, strconv.Itoa(blocker.ID))
+		}
+	}
+	if len(open) == 0 {
+		return nil
+	}
+	return &ValidationError{Errors: []FieldError{{
+		Field:   "status",
+		Rule:    RuleBlocked,
+		Message: "task cannot be completed while blocked by incomplete tasks " + strings.Join(open, ", "),
+	}}}
+}
+
+// Plan is an order in which a set of tasks can be worked on.
+type Plan struct {
+	Order        []*Task `json:"order"`         // Every task after the tasks blocking it; ties go to the lowest ID
+	CriticalPath []int   `json:"critical_path"` // IDs of the longest chain of tasks blocking one another, first to last
+}
+
+// PlanExecution orders tasks so that every task comes after the tasks of the
+// set blocking it, taking blockers outside the set as done. Each task counts
+// as one step, so the critical path is the longest chain of tasks that have to
+// be done one after the other. It returns an error if the tasks block each
+// other in a cycle, which storage never allows.
+func PlanExecution(tasks []*Task) (*Plan, error) {
+	byID := make(map[int]*Task, len(tasks))
+	for _, task := range tasks {
+		byID[task.ID] = task
+	}
+
+	// Kahn's algorithm, always taking the ready task with the lowest ID
+	waiting := make(map[int]int, len(tasks))
+	unblocks := make(map[int][]int)
+	var ready []int
+	for _, task := range tasks {
+		for _, blocker := range task.BlockedBy {
+			if _, ok := byID[blocker]; ok {
+				waiting[task.ID]++
+				unblocks[blocker] = append(unblocks[blocker], task.ID)
+			}
+		}
+		if waiting[task.ID] == 0 {
+			ready = append(ready, task.ID)
+		}
+	}
+	slices.Sort(ready)
+
+	plan := &Plan{Order: make([]*Task, 0, len(tasks)), CriticalPath: []int{}}
+	length := make(map[int]int, len(tasks)) // Tasks in the longest chain ending at a task
+	previous := make(map[int]int, len(tasks))
+	last := 0
+	for len(ready) > 0 {
+		id := ready[0]
+		ready = ready[1:]
+		plan.Order = append(plan.Order, byID[id])
+
+		length[id]++
+		if length[id] > length[last] {
+			last = id
+		}
+		for _, next := range unblocks[id] {
+			if length[id] > length[next] {
+				length[next], previous[next] = length[id], id
+			}
+			if waiting[next]--; waiting[next] == 0 {
+				i, _ := slices.BinarySearch(ready, next)
+				ready = slices.Insert(ready, i, next)
+			}
+		}
+	}
+	if len(plan.Order) < len(tasks) {
+		var cycle []int
+		for _, task := range tasks {
+			if waiting[task.ID] > 0 {
+				cycle = append(cycle, task.ID)
+			}
+		}
+		slices.Sort(cycle)
+		return nil, fmt.Errorf("tasks %v block each other in a cycle", cycle)
+	}
+
+	for id := last; id != 0; id = previous[id] {
+		plan.CriticalPath = append(plan.CriticalPath, id)
+	}
+	slices.Reverse(plan.CriticalPath)
+	return plan, nil
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// TestTask_CheckBlockers tests that only completing a task with incomplete blockers is rejected
+func TestTask_CheckBlockers(t *testing.T) {
+	done := &Task{ID: 1, Status: 1}
+	open := &Task{ID: 2, Status: 0}
+	tests := []struct {
+		name     string
+		status   int
+		blockers []*Task
+		message  string
+	}{
+		{"incomplete task", 0, []*Task{open}, ""},
+		{"no blockers", 1, nil, ""},
+		{"completed blockers", 1, []*Task{done}, ""},
+		{"incomplete blocker", 1, []*Task{done, open}, "task cannot be completed while blocked by incomplete tasks 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := &Task{ID: 3, Status: tt.status}
+			err := task.CheckBlockers(tt.blockers)
+			if tt.message == "" {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				return
+			}
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("Expected a *ValidationError, got %v", err)
+			}
+			expected := []FieldError{{Field: "status", Rule: RuleBlocked, Message: tt.message}}
+			if !reflect.DeepEqual(validationErr.Errors, expected) {
+				t.Errorf("Expected %+v, got %+v", expected, validationErr.Errors)
+			}
+		})
+	}
+}
+
+// TestPlanExecution tests the execution order and critical path of tasks blocking each other
+func TestPlanExecution(t *testing.T) {
+	task := func(id int, blockedBy ...int) *Task {
+		return &Task{ID: id, Details: Details{BlockedBy: blockedBy}}
+	}
+	tests := []struct {
+		name     string
+		tasks    []*Task
+		order    []int
+		critical []int
+	}{
+		{"empty", nil, []int{}, []int{}},
+		{"independent", []*Task{task(3), task(1), task(2)}, []int{1, 2, 3}, []int{1}},
+		{
+			name:     "chain",
+			tasks:    []*Task{task(1, 2), task(2, 3), task(3)},
+			order:    []int{3, 2, 1},
+			critical: []int{3, 2, 1},
+		},
+		{
+			// 1 → 4 → 5 is longer than 2 → 5; 3 is independent
+			name:     "diamond",
+			tasks:    []*Task{task(1), task(2), task(3), task(4, 1), task(5, 2, 4)},
+			order:    []int{1, 2, 3, 4, 5},
+			critical: []int{1, 4, 5},
+		},
+		{
+			name:     "blocker outside the set",
+			tasks:    []*Task{task(2, 1), task(3, 2)},
+			order:    []int{2, 3},
+			critical: []int{2, 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := PlanExecution(tt.tasks)
+			if err != nil {
+				t.Fatalf("PlanExecution failed: %v", err)
+			}
+			order := make([]int, len(plan.Order))
+			for i, task := range plan.Order {
+				order[i] = task.ID
+			}
+			if !reflect.DeepEqual(order, tt.order) {
+				t.Errorf("Expected order %v, got %v", tt.order, order)
+			}
+			if !reflect.DeepEqual(plan.CriticalPath, tt.critical) {
+				t.Errorf("Expected critical path %v, got %v", tt.critical, plan.CriticalPath)
+			}
+		})
+	}
+
+	if _, err := PlanExecution([]*Task{task(1, 2), task(2, 1), task(3)}); err == nil {
+		t.Error("Expected an error for tasks blocking each other")
+	}
+}
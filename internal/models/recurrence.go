@@ -0,0 +1,45 @@
+package models
+
+import (
+	"task-api/internal/rrule"
+	"time"
+)
+
+// NextOccurrence returns the task following the recurring task t once it is
+// completed at completedAt, or nil if t does not recur or its series has
+// ended. The occurrence is a new, incomplete task in the initial workflow
+// state with the same name and details, except that nothing blocks it and it
+// is due at the first occurrence of the rule after both the due date of t and
+// completedAt, so occurrences missed while t was overdue are skipped. The
+// series starts at the due date of t, or at completedAt if t has none.
+func (t *Task) NextOccurrence(completedAt time.Time) *Task {
+	if t.Recurrence == "" {
+		return nil
+	}
+	rule, err := rrule.Parse(t.Recurrence)
+	if err != nil {
+		return nil
+	}
+
+	start, after := completedAt.UTC(), completedAt
+	if t.DueAt != nil {
+		start = t.DueAt.UTC()
+		if after.Before(start) {
+			after = start
+		}
+	}
+	dueAt, ok := rule.Next(start, after)
+	if !ok {
+		return nil
+	}
+
+	next := t.Clone()
+	next.ID, next.Version = 0, 0
+	next.Status = 0
+	next.State = DefaultWorkflow().StateForStatus("", 0)
+	next.DueAt = &dueAt
+	next.BlockedBy = nil
+	next.Timestamps = Timestamps{}
+	next.Alerts = Alerts{}
+	return next
+}
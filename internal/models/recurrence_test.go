@@ -0,0 +1,62 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+// TestTask_NextOccurrence tests the task following a completed recurring task
+func TestTask_NextOccurrence(t *testing.T) {
+	// A Monday
+	dueAt := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	at := func(day, hour int) time.Time {
+		return time.Date(2030, 1, day, hour, 0, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name        string
+		recurrence  string
+		dueAt       *time.Time
+		completedAt time.Time
+		expected    *time.Time // Due date of the next occurrence, nil for none
+	}{
+		{"one-off task", "", &dueAt, at(7, 8), nil},
+		{"completed early", "FREQ=DAILY", &dueAt, at(6, 12), ptr(at(8, 9))},
+		{"completed late", "FREQ=WEEKLY;BYDAY=MO,TH", &dueAt, at(11, 12), ptr(at(14, 9))},
+		{"without due date", "FREQ=DAILY", nil, at(7, 15), ptr(at(8, 15))},
+		{"series ended", "FREQ=DAILY;UNTIL=20300107", &dueAt, at(7, 10), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := &Task{ID: 4, Name: "Checklist", Status: 1, State: "done", Version: 3, Details: Details{
+				DueAt: tt.dueAt, Tags: []string{"ops"}, Assignee: "bob", ParentID: 2, BlockedBy: []int{1}, Recurrence: tt.recurrence,
+			}}
+			next := task.NextOccurrence(tt.completedAt)
+			if tt.expected == nil {
+				if next != nil {
+					t.Errorf("Expected no next occurrence, got %+v", next)
+				}
+				return
+			}
+
+			if next == nil {
+				t.Fatal("Expected a next occurrence")
+			}
+			if next.DueAt == nil || !next.DueAt.Equal(*tt.expected) {
+				t.Errorf("Expected due date %v, got %v", tt.expected, next.DueAt)
+			}
+			if next.ID != 0 || next.Version != 0 || next.Status != 0 || next.State != DefaultWorkflow().StateForStatus("", 0) {
+				t.Errorf("Expected a new incomplete task, got %+v", next)
+			}
+			if next.Name != task.Name || next.Assignee != "bob" || next.ParentID != 2 || next.Recurrence != tt.recurrence || next.BlockedBy != nil {
+				t.Errorf("Expected the details without blockers to carry over, got %+v", next.Details)
+			}
+			if task.DueAt != tt.dueAt || task.Status != 1 {
+				t.Errorf("Expected the completed task to be unchanged, got %+v", task)
+			}
+		})
+	}
+}
+
+func ptr(t time.Time) *time.Time {
+	return &t
+}
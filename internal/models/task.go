@@ -1,54 +1,210 @@
 package models
 
-import (
-	"errors"
-	"strings"
-)
+import "time"
 
 // Task represents a task in our task management system
 // Note: In production, consider using UUID for better security and distributed system compatibility
 type Task struct {
-	ID     int    `json:"id"`     // Unique identifier (use UUID in production)
-	Name   string `json:"name"`   // Task name
-	Status int    `json:"status"` // 0 = incomplete, 1 = completed
+	ID         int    `json:"id"`     // Unique identifier (use UUID in production)
+	Name       string `json:"name"`   // Task name
+	Status     int    `json:"status"` // 0 = incomplete, 1 = completed; follows from State
+	State      string `json:"state"`  // Workflow state, see Workflow
+	Details           // Optional descriptive fields, inlined in JSON
+	Timestamps        // Maintained by storage, inlined in JSON
+	Alerts            // Maintained by the scheduler, inlined in JSON
+	Version    int    `json:"version"` // Incremented by storage on every update, used for optimistic concurrency
 }
 
-// NewTask creates a new Task with the given name and status.
-// It returns an error if the name is empty or contains only whitespace,
-// or if the status is not 0 (incomplete) or 1 (complete).
-func NewTask(name string, status int) (*Task, error) {
-	// Note: Input validation helps prevent injection attacks and ensures data consistency.
-	// In production, consider additional validation like length limits, character filtering, etc.
-	if strings.TrimSpace(name) == "" {
-		return nil, errors.New("task name cannot be empty")
+// Timestamps record when a task was created, last changed and completed.
+// Storage sets them on every write; values sent by clients are ignored.
+type Timestamps struct {
+	CreatedAt   time.Time  `json:"created_at"`             // When the task was stored
+	UpdatedAt   time.Time  `json:"updated_at"`             // When the task was last stored
+	CompletedAt *time.Time `json:"completed_at,omitempty"` // When the status last became 1, nil while incomplete
+}
+
+// Alerts record what the scheduler noticed about the due date of a task.
+// Storage clears them whenever the due date changes or the task is created or
+// completed, so that a new due date is watched afresh; clients cannot set them.
+type Alerts struct {
+	RemindedAt *time.Time `json:"reminded_at,omitempty"` // When a reminder of the coming due date was sent, nil if none was
+	OverdueAt  *time.Time `json:"overdue_at,omitempty"`  // When the task was found past its due date, nil while it is not
+}
+
+// Task priorities, from least to most urgent
+const (
+	PriorityNone   = 0
+	PriorityLow    = 1
+	PriorityMedium = 2
+	PriorityHigh   = 3
+)
+
+// Details are the optional fields of a task that describe and organize the work.
+// Every field is omitted from JSON while unset, so clients that only know
+// about ID, name, status and version see the same documents as before.
+type Details struct {
+	Description string     `json:"description,omitempty"` // Markdown text
+	DueAt       *time.Time `json:"due_at,omitempty"`      // Deadline, nil for none; stored in UTC
+	Priority    int        `json:"priority,omitempty"`    // One of the Priority constants
+	Tags        []string   `json:"tags,omitempty"`        // Lower-case labels without duplicates
+	Assignee    string     `json:"assignee,omitempty"`    // Who works on the task, empty when unassigned
+	ParentID    int        `json:"parent_id,omitempty"`   // Task this one is a subtask of, 0 for a top-level task
+	BlockedBy   []int      `json:"blocked_by,omitempty"`  // Tasks that must be completed before this one, in ID order
+	Recurrence  string     `json:"recurrence,omitempty"`  // RRULE the task repeats by, see package rrule; empty for a one-off task
+}
+
+// Clone returns a deep copy of the task that shares no memory with the original.
+// Storage backends hand out clones so that callers can modify the tasks they
+// receive without racing with other readers of the stored value.
+func (t *Task) Clone() *Task {
+	if t == nil {
+		return nil
+	}
+	clone := *t
+	if t.DueAt != nil {
+		dueAt := *t.DueAt
+		clone.DueAt = &dueAt
 	}
+	if t.Tags != nil {
+		clone.Tags = append([]string(nil), t.Tags...)
+	}
+	if t.BlockedBy != nil {
+		clone.BlockedBy = append([]int(nil), t.BlockedBy...)
+	}
+	if t.CompletedAt != nil {
+		completedAt := *t.CompletedAt
+		clone.CompletedAt = &completedAt
+	}
+	if t.RemindedAt != nil {
+		remindedAt := *t.RemindedAt
+		clone.RemindedAt = &remindedAt
+	}
+	if t.OverdueAt != nil {
+		overdueAt := *t.OverdueAt
+		clone.OverdueAt = &overdueAt
+	}
+	return &clone
+}
 
-	// Validate status - must be 0 (incomplete) or 1 (completed)
-	if status < 0 || status > 1 {
-		return nil, errors.New("status must be 0 (incomplete) or 1 (completed)")
+// NewTask creates a new Task with the given name and status and no details.
+// The name is normalized first (see Validator.Normalize). It returns a
+// *ValidationError if the name is empty, too long or contains control
+// characters, or if the status is not 0 (incomplete) or 1 (complete).
+// The task starts in the workflow state matching its status (see Workflow.StateForStatus).
+func NewTask(name string, status int) (*Task, error) {
+	return NewTaskWithDetails(name, status, Details{})
+}
+
+// NewTaskWithDetails creates a new Task like NewTask, also setting its details.
+// Every field is normalized and validated; all violations are reported at once.
+func NewTaskWithDetails(name string, status int, details Details) (*Task, error) {
+	workflow := DefaultWorkflow()
+	task := &Task{
+		Name:    name,
+		Status:  status,
+		State:   workflow.StateForStatus("", status),
+		Details: details,
+	}
+	if err := validateTask(task, "", workflow); err != nil {
+		return nil, err
 	}
+	return task, nil
+}
 
-	return &Task{
-		Name:   name,
-		Status: status,
-	}, nil
+// NewTaskInState creates a new Task like NewTaskWithDetails, starting in the
+// given workflow state instead of one derived from a status. The status
+// follows from the state.
+func NewTaskInState(name, state string, details Details) (*Task, error) {
+	workflow := DefaultWorkflow()
+	task := &Task{
+		Name:    name,
+		Status:  workflow.Status(state),
+		State:   state,
+		Details: details,
+	}
+	if err := validateTask(task, "", workflow); err != nil {
+		return nil, err
+	}
+	return task, nil
 }
 
-// Update modifies the task with new name and status, applying validation.
+// Update modifies the task with new name and status, applying the same
+// normalization and validation as NewTask. The details are kept.
 // This method follows the fetch-modify-save pattern used in production systems.
+// The task is left unchanged if validation fails.
 func (t *Task) Update(name string, status int) error {
-	// Validate name
-	if strings.TrimSpace(name) == "" {
-		return errors.New("task name cannot be empty")
-	}
+	return t.UpdateWithDetails(name, status, t.Details)
+}
+
+// UpdateWithDetails modifies the task with new name, status and details,
+// applying the same normalization and validation as NewTaskWithDetails.
+// A changed status moves the task to a matching workflow state (see
+// Workflow.StateForStatus), which must be an allowed transition.
+// The task is left unchanged if validation fails.
+func (t *Task) UpdateWithDetails(name string, status int, details Details) error {
+	workflow := DefaultWorkflow()
+	from := t.currentState(workflow)
 
-	// Validate status
-	if status < 0 || status > 1 {
-		return errors.New("status must be 0 (incomplete) or 1 (completed)")
+	// Validate the result before touching the task
+	updated := *t
+	updated.Name = name
+	updated.Status = status
+	updated.State = workflow.StateForStatus(from, status)
+	updated.Details = details
+	if err := validateTask(&updated, from, workflow); err != nil {
+		return err
 	}
 
 	// Apply changes
-	t.Name = name
-	t.Status = status
+	*t = updated
+	return nil
+}
+
+// UpdateInState modifies the task like UpdateWithDetails, moving it to the
+// given workflow state instead of one derived from a status. The workflow must
+// allow the transition from the current state; the status follows from the state.
+// The task is left unchanged if validation fails.
+func (t *Task) UpdateInState(name, state string, details Details) error {
+	workflow := DefaultWorkflow()
+	from := t.currentState(workflow)
+
+	updated := *t
+	updated.Name = name
+	updated.Status = workflow.Status(state)
+	updated.State = state
+	updated.Details = details
+	if err := validateTask(&updated, from, workflow); err != nil {
+		return err
+	}
+
+	*t = updated
+	return nil
+}
+
+// currentState returns the workflow state of the task. Tasks stored before
+// workflows existed have no state and are in the state matching their status.
+func (t *Task) currentState(workflow *Workflow) string {
+	if t.State == "" {
+		return workflow.StateForStatus("", t.Status)
+	}
+	return t.State
+}
+
+// validateTask normalizes the task and checks every field, including that it
+// may move from state from ("" for a new task) into its state. A task without
+// a state got it from an invalid status, which is reported on its own.
+func validateTask(task *Task, from string, workflow *Workflow) error {
+	validator := DefaultValidator()
+	validator.Normalize(task)
+
+	errs := validator.fieldErrors(task)
+	if task.State != "" || task.Status == 0 || task.Status == 1 {
+		if fieldErr := workflow.checkTransition(from, task.State); fieldErr != nil {
+			errs = append(errs, *fieldErr)
+		}
+	}
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
 	return nil
 }
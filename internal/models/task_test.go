@@ -1,7 +1,11 @@
 package models
 
 import (
+	"errors"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 // TestTask_CreateTask tests task creation using table-driven approach
@@ -188,3 +192,266 @@ func TestTask_Update(t *testing.T) {
 		})
 	}
 }
+
+// TestTask_Clone tests that a clone is equal to but independent of the original
+func TestTask_Clone(t *testing.T) {
+	dueAt := time.Date(2030, 1, 2, 15, 4, 5, 0, time.UTC)
+	original := &Task{ID: 7, Name: "Original", Status: 1, Version: 3, Details: Details{
+		Description: "Some *markdown*",
+		DueAt:       &dueAt,
+		Priority:    PriorityHigh,
+		Tags:        []string{"backend", "urgent"},
+		Assignee:    "alice",
+		BlockedBy:   []int{2, 5},
+	}, Alerts: Alerts{OverdueAt: &dueAt}}
+
+	clone := original.Clone()
+	if clone == original {
+		t.Fatal("Expected Clone to return a new pointer")
+	}
+	if !reflect.DeepEqual(clone, original) {
+		t.Errorf("Expected clone %+v to equal original %+v", clone, original)
+	}
+
+	clone.Name = "Changed"
+	clone.Version = 4
+	clone.Tags[0] = "frontend"
+	clone.BlockedBy[0] = 3
+	*clone.DueAt = clone.DueAt.Add(time.Hour)
+	*clone.OverdueAt = clone.OverdueAt.Add(time.Hour)
+	if original.Name != "Original" || original.Version != 3 {
+		t.Errorf("Expected original to be unaffected, got %+v", original)
+	}
+	if original.Tags[0] != "backend" || original.BlockedBy[0] != 2 || !original.DueAt.Equal(dueAt) {
+		t.Errorf("Expected original details to be unaffected, got %+v", original.Details)
+	}
+	if !original.OverdueAt.Equal(dueAt) {
+		t.Errorf("Expected original alerts to be unaffected, got %+v", original.Alerts)
+	}
+
+	var nilTask *Task
+	if nilTask.Clone() != nil {
+		t.Error("Expected Clone of nil task to be nil")
+	}
+}
+
+// TestTask_Validate tests that validation reports every invalid field with its rule
+func TestTask_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		task     Task
+		expected []FieldError
+	}{
+		{
+			name: "valid",
+			task: Task{Name: "Write docs", Status: 1},
+		},
+		{
+			name:     "empty name",
+			task:     Task{Name: " ", Status: 0},
+			expected: []FieldError{{Field: "name", Rule: RuleRequired, Message: "task name cannot be empty"}},
+		},
+		{
+			name:     "name too long",
+			task:     Task{Name: strings.Repeat("é", DefaultLimits.MaxNameLength+1), Status: 0},
+			expected: []FieldError{{Field: "name", Rule: RuleMaxLength, Message: "task name must be at most 200 characters long"}},
+		},
+		{
+			name: "name at the limit",
+			task: Task{Name: strings.Repeat("é", DefaultLimits.MaxNameLength), Status: 0},
+		},
+		{
+			name:     "control character",
+			task:     Task{Name: "line\nbreak", Status: 0},
+			expected: []FieldError{{Field: "name", Rule: RuleNoControl, Message: "task name must not contain control characters"}},
+		},
+		{
+			name:     "invalid UTF-8",
+			task:     Task{Name: "bad \xff byte", Status: 0},
+			expected: []FieldError{{Field: "name", Rule: RuleValidUTF8, Message: "task name must be valid UTF-8"}},
+		},
+		{
+			name: "multi-line description",
+			task: Task{Name: "Docs", Details: Details{Description: "# Title\n\n\tcode\r\n"}},
+		},
+		{
+			name:     "control character in description",
+			task:     Task{Name: "Docs", Details: Details{Description: "bell\a"}},
+			expected: []FieldError{{Field: "description", Rule: RuleNoControl, Message: "description must not contain control characters"}},
+		},
+		{
+			name:     "invalid priority",
+			task:     Task{Name: "Docs", Details: Details{Priority: 4}},
+			expected: []FieldError{{Field: "priority", Rule: RuleOneOf, Message: "priority must be 0 (none), 1 (low), 2 (medium) or 3 (high)"}},
+		},
+		{
+			name:     "too many tags",
+			task:     Task{Name: "Docs", Details: Details{Tags: strings.Fields("a b c d e f g h i j k l m n o p q r s t u")}},
+			expected: []FieldError{{Field: "tags", Rule: RuleMaxItems, Message: "tags must have at most 20 entries"}},
+		},
+		{
+			name: "invalid tags",
+			task: Task{Name: "Docs", Details: Details{Tags: []string{"ok", "", strings.Repeat("x", DefaultLimits.MaxTagLength+1)}}},
+			expected: []FieldError{
+				{Field: "tags[1]", Rule: RuleRequired, Message: "tag cannot be empty"},
+				{Field: "tags[2]", Rule: RuleMaxLength, Message: "tag must be at most 50 characters long"},
+			},
+		},
+		{
+			name:     "assignee with line break",
+			task:     Task{Name: "Docs", Details: Details{Assignee: "al\nice"}},
+			expected: []FieldError{{Field: "assignee", Rule: RuleNoControl, Message: "assignee must not contain control characters"}},
+		},
+		{
+			name:     "negative parent",
+			task:     Task{Name: "Docs", Details: Details{ParentID: -1}},
+			expected: []FieldError{{Field: "parent_id", Rule: RuleMin, Message: "parent task ID must be at least 0"}},
+		},
+		{
+			name:     "invalid recurrence",
+			task:     Task{Name: "Docs", Details: Details{Recurrence: "FREQ=YEARLY"}},
+			expected: []FieldError{{Field: "recurrence", Rule: RuleRRule, Message: "recurrence " + ValidRRule().Describe}},
+		},
+		{
+			name:     "invalid blocker",
+			task:     Task{Name: "Docs", Details: Details{BlockedBy: []int{0, 4}}},
+			expected: []FieldError{{Field: "blocked_by[0]", Rule: RuleMin, Message: "blocking task ID must be at least 1"}},
+		},
+		{
+			name: "every field invalid",
+			task: Task{Name: "", Status: 7},
+			expected: []FieldError{
+				{Field: "name", Rule: RuleRequired, Message: "task name cannot be empty"},
+				{Field: "status", Rule: RuleOneOf, Message: "status must be 0 (incomplete) or 1 (completed)"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.task.Validate()
+			if tt.expected == nil {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				return
+			}
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("Expected a *ValidationError, got %T: %v", err, err)
+			}
+			if !reflect.DeepEqual(validationErr.Errors, tt.expected) {
+				t.Errorf("Expected field errors %+v, got %+v", tt.expected, validationErr.Errors)
+			}
+		})
+	}
+}
+
+// TestTask_UpdateInvalidKeepsTask tests that a rejected update leaves the task unchanged
+func TestTask_UpdateInvalidKeepsTask(t *testing.T) {
+	task := &Task{ID: 3, Name: "Original", Status: 0, Version: 2}
+
+	if err := task.Update("Renamed", 5); err == nil {
+		t.Fatal("Expected an error for an invalid status")
+	}
+
+	expected := &Task{ID: 3, Name: "Original", Status: 0, Version: 2}
+	if !reflect.DeepEqual(task, expected) {
+		t.Errorf("Expected task to stay %+v, got %+v", expected, task)
+	}
+}
+
+// TestTask_Normalize tests that names are stored in Unicode normalization form C
+func TestTask_Normalize(t *testing.T) {
+	decomposed, composed := "Cafe\u0301", "Caf\u00e9"
+
+	task, err := NewTask(decomposed, 0)
+	if err != nil {
+		t.Fatalf("NewTask failed: %v", err)
+	}
+	if task.Name != composed {
+		t.Errorf("Expected NewTask to store %q, got %q", composed, task.Name)
+	}
+
+	if err := task.Update("Re"+decomposed, 1); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if task.Name != "Re"+composed {
+		t.Errorf("Expected Update to store %q, got %q", "Re"+composed, task.Name)
+	}
+}
+
+// TestTask_NormalizeDetails tests the canonical form of tags, assignee and due date
+func TestTask_NormalizeDetails(t *testing.T) {
+	local := time.Date(2030, 3, 4, 12, 0, 0, 0, time.FixedZone("UTC+2", 2*60*60))
+	details := Details{
+		DueAt:      &local,
+		Tags:       []string{" Backend", "backend", "Caf\u0065\u0301", "bug"},
+		Assignee:   "  bob ",
+		BlockedBy:  []int{7, 3, 7},
+		Recurrence: "byday=fr,mo;freq=weekly",
+	}
+
+	task, err := NewTaskWithDetails("Normalize", 0, details)
+	if err != nil {
+		t.Fatalf("NewTaskWithDetails failed: %v", err)
+	}
+
+	if expected := []string{"backend", "caf\u00e9", "bug"}; !reflect.DeepEqual(task.Tags, expected) {
+		t.Errorf("Expected tags %q, got %q", expected, task.Tags)
+	}
+	if expected := []int{3, 7}; !reflect.DeepEqual(task.BlockedBy, expected) {
+		t.Errorf("Expected blockers %v, got %v", expected, task.BlockedBy)
+	}
+	if expected := "FREQ=WEEKLY;BYDAY=MO,FR"; task.Recurrence != expected {
+		t.Errorf("Expected recurrence %q, got %q", expected, task.Recurrence)
+	}
+	if task.Assignee != "bob" {
+		t.Errorf("Expected assignee %q, got %q", "bob", task.Assignee)
+	}
+	if expected := time.Date(2030, 3, 4, 10, 0, 0, 0, time.UTC); task.DueAt == nil || *task.DueAt != expected {
+		t.Errorf("Expected due date %v, got %v", expected, task.DueAt)
+	}
+
+	// The caller's values are not modified
+	if details.Tags[0] != " Backend" || details.BlockedBy[0] != 7 || details.DueAt.Location() == time.UTC {
+		t.Errorf("Expected the input details to be unchanged, got %+v", details)
+	}
+
+	// Without tags the list is nil, however it was given
+	if err := task.UpdateWithDetails("Normalize", 0, Details{Tags: []string{}}); err != nil {
+		t.Fatalf("UpdateWithDetails failed: %v", err)
+	}
+	if task.Tags != nil {
+		t.Errorf("Expected nil tags, got %#v", task.Tags)
+	}
+}
+
+// TestSetLimits tests that configured limits apply to NewTask and Task.Update
+func TestSetLimits(t *testing.T) {
+	t.Cleanup(func() { SetLimits(DefaultLimits) })
+	SetLimits(Limits{MaxNameLength: 5})
+
+	if limits := DefaultValidator().Limits(); limits.MaxNameLength != 5 {
+		t.Errorf("Expected max name length 5, got %d", limits.MaxNameLength)
+	}
+	if _, err := NewTask("Short", 0); err != nil {
+		t.Errorf("Expected a 5 character name to be valid, got %v", err)
+	}
+
+	var validationErr *ValidationError
+	_, err := NewTask("Too long", 0)
+	if !errors.As(err, &validationErr) || validationErr.Errors[0].Rule != RuleMaxLength {
+		t.Errorf("Expected a %s error from NewTask, got %v", RuleMaxLength, err)
+	}
+	task := &Task{Name: "Short"}
+	if err := task.Update("Too long", 0); !errors.As(err, &validationErr) || validationErr.Errors[0].Rule != RuleMaxLength {
+		t.Errorf("Expected a %s error from Update, got %v", RuleMaxLength, err)
+	}
+
+	// A custom validator is independent of the package limits
+	if err := NewValidator(Limits{}).Validate(&Task{Name: "Much longer than five"}); err != nil {
+		t.Errorf("Expected no limit with zero limits, got %v", err)
+	}
+}
@@ -0,0 +1,458 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"task-api/internal/rrule"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Validation rules reported in FieldError.Rule
+const (
+	RuleRequired  = "required"  // The field must not be empty
+	RuleOneOf     = "oneof"     // The field must be one of a fixed set of values
+	RuleMaxLength = "maxlength" // The field must not be longer than a limit
+	RuleMaxItems  = "maxitems"  // The list must not have more entries than a limit
+	RuleNoControl = "nocontrol" // The field must not contain control characters
+	RuleValidUTF8 = "utf8"      // The field must be valid UTF-8
+	RuleMin       = "min"       // The number must not be below a limit
+	RuleParent    = "parent"    // The parent must be an existing task outside the task's own subtree
+	RuleBlocker   = "blocker"   // Blocking tasks must exist and must not be blocked by the task in turn
+	RuleBlocked   = "blocked"   // The task cannot be completed while a task blocking it is incomplete
+	RuleRRule     = "rrule"     // The field must be a supported recurrence rule
+)
+
+// FieldError describes why a single field of a task is invalid.
+type FieldError struct {
+	Field   string `json:"field"`   // JSON name of the field
+	Rule    string `json:"rule"`    // Rule the value broke, e.g. "required"
+	Message string `json:"message"` // Human-readable explanation
+}
+
+func (e FieldError) Error() string {
+	return e.Message
+}
+
+// ValidationError lists every invalid field of a task, not only the first one found,
+// so clients can fix all of them at once. Use errors.As to inspect the fields.
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, fieldErr := range e.Errors {
+		messages = append(messages, fieldErr.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Rule is a single requirement on a field value of type T.
+type Rule[T any] struct {
+	Name     string       // Reported in FieldError.Rule
+	Check    func(T) bool // Reports whether the value satisfies the rule
+	Describe string       // Requirement, prefixed with the field label in messages
+}
+
+// Required rejects empty strings and strings of only whitespace.
+func Required() Rule[string] {
+	return Rule[string]{
+		Name:     RuleRequired,
+		Check:    func(s string) bool { return strings.TrimSpace(s) != "" },
+		Describe: "cannot be empty",
+	}
+}
+
+// MaxLength rejects strings longer than max characters (not bytes).
+// A max of 0 or less disables the rule.
+func MaxLength(max int) Rule[string] {
+	return Rule[string]{
+		Name:     RuleMaxLength,
+		Check:    func(s string) bool { return max <= 0 || utf8.RuneCountInString(s) <= max },
+		Describe: fmt.Sprintf("must be at most %d characters long", max),
+	}
+}
+
+// NoControlChars rejects strings containing control characters such as
+// NUL, escape or line breaks, except for the runes listed in allowed.
+func NoControlChars(allowed ...rune) Rule[string] {
+	return Rule[string]{
+		Name: RuleNoControl,
+		Check: func(s string) bool {
+			return strings.IndexFunc(s, func(r rune) bool {
+				return unicode.IsControl(r) && !strings.ContainsRune(string(allowed), r)
+			}) < 0
+		},
+		Describe: "must not contain control characters",
+	}
+}
+
+// MaxItems rejects lists with more than max entries.
+// A max of 0 or less disables the rule.
+func MaxItems[T any](max int) Rule[[]T] {
+	return Rule[[]T]{
+		Name:     RuleMaxItems,
+		Check:    func(items []T) bool { return max <= 0 || len(items) <= max },
+		Describe: fmt.Sprintf("must have at most %d entries", max),
+	}
+}
+
+// ValidUTF8 rejects strings that are not valid UTF-8.
+func ValidUTF8() Rule[string] {
+	return Rule[string]{
+		Name:     RuleValidUTF8,
+		Check:    utf8.ValidString,
+		Describe: "must be valid UTF-8",
+	}
+}
+
+// Min rejects numbers below min.
+func Min(min int) Rule[int] {
+	return Rule[int]{
+		Name:     RuleMin,
+		Check:    func(v int) bool { return v >= min },
+		Describe: fmt.Sprintf("must be at least %d", min),
+	}
+}
+
+// ValidRRule accepts empty strings and recurrence rules rrule.Parse accepts.
+func ValidRRule() Rule[string] {
+	return Rule[string]{
+		Name: RuleRRule,
+		Check: func(s string) bool {
+			if s == "" {
+				return true
+			}
+			_, err := rrule.Parse(s)
+			return err == nil
+		},
+		Describe: "must be an RRULE with FREQ=DAILY, WEEKLY or MONTHLY and optionally INTERVAL, BYDAY (weekly), BYMONTHDAY (monthly) and UNTIL",
+	}
+}
+
+// OneOf accepts only the given values; describe lists them for messages,
+// e.g. "0 (incomplete) or 1 (completed)".
+func OneOf[T comparable](describe string, values ...T) Rule[T] {
+	return Rule[T]{
+		Name: RuleOneOf,
+		Check: func(v T) bool {
+			for _, allowed := range values {
+				if v == allowed {
+					return true
+				}
+			}
+			return false
+		},
+		Describe: "must be " + describe,
+	}
+}
+
+// Field declares how one field of a task is normalized and validated.
+type Field[T any] struct {
+	Name      string           // JSON name, reported in FieldError.Field
+	Label     string           // Name used in messages
+	Value     func(t *Task) *T // Locates the field in a task
+	Normalize func(value T) T  // Optional, applied before validation
+	Rules     []Rule[T]        // Every broken rule is reported
+}
+
+// fieldSpec is a Field of any type.
+type fieldSpec interface {
+	normalize(t *Task)
+	validate(t *Task) []FieldError
+}
+
+func (f Field[T]) normalize(t *Task) {
+	if f.Normalize != nil {
+		value := f.Value(t)
+		*value = f.Normalize(*value)
+	}
+}
+
+func (f Field[T]) validate(t *Task) []FieldError {
+	var errs []FieldError
+	value := *f.Value(t)
+	for _, rule := range f.Rules {
+		if !rule.Check(value) {
+			errs = append(errs, FieldError{Field: f.Name, Rule: rule.Name, Message: f.Label + " " + rule.Describe})
+		}
+	}
+	return errs
+}
+
+// ListField declares how a list field of a task is normalized and validated.
+// Rules apply to the list as a whole; ItemRules apply to every entry and are
+// reported with the entry's index, e.g. "tags[2]".
+type ListField[T any] struct {
+	Name      string              // JSON name, reported in FieldError.Field
+	Label     string              // Name of the list used in messages
+	ItemLabel string              // Name of one entry used in messages
+	Value     func(t *Task) *[]T  // Locates the field in a task
+	Normalize func(items []T) []T // Optional, applied before validation; must not modify items in place
+	Rules     []Rule[[]T]         // Every broken rule is reported
+	ItemRules []Rule[T]           // Every broken rule of every entry is reported
+}
+
+func (f ListField[T]) normalize(t *Task) {
+	if f.Normalize != nil {
+		value := f.Value(t)
+		*value = f.Normalize(*value)
+	}
+}
+
+func (f ListField[T]) validate(t *Task) []FieldError {
+	var errs []FieldError
+	items := *f.Value(t)
+	for _, rule := range f.Rules {
+		if !rule.Check(items) {
+			errs = append(errs, FieldError{Field: f.Name, Rule: rule.Name, Message: f.Label + " " + rule.Describe})
+		}
+	}
+	for i, item := range items {
+		for _, rule := range f.ItemRules {
+			if !rule.Check(item) {
+				errs = append(errs, FieldError{
+					Field:   fmt.Sprintf("%s[%d]", f.Name, i),
+					Rule:    rule.Name,
+					Message: f.ItemLabel + " " + rule.Describe,
+				})
+			}
+		}
+	}
+	return errs
+}
+
+// NormalizeText converts text to Unicode normalization form C, so visually
+// identical names are stored, compared and searched identically.
+func NormalizeText(s string) string {
+	return norm.NFC.String(s)
+}
+
+// NormalizeTag returns the canonical form of a tag: normalized text without
+// surrounding whitespace, in lower case, so "Backend " and "backend" are the same tag.
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(NormalizeText(tag)))
+}
+
+// NormalizeTags normalizes every tag and drops repeated ones, keeping the
+// first occurrence. An empty list becomes nil. The input is not modified.
+func NormalizeTags(tags []string) []string {
+	var normalized []string
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = NormalizeTag(tag)
+		if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+// NormalizeBlockers sorts the IDs of blocking tasks and drops repeated ones.
+// An empty list becomes nil. The input is not modified.
+func NormalizeBlockers(ids []int) []int {
+	if len(ids) == 0 {
+		return nil
+	}
+	sorted := slices.Clone(ids)
+	slices.Sort(sorted)
+	return slices.Compact(sorted)
+}
+
+// NormalizeRecurrence returns the canonical form of a recurrence rule (see
+// rrule.Rule.String), so equivalent rules are stored identically. Rules that
+// do not parse are only trimmed and left for validation to report.
+func NormalizeRecurrence(recurrence string) string {
+	recurrence = strings.TrimSpace(recurrence)
+	if rule, err := rrule.Parse(recurrence); err == nil {
+		return rule.String()
+	}
+	return recurrence
+}
+
+// NormalizeAssignee returns the canonical form of an assignee: normalized
+// text without surrounding whitespace.
+func NormalizeAssignee(assignee string) string {
+	return strings.TrimSpace(NormalizeText(assignee))
+}
+
+// normalizeTime converts a time to UTC, so times are stored and compared
+// the same way whatever offset the client sent. The input is not modified.
+func normalizeTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
+
+// Limits are the configurable bounds enforced by validation.
+// A limit of 0 disables it.
+type Limits struct {
+	MaxNameLength        int // Maximum task name length in characters
+	MaxDescriptionLength int // Maximum description length in characters
+	MaxTags              int // Maximum number of tags on a task
+	MaxTagLength         int // Maximum tag length in characters
+	MaxAssigneeLength    int // Maximum assignee length in characters
+	MaxBlockers          int // Maximum number of tasks blocking a task
+}
+
+// DefaultLimits are the limits used unless SetLimits is called.
+var DefaultLimits = Limits{
+	MaxNameLength:        200,
+	MaxDescriptionLength: 10000,
+	MaxTags:              20,
+	MaxTagLength:         50,
+	MaxAssigneeLength:    100,
+	MaxBlockers:          50,
+}
+
+// Validator normalizes and validates tasks against a set of limits.
+// It is safe for concurrent use.
+type Validator struct {
+	limits Limits
+	fields []fieldSpec
+}
+
+// NewValidator builds the validation schema of a task for the given limits.
+func NewValidator(limits Limits) *Validator {
+	return &Validator{
+		limits: limits,
+		fields: []fieldSpec{
+			Field[string]{
+				Name:      "name",
+				Label:     "task name",
+				Value:     func(t *Task) *string { return &t.Name },
+				Normalize: NormalizeText,
+				Rules:     []Rule[string]{Required(), ValidUTF8(), NoControlChars(), MaxLength(limits.MaxNameLength)},
+			},
+			Field[int]{
+				Name:  "status",
+				Label: "status",
+				Value: func(t *Task) *int { return &t.Status },
+				Rules: []Rule[int]{OneOf("0 (incomplete) or 1 (completed)", 0, 1)},
+			},
+			Field[string]{
+				Name:      "description",
+				Label:     "description",
+				Value:     func(t *Task) *string { return &t.Description },
+				Normalize: NormalizeText,
+				// Markdown is multi-line, so line breaks and tabs are allowed
+				Rules: []Rule[string]{ValidUTF8(), NoControlChars('\n', '\r', '\t'), MaxLength(limits.MaxDescriptionLength)},
+			},
+			Field[*time.Time]{
+				Name:      "due_at",
+				Label:     "due date",
+				Value:     func(t *Task) **time.Time { return &t.DueAt },
+				Normalize: normalizeTime,
+			},
+			Field[int]{
+				Name:  "priority",
+				Label: "priority",
+				Value: func(t *Task) *int { return &t.Priority },
+				Rules: []Rule[int]{OneOf("0 (none), 1 (low), 2 (medium) or 3 (high)",
+					PriorityNone, PriorityLow, PriorityMedium, PriorityHigh)},
+			},
+			ListField[string]{
+				Name:      "tags",
+				Label:     "tags",
+				ItemLabel: "tag",
+				Value:     func(t *Task) *[]string { return &t.Tags },
+				Normalize: NormalizeTags,
+				Rules:     []Rule[[]string]{MaxItems[string](limits.MaxTags)},
+				ItemRules: []Rule[string]{Required(), ValidUTF8(), NoControlChars(), MaxLength(limits.MaxTagLength)},
+			},
+			Field[string]{
+				Name:      "assignee",
+				Label:     "assignee",
+				Value:     func(t *Task) *string { return &t.Assignee },
+				Normalize: NormalizeAssignee,
+				Rules:     []Rule[string]{ValidUTF8(), NoControlChars(), MaxLength(limits.MaxAssigneeLength)},
+			},
+			Field[int]{
+				Name:  "parent_id",
+				Label: "parent task ID",
+				Value: func(t *Task) *int { return &t.ParentID },
+				Rules: []Rule[int]{Min(0)},
+			},
+			ListField[int]{
+				Name:      "blocked_by",
+				Label:     "blocking tasks",
+				ItemLabel: "blocking task ID",
+				Value:     func(t *Task) *[]int { return &t.BlockedBy },
+				Normalize: NormalizeBlockers,
+				Rules:     []Rule[[]int]{MaxItems[int](limits.MaxBlockers)},
+				ItemRules: []Rule[int]{Min(1)},
+			},
+			Field[string]{
+				Name:      "recurrence",
+				Label:     "recurrence",
+				Value:     func(t *Task) *string { return &t.Recurrence },
+				Normalize: NormalizeRecurrence,
+				Rules:     []Rule[string]{ValidRRule()},
+			},
+		},
+	}
+}
+
+// Limits returns the limits the validator enforces.
+func (v *Validator) Limits() Limits {
+	return v.limits
+}
+
+// Normalize rewrites the fields of t into their canonical form.
+func (v *Validator) Normalize(t *Task) {
+	for _, field := range v.fields {
+		field.normalize(t)
+	}
+}
+
+// Validate checks every field of t and reports all violations at once.
+// It returns nil or a *ValidationError.
+func (v *Validator) Validate(t *Task) error {
+	if errs := v.fieldErrors(t); len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+// fieldErrors checks every field of t and returns all violations.
+func (v *Validator) fieldErrors(t *Task) []FieldError {
+	var errs []FieldError
+	for _, field := range v.fields {
+		errs = append(errs, field.validate(t)...)
+	}
+	return errs
+}
+
+// defaultValidator is used by NewTask, Task.Update and Task.Validate.
+var defaultValidator atomic.Pointer[Validator]
+
+func init() {
+	defaultValidator.Store(NewValidator(DefaultLimits))
+}
+
+// SetLimits replaces the limits used by NewTask, Task.Update and Task.Validate.
+// It is meant to be called once at startup, before serving requests.
+func SetLimits(limits Limits) {
+	defaultValidator.Store(NewValidator(limits))
+}
+
+// DefaultValidator returns the validator used by NewTask, Task.Update and Task.Validate.
+func DefaultValidator() *Validator {
+	return defaultValidator.Load()
+}
+
+// Validate checks every field of the task against the configured limits and
+// reports all violations at once. It returns nil or a *ValidationError.
+func (t *Task) Validate() error {
+	return DefaultValidator().Validate(t)
+}
@@ -0,0 +1,240 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+// RuleTransition is reported in FieldError.Rule when a task may not move
+// from its current state to the requested one.
+const RuleTransition = "transition"
+
+// stateNamePattern restricts state names to identifiers that read well in URLs and JSON.
+var stateNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// State is one step of a workflow.
+type State struct {
+	Name        string   `json:"name"`            // Identifier used in the API, e.g. "in_progress"
+	Label       string   `json:"label,omitempty"` // Human-readable name
+	Done        bool     `json:"done"`            // Whether tasks in this state count as completed (status 1)
+	Transitions []string `json:"transitions"`     // States a task may move to from this one
+}
+
+// Workflow is the set of states a task moves through and the transitions
+// allowed between them. Every task is in exactly one state; its legacy
+// status is 1 in done states and 0 otherwise.
+//
+// A Workflow is immutable once built by NewWorkflow or ParseWorkflow and is
+// safe for concurrent use.
+type Workflow struct {
+	initial string
+	states  []State
+	index   map[string]int // State name to position in states
+}
+
+// workflowDocument is the JSON form of a workflow.
+type workflowDocument struct {
+	Initial string  `json:"initial"` // State of new tasks created with status 0
+	States  []State `json:"states"`
+}
+
+// NewWorkflow builds a workflow from its states. New tasks start in initial
+// unless created in another state. State names must be unique lower-case
+// identifiers, every transition must name a known state, and there must be at
+// least one done state besides the initial state so legacy 0/1 statuses can
+// always be mapped onto a state.
+func NewWorkflow(initial string, states []State) (*Workflow, error) {
+	w := &Workflow{initial: initial, index: make(map[string]int, len(states))}
+	for i, state := range states {
+		if !stateNamePattern.MatchString(state.Name) {
+			return nil, fmt.Errorf("invalid state name %q (expected lower-case letters, digits and underscores)", state.Name)
+		}
+		if _, ok := w.index[state.Name]; ok {
+			return nil, fmt.Errorf("state %q is defined more than once", state.Name)
+		}
+		w.index[state.Name] = i
+
+		state.Transitions = slices.Clone(state.Transitions)
+		if state.Transitions == nil {
+			state.Transitions = []string{}
+		}
+		w.states = append(w.states, state)
+	}
+
+	for _, state := range w.states {
+		for _, to := range state.Transitions {
+			if _, ok := w.index[to]; !ok {
+				return nil, fmt.Errorf("state %q has a transition to unknown state %q", state.Name, to)
+			}
+		}
+	}
+
+	initialState, ok := w.State(initial)
+	switch {
+	case !ok:
+		return nil, fmt.Errorf("initial state %q is not defined", initial)
+	case initialState.Done:
+		return nil, fmt.Errorf("initial state %q cannot be a done state", initial)
+	}
+	if !slices.ContainsFunc(w.states, func(s State) bool { return s.Done }) {
+		return nil, errors.New("workflow needs at least one done state")
+	}
+	return w, nil
+}
+
+// ParseWorkflow builds a workflow from its JSON form:
+//
+//	{"initial": "todo", "states": [{"name": "todo", "transitions": ["done"]}, {"name": "done", "done": true}]}
+func ParseWorkflow(data []byte) (*Workflow, error) {
+	var doc workflowDocument
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decode workflow: %w", err)
+	}
+	return NewWorkflow(doc.Initial, doc.States)
+}
+
+// MarshalJSON encodes the workflow in the form accepted by ParseWorkflow.
+func (w *Workflow) MarshalJSON() ([]byte, error) {
+	return json.Marshal(workflowDocument{Initial: w.initial, States: w.States()})
+}
+
+// BasicWorkflow returns the workflow used unless SetWorkflow is called:
+// tasks are either "todo" (status 0) or "done" (status 1) and may move freely
+// between the two, exactly like the legacy status.
+func BasicWorkflow() *Workflow {
+	w, err := NewWorkflow("todo", []State{
+		{Name: "todo", Label: "To do", Transitions: []string{"done"}},
+		{Name: "done", Label: "Done", Done: true, Transitions: []string{"todo"}},
+	})
+	if err != nil {
+		panic(err) // The basic workflow is valid by construction
+	}
+	return w
+}
+
+// Initial returns the name of the state new tasks start in.
+func (w *Workflow) Initial() string {
+	return w.initial
+}
+
+// States returns a copy of the states in the order they were defined.
+func (w *Workflow) States() []State {
+	states := make([]State, len(w.states))
+	for i, state := range w.states {
+		state.Transitions = slices.Clone(state.Transitions)
+		states[i] = state
+	}
+	return states
+}
+
+// State looks up a state by name.
+func (w *Workflow) State(name string) (State, bool) {
+	i, ok := w.index[name]
+	if !ok {
+		return State{}, false
+	}
+	return w.states[i], true
+}
+
+// Status returns the legacy status of tasks in the named state:
+// 1 in done states, 0 otherwise.
+func (w *Workflow) Status(name string) int {
+	if state, ok := w.State(name); ok && state.Done {
+		return 1
+	}
+	return 0
+}
+
+// CanTransition reports whether a task may move from one state to another.
+// Staying in the same state is always allowed, and so is leaving a state the
+// workflow does not know (e.g. one removed from the configuration), so such
+// tasks are never stuck.
+func (w *Workflow) CanTransition(from, to string) bool {
+	state, ok := w.State(from)
+	if !ok || from == to {
+		return true
+	}
+	return slices.Contains(state.Transitions, to)
+}
+
+// StateForStatus maps a legacy status onto a state for a task currently in
+// the named state ("" for a new task). A task whose state already has that
+// status stays where it is. Otherwise status 1 picks the first done state the
+// task may move to, and status 0 the first state that is not done, falling
+// back to the first done state and the initial state. Any other status maps to "".
+func (w *Workflow) StateForStatus(current string, status int) string {
+	var done bool
+	switch status {
+	case 0:
+	case 1:
+		done = true
+	default:
+		return ""
+	}
+
+	if state, ok := w.State(current); ok {
+		if state.Done == done {
+			return current
+		}
+		for _, to := range state.Transitions {
+			if next, _ := w.State(to); next.Done == done {
+				return to
+			}
+		}
+	}
+	if !done {
+		return w.initial
+	}
+	for _, state := range w.states {
+		if state.Done {
+			return state.Name
+		}
+	}
+	return ""
+}
+
+// checkTransition reports why a task may not move between two states,
+// or nil if it may. from is "" for a new task, which may start in any state.
+func (w *Workflow) checkTransition(from, to string) *FieldError {
+	if _, ok := w.State(to); !ok {
+		names := make([]string, len(w.states))
+		for i, state := range w.states {
+			names[i] = fmt.Sprintf("%q", state.Name)
+		}
+		return &FieldError{Field: "state", Rule: RuleOneOf, Message: "state must be one of " + strings.Join(names, ", ")}
+	}
+	if from != "" && !w.CanTransition(from, to) {
+		return &FieldError{
+			Field:   "state",
+			Rule:    RuleTransition,
+			Message: fmt.Sprintf("state cannot change from %q to %q", from, to),
+		}
+	}
+	return nil
+}
+
+// defaultWorkflow is used by NewTask, Task.Update and their variants.
+var defaultWorkflow atomic.Pointer[Workflow]
+
+func init() {
+	defaultWorkflow.Store(BasicWorkflow())
+}
+
+// SetWorkflow replaces the workflow used by NewTask, Task.Update and their variants.
+// It is meant to be called once at startup, before serving requests.
+func SetWorkflow(w *Workflow) {
+	defaultWorkflow.Store(w)
+}
+
+// DefaultWorkflow returns the workflow used by NewTask, Task.Update and their variants.
+func DefaultWorkflow() *Workflow {
+	return defaultWorkflow.Load()
+}
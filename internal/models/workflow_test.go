@@ -0,0 +1,199 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// reviewWorkflow is a workflow with review and a done state that cannot be reopened directly
+const reviewWorkflow = `{
+	"initial": "todo",
+	"states": [
+		{"name": "todo", "label": "To do", "transitions": ["in_progress"]},
+		{"name": "in_progress", "label": "In progress", "transitions": ["todo", "review"]},
+		{"name": "review", "label": "In review", "transitions": ["in_progress", "done"]},
+		{"name": "done", "label": "Done", "done": true, "transitions": ["archived"]},
+		{"name": "archived", "done": true}
+	]
+}`
+
+// mustParseWorkflow parses a workflow or fails the test
+func mustParseWorkflow(t *testing.T, data string) *Workflow {
+	t.Helper()
+
+	w, err := ParseWorkflow([]byte(data))
+	if err != nil {
+		t.Fatalf("Failed to parse workflow: %v", err)
+	}
+	return w
+}
+
+// TestParseWorkflow tests that invalid workflow definitions are rejected
+func TestParseWorkflow(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{"valid", reviewWorkflow, ""},
+		{"not JSON", `states: todo`, "decode workflow"},
+		{"unknown field", `{"initial": "todo", "states": [], "final": "done"}`, "unknown field"},
+		{"invalid state name", `{"initial": "To do", "states": [{"name": "To do"}, {"name": "done", "done": true}]}`, "invalid state name"},
+		{"duplicate state", `{"initial": "todo", "states": [{"name": "todo"}, {"name": "todo"}, {"name": "done", "done": true}]}`, "more than once"},
+		{"unknown transition", `{"initial": "todo", "states": [{"name": "todo", "transitions": ["doing"]}, {"name": "done", "done": true}]}`, "unknown state \"doing\""},
+		{"unknown initial state", `{"initial": "new", "states": [{"name": "todo"}, {"name": "done", "done": true}]}`, "initial state \"new\""},
+		{"done initial state", `{"initial": "done", "states": [{"name": "todo"}, {"name": "done", "done": true}]}`, "cannot be a done state"},
+		{"no done state", `{"initial": "todo", "states": [{"name": "todo"}]}`, "at least one done state"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseWorkflow([]byte(tt.data))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Expected a valid workflow, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected an error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+// TestWorkflow_JSON tests that a workflow encodes to the form it was parsed from
+func TestWorkflow_JSON(t *testing.T) {
+	w := mustParseWorkflow(t, reviewWorkflow)
+
+	data, err := json.Marshal(w)
+	if err != nil {
+		t.Fatalf("Failed to encode workflow: %v", err)
+	}
+	decoded := mustParseWorkflow(t, string(data))
+	if decoded.Initial() != "todo" || !reflect.DeepEqual(decoded.States(), w.States()) {
+		t.Errorf("Expected %s to round-trip, got %s", reviewWorkflow, data)
+	}
+	if archived, _ := decoded.State("archived"); archived.Transitions == nil {
+		t.Error("Expected a state without transitions to list none, got nil")
+	}
+}
+
+// TestWorkflow_CanTransition tests which moves between states are allowed
+func TestWorkflow_CanTransition(t *testing.T) {
+	w := mustParseWorkflow(t, reviewWorkflow)
+
+	tests := []struct {
+		from, to string
+		expected bool
+	}{
+		{"todo", "in_progress", true},
+		{"todo", "done", false},
+		{"review", "done", true},
+		{"done", "todo", false},
+		{"archived", "archived", true},
+		{"removed", "todo", true}, // A state no longer configured can be left
+	}
+
+	for _, tt := range tests {
+		if got := w.CanTransition(tt.from, tt.to); got != tt.expected {
+			t.Errorf("CanTransition(%q, %q) = %v, expected %v", tt.from, tt.to, got, tt.expected)
+		}
+	}
+}
+
+// TestWorkflow_StateForStatus tests mapping legacy statuses onto states
+func TestWorkflow_StateForStatus(t *testing.T) {
+	w := mustParseWorkflow(t, reviewWorkflow)
+
+	tests := []struct {
+		name     string
+		current  string
+		status   int
+		expected string
+	}{
+		{"new incomplete task", "", 0, "todo"},
+		{"new completed task", "", 1, "done"},
+		{"unchanged status keeps the state", "review", 0, "review"},
+		{"completing picks a reachable done state", "review", 1, "done"},
+		{"completing without a reachable done state", "todo", 1, "done"},
+		{"reopening without a reachable open state", "done", 0, "todo"},
+		{"invalid status", "todo", 2, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := w.StateForStatus(tt.current, tt.status); got != tt.expected {
+				t.Errorf("StateForStatus(%q, %d) = %q, expected %q", tt.current, tt.status, got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestSetWorkflow tests that tasks follow the configured workflow, moving
+// only along allowed transitions, while legacy statuses keep working
+func TestSetWorkflow(t *testing.T) {
+	t.Cleanup(func() { SetWorkflow(BasicWorkflow()) })
+	SetWorkflow(mustParseWorkflow(t, reviewWorkflow))
+
+	task, err := NewTask("Write docs", 0)
+	if err != nil {
+		t.Fatalf("NewTask failed: %v", err)
+	}
+	if task.State != "todo" {
+		t.Errorf("Expected a new task in the initial state, got %q", task.State)
+	}
+
+	for _, state := range []string{"in_progress", "review", "done"} {
+		if err := task.UpdateInState(task.Name, state, task.Details); err != nil {
+			t.Fatalf("Expected to move to %q, got %v", state, err)
+		}
+	}
+	if task.Status != 1 {
+		t.Errorf("Expected status 1 in a done state, got %d", task.Status)
+	}
+
+	// Illegal transitions are rejected and leave the task unchanged
+	before := *task
+	var validationErr *ValidationError
+	err = task.UpdateInState("Renamed", "in_progress", task.Details)
+	if !errors.As(err, &validationErr) || validationErr.Errors[0].Field != "state" || validationErr.Errors[0].Rule != RuleTransition {
+		t.Errorf("Expected a %s error on state, got %v", RuleTransition, err)
+	}
+	if err := task.Update("Renamed", 0); !errors.As(err, &validationErr) || validationErr.Errors[0].Rule != RuleTransition {
+		t.Errorf("Expected reopening a done task to be rejected, got %v", err)
+	}
+	if !reflect.DeepEqual(*task, before) {
+		t.Errorf("Expected the task to be unchanged, got %+v", *task)
+	}
+
+	// Unknown states are reported with the known ones
+	_, err = NewTaskInState("Write docs", "blocked", Details{})
+	if !errors.As(err, &validationErr) || validationErr.Errors[0].Rule != RuleOneOf || !strings.Contains(err.Error(), `"review"`) {
+		t.Errorf("Expected a %s error listing the states, got %v", RuleOneOf, err)
+	}
+
+	// A legacy status of 1 completes a task through an allowed transition
+	reviewed, err := NewTaskInState("Review docs", "review", Details{})
+	if err != nil {
+		t.Fatalf("NewTaskInState failed: %v", err)
+	}
+	if err := reviewed.Update(reviewed.Name, 1); err != nil || reviewed.State != "done" {
+		t.Errorf("Expected status 1 to move the task to done, got %q (%v)", reviewed.State, err)
+	}
+
+	// Tasks stored before workflows existed are in the state matching their status
+	legacy := &Task{Name: "Legacy", Status: 1}
+	if err := legacy.UpdateInState(legacy.Name, "archived", legacy.Details); err != nil {
+		t.Errorf("Expected a legacy completed task to be archivable, got %v", err)
+	}
+
+	// An invalid legacy status is reported once, on the status
+	_, err = NewTask("Write docs", 2)
+	if !errors.As(err, &validationErr) || len(validationErr.Errors) != 1 || validationErr.Errors[0].Field != "status" {
+		t.Errorf("Expected a single status error, got %v", err)
+	}
+}
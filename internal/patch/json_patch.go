@@ -0,0 +1,293 @@
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// operation is a single JSON Patch operation (RFC 6902 section 4).
+type operation struct {
+	Op    string          `json:"op"`
+	Path  *string         `json:"path"`
+	From  *string         `json:"from"`
+	Value json.RawMessage `json:"value"` // nil when the member is absent, "null" when explicitly null
+}
+
+// JSONPatch applies a JSON Patch (RFC 6902) to doc and returns the patched document.
+// Operations are applied in order; if any of them fails the whole patch is
+// rejected and doc is left as it was.
+func JSONPatch(doc, patch []byte) ([]byte, error) {
+	var ops []operation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+
+	var target interface{}
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, fmt.Errorf("decode document: %w", err)
+	}
+
+	for i, op := range ops {
+		var err error
+		if target, err = applyOperation(target, op); err != nil {
+			return nil, fmt.Errorf("operation %d (%s): %w", i, op.Op, err)
+		}
+	}
+
+	return json.Marshal(target)
+}
+
+// applyOperation applies one operation and returns the new document root.
+func applyOperation(doc interface{}, op operation) (interface{}, error) {
+	if op.Path == nil {
+		return nil, fmt.Errorf("%w: missing path", ErrInvalidPatch)
+	}
+	path, err := parsePointer(*op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return nil, fmt.Errorf("%w: missing value", ErrInvalidPatch)
+		}
+		var value interface{}
+		if err := json.Unmarshal(op.Value, &value); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+		}
+
+		switch op.Op {
+		case "add":
+			return addValue(doc, path, value)
+		case "replace":
+			if len(path) == 0 {
+				return value, nil // The whole document always exists
+			}
+			if doc, _, err = removeValue(doc, path); err != nil {
+				return nil, err
+			}
+			return addValue(doc, path, value)
+		default:
+			current, err := getValue(doc, path)
+			if err != nil {
+				return nil, err
+			}
+			if !reflect.DeepEqual(current, value) {
+				return nil, fmt.Errorf("%w: value at %q differs", ErrTestFailed, *op.Path)
+			}
+			return doc, nil
+		}
+
+	case "remove":
+		doc, _, err = removeValue(doc, path)
+		return doc, err
+
+	case "move", "copy":
+		if op.From == nil {
+			return nil, fmt.Errorf("%w: missing from", ErrInvalidPatch)
+		}
+		from, err := parsePointer(*op.From)
+		if err != nil {
+			return nil, err
+		}
+
+		var value interface{}
+		if op.Op == "move" {
+			if len(from) < len(path) && reflect.DeepEqual(from, path[:len(from)]) {
+				return nil, fmt.Errorf("%w: cannot move a value into one of its children", ErrInvalidPatch)
+			}
+			if doc, value, err = removeValue(doc, from); err != nil {
+				return nil, err
+			}
+		} else {
+			if value, err = getValue(doc, from); err != nil {
+				return nil, err
+			}
+			// The copy must not share maps or slices with the original
+			if value, err = deepCopy(value); err != nil {
+				return nil, err
+			}
+		}
+		return addValue(doc, path, value)
+
+	default:
+		return nil, fmt.Errorf("%w: unknown operation %q", ErrInvalidPatch, op.Op)
+	}
+}
+
+// parsePointer splits a JSON Pointer (RFC 6901) into its unescaped reference tokens.
+// The empty pointer refers to the whole document and yields no tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("%w: path %q must start with '/'", ErrInvalidPatch, pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// arrayIndex parses token as an index into an array of length n.
+// When allowEnd is set the index may equal n, which is where "-" points.
+func arrayIndex(token string, n int, allowEnd bool) (int, error) {
+	if token == "-" && allowEnd {
+		return n, nil
+	}
+
+	// Indexes are plain decimal numbers without leading zeros
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || (len(token) > 1 && token[0] == '0') || token[0] == '+' {
+		return 0, fmt.Errorf("%w: invalid array index %q", ErrInvalidPatch, token)
+	}
+
+	limit := n - 1
+	if allowEnd {
+		limit = n
+	}
+	if index > limit {
+		return 0, fmt.Errorf("%w: array index %d out of range", ErrPathNotFound, index)
+	}
+	return index, nil
+}
+
+// getValue returns the value referenced by path.
+func getValue(doc interface{}, path []string) (interface{}, error) {
+	current := doc
+	for _, token := range path {
+		switch container := current.(type) {
+		case map[string]interface{}:
+			value, ok := container[token]
+			if !ok {
+				return nil, fmt.Errorf("%w: member %q", ErrPathNotFound, token)
+			}
+			current = value
+		case []interface{}:
+			index, err := arrayIndex(token, len(container), false)
+			if err != nil {
+				return nil, err
+			}
+			current = container[index]
+		default:
+			return nil, fmt.Errorf("%w: %q is not inside an object or array", ErrPathNotFound, token)
+		}
+	}
+	return current, nil
+}
+
+// addValue inserts value at path and returns the new document root.
+// Objects gain or replace the member, arrays shift later elements to the right.
+func addValue(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	token := path[0]
+	switch container := doc.(type) {
+	case map[string]interface{}:
+		if len(path) == 1 {
+			container[token] = value
+			return container, nil
+		}
+		child, ok := container[token]
+		if !ok {
+			return nil, fmt.Errorf("%w: member %q", ErrPathNotFound, token)
+		}
+		updated, err := addValue(child, path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		container[token] = updated
+		return container, nil
+
+	case []interface{}:
+		if len(path) == 1 {
+			index, err := arrayIndex(token, len(container), true)
+			if err != nil {
+				return nil, err
+			}
+			container = append(container, nil)
+			copy(container[index+1:], container[index:])
+			container[index] = value
+			return container, nil
+		}
+		index, err := arrayIndex(token, len(container), false)
+		if err != nil {
+			return nil, err
+		}
+		updated, err := addValue(container[index], path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		container[index] = updated
+		return container, nil
+
+	default:
+		return nil, fmt.Errorf("%w: %q is not inside an object or array", ErrPathNotFound, token)
+	}
+}
+
+// removeValue deletes the value at path and returns the new document root
+// together with the removed value.
+func removeValue(doc interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil, fmt.Errorf("%w: cannot remove the whole document", ErrInvalidPatch)
+	}
+
+	token := path[0]
+	switch container := doc.(type) {
+	case map[string]interface{}:
+		child, ok := container[token]
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: member %q", ErrPathNotFound, token)
+		}
+		if len(path) == 1 {
+			delete(container, token)
+			return container, child, nil
+		}
+		updated, removed, err := removeValue(child, path[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+		container[token] = updated
+		return container, removed, nil
+
+	case []interface{}:
+		index, err := arrayIndex(token, len(container), false)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(path) == 1 {
+			removed := container[index]
+			return append(container[:index], container[index+1:]...), removed, nil
+		}
+		updated, removed, err := removeValue(container[index], path[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+		container[index] = updated
+		return container, removed, nil
+
+	default:
+		return nil, nil, fmt.Errorf("%w: %q is not inside an object or array", ErrPathNotFound, token)
+	}
+}
+
+// deepCopy returns a copy of a decoded JSON value that shares no maps or slices with it.
+func deepCopy(value interface{}) (interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var copied interface{}
+	err = json.Unmarshal(data, &copied)
+	return copied, err
+}
@@ -0,0 +1,90 @@
+package patch
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestJSONPatch tests the examples from RFC 6902 Appendix A
+func TestJSONPatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		doc      string
+		patch    string
+		expected string
+	}{
+		{"add object member", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`},
+		{"add array element", `{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		{"append array element", `{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":"qux"}]`, `{"foo":["bar","qux"]}`},
+		{"remove object member", `{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+		{"remove array element", `{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+		{"replace value", `{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+		{"move value", `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`, `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{"move array element", `{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`},
+		{"copy value", `{"a":{"b":1}}`, `[{"op":"copy","from":"/a","path":"/c"},{"op":"replace","path":"/c/b","value":2}]`, `{"a":{"b":1},"c":{"b":2}}`},
+		{"test success", `{"baz":"qux","foo":["a",2,"c"]}`, `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`, `{"baz":"qux","foo":["a",2,"c"]}`},
+		{"add nested object", `{"foo":"bar"}`, `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`, `{"foo":"bar","child":{"grandchild":{}}}`},
+		{"escaped pointer", `{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":10},{"op":"replace","path":"/~1","value":1}]`, `{"/":1,"~1":10}`},
+		{"add null value", `{"foo":1}`, `[{"op":"add","path":"/bar","value":null}]`, `{"foo":1,"bar":null}`},
+		{"add whole document", `{"foo":1}`, `[{"op":"add","path":"","value":{"bar":2}}]`, `{"bar":2}`},
+		{"replace whole document", `{"foo":1}`, `[{"op":"replace","path":"","value":{"bar":2}}]`, `{"bar":2}`},
+		{"empty patch", `{"foo":1}`, `[]`, `{"foo":1}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := JSONPatch([]byte(tt.doc), []byte(tt.patch))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			assertJSONEqual(t, got, tt.expected)
+		})
+	}
+}
+
+// TestJSONPatch_Errors tests that failing patches report why they failed
+func TestJSONPatch_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		doc         string
+		patch       string
+		expectedErr error
+	}{
+		{"malformed patch", `{}`, `{"op":"add"}`, ErrInvalidPatch},
+		{"unknown operation", `{}`, `[{"op":"frobnicate","path":"/a"}]`, ErrInvalidPatch},
+		{"missing path", `{}`, `[{"op":"remove"}]`, ErrInvalidPatch},
+		{"missing value", `{}`, `[{"op":"add","path":"/a"}]`, ErrInvalidPatch},
+		{"missing from", `{}`, `[{"op":"move","path":"/a"}]`, ErrInvalidPatch},
+		{"relative pointer", `{}`, `[{"op":"add","path":"a","value":1}]`, ErrInvalidPatch},
+		{"leading zero index", `{"a":[1,2]}`, `[{"op":"remove","path":"/a/01"}]`, ErrInvalidPatch},
+		{"move into own child", `{"a":{"b":1}}`, `[{"op":"move","from":"/a","path":"/a/b/c"}]`, ErrInvalidPatch},
+		{"remove missing member", `{"foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, ErrPathNotFound},
+		{"replace missing member", `{"foo":"bar"}`, `[{"op":"replace","path":"/baz","value":1}]`, ErrPathNotFound},
+		{"add to missing parent", `{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`, ErrPathNotFound},
+		{"index out of range", `{"foo":["bar"]}`, `[{"op":"add","path":"/foo/5","value":"qux"}]`, ErrPathNotFound},
+		{"test mismatch", `{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`, ErrTestFailed},
+		{"test number against string", `{"baz":"10"}`, `[{"op":"test","path":"/baz","value":10}]`, ErrTestFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := JSONPatch([]byte(tt.doc), []byte(tt.patch))
+			if !errors.Is(err, tt.expectedErr) {
+				t.Errorf("Expected %v, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+// TestJSONPatch_Atomic tests that a failing operation leaves the document untouched
+func TestJSONPatch_Atomic(t *testing.T) {
+	doc := []byte(`{"name":"Original","status":0}`)
+	patch := []byte(`[{"op":"replace","path":"/name","value":"Changed"},{"op":"test","path":"/status","value":1}]`)
+
+	if _, err := JSONPatch(doc, patch); !errors.Is(err, ErrTestFailed) {
+		t.Fatalf("Expected ErrTestFailed, got %v", err)
+	}
+	if string(doc) != `{"name":"Original","status":0}` {
+		t.Errorf("Expected document to be unchanged, got %s", doc)
+	}
+}
@@ -0,0 +1,66 @@
+// Package patch applies partial-update documents to JSON resources.
+// It implements JSON Merge Patch (RFC 7396) and JSON Patch (RFC 6902)
+// on top of encoding/json, so callers can patch any JSON representation
+// and validate the result with their usual model rules.
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Media types of the supported patch formats
+const (
+	MergePatchType = "application/merge-patch+json"
+	JSONPatchType  = "application/json-patch+json"
+)
+
+// Errors describing why a patch could not be applied
+var (
+	// ErrInvalidPatch means the patch document itself is malformed.
+	ErrInvalidPatch = errors.New("invalid patch document")
+	// ErrTestFailed means a JSON Patch "test" operation did not match the document.
+	ErrTestFailed = errors.New("patch test operation failed")
+	// ErrPathNotFound means an operation references a location that does not exist.
+	ErrPathNotFound = errors.New("patch path not found")
+)
+
+// MergePatch applies a JSON Merge Patch (RFC 7396) to doc and returns the patched document.
+// Members set to null are removed, objects are merged recursively and any other
+// value replaces the target member.
+func MergePatch(doc, patch []byte) ([]byte, error) {
+	var patchValue interface{}
+	if err := json.Unmarshal(patch, &patchValue); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+
+	var target interface{}
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, fmt.Errorf("decode document: %w", err)
+	}
+
+	return json.Marshal(mergeValue(target, patchValue))
+}
+
+// mergeValue implements the MergePatch algorithm from RFC 7396 section 2.
+func mergeValue(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{})
+	}
+
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+			continue
+		}
+		targetObject[name] = mergeValue(targetObject[name], value)
+	}
+	return targetObject
+}
@@ -0,0 +1,67 @@
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// assertJSONEqual fails the test unless got and expected hold the same JSON value
+func assertJSONEqual(t *testing.T, got []byte, expected string) {
+	t.Helper()
+	var gotValue, expectedValue interface{}
+	if err := json.Unmarshal(got, &gotValue); err != nil {
+		t.Fatalf("Result is not valid JSON: %v", err)
+	}
+	if err := json.Unmarshal([]byte(expected), &expectedValue); err != nil {
+		t.Fatalf("Expected value is not valid JSON: %v", err)
+	}
+	if !reflect.DeepEqual(gotValue, expectedValue) {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+}
+
+// TestMergePatch tests the examples from RFC 7396 Appendix A
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		doc      string
+		patch    string
+		expected string
+	}{
+		{"replace member", `{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{"add member", `{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{"remove member", `{"a":"b"}`, `{"a":null}`, `{}`},
+		{"remove one of many", `{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{"replace array", `{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{"replace with array", `{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{"nested merge", `{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{"arrays are replaced", `{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{"non-object document", `["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{"non-object patch", `{"a":"b"}`, `["c"]`, `["c"]`},
+		{"null patch", `{"a":"foo"}`, `null`, `null`},
+		{"string patch", `{"a":"foo"}`, `"bar"`, `"bar"`},
+		{"explicit null is not stored", `{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{"object patch on array", `[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{"deep nested creation", `{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MergePatch([]byte(tt.doc), []byte(tt.patch))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			assertJSONEqual(t, got, tt.expected)
+		})
+	}
+}
+
+// TestMergePatch_Invalid tests that malformed patches are rejected
+func TestMergePatch_Invalid(t *testing.T) {
+	_, err := MergePatch([]byte(`{"a":"b"}`), []byte(`{"a":`))
+	if !errors.Is(err, ErrInvalidPatch) {
+		t.Errorf("Expected ErrInvalidPatch, got %v", err)
+	}
+}
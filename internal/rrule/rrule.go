@@ -0,0 +1,265 @@
+// Package rrule parses and evaluates the subset of iCalendar recurrence rules
+// (RFC 5545 section 3.3.10) that recurring tasks use: daily, weekly on given
+// weekdays and monthly on given days of the month, every INTERVAL periods,
+// optionally UNTIL a time.
+package rrule
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidRule means a recurrence rule is malformed or uses a part outside
+// the supported subset.
+var ErrInvalidRule = errors.New("invalid recurrence rule")
+
+// Frequency is how often a rule recurs.
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+)
+
+// untilLayouts are the forms of UNTIL accepted, a UTC date-time or a date
+const (
+	untilDateTime = "20060102T150405Z"
+	untilDate     = "20060102"
+)
+
+// maxPeriods bounds the periods Next looks at past the first candidate one,
+// so rules whose days never occur, like the 31st every 12 months from
+// February, end instead of looping forever
+const maxPeriods = 1000
+
+// weekdays maps the two-letter iCalendar weekday names to weekdays
+var weekdays = map[string]time.Weekday{
+	"MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday, "TH": time.Thursday,
+	"FR": time.Friday, "SA": time.Saturday, "SU": time.Sunday,
+}
+
+// Rule is a parsed recurrence rule. Occurrences are evaluated in the location
+// of the start time passed to Next; tasks store due dates in UTC.
+type Rule struct {
+	Freq       Frequency      // How often the rule recurs
+	Interval   int            // Periods between occurrences, at least 1
+	ByDay      []time.Weekday // Weekly rules only: the weekdays it occurs on, Monday first; empty for the start's weekday
+	ByMonthDay []int          // Monthly rules only: the days of the month it occurs on, ascending; empty for the start's day
+	Until      *time.Time     // Last time an occurrence may fall on, nil for none
+}
+
+// Parse reads a rule such as "FREQ=WEEKLY;BYDAY=MO,TH". Parts may come in any
+// order and in any case, optionally after an "RRULE:" prefix. It returns an
+// error wrapping ErrInvalidRule if the rule is malformed or unsupported.
+func Parse(s string) (*Rule, error) {
+	s = strings.TrimSpace(s)
+	if len(s) >= 6 && strings.EqualFold(s[:6], "RRULE:") {
+		s = s[6:]
+	}
+	if s == "" {
+		return nil, fmt.Errorf("%w: empty rule", ErrInvalidRule)
+	}
+
+	rule := &Rule{Interval: 1}
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(s, ";") {
+		name, value, ok := strings.Cut(part, "=")
+		name = strings.ToUpper(strings.TrimSpace(name))
+		value = strings.ToUpper(strings.TrimSpace(value))
+		if !ok || name == "" || value == "" {
+			return nil, fmt.Errorf("%w: malformed part %q", ErrInvalidRule, part)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("%w: %s given more than once", ErrInvalidRule, name)
+		}
+		seen[name] = true
+
+		var err error
+		switch name {
+		case "FREQ":
+			rule.Freq = Frequency(value)
+			if rule.Freq != Daily && rule.Freq != Weekly && rule.Freq != Monthly {
+				err = fmt.Errorf("unsupported FREQ %s (expected DAILY, WEEKLY or MONTHLY)", value)
+			}
+		case "INTERVAL":
+			rule.Interval, err = strconv.Atoi(value)
+			if err != nil || rule.Interval < 1 {
+				err = fmt.Errorf("INTERVAL must be a positive integer, got %s", value)
+			}
+		case "BYDAY":
+			rule.ByDay, err = parseWeekdays(value)
+		case "BYMONTHDAY":
+			rule.ByMonthDay, err = parseMonthDays(value)
+		case "UNTIL":
+			rule.Until, err = parseUntil(value)
+		default:
+			err = fmt.Errorf("unsupported part %s", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidRule, err)
+		}
+	}
+
+	switch {
+	case rule.Freq == "":
+		return nil, fmt.Errorf("%w: FREQ is required", ErrInvalidRule)
+	case len(rule.ByDay) > 0 && rule.Freq != Weekly:
+		return nil, fmt.Errorf("%w: BYDAY is only supported with FREQ=WEEKLY", ErrInvalidRule)
+	case len(rule.ByMonthDay) > 0 && rule.Freq != Monthly:
+		return nil, fmt.Errorf("%w: BYMONTHDAY is only supported with FREQ=MONTHLY", ErrInvalidRule)
+	}
+	return rule, nil
+}
+
+// parseWeekdays reads a BYDAY list such as "MO,WE", sorted Monday first
+func parseWeekdays(value string) ([]time.Weekday, error) {
+	var days []time.Weekday
+	for _, name := range strings.Split(value, ",") {
+		day, ok := weekdays[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("invalid BYDAY weekday %q (expected MO, TU, WE, TH, FR, SA or SU)", name)
+		}
+		days = append(days, day)
+	}
+	slices.SortFunc(days, func(a, b time.Weekday) int { return mondayOffset(a) - mondayOffset(b) })
+	return slices.Compact(days), nil
+}
+
+// parseMonthDays reads a BYMONTHDAY list such as "1,15", sorted ascending
+func parseMonthDays(value string) ([]int, error) {
+	var days []int
+	for _, raw := range strings.Split(value, ",") {
+		day, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil || day < 1 || day > 31 {
+			return nil, fmt.Errorf("invalid BYMONTHDAY day %q (expected 1 to 31)", raw)
+		}
+		days = append(days, day)
+	}
+	slices.Sort(days)
+	return slices.Compact(days), nil
+}
+
+// parseUntil reads UNTIL as a UTC date-time or as a date, which includes the whole day
+func parseUntil(value string) (*time.Time, error) {
+	if until, err := time.Parse(untilDateTime, value); err == nil {
+		return &until, nil
+	}
+	until, err := time.Parse(untilDate, value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid UNTIL %q (expected YYYYMMDD or YYYYMMDDTHHMMSSZ)", value)
+	}
+	until = until.Add(24*time.Hour - time.Nanosecond)
+	return &until, nil
+}
+
+// String returns the canonical form of the rule: FREQ, INTERVAL (unless 1),
+// BYDAY, BYMONTHDAY and UNTIL, in that order and in upper case.
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		names := make([]string, len(r.ByDay))
+		for i, day := range r.ByDay {
+			names[i] = strings.ToUpper(day.String()[:2])
+		}
+		parts = append(parts, "BYDAY="+strings.Join(names, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, len(r.ByMonthDay))
+		for i, day := range r.ByMonthDay {
+			days[i] = strconv.Itoa(day)
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format(untilDateTime))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Next returns the first occurrence of the series starting at start that
+// falls strictly after after, and false if there is none because the series
+// ended. start is the first occurrence and sets the time of day of every
+// other one; it also sets the weekday of weekly rules and the day of monthly
+// rules without BYDAY or BYMONTHDAY.
+func (r *Rule) Next(start, after time.Time) (time.Time, bool) {
+	interval := max(r.Interval, 1)
+
+	// Skip whole periods up to the one containing after; it may still hold an occurrence
+	period := 0
+	if after.After(start) {
+		switch r.Freq {
+		case Daily:
+			period = int(after.Sub(start).Hours()/24) / interval
+		case Weekly:
+			period = int(after.Sub(weekStart(start)).Hours()/(24*7)) / interval
+		case Monthly:
+			period = ((after.Year()-start.Year())*12 + int(after.Month()-start.Month())) / interval
+		}
+	}
+
+	for end := period + maxPeriods; period <= end; period++ {
+		for _, candidate := range r.occurrences(start, period*interval) {
+			if candidate.Before(start) || !candidate.After(after) {
+				continue
+			}
+			if r.Until != nil && candidate.After(*r.Until) {
+				return time.Time{}, false
+			}
+			return candidate, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// occurrences returns the occurrences of the period offset days, weeks or
+// months after the one start falls in, in order. Days missing from a month,
+// like the 31st of April, have none.
+func (r *Rule) occurrences(start time.Time, offset int) []time.Time {
+	switch r.Freq {
+	case Weekly:
+		week := weekStart(start).AddDate(0, 0, 7*offset)
+		days := r.ByDay
+		if len(days) == 0 {
+			days = []time.Weekday{start.Weekday()}
+		}
+		occurrences := make([]time.Time, len(days))
+		for i, day := range days {
+			occurrences[i] = week.AddDate(0, 0, mondayOffset(day))
+		}
+		return occurrences
+	case Monthly:
+		year, month := start.Year(), start.Month()+time.Month(offset)
+		days := r.ByMonthDay
+		if len(days) == 0 {
+			days = []int{start.Day()}
+		}
+		var occurrences []time.Time
+		for _, day := range days {
+			occurrence := time.Date(year, month, day, start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), start.Location())
+			if occurrence.Day() == day {
+				occurrences = append(occurrences, occurrence)
+			}
+		}
+		return occurrences
+	default:
+		return []time.Time{start.AddDate(0, 0, offset)}
+	}
+}
+
+// weekStart returns the Monday of the week start falls in, at start's time of day
+func weekStart(start time.Time) time.Time {
+	return start.AddDate(0, 0, -mondayOffset(start.Weekday()))
+}
+
+// mondayOffset returns the number of days from Monday to day
+func mondayOffset(day time.Weekday) int {
+	return (int(day) + 6) % 7
+}
@@ -0,0 +1,96 @@
+package rrule
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestParse tests reading rules into their canonical form and rejecting unsupported ones
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     string
+		expected string // Canonical form, empty if the rule is invalid
+	}{
+		{"daily", "FREQ=DAILY", "FREQ=DAILY"},
+		{"prefix and case", " rrule:freq=daily;interval=1 ", "FREQ=DAILY"},
+		{"weekly", "BYDAY=FR,mo,MO;FREQ=WEEKLY;INTERVAL=2", "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR"},
+		{"monthly", "FREQ=MONTHLY;BYMONTHDAY=15,1", "FREQ=MONTHLY;BYMONTHDAY=1,15"},
+		{"until date", "FREQ=DAILY;UNTIL=20300131", "FREQ=DAILY;UNTIL=20300131T235959Z"},
+		{"until date-time", "FREQ=DAILY;UNTIL=20300131T120000Z", "FREQ=DAILY;UNTIL=20300131T120000Z"},
+		{"empty", "", ""},
+		{"missing FREQ", "INTERVAL=2", ""},
+		{"unsupported FREQ", "FREQ=YEARLY", ""},
+		{"unsupported part", "FREQ=DAILY;COUNT=3", ""},
+		{"repeated part", "FREQ=DAILY;FREQ=WEEKLY", ""},
+		{"malformed part", "FREQ=DAILY;INTERVAL", ""},
+		{"zero interval", "FREQ=DAILY;INTERVAL=0", ""},
+		{"invalid weekday", "FREQ=WEEKLY;BYDAY=1MO", ""},
+		{"BYDAY on a daily rule", "FREQ=DAILY;BYDAY=MO", ""},
+		{"invalid month day", "FREQ=MONTHLY;BYMONTHDAY=32", ""},
+		{"BYMONTHDAY on a weekly rule", "FREQ=WEEKLY;BYMONTHDAY=1", ""},
+		{"invalid until", "FREQ=DAILY;UNTIL=tomorrow", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.rule)
+			if tt.expected == "" {
+				if !errors.Is(err, ErrInvalidRule) {
+					t.Errorf("Expected ErrInvalidRule, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			if got := rule.String(); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+// TestRule_Next tests finding the next occurrence after a time
+func TestRule_Next(t *testing.T) {
+	// A Wednesday
+	start := time.Date(2030, 1, 2, 9, 0, 0, 0, time.UTC)
+	day := func(month time.Month, day int) time.Time {
+		return time.Date(2030, month, day, 9, 0, 0, 0, time.UTC)
+	}
+	feb := day(2, 1)
+	tests := []struct {
+		name     string
+		rule     string
+		start    time.Time
+		after    time.Time
+		expected time.Time // Zero if the series has ended
+	}{
+		{"daily", "FREQ=DAILY", start, start, day(1, 3)},
+		{"daily before the start", "FREQ=DAILY", start, start.AddDate(0, 0, -5), start},
+		{"every third day, late", "FREQ=DAILY;INTERVAL=3", start, day(1, 9), day(1, 11)},
+		{"weekly on the start's weekday", "FREQ=WEEKLY", start, start, day(1, 9)},
+		{"weekly on given days", "FREQ=WEEKLY;BYDAY=MO,FR", start, start, day(1, 4)},
+		{"weekly, next week", "FREQ=WEEKLY;BYDAY=MO,FR", start, day(1, 4), day(1, 7)},
+		{"fortnightly", "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO", start, start, day(1, 14)},
+		{"monthly on the start's day", "FREQ=MONTHLY", start, start, day(2, 2)},
+		{"monthly on given days", "FREQ=MONTHLY;BYMONTHDAY=1,15", start, start, day(1, 15)},
+		{"monthly skips short months", "FREQ=MONTHLY;BYMONTHDAY=31", start, day(1, 31), day(3, 31)},
+		{"quarterly, late", "FREQ=MONTHLY;INTERVAL=3", start, day(5, 10), day(7, 2)},
+		{"until", "FREQ=DAILY;UNTIL=20300103", start, start, day(1, 3)},
+		{"ended", "FREQ=DAILY;UNTIL=20300103", start, day(1, 3), time.Time{}},
+		{"never occurs", "FREQ=MONTHLY;INTERVAL=12;BYMONTHDAY=30", feb, feb, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			next, ok := rule.Next(tt.start, tt.after)
+			if ok != !tt.expected.IsZero() || !next.Equal(tt.expected) {
+				t.Errorf("Expected %v (%v), got %v (%v)", tt.expected, !tt.expected.IsZero(), next, ok)
+			}
+		})
+	}
+}
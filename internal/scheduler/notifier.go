@@ -0,0 +1,122 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Notifier delivers scheduler events somewhere. Implementations must be safe
+// for concurrent use and should give up once ctx is done.
+type Notifier interface {
+	Notify(ctx context.Context, event Event) error
+}
+
+// LogNotifier writes a line per event to a logger.
+type LogNotifier struct {
+	logger *log.Logger
+}
+
+// NewLogNotifier creates a notifier logging to logger, or to the standard logger if nil.
+func NewLogNotifier(logger *log.Logger) *LogNotifier {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &LogNotifier{logger: logger}
+}
+
+// Notify logs event.
+func (n *LogNotifier) Notify(_ context.Context, event Event) error {
+	switch event.Type {
+	case EventOverdue:
+		n.logger.Printf("Task %d %q is overdue (due %s)", event.Task.ID, event.Task.Name, event.Task.DueAt.Format(time.RFC3339))
+	default:
+		n.logger.Printf("Reminder: task %d %q is due %s", event.Task.ID, event.Task.Name, event.Task.DueAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// WebhookNotifier POSTs every event as JSON to a URL.
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+// webhookTimeout bounds a webhook call when no client is given
+const webhookTimeout = 10 * time.Second
+
+// NewWebhookNotifier creates a notifier posting to url with client, or with a
+// client giving up after webhookTimeout if nil.
+func NewWebhookNotifier(url string, client *http.Client) *WebhookNotifier {
+	if client == nil {
+		client = &http.Client{Timeout: webhookTimeout}
+	}
+	return &WebhookNotifier{url: url, client: client}
+}
+
+// Notify posts event and fails unless the webhook answers with a 2xx status.
+func (n *WebhookNotifier) Notify(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %s answered %s", n.url, resp.Status)
+	}
+	return nil
+}
+
+// FileNotifier appends every event to a file as a line of JSON.
+type FileNotifier struct {
+	mutex sync.Mutex
+	file  *os.File
+}
+
+// NewFileNotifier opens the file at path for appending, creating it if needed.
+// The notifier must be closed when it is no longer used.
+func NewFileNotifier(path string) (*FileNotifier, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open notification file: %w", err)
+	}
+	return &FileNotifier{file: file}, nil
+}
+
+// Notify appends event to the file.
+func (n *FileNotifier) Notify(_ context.Context, event Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	_, err = n.file.Write(append(line, '\n'))
+	return err
+}
+
+// Close closes the file.
+func (n *FileNotifier) Close() error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	return n.file.Close()
+}
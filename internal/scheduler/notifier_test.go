@@ -0,0 +1,121 @@
+package scheduler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"task-api/internal/models"
+	"testing"
+)
+
+// newEvent returns an event about a task due at start
+func newEvent(eventType EventType, id int) Event {
+	dueAt := start
+	return Event{Type: eventType, At: start, Task: &models.Task{ID: id, Name: "Pay rent", Details: models.Details{DueAt: &dueAt}}}
+}
+
+// TestLogNotifier tests that every event is logged as a line naming the task
+func TestLogNotifier(t *testing.T) {
+	tests := []struct {
+		name     string
+		event    Event
+		expected string
+	}{
+		{"reminder", newEvent(EventReminder, 3), `Reminder: task 3 "Pay rent" is due 2030-06-01T12:00:00Z`},
+		{"overdue", newEvent(EventOverdue, 4), `Task 4 "Pay rent" is overdue (due 2030-06-01T12:00:00Z)`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := NewLogNotifier(log.New(&buf, "", 0)).Notify(context.Background(), tt.event); err != nil {
+				t.Fatalf("Notify failed: %v", err)
+			}
+			if line := strings.TrimSpace(buf.String()); line != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, line)
+			}
+		})
+	}
+}
+
+// TestWebhookNotifier tests that events are posted as JSON and that error statuses fail
+func TestWebhookNotifier(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{"accepted", http.StatusNoContent, false},
+		{"failing", http.StatusInternalServerError, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var received Event
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+					t.Errorf("Expected a JSON POST, got %s %s", r.Method, r.Header.Get("Content-Type"))
+				}
+				if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+					t.Errorf("Failed to decode event: %v", err)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			err := NewWebhookNotifier(server.URL, nil).Notify(context.Background(), newEvent(EventOverdue, 7))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error: %v, got %v", tt.wantErr, err)
+			}
+			if received.Type != EventOverdue || received.Task == nil || received.Task.ID != 7 {
+				t.Errorf("Expected the overdue event of task 7, got %+v", received)
+			}
+		})
+	}
+}
+
+// TestFileNotifier tests that events are appended to the file as JSON lines
+func TestFileNotifier(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	if err := os.WriteFile(path, []byte(`{"type":"task.reminder"}`+"\n"), 0o644); err != nil {
+		t.Fatalf("Failed to seed file: %v", err)
+	}
+
+	notifier, err := NewFileNotifier(path)
+	if err != nil {
+		t.Fatalf("Failed to open file notifier: %v", err)
+	}
+	for _, event := range []Event{newEvent(EventReminder, 1), newEvent(EventOverdue, 2)} {
+		if err := notifier.Notify(context.Background(), event); err != nil {
+			t.Fatalf("Notify failed: %v", err)
+		}
+	}
+	if err := notifier.Close(); err != nil {
+		t.Fatalf("Failed to close file notifier: %v", err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	defer file.Close()
+	var types []EventType
+	for scanner := bufio.NewScanner(file); scanner.Scan(); {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("Invalid line %q: %v", scanner.Text(), err)
+		}
+		types = append(types, event.Type)
+	}
+	if expected := []EventType{EventReminder, EventReminder, EventOverdue}; !slices.Equal(types, expected) {
+		t.Errorf("Expected events %v after the existing line, got %v", expected, types)
+	}
+}
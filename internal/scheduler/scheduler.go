@@ -0,0 +1,134 @@
+// Package scheduler watches the due dates of tasks in the background. It
+// reminds of incomplete tasks coming due, marks the ones past their due date
+// overdue, and tells notifiers about both.
+//
+// What the scheduler noticed is stored with each task (see models.Alerts), so
+// with a persistent backend a restarted server neither repeats reminders nor
+// misses tasks that came due while it was down.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"task-api/internal/clock"
+	"task-api/internal/models"
+	"task-api/internal/storage"
+	"time"
+)
+
+// EventType names what happened to a task.
+type EventType string
+
+const (
+	EventReminder EventType = "task.reminder" // The task is due within the reminder lead
+	EventOverdue  EventType = "task.overdue"  // The task is past its due date and still incomplete
+)
+
+// Event is what notifiers are told about a task.
+type Event struct {
+	Type EventType    `json:"type"`
+	At   time.Time    `json:"at"`   // When the scheduler noticed, in UTC
+	Task *models.Task `json:"task"` // The task as stored with the alert
+}
+
+// Config sets how the scheduler watches tasks.
+type Config struct {
+	Interval     time.Duration // Time between two scans, see Run
+	ReminderLead time.Duration // How long before its due date a task is reminded of, 0 for no reminders
+}
+
+// DefaultConfig scans every minute and reminds of tasks an hour before they are due.
+var DefaultConfig = Config{Interval: time.Minute, ReminderLead: time.Hour}
+
+// Scheduler scans tasks for due dates and fires events to its notifiers.
+type Scheduler struct {
+	storage   storage.TaskStorage
+	clock     clock.Clock
+	config    Config
+	notifiers []Notifier
+}
+
+// New creates a scheduler watching the tasks in s, reading the time from c.
+// c should be the clock of s, so the alerts agree with the task timestamps.
+func New(s storage.TaskStorage, c clock.Clock, config Config, notifiers ...Notifier) *Scheduler {
+	return &Scheduler{storage: s, clock: c, config: config, notifiers: notifiers}
+}
+
+// Run scans once right away, to catch up on whatever came due while the
+// scheduler was not running, and then every interval until ctx is done.
+// A failed scan is logged and retried at the next one.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Scan(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Scheduler scan failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Scan looks at every incomplete task due before the reminder lead from now,
+// once. A task past its due date is marked overdue, one due soon is marked
+// reminded, and each mark is stored before notifiers hear of it, so no event
+// fires twice. Marks are stored with MarkAlerts, so they neither bump the
+// version clients hold nor show up as task updates. Tasks changed
+// concurrently are left for the next scan. Scan
+// returns the storage errors it met; notifier errors are only logged, since
+// the task is marked either way.
+func (s *Scheduler) Scan(ctx context.Context) error {
+	now := s.clock.Now().UTC()
+	horizon := now.Add(s.config.ReminderLead)
+	open, notOverdue := 0, false
+	page, err := s.storage.Query(ctx, storage.TaskQuery{
+		Status:  &open,
+		Overdue: &notOverdue,
+		Due:     storage.TimeRange{Before: &horizon},
+	})
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, task := range page.Tasks {
+		var eventType EventType
+		alerts := task.Alerts
+		switch {
+		case task.DueAt.Before(now):
+			alerts.OverdueAt, eventType = &now, EventOverdue
+		case s.config.ReminderLead > 0 && task.RemindedAt == nil:
+			alerts.RemindedAt, eventType = &now, EventReminder
+		default:
+			continue
+		}
+
+		marked, err := s.storage.MarkAlerts(ctx, task.ID, task.Version, alerts)
+		if errors.Is(err, storage.ErrConflict) || errors.Is(err, storage.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		s.notify(ctx, Event{Type: eventType, At: now, Task: marked})
+	}
+	return errors.Join(errs...)
+}
+
+// notify hands event to every notifier, logging the ones that fail
+func (s *Scheduler) notify(ctx context.Context, event Event) {
+	for _, notifier := range s.notifiers {
+		if err := notifier.Notify(ctx, event); err != nil {
+			log.Printf("Failed to notify %s of task %d: %v", event.Type, event.Task.ID, err)
+		}
+	}
+}
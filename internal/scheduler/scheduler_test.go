@@ -0,0 +1,241 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"task-api/internal/clock"
+	"task-api/internal/models"
+	"task-api/internal/storage"
+	"testing"
+	"time"
+)
+
+// recorder is a notifier remembering the events it was told about
+type recorder struct {
+	mutex  sync.Mutex
+	events []Event
+}
+
+func (r *recorder) Notify(_ context.Context, event Event) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.events = append(r.events, event)
+	return nil
+}
+
+// take returns the events recorded so far as "type id" strings and forgets them
+func (r *recorder) take() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var taken []string
+	for _, event := range r.events {
+		taken = append(taken, fmt.Sprintf("%s %d", event.Type, event.Task.ID))
+	}
+	r.events = nil
+	return taken
+}
+
+// start is the time of the manual clocks used by the tests
+var start = time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// mustCreate stores a task due at the given offset from start, or without a
+// due date if due is 0, or fails the test
+func mustCreate(t *testing.T, s storage.TaskStorage, name string, status int, due time.Duration) *models.Task {
+	t.Helper()
+
+	var details models.Details
+	if due != 0 {
+		dueAt := start.Add(due)
+		details.DueAt = &dueAt
+	}
+	task, err := models.NewTaskWithDetails(name, status, details)
+	if err != nil {
+		t.Fatalf("Failed to create task model: %v", err)
+	}
+	created, err := s.Create(context.Background(), task)
+	if err != nil {
+		t.Fatalf("Failed to create task %q: %v", name, err)
+	}
+	return created
+}
+
+// TestScheduler_Scan tests that scans remind of tasks coming due and mark
+// tasks past their due date overdue, once each, as the clock moves on
+func TestScheduler_Scan(t *testing.T) {
+	c := clock.NewManual(start)
+	s := storage.NewInMemoryStorage(storage.WithClock(c))
+	late := mustCreate(t, s, "Late", 0, -time.Hour)
+	soon := mustCreate(t, s, "Soon", 0, 30*time.Minute)
+	later := mustCreate(t, s, "Later", 0, 3*time.Hour)
+	mustCreate(t, s, "Done", 1, -time.Hour)
+	mustCreate(t, s, "Whenever", 0, 0)
+
+	notifier := &recorder{}
+	scheduler := New(s, c, Config{Interval: time.Minute, ReminderLead: time.Hour}, notifier)
+
+	steps := []struct {
+		name     string
+		advance  time.Duration
+		expected []string
+	}{
+		{"first scan", 0, []string{fmt.Sprintf("task.overdue %d", late.ID), fmt.Sprintf("task.reminder %d", soon.ID)}},
+		{"nothing new", time.Minute, nil},
+		{"soon is overdue", 30 * time.Minute, []string{fmt.Sprintf("task.overdue %d", soon.ID)}},
+		{"later is coming", 90 * time.Minute, []string{fmt.Sprintf("task.reminder %d", later.ID)}},
+		{"later is overdue", 2 * time.Hour, []string{fmt.Sprintf("task.overdue %d", later.ID)}},
+	}
+
+	for _, step := range steps {
+		c.Advance(step.advance)
+		if err := scheduler.Scan(context.Background()); err != nil {
+			t.Fatalf("%s: scan failed: %v", step.name, err)
+		}
+		if events := notifier.take(); !reflect.DeepEqual(events, step.expected) {
+			t.Errorf("%s: expected events %v, got %v", step.name, step.expected, events)
+		}
+	}
+
+	stored, err := s.GetByID(context.Background(), soon.ID)
+	if err != nil {
+		t.Fatalf("Failed to retrieve task: %v", err)
+	}
+	if stored.RemindedAt == nil || !stored.RemindedAt.Equal(start) {
+		t.Errorf("Expected task reminded at %v, got %v", start, stored.RemindedAt)
+	}
+	if expected := start.Add(31 * time.Minute); stored.OverdueAt == nil || !stored.OverdueAt.Equal(expected) {
+		t.Errorf("Expected task overdue at %v, got %v", expected, stored.OverdueAt)
+	}
+}
+
+// TestScheduler_ScanLeavesVersion tests that marking a task keeps its version
+// and update time and is not reported as a change
+func TestScheduler_ScanLeavesVersion(t *testing.T) {
+	c := clock.NewManual(start)
+	var changes []storage.Change
+	s := storage.NewInMemoryStorage(storage.WithClock(c), storage.WithObserver(func(observed []storage.Change) {
+		changes = append(changes, observed...)
+	}))
+	late := mustCreate(t, s, "Late", 0, -time.Hour)
+	changes = nil
+
+	c.Advance(time.Minute)
+	if err := New(s, c, DefaultConfig, &recorder{}).Scan(context.Background()); err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	stored, err := s.GetByID(context.Background(), late.ID)
+	if err != nil {
+		t.Fatalf("Failed to retrieve task: %v", err)
+	}
+	if stored.OverdueAt == nil {
+		t.Fatal("Expected the task to be marked overdue")
+	}
+	if stored.Version != late.Version || !stored.UpdatedAt.Equal(late.UpdatedAt) {
+		t.Errorf("Expected version %d updated at %v, got %d at %v", late.Version, late.UpdatedAt, stored.Version, stored.UpdatedAt)
+	}
+	if len(changes) != 0 {
+		t.Errorf("Expected no changes reported, got %+v", changes)
+	}
+}
+
+// TestScheduler_ScanWithoutReminders tests that a zero reminder lead only marks overdue tasks
+func TestScheduler_ScanWithoutReminders(t *testing.T) {
+	c := clock.NewManual(start)
+	s := storage.NewInMemoryStorage(storage.WithClock(c))
+	late := mustCreate(t, s, "Late", 0, -time.Minute)
+	mustCreate(t, s, "Soon", 0, time.Minute)
+
+	notifier := &recorder{}
+	if err := New(s, c, Config{Interval: time.Minute}, notifier).Scan(context.Background()); err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if events, expected := notifier.take(), []string{fmt.Sprintf("task.overdue %d", late.ID)}; !reflect.DeepEqual(events, expected) {
+		t.Errorf("Expected events %v, got %v", expected, events)
+	}
+}
+
+// TestScheduler_ScanAfterRestart tests that with a persistent backend a
+// restarted scheduler neither repeats events nor misses tasks that came due
+// while it was down
+func TestScheduler_ScanAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	c := clock.NewManual(start)
+	config := Config{Interval: time.Minute, ReminderLead: time.Hour}
+
+	s, err := storage.NewFileStorage(dir, storage.WithClock(c))
+	if err != nil {
+		t.Fatalf("Failed to open file storage: %v", err)
+	}
+	late := mustCreate(t, s, "Late", 0, -time.Hour)
+	soon := mustCreate(t, s, "Soon", 0, 30*time.Minute)
+	notifier := &recorder{}
+	if err := New(s, c, config, notifier).Scan(context.Background()); err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if events := notifier.take(); len(events) != 2 {
+		t.Fatalf("Expected 2 events before the restart, got %v", events)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Failed to close file storage: %v", err)
+	}
+
+	c.Advance(time.Hour)
+	s, err = storage.NewFileStorage(dir, storage.WithClock(c))
+	if err != nil {
+		t.Fatalf("Failed to reopen file storage: %v", err)
+	}
+	defer s.Close()
+	if err := New(s, c, config, notifier).Scan(context.Background()); err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if events, expected := notifier.take(), []string{fmt.Sprintf("task.overdue %d", soon.ID)}; !reflect.DeepEqual(events, expected) {
+		t.Errorf("Expected only task %d to become overdue after task %d, got %v", soon.ID, late.ID, events)
+	}
+}
+
+// TestScheduler_Run tests that Run scans right away and returns once its context is done
+func TestScheduler_Run(t *testing.T) {
+	c := clock.NewManual(start)
+	s := storage.NewInMemoryStorage(storage.WithClock(c))
+	late := mustCreate(t, s, "Late", 0, -time.Hour)
+
+	notified := make(chan Event, 1)
+	notifier := notifierFunc(func(_ context.Context, event Event) error {
+		notified <- event
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		New(s, c, Config{Interval: time.Hour}, notifier).Run(ctx)
+		close(done)
+	}()
+
+	select {
+	case event := <-notified:
+		if event.Type != EventOverdue || event.Task.ID != late.ID {
+			t.Errorf("Expected task %d to be overdue, got %s for task %d", late.ID, event.Type, event.Task.ID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected Run to scan right away")
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected Run to return once its context is done")
+	}
+}
+
+// notifierFunc adapts a function to a Notifier
+type notifierFunc func(ctx context.Context, event Event) error
+
+func (f notifierFunc) Notify(ctx context.Context, event Event) error {
+	return f(ctx, event)
+}
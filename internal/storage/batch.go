@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"task-api/internal/models"
+)
+
+// BatchOpKind identifies the kind of operation in a batch.
+type BatchOpKind string
+
+const (
+	BatchCreate BatchOpKind = "create"
+	BatchUpdate BatchOpKind = "update"
+	BatchDelete BatchOpKind = "delete"
+)
+
+// BatchOp is a single operation of a batch.
+type BatchOp struct {
+	Kind    BatchOpKind
+	Task    *models.Task // Task to create or update; updates are version-checked like Update
+	ID      int          // Task to delete
+	Version int          // Version the task to delete must still have, ErrConflict otherwise; 0 deletes any version
+}
+
+// BatchResult is the outcome of one operation of a batch.
+type BatchResult struct {
+	Task    *models.Task // Created or updated task, nil for deletes
+	Err     error        // Why the operation failed, nil if it succeeded or was not attempted
+	Applied bool         // Whether the operation took effect
+}
+
+// errUnknownBatchOp is the cause reported for an operation of unknown kind.
+var errUnknownBatchOp = errors.New("unknown batch operation")
+
+// check rejects operations that cannot be applied regardless of the stored state.
+func (op BatchOp) check() error {
+	switch op.Kind {
+	case BatchCreate, BatchUpdate:
+		if op.Task == nil {
+			return invalid(string(op.Kind), errNilTask)
+		}
+	case BatchDelete:
+	default:
+		return invalid("batch", fmt.Errorf("%w %q", errUnknownBatchOp, op.Kind))
+	}
+	return nil
+}
+
+// taskID returns the ID of the existing task the operation touches, 0 for creates.
+func (op BatchOp) taskID() int {
+	switch op.Kind {
+	case BatchUpdate:
+		if op.Task != nil {
+			return op.Task.ID
+		}
+	case BatchDelete:
+		return op.ID
+	}
+	return 0
+}
+
+// abortBatch marks every operation except the failed one as not applied,
+// after an atomic batch was rolled back.
+func abortBatch(results []BatchResult, failed int) {
+	for i := range results {
+		if i != failed {
+			results[i] = BatchResult{}
+		}
+	}
+}
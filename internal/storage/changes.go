@@ -0,0 +1,52 @@
+package storage
+
+import "task-api/internal/models"
+
+// ChangeKind says how a write changed a task.
+type ChangeKind string
+
+const (
+	ChangeCreated ChangeKind = "created"
+	ChangeUpdated ChangeKind = "updated"
+	ChangeDeleted ChangeKind = "deleted"
+)
+
+// Change is what a committed write did to one task. A write changing a task
+// several times, such as a transaction, reports a single change with its final
+// state; a task it both created and deleted is not reported at all.
+type Change struct {
+	Kind ChangeKind
+	ID   int
+	Task *models.Task // The task as stored after the write, nil if it was deleted
+}
+
+// collapse merges the changes of a write, in the order they were made, into
+// one change per task, in the order the tasks were first changed.
+func collapse(changes []Change) []Change {
+	merged := make([]Change, 0, len(changes))
+	index := make(map[int]int, len(changes))
+	for _, change := range changes {
+		i, seen := index[change.ID]
+		if !seen {
+			index[change.ID] = len(merged)
+			merged = append(merged, change)
+			continue
+		}
+		switch {
+		case merged[i].Kind != ChangeCreated:
+			merged[i] = change
+		case change.Kind == ChangeDeleted:
+			merged[i].Kind, merged[i].Task = "", nil // Never seen by anybody else
+		default:
+			merged[i].Task = change.Task
+		}
+	}
+
+	result := merged[:0]
+	for _, change := range merged {
+		if change.Kind != "" {
+			result = append(result, change)
+		}
+	}
+	return result
+}
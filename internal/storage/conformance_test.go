@@ -0,0 +1,61 @@
+package storage_test
+
+import (
+	"os"
+	"path/filepath"
+	"task-api/internal/storage"
+	"task-api/internal/storage/storagetest"
+	"testing"
+)
+
+// TestConformance_InMemory runs the conformance suite against InMemoryStorage
+func TestConformance_InMemory(t *testing.T) {
+	storagetest.RunConformance(t, func(t *testing.T, opts ...storage.Option) storage.TaskStorage {
+		return storage.NewInMemoryStorage(opts...)
+	})
+}
+
+// TestConformance_File runs the conformance suite against FileStorage
+func TestConformance_File(t *testing.T) {
+	storagetest.RunConformance(t, func(t *testing.T, opts ...storage.Option) storage.TaskStorage {
+		s, err := storage.NewFileStorage(t.TempDir(), opts...)
+		if err != nil {
+			t.Fatalf("Failed to open file storage: %v", err)
+		}
+		t.Cleanup(func() { s.Close() })
+		return s
+	})
+}
+
+// TestConformance_SQLite runs the conformance suite against SQLStorage backed by SQLite
+func TestConformance_SQLite(t *testing.T) {
+	storagetest.RunConformance(t, func(t *testing.T, opts ...storage.Option) storage.TaskStorage {
+		s, err := storage.NewSQLStorage("sqlite://"+filepath.Join(t.TempDir(), "tasks.db"), opts...)
+		if err != nil {
+			t.Fatalf("Failed to open SQL storage: %v", err)
+		}
+		t.Cleanup(func() { s.Close() })
+		return s
+	})
+}
+
+// TestConformance_Postgres runs the conformance suite against SQLStorage backed by PostgreSQL.
+// Set TEST_POSTGRES_URL to a disposable database to enable it.
+func TestConformance_Postgres(t *testing.T) {
+	url := os.Getenv("TEST_POSTGRES_URL")
+	if url == "" {
+		t.Skip("TEST_POSTGRES_URL not set")
+	}
+
+	storagetest.RunConformance(t, func(t *testing.T, opts ...storage.Option) storage.TaskStorage {
+		s, err := storage.NewSQLStorage(url, opts...)
+		if err != nil {
+			t.Fatalf("Failed to open SQL storage: %v", err)
+		}
+		t.Cleanup(func() { s.Close() })
+		if err := storage.ResetSQLStorage(s); err != nil {
+			t.Fatalf("Failed to reset database: %v", err)
+		}
+		return s
+	})
+}
@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"task-api/internal/models"
+)
+
+// Causes of the ErrInvalid errors rejecting the blockers of a task.
+var (
+	// ErrBlockerNotFound means a task listed in BlockedBy does not exist.
+	ErrBlockerNotFound = errors.New("blocking task not found")
+	// ErrDependencyCycle means a task was made to wait for itself, directly or
+	// through the tasks blocking it.
+	ErrDependencyCycle = errors.New("task cannot be blocked by itself")
+)
+
+// checkRelations rejects task if its parent or its blockers break the rules
+// relating tasks to each other (see checkParent and checkBlockers).
+func checkRelations(tree taskTree, op string, task, current *models.Task) error {
+	if err := checkParent(tree, op, task, current); err != nil {
+		return err
+	}
+	return checkBlockers(tree, op, task, current)
+}
+
+// maintainRelations restores the rules relating tasks to each other after a
+// task changed from before to after; before is nil for creates and after is
+// nil for deletes. A deleted task stops blocking others, then the hierarchy
+// follows (see maintainHierarchy).
+func maintainRelations(tree taskTree, before, after *models.Task) error {
+	if before != nil && after == nil {
+		if err := dropBlocker(tree, before.ID); err != nil {
+			return err
+		}
+	}
+	return maintainHierarchy(tree, before, after)
+}
+
+// checkBlockers rejects task if a task blocking it does not exist, if it would
+// end up waiting for itself, or if it is completed while a blocker is not (see
+// models.Task.CheckBlockers). current is the stored task for updates and nil
+// for creates; an update that neither changes the blockers nor completes the
+// task is not checked again.
+func checkBlockers(tree taskTree, op string, task, current *models.Task) error {
+	id := 0
+	changed, completing := true, task.Status == 1
+	if current != nil {
+		id = current.ID
+		changed = !slices.Equal(current.BlockedBy, task.BlockedBy)
+		completing = completing && (current.Status != 1 || changed)
+	}
+	if !changed && !completing {
+		return nil
+	}
+
+	blockers := make([]*models.Task, 0, len(task.BlockedBy))
+	for _, blockerID := range task.BlockedBy {
+		if blockerID == id {
+			return &Error{Op: op, ID: id, Kind: ErrInvalid, Err: fmt.Errorf("%w (blocked_by %d)", ErrDependencyCycle, blockerID)}
+		}
+		blocker, err := tree.get(blockerID)
+		if errors.Is(err, ErrNotFound) {
+			return &Error{Op: op, ID: id, Kind: ErrInvalid, Err: fmt.Errorf("%w (blocked_by %d)", ErrBlockerNotFound, blockerID)}
+		}
+		if err != nil {
+			return err
+		}
+		blockers = append(blockers, blocker)
+	}
+
+	// A new task is not blocking anything yet, so only updates can close a cycle
+	if changed && id != 0 {
+		if blockerID, err := waitsFor(tree, blockers, id); err != nil {
+			return err
+		} else if blockerID != 0 {
+			return &Error{Op: op, ID: id, Kind: ErrInvalid, Err: fmt.Errorf("%w (blocked_by %d)", ErrDependencyCycle, blockerID)}
+		}
+	}
+
+	if err := task.CheckBlockers(blockers); err != nil {
+		return &Error{Op: op, ID: id, Kind: ErrInvalid, Err: err}
+	}
+	return nil
+}
+
+// waitsFor follows the blockers of blockers, transitively, and returns the
+// first of blockers through which the task with the given ID is reached, or
+// 0 if it is not.
+func waitsFor(tree taskTree, blockers []*models.Task, id int) (int, error) {
+	seen := make(map[int]bool)
+	for _, blocker := range blockers {
+		for stack := []int{blocker.ID}; len(stack) > 0; {
+			next := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if next == id {
+				return blocker.ID, nil
+			}
+			if seen[next] {
+				continue
+			}
+			seen[next] = true
+
+			task, err := tree.get(next)
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return 0, err
+			}
+			stack = append(stack, task.BlockedBy...)
+		}
+	}
+	return 0, nil
+}
+
+// dropBlocker removes the task with the given ID from the blockers of every
+// task it blocks.
+func dropBlocker(tree taskTree, id int) error {
+	dependents, err := tree.dependents(id)
+	if err != nil {
+		return err
+	}
+	for _, dependent := range dependents {
+		dependent.BlockedBy = slices.DeleteFunc(dependent.BlockedBy, func(blocker int) bool { return blocker == id })
+		if len(dependent.BlockedBy) == 0 {
+			dependent.BlockedBy = nil
+		}
+		if _, err := tree.update(dependent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isBlocked reports whether err rejects completing a task because of an
+// incomplete blocker.
+func isBlocked(err error) bool {
+	var validationErr *models.ValidationError
+	return errors.As(err, &validationErr) && len(validationErr.Errors) == 1 &&
+		validationErr.Errors[0].Rule == models.RuleBlocked
+}
@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors describing why a storage operation failed.
+// Every error returned by a TaskStorage matches at most one of them with errors.Is,
+// so callers can react to the cause without inspecting backend-specific errors.
+var (
+	// ErrNotFound means the requested task does not exist.
+	ErrNotFound = errors.New("task not found")
+	// ErrConflict means the operation clashes with the current state of the task.
+	ErrConflict = errors.New("task conflict")
+	// ErrUnavailable means the backend could not serve the request right now
+	// (connection lost, disk failure, deadline exceeded). Retrying may succeed.
+	ErrUnavailable = errors.New("storage unavailable")
+	// ErrInvalid means the task was rejected by the storage layer itself.
+	ErrInvalid = errors.New("invalid task")
+)
+
+// Error describes a failed storage operation.
+// It matches its Kind sentinel and its underlying cause with errors.Is/As.
+type Error struct {
+	Op   string // Operation that failed, e.g. "create", "get"
+	ID   int    // Task ID involved, 0 if none
+	Kind error  // One of ErrNotFound, ErrConflict, ErrUnavailable or ErrInvalid
+	Err  error  // Underlying cause, may be nil
+}
+
+func (e *Error) Error() string {
+	msg := e.Op
+	if e.ID != 0 {
+		msg = fmt.Sprintf("%s task %d", e.Op, e.ID)
+	}
+	msg += ": " + e.Kind.Error()
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap exposes both the kind and the cause to errors.Is and errors.As.
+func (e *Error) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// notFound reports that the task with the given ID does not exist.
+func notFound(op string, id int) error {
+	return &Error{Op: op, ID: id, Kind: ErrNotFound}
+}
+
+// invalid reports a task rejected by the storage layer.
+func invalid(op string, err error) error {
+	return &Error{Op: op, Kind: ErrInvalid, Err: err}
+}
+
+// staleVersion reports an update based on an outdated version of the task.
+func staleVersion(op string, id, got, current int) error {
+	return &Error{Op: op, ID: id, Kind: ErrConflict, Err: fmt.Errorf("version %d is stale, current version is %d", got, current)}
+}
+
+// unavailable reports a backend failure, such as I/O errors or a canceled context.
+func unavailable(op string, id int, err error) error {
+	return &Error{Op: op, ID: id, Kind: ErrUnavailable, Err: err}
+}
+
+// checkContext returns an ErrUnavailable error wrapping ctx.Err() once ctx is done.
+func checkContext(ctx context.Context, op string, id int) error {
+	if err := ctx.Err(); err != nil {
+		return unavailable(op, id, err)
+	}
+	return nil
+}
+
+// errNilTask is the cause reported when a nil task is passed to storage.
+var errNilTask = errors.New("task cannot be nil")
+
+// errTxDone is the cause reported when a transaction is used after it finished.
+var errTxDone = errors.New("transaction has already finished")
+
+// errReadOnly is the cause reported for writes through a view.
+var errReadOnly = errors.New("view is read-only")
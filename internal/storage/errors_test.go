@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// TestError_Is tests that storage errors match their kind and their cause
+func TestError_Is(t *testing.T) {
+	cause := errors.New("connection refused")
+	err := unavailable("get", 7, cause)
+
+	if !errors.Is(err, ErrUnavailable) {
+		t.Error("Expected error to match ErrUnavailable")
+	}
+	if !errors.Is(err, cause) {
+		t.Error("Expected error to match its cause")
+	}
+	if errors.Is(err, ErrNotFound) {
+		t.Error("Expected error not to match ErrNotFound")
+	}
+
+	var storageErr *Error
+	if !errors.As(err, &storageErr) {
+		t.Fatal("Expected errors.As to find *Error")
+	}
+	if storageErr.Op != "get" || storageErr.ID != 7 {
+		t.Errorf("Expected op get and ID 7, got %q and %d", storageErr.Op, storageErr.ID)
+	}
+}
+
+// TestError_Error tests the error message format
+func TestError_Error(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{
+			name:     "not found with ID",
+			err:      notFound("get", 3),
+			expected: "get task 3: task not found",
+		},
+		{
+			name:     "invalid without ID",
+			err:      invalid("create", errNilTask),
+			expected: "create: invalid task: task cannot be nil",
+		},
+		{
+			name:     "unavailable with cause",
+			err:      unavailable("delete", 5, context.Canceled),
+			expected: "delete task 5: storage unavailable: context canceled",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.Error(); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package storage
+
+import "strings"
+
+// dataTables lists every table holding task data. Tables without a foreign key
+// to tasks, like task_blockers, are not emptied by a cascade, so each one has
+// to be named here.
+var dataTables = []string{"task_blockers", "task_tags", "task_tokens", "tasks"}
+
+// ResetSQLStorage empties every data table and restarts task IDs so a shared
+// database can back several conformance runs. Exposed to the external
+// storage_test package only.
+func ResetSQLStorage(s *SQLStorage) error {
+	if s.dialect == dialectPostgres {
+		_, err := s.db.Exec(`TRUNCATE ` + strings.Join(dataTables, ", ") + ` RESTART IDENTITY`)
+		return err
+	}
+	for _, table := range dataTables {
+		if _, err := s.db.Exec(`DELETE FROM ` + table); err != nil {
+			return err
+		}
+	}
+	_, err := s.db.Exec(`DELETE FROM sqlite_sequence`)
+	return err
+}
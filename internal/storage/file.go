@@ -0,0 +1,511 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"task-api/internal/models"
+)
+
+const (
+	logFileName      = "tasks.log"
+	snapshotFileName = "tasks.snapshot.json"
+
+	// defaultSnapshotEvery is the number of log records after which the
+	// log is folded into a fresh snapshot and truncated.
+	defaultSnapshotEvery = 1000
+)
+
+// errStorageClosed is reported for writes after Close.
+var errStorageClosed = errors.New("storage is closed")
+
+// logOp identifies the kind of mutation recorded in the write-ahead log.
+type logOp string
+
+const (
+	opPut    logOp = "put"
+	opDelete logOp = "delete"
+	opBatch  logOp = "batch"
+)
+
+// logRecord is a single line in the append-only log.
+type logRecord struct {
+	Op   logOp        `json:"op"`
+	Task *models.Task `json:"task,omitempty"` // Full task state for opPut
+	ID   int          `json:"id,omitempty"`   // Task ID for opDelete
+
+	// Records of an opBatch, written as one line so a torn write drops the whole batch
+	Records []logRecord `json:"records,omitempty"`
+}
+
+// logWriter is the open write-ahead log: a file, unless a test puts
+// something failing in its place.
+type logWriter interface {
+	io.WriteCloser
+	Sync() error
+	Truncate(size int64) error
+}
+
+// snapshot is the compacted state written during log compaction.
+type snapshot struct {
+	NextID int            `json:"next_id"`
+	Tasks  []*models.Task `json:"tasks"`
+}
+
+// FileStorage implements TaskStorage interface with durable file-based storage.
+// Tasks are served from memory; every mutation is appended to a write-ahead
+// log and fsynced before it is acknowledged. On startup the latest snapshot is
+// loaded and the log replayed on top of it. Once the log grows past a threshold
+// it is compacted into a new snapshot.
+//
+// The context is honored until a mutation is applied in memory; after that the
+// log write always completes so memory and disk never diverge.
+type FileStorage struct {
+	dir           string
+	mem           *InMemoryStorage // Current state, rebuilt from disk on startup
+	log           logWriter        // Append-only write-ahead log
+	logSize       int64            // Length of the complete records in the log
+	failed        error            // Set once a failed log write could not be undone; every later write fails with it
+	records       int              // Records in the log since the last snapshot
+	snapshotEvery int              // Compaction threshold
+	mutex         sync.Mutex       // Serializes mutations so log order matches apply order
+}
+
+// NewFileStorage opens (or creates) a file-backed storage in dir and
+// restores its state from the snapshot and write-ahead log found there.
+func NewFileStorage(dir string, opts ...Option) (*FileStorage, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("create data directory: %w", err)
+	}
+
+	s := &FileStorage{
+		dir:           dir,
+		mem:           NewInMemoryStorage(opts...).(*InMemoryStorage),
+		snapshotEvery: defaultSnapshotEvery,
+	}
+
+	if err := s.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	if err := s.replayLog(); err != nil {
+		return nil, err
+	}
+
+	logFile, err := os.OpenFile(s.path(logFileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open write-ahead log: %w", err)
+	}
+	info, err := logFile.Stat()
+	if err != nil {
+		logFile.Close()
+		return nil, fmt.Errorf("stat write-ahead log: %w", err)
+	}
+	s.log = logFile
+	s.logSize = info.Size()
+
+	return s, nil
+}
+
+// path returns the location of a storage file inside the data directory.
+func (s *FileStorage) path(name string) string {
+	return filepath.Join(s.dir, name)
+}
+
+// loadSnapshot restores state from the last snapshot, if any.
+func (s *FileStorage) loadSnapshot() error {
+	data, err := os.ReadFile(s.path(snapshotFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read snapshot: %w", err)
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("decode snapshot: %w", err)
+	}
+
+	for _, task := range snap.Tasks {
+		upgradeState(task)
+		s.mem.put(task)
+	}
+	s.mem.setNextID(snap.NextID)
+
+	return nil
+}
+
+// replayLog applies every log record written since the last snapshot.
+// A torn final record (from a crash mid-write) is discarded and the log
+// truncated to the last complete record; corruption anywhere else is an error.
+func (s *FileStorage) replayLog() error {
+	logFile, err := os.OpenFile(s.path(logFileName), os.O_RDWR, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open write-ahead log: %w", err)
+	}
+	defer logFile.Close()
+
+	reader := bufio.NewReader(logFile)
+	var offset int64 // End of the last complete record
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if errors.Is(readErr, io.EOF) {
+			if len(line) > 0 {
+				// Incomplete trailing record: the write never finished
+				return s.truncateLog(logFile, offset)
+			}
+			return nil
+		}
+		if readErr != nil {
+			return fmt.Errorf("read write-ahead log: %w", readErr)
+		}
+
+		var record logRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			if _, peekErr := reader.Peek(1); errors.Is(peekErr, io.EOF) {
+				return s.truncateLog(logFile, offset)
+			}
+			return fmt.Errorf("corrupt write-ahead log record at offset %d: %w", offset, err)
+		}
+		if err := s.applyRecord(record); err != nil {
+			return fmt.Errorf("replay write-ahead log record at offset %d: %w", offset, err)
+		}
+
+		offset += int64(len(line))
+		s.records++
+	}
+}
+
+// truncateLog drops a partially written record from the end of the log.
+func (s *FileStorage) truncateLog(logFile *os.File, size int64) error {
+	if err := logFile.Truncate(size); err != nil {
+		return fmt.Errorf("truncate torn write-ahead log record: %w", err)
+	}
+	return logFile.Sync()
+}
+
+// applyRecord replays one log record into the in-memory state.
+func (s *FileStorage) applyRecord(record logRecord) error {
+	switch record.Op {
+	case opPut:
+		if record.Task == nil {
+			return errors.New("put record without task")
+		}
+		upgradeState(record.Task)
+		s.mem.put(record.Task)
+	case opDelete:
+		s.mem.remove(record.ID)
+	case opBatch:
+		for _, nested := range record.Records {
+			if err := s.applyRecord(nested); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unknown operation %q", record.Op)
+	}
+	return nil
+}
+
+// writeRecord durably writes one record to the log. If the write or the sync
+// fails the record is cut off the log again (see undoAppend).
+// The caller must hold s.mutex.
+func (s *FileStorage) writeRecord(record logRecord) error {
+	if s.log == nil {
+		return errStorageClosed
+	}
+	if s.failed != nil {
+		return s.failed
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("encode log record: %w", err)
+	}
+	data = append(data, '\n')
+
+	if _, err := s.log.Write(data); err != nil {
+		return s.undoAppend(fmt.Errorf("write log record: %w", err))
+	}
+	if err := s.log.Sync(); err != nil {
+		return s.undoAppend(fmt.Errorf("sync write-ahead log: %w", err))
+	}
+
+	s.logSize += int64(len(data))
+	s.records++
+	return nil
+}
+
+// undoAppend truncates the log back to its last complete record after a
+// failed write, returning err. Otherwise a torn line would end up in the
+// middle of the log, which cannot be replayed, and a record written but not
+// synced would bring back a write that was rolled back. The log is opened for
+// appending, so the next record lands at the new end. If the truncation fails
+// too the storage refuses every further write, leaving the torn record last,
+// where a restart discards it. The caller must hold s.mutex.
+func (s *FileStorage) undoAppend(err error) error {
+	truncateErr := s.log.Truncate(s.logSize)
+	if truncateErr == nil {
+		truncateErr = s.log.Sync()
+	}
+	if truncateErr != nil {
+		s.failed = fmt.Errorf("write-ahead log cannot be repaired: %w", errors.Join(err, truncateErr))
+		return s.failed
+	}
+	return err
+}
+
+// compactIfDue folds the log into a snapshot once it has grown past the threshold.
+// The caller must hold s.mutex.
+func (s *FileStorage) compactIfDue() {
+	if s.records >= s.snapshotEvery {
+		// The records are already durable; a failed compaction only means the
+		// log keeps growing until the next attempt succeeds.
+		_ = s.compact()
+	}
+}
+
+// Snapshot writes the current state to a snapshot file and truncates the log.
+func (s *FileStorage) Snapshot() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.compact()
+}
+
+// compact writes a snapshot atomically (temp file + rename) and then empties the log.
+// A crash between the two steps is harmless: replaying records already contained
+// in the snapshot yields the same state. The caller must hold s.mutex.
+func (s *FileStorage) compact() error {
+	tasks, nextID := s.mem.state()
+	data, err := json.Marshal(snapshot{NextID: nextID, Tasks: tasks})
+	if err != nil {
+		return fmt.Errorf("encode snapshot: %w", err)
+	}
+
+	tmpPath := s.path(snapshotFileName + ".tmp")
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("create snapshot: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("sync snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close snapshot: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.path(snapshotFileName)); err != nil {
+		return fmt.Errorf("install snapshot: %w", err)
+	}
+	if err := syncDir(s.dir); err != nil {
+		return err
+	}
+
+	if err := s.log.Truncate(0); err != nil {
+		return fmt.Errorf("truncate write-ahead log: %w", err)
+	}
+	s.logSize = 0
+	if err := s.log.Sync(); err != nil {
+		return fmt.Errorf("sync write-ahead log: %w", err)
+	}
+	s.records = 0
+
+	return nil
+}
+
+// syncDir flushes directory metadata so a rename survives a crash.
+func syncDir(dir string) error {
+	d, err := os.Open(dir) // #nosec G304 -- dir is the configured data directory
+	if err != nil {
+		return fmt.Errorf("open data directory: %w", err)
+	}
+	defer d.Close()
+
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("sync data directory: %w", err)
+	}
+	return nil
+}
+
+// Close compacts the log into a final snapshot and releases the log file.
+func (s *FileStorage) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.log == nil {
+		return nil
+	}
+
+	compactErr := s.compact()
+	closeErr := s.log.Close()
+	s.log = nil
+
+	return errors.Join(compactErr, closeErr)
+}
+
+// Create stores a new task and assigns it a unique ID.
+// Returns the task with assigned ID or an error if creation fails.
+func (s *FileStorage) Create(ctx context.Context, task *models.Task) (*models.Task, error) {
+	var created *models.Task
+	err := s.write(ctx, "create", 0, func(tx TaskStorage) error {
+		var err error
+		created, err = tx.Create(ctx, task)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// GetAll retrieves all tasks from storage.
+// Returns slice of tasks or error if retrieval fails.
+func (s *FileStorage) GetAll(ctx context.Context) ([]*models.Task, error) {
+	return s.mem.GetAll(ctx)
+}
+
+// Query returns the page of tasks selected by q.
+// Reads are served from memory and never touch the log.
+func (s *FileStorage) Query(ctx context.Context, q TaskQuery) (*TaskPage, error) {
+	return s.mem.Query(ctx, q)
+}
+
+// Search returns the tasks matching the words of query, most relevant first.
+// The index lives in memory and is rebuilt while replaying the log.
+func (s *FileStorage) Search(ctx context.Context, query string, limit int) ([]*SearchResult, error) {
+	return s.mem.Search(ctx, query, limit)
+}
+
+// GetByID retrieves a specific task by its ID.
+// Returns the task or error if not found or retrieval fails.
+func (s *FileStorage) GetByID(ctx context.Context, id int) (*models.Task, error) {
+	return s.mem.GetByID(ctx, id)
+}
+
+// Update modifies an existing task in storage.
+// Returns the stored task with its new version, or error if the task doesn't
+// exist, its version is stale or update fails.
+func (s *FileStorage) Update(ctx context.Context, task *models.Task) (*models.Task, error) {
+	if task == nil {
+		return nil, invalid("update", errNilTask)
+	}
+
+	var updated *models.Task
+	err := s.write(ctx, "update", task.ID, func(tx TaskStorage) error {
+		var err error
+		updated, err = tx.Update(ctx, task)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// MarkAlerts stores the alerts of a task without bumping its version.
+// The marked task is logged like any other write, so the alerts survive a restart.
+func (s *FileStorage) MarkAlerts(ctx context.Context, id, version int, alerts models.Alerts) (*models.Task, error) {
+	var marked *models.Task
+	err := s.write(ctx, "mark", id, func(tx TaskStorage) error {
+		var err error
+		marked, err = tx.MarkAlerts(ctx, id, version, alerts)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return marked, nil
+}
+
+// Delete removes a task from storage by ID.
+// Returns error if task doesn't exist or deletion fails.
+func (s *FileStorage) Delete(ctx context.Context, id int) error {
+	return s.write(ctx, "delete", id, func(tx TaskStorage) error {
+		return tx.Delete(ctx, id)
+	})
+}
+
+// Batch applies ops in memory and logs every change as a single record, so
+// an atomic batch is also all-or-nothing on disk and a large import costs
+// one fsync. If the log write fails the batch is undone.
+func (s *FileStorage) Batch(ctx context.Context, ops []BatchOp, atomic bool) ([]BatchResult, error) {
+	var results []BatchResult
+	err := s.write(ctx, "batch", 0, func(tx TaskStorage) error {
+		var err error
+		results, err = tx.Batch(ctx, ops, atomic)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// WithTx runs fn in a transaction of the in-memory state and logs everything
+// it changed as a single batch record when it commits, so the transaction is
+// all-or-nothing on disk as well. If the log write fails the transaction is
+// rolled back and ErrUnavailable returned.
+func (s *FileStorage) WithTx(ctx context.Context, fn func(tx TaskStorage) error) error {
+	return s.write(ctx, "commit", 0, fn)
+}
+
+// View runs fn with a read-only view of the in-memory state. Nothing is
+// written, so the log is left alone.
+func (s *FileStorage) View(ctx context.Context, fn func(tx TaskStorage) error) error {
+	return s.mem.View(ctx, fn)
+}
+
+// write runs fn in a transaction of the in-memory state and logs the final
+// state of every task it changed when it commits: a single change as a plain
+// record, several as one batch record. Writes go through a transaction even
+// for a single task because a write may change other tasks of the hierarchy.
+// If the log write fails the transaction is rolled back and an ErrUnavailable
+// error for op and id returned.
+func (s *FileStorage) write(ctx context.Context, op string, id int, fn func(tx TaskStorage) error) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	err := s.mem.withTx(ctx, fn, func(changes []Change) error {
+		if len(changes) == 0 {
+			return nil
+		}
+
+		records := make([]logRecord, 0, len(changes))
+		for _, change := range changes {
+			if change.Task == nil {
+				records = append(records, logRecord{Op: opDelete, ID: change.ID})
+			} else {
+				records = append(records, logRecord{Op: opPut, Task: change.Task})
+			}
+		}
+		record := logRecord{Op: opBatch, Records: records}
+		if len(records) == 1 {
+			record = records[0]
+		}
+		// The in-memory lock is held here, so compaction has to wait until it is released
+		if err := s.writeRecord(record); err != nil {
+			return unavailable(op, id, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.compactIfDue()
+	return nil
+}
@@ -0,0 +1,526 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"task-api/internal/models"
+	"testing"
+	"time"
+)
+
+// newTestFileStorage opens a file storage that is closed when the test finishes
+func newTestFileStorage(t *testing.T, dir string) *FileStorage {
+	t.Helper()
+
+	s, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatalf("Failed to open file storage: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// reopen simulates a crash and restart: the log is released without the
+// final snapshot that Close would write, then the directory is loaded again
+func reopen(t *testing.T, s *FileStorage) *FileStorage {
+	t.Helper()
+
+	s.mutex.Lock()
+	s.log.Close()
+	s.log = nil
+	s.mutex.Unlock()
+
+	return newTestFileStorage(t, s.dir)
+}
+
+// TestFileStorage_ReplaysLogAfterCrash tests that every acknowledged write survives a restart
+func TestFileStorage_ReplaysLogAfterCrash(t *testing.T) {
+	s := newTestFileStorage(t, t.TempDir())
+
+	task1, _ := models.NewTask("Keep me", 0)
+	created1, err := s.Create(context.Background(), task1)
+	if err != nil {
+		t.Fatalf("Failed to create task1: %v", err)
+	}
+	task2, _ := models.NewTask("Delete me", 0)
+	created2, err := s.Create(context.Background(), task2)
+	if err != nil {
+		t.Fatalf("Failed to create task2: %v", err)
+	}
+
+	updated, _ := models.NewTask("Keep me - updated", 1)
+	updated.ID = created1.ID
+	updated.Version = created1.Version
+	if _, err := s.Update(context.Background(), updated); err != nil {
+		t.Fatalf("Failed to update task1: %v", err)
+	}
+	if err := s.Delete(context.Background(), created2.ID); err != nil {
+		t.Fatalf("Failed to delete task2: %v", err)
+	}
+
+	restarted := reopen(t, s)
+
+	tasks, err := restarted.GetAll(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get tasks after restart: %v", err)
+	}
+	if len(tasks) != 1 {
+		t.Fatalf("Expected 1 task after restart, got %d", len(tasks))
+	}
+	if tasks[0].Name != "Keep me - updated" || tasks[0].Status != 1 || tasks[0].Version != 2 {
+		t.Errorf("Expected updated task to be replayed, got {%s %d v%d}", tasks[0].Name, tasks[0].Status, tasks[0].Version)
+	}
+
+	// The deleted task held the highest ID; it must not be handed out again
+	task3, _ := models.NewTask("New task", 0)
+	created3, err := restarted.Create(context.Background(), task3)
+	if err != nil {
+		t.Fatalf("Failed to create task after restart: %v", err)
+	}
+	if created3.ID <= created2.ID {
+		t.Errorf("Expected ID greater than %d after restart, got %d", created2.ID, created3.ID)
+	}
+}
+
+// TestFileStorage_SnapshotCompactsLog tests that compaction folds the log into a snapshot
+func TestFileStorage_SnapshotCompactsLog(t *testing.T) {
+	s := newTestFileStorage(t, t.TempDir())
+	s.snapshotEvery = 3
+
+	for i := 0; i < 4; i++ {
+		task, _ := models.NewTask("Task", 0)
+		if _, err := s.Create(context.Background(), task); err != nil {
+			t.Fatalf("Failed to create task: %v", err)
+		}
+	}
+
+	// Three records triggered compaction; only the fourth remains in the log
+	data, err := os.ReadFile(filepath.Join(s.dir, logFileName))
+	if err != nil {
+		t.Fatalf("Failed to read log: %v", err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 1 {
+		t.Errorf("Expected 1 record in log after compaction, got %d", lines)
+	}
+	if _, err := os.Stat(filepath.Join(s.dir, snapshotFileName)); err != nil {
+		t.Errorf("Expected snapshot file to exist: %v", err)
+	}
+
+	restarted := reopen(t, s)
+	tasks, err := restarted.GetAll(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get tasks after restart: %v", err)
+	}
+	if len(tasks) != 4 {
+		t.Errorf("Expected 4 tasks from snapshot plus log, got %d", len(tasks))
+	}
+}
+
+// TestFileStorage_CloseWritesSnapshot tests that a clean shutdown leaves an empty log
+func TestFileStorage_CloseWritesSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatalf("Failed to open file storage: %v", err)
+	}
+
+	task, _ := models.NewTask("Task", 1)
+	if _, err := s.Create(context.Background(), task); err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Failed to close storage: %v", err)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, logFileName))
+	if err != nil {
+		t.Fatalf("Failed to stat log: %v", err)
+	}
+	if info.Size() != 0 {
+		t.Errorf("Expected empty log after clean shutdown, got %d bytes", info.Size())
+	}
+
+	restarted := newTestFileStorage(t, dir)
+	tasks, _ := restarted.GetAll(context.Background())
+	if len(tasks) != 1 {
+		t.Errorf("Expected 1 task after restart, got %d", len(tasks))
+	}
+}
+
+// TestFileStorage_TornRecord tests recovery from a crash in the middle of a log write
+func TestFileStorage_TornRecord(t *testing.T) {
+	s := newTestFileStorage(t, t.TempDir())
+
+	task, _ := models.NewTask("Complete record", 0)
+	if _, err := s.Create(context.Background(), task); err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+
+	s.mutex.Lock()
+	if _, err := io.WriteString(s.log, `{"op":"put","task":{"id":2,"na`); err != nil {
+		t.Fatalf("Failed to write torn record: %v", err)
+	}
+	s.mutex.Unlock()
+
+	restarted := reopen(t, s)
+	tasks, err := restarted.GetAll(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get tasks after restart: %v", err)
+	}
+	if len(tasks) != 1 {
+		t.Errorf("Expected torn record to be discarded, got %d tasks", len(tasks))
+	}
+
+	// New records must land after the last complete one, not after the garbage
+	if _, err := restarted.Create(context.Background(), task); err != nil {
+		t.Fatalf("Failed to create task after recovery: %v", err)
+	}
+	again := reopen(t, restarted)
+	tasks, err = again.GetAll(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get tasks after second restart: %v", err)
+	}
+	if len(tasks) != 2 {
+		t.Errorf("Expected 2 tasks after second restart, got %d", len(tasks))
+	}
+}
+
+// TestFileStorage_CorruptLog tests that corruption before the last record is reported
+func TestFileStorage_CorruptLog(t *testing.T) {
+	dir := t.TempDir()
+	content := "not json\n" + `{"op":"put","task":{"id":1,"name":"Task","status":0}}` + "\n"
+	if err := os.WriteFile(filepath.Join(dir, logFileName), []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write log: %v", err)
+	}
+
+	if _, err := NewFileStorage(dir); err == nil {
+		t.Error("Expected error opening storage with a corrupt log")
+	}
+}
+
+// TestFileStorage_LegacyState tests that tasks logged before workflows existed
+// are replayed into the state matching their status
+func TestFileStorage_LegacyState(t *testing.T) {
+	dir := t.TempDir()
+	content := `{"op":"put","task":{"id":1,"name":"Open","status":0}}` + "\n" +
+		`{"op":"put","task":{"id":2,"name":"Finished","status":1}}` + "\n"
+	if err := os.WriteFile(filepath.Join(dir, logFileName), []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write log: %v", err)
+	}
+
+	s := newTestFileStorage(t, dir)
+	page, err := s.Query(context.Background(), TaskQuery{State: "done"})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(page.Tasks) != 1 || page.Tasks[0].ID != 2 {
+		t.Errorf("Expected the finished task in state done, got %+v", page.Tasks)
+	}
+}
+
+// TestFileStorage_SearchAfterRestart tests that the search index is rebuilt on replay
+func TestFileStorage_SearchAfterRestart(t *testing.T) {
+	s := newTestFileStorage(t, t.TempDir())
+
+	task, _ := models.NewTask("Renew passport", 0)
+	created, _ := s.Create(context.Background(), task)
+	created.Name = "Renew driving licence"
+	if _, err := s.Update(context.Background(), created); err != nil {
+		t.Fatalf("Failed to update task: %v", err)
+	}
+
+	s = reopen(t, s)
+
+	if results, _ := s.Search(context.Background(), "passport", 0); len(results) != 0 {
+		t.Errorf("Expected replaced name to be unindexed after restart, got %d results", len(results))
+	}
+	results, err := s.Search(context.Background(), "licence", 0)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Task.ID != created.ID {
+		t.Errorf("Expected task %d to be found after restart, got %+v", created.ID, results)
+	}
+}
+
+// TestFileStorage_BatchSurvivesRestart tests that a batch is logged as a single record and replayed
+func TestFileStorage_BatchSurvivesRestart(t *testing.T) {
+	s := newTestFileStorage(t, t.TempDir())
+
+	existing, _ := models.NewTask("Existing", 0)
+	created, _ := s.Create(context.Background(), existing)
+
+	first, _ := models.NewTask("Imported one", 0)
+	second, _ := models.NewTask("Imported two", 1)
+	results, err := s.Batch(context.Background(), []BatchOp{
+		{Kind: BatchCreate, Task: first},
+		{Kind: BatchCreate, Task: second},
+		{Kind: BatchDelete, ID: created.ID},
+	}, true)
+	if err != nil {
+		t.Fatalf("Batch failed: %v", err)
+	}
+	if s.records != 2 {
+		t.Errorf("Expected the batch to be logged as one record, got %d records", s.records)
+	}
+
+	restarted := reopen(t, s)
+	tasks, err := restarted.GetAll(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get tasks after restart: %v", err)
+	}
+	expected := []*models.Task{results[0].Task, results[1].Task}
+	if !reflect.DeepEqual(tasks, expected) {
+		t.Errorf("Expected %v after restart, got %v", expected, tasks)
+	}
+}
+
+// TestFileStorage_TornBatchRecord tests that a batch torn by a crash is dropped as a whole
+func TestFileStorage_TornBatchRecord(t *testing.T) {
+	s := newTestFileStorage(t, t.TempDir())
+
+	s.mutex.Lock()
+	if _, err := io.WriteString(s.log, `{"op":"batch","records":[{"op":"put","task":{"id":1,"name":"One","status":0,"version":1}},{"op":"put","task":{"id":2,"na`); err != nil {
+		t.Fatalf("Failed to write torn record: %v", err)
+	}
+	s.mutex.Unlock()
+
+	restarted := reopen(t, s)
+	tasks, err := restarted.GetAll(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get tasks after restart: %v", err)
+	}
+	if len(tasks) != 0 {
+		t.Errorf("Expected torn batch to be discarded entirely, got %d tasks", len(tasks))
+	}
+}
+
+// TestFileStorage_TxSurvivesRestart tests that a committed transaction is logged
+// as one record and replayed after a restart
+func TestFileStorage_TxSurvivesRestart(t *testing.T) {
+	ctx := context.Background()
+	s := newTestFileStorage(t, t.TempDir())
+
+	existing, _ := models.NewTask("Existing", 0)
+	created, _ := s.Create(ctx, existing)
+
+	err := s.WithTx(ctx, func(tx TaskStorage) error {
+		task, _ := models.NewTask("Created in tx", 1)
+		if _, err := tx.Create(ctx, task); err != nil {
+			return err
+		}
+		update := created.Clone()
+		update.Name = "Renamed in tx"
+		if _, err := tx.Update(ctx, update); err != nil {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithTx failed: %v", err)
+	}
+	if s.records != 2 {
+		t.Errorf("Expected the transaction to be logged as one record, got %d records", s.records)
+	}
+
+	expected, _ := s.GetAll(ctx)
+	restarted := reopen(t, s)
+	tasks, err := restarted.GetAll(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get tasks after restart: %v", err)
+	}
+	if !reflect.DeepEqual(tasks, expected) {
+		t.Errorf("Expected %v after restart, got %v", expected, tasks)
+	}
+}
+
+// TestFileStorage_ViewReadsOnly tests that a view neither blocks other
+// readers nor writes to the log
+func TestFileStorage_ViewReadsOnly(t *testing.T) {
+	ctx := context.Background()
+	s := newTestFileStorage(t, t.TempDir())
+	task, _ := models.NewTask("Existing", 0)
+	created, _ := s.Create(ctx, task)
+	records := s.records
+
+	err := s.View(ctx, func(tx TaskStorage) error {
+		read := make(chan error, 1)
+		go func() {
+			_, err := s.GetByID(ctx, created.ID)
+			read <- err
+		}()
+		select {
+		case err := <-read:
+			return err
+		case <-time.After(5 * time.Second):
+			return errors.New("reader blocked by the view")
+		}
+	})
+	if err != nil {
+		t.Fatalf("View failed: %v", err)
+	}
+	if s.records != records {
+		t.Errorf("Expected the view to log nothing, got %d new records", s.records-records)
+	}
+}
+
+// TestFileStorage_TxLogFailure tests that a transaction whose log write fails
+// is rolled back in memory too
+func TestFileStorage_TxLogFailure(t *testing.T) {
+	ctx := context.Background()
+	s := newTestFileStorage(t, t.TempDir())
+
+	existing, _ := models.NewTask("Existing", 0)
+	created, _ := s.Create(ctx, existing)
+
+	s.mutex.Lock()
+	s.log.Close() // Every following write fails
+	s.mutex.Unlock()
+
+	err := s.WithTx(ctx, func(tx TaskStorage) error {
+		task, _ := models.NewTask("Never logged", 0)
+		if _, err := tx.Create(ctx, task); err != nil {
+			return err
+		}
+		return tx.Delete(ctx, created.ID)
+	})
+	if !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("Expected ErrUnavailable, got %v", err)
+	}
+
+	tasks, _ := s.GetAll(ctx)
+	if !reflect.DeepEqual(tasks, []*models.Task{created}) {
+		t.Errorf("Expected only %v after the failed commit, got %v", created, tasks)
+	}
+}
+
+// failingLog fails the next write to the log it wraps: halfway through the
+// record, or after all of it when syncing. With failTruncate the log cannot
+// be truncated either.
+type failingLog struct {
+	logWriter
+	tornWrite, failSync, failTruncate bool
+}
+
+func (l *failingLog) Write(p []byte) (int, error) {
+	if l.tornWrite {
+		l.tornWrite = false
+		n, _ := l.logWriter.Write(p[:len(p)/2])
+		return n, errors.New("no space left on device")
+	}
+	return l.logWriter.Write(p)
+}
+
+func (l *failingLog) Sync() error {
+	if l.failSync {
+		l.failSync = false
+		return errors.New("input/output error")
+	}
+	return l.logWriter.Sync()
+}
+
+func (l *failingLog) Truncate(size int64) error {
+	if l.failTruncate {
+		return errors.New("input/output error")
+	}
+	return l.logWriter.Truncate(size)
+}
+
+// TestFileStorage_FailedLogWrite tests that a write the log rejects leaves
+// nothing behind: later writes are logged and the store reopens without it
+func TestFileStorage_FailedLogWrite(t *testing.T) {
+	tests := []struct {
+		name          string
+		log           failingLog
+		expectedAfter bool // Whether writes succeed after the failed one
+		expected      []string
+	}{
+		{name: "torn write", log: failingLog{tornWrite: true}, expectedAfter: true, expected: []string{"Before", "After"}},
+		{name: "failed sync", log: failingLog{failSync: true}, expectedAfter: true, expected: []string{"Before", "After"}},
+		{name: "torn write not truncated", log: failingLog{tornWrite: true, failTruncate: true}, expected: []string{"Before"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestFileStorage(t, t.TempDir())
+			create := func(name string) error {
+				task, _ := models.NewTask(name, 0)
+				_, err := s.Create(ctx, task)
+				return err
+			}
+			if err := create("Before"); err != nil {
+				t.Fatalf("Failed to create task: %v", err)
+			}
+
+			s.mutex.Lock()
+			tt.log.logWriter = s.log
+			s.log = &tt.log
+			s.mutex.Unlock()
+
+			if err := create("Rejected"); !errors.Is(err, ErrUnavailable) {
+				t.Fatalf("Expected ErrUnavailable, got %v", err)
+			}
+			if err := create("After"); (err == nil) != tt.expectedAfter {
+				t.Fatalf("Expected the next write to succeed: %v, got %v", tt.expectedAfter, err)
+			}
+
+			restarted := reopen(t, s)
+			tasks, err := restarted.GetAll(ctx)
+			if err != nil {
+				t.Fatalf("Failed to get tasks after restart: %v", err)
+			}
+			var names []string
+			for _, task := range tasks {
+				names = append(names, task.Name)
+			}
+			if !reflect.DeepEqual(names, tt.expected) {
+				t.Errorf("Expected %v after restart, got %v", tt.expected, names)
+			}
+		})
+	}
+}
+
+// TestFileStorage_HierarchySurvivesRestart tests that the changes a single
+// write makes to other tasks of the hierarchy are logged with it
+func TestFileStorage_HierarchySurvivesRestart(t *testing.T) {
+	ctx := context.Background()
+	s := newTestFileStorage(t, t.TempDir())
+
+	epic, _ := models.NewTask("Epic", 0)
+	parent, _ := s.Create(ctx, epic)
+	story, _ := models.NewTaskWithDetails("Story", 0, models.Details{ParentID: parent.ID})
+	child, _ := s.Create(ctx, story)
+	subtask, _ := models.NewTaskWithDetails("Subtask", 0, models.Details{ParentID: child.ID})
+	grandchild, _ := s.Create(ctx, subtask)
+
+	// Completing the grandchild completes its ancestors; deleting the child moves it up
+	done := grandchild.Clone()
+	if err := done.Update(done.Name, 1); err != nil {
+		t.Fatalf("Failed to complete task: %v", err)
+	}
+	if _, err := s.Update(ctx, done); err != nil {
+		t.Fatalf("Failed to update task: %v", err)
+	}
+	if err := s.Delete(ctx, child.ID); err != nil {
+		t.Fatalf("Failed to delete task: %v", err)
+	}
+
+	expected, _ := s.GetAll(ctx)
+	if len(expected) != 2 || expected[0].Status != 1 || expected[1].ParentID != parent.ID {
+		t.Fatalf("Unexpected tasks before restart: %v", expected)
+	}
+	restarted := reopen(t, s)
+	tasks, err := restarted.GetAll(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get tasks after restart: %v", err)
+	}
+	if !reflect.DeepEqual(tasks, expected) {
+		t.Errorf("Expected %v after restart, got %v", expected, tasks)
+	}
+}
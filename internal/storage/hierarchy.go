@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"task-api/internal/models"
+)
+
+// Causes of the ErrInvalid errors rejecting the parent of a task.
+var (
+	// ErrParentNotFound means the parent of a task does not exist.
+	ErrParentNotFound = errors.New("parent task not found")
+	// ErrParentCycle means a task was made a subtask of itself or of one of its own subtasks.
+	ErrParentCycle = errors.New("task cannot be a subtask of itself")
+)
+
+// taskTree gives the hierarchy and dependency rules access to the tasks of a
+// backend from within the lock or transaction of the write that triggered them.
+type taskTree interface {
+	// get returns the task with the given ID, or an ErrNotFound error.
+	get(id int) (*models.Task, error)
+	// children returns the direct subtasks of a task ordered by ID.
+	children(id int) ([]*models.Task, error)
+	// dependents returns the tasks blocked by a task ordered by ID.
+	dependents(id int) ([]*models.Task, error)
+	// update stores a changed task through the backend's full update path,
+	// hierarchy and dependency rules included, and returns it.
+	update(task *models.Task) (*models.Task, error)
+}
+
+// checkParent rejects task if its parent does not exist, or if the task would
+// become its own ancestor. current is the stored task for updates and nil for
+// creates; an update that keeps the parent is not checked again.
+func checkParent(tree taskTree, op string, task, current *models.Task) error {
+	id := 0
+	if current != nil {
+		if current.ParentID == task.ParentID {
+			return nil
+		}
+		id = current.ID
+	}
+
+	// Walk up from the new parent; meeting the task itself means a cycle
+	seen := make(map[int]bool)
+	for ancestor := task.ParentID; ancestor != 0 && !seen[ancestor]; {
+		if ancestor == id {
+			return &Error{Op: op, ID: id, Kind: ErrInvalid, Err: fmt.Errorf("%w (parent_id %d)", ErrParentCycle, task.ParentID)}
+		}
+		seen[ancestor] = true
+
+		parent, err := tree.get(ancestor)
+		switch {
+		case errors.Is(err, ErrNotFound) && ancestor == task.ParentID:
+			return &Error{Op: op, ID: id, Kind: ErrInvalid, Err: fmt.Errorf("%w (parent_id %d)", ErrParentNotFound, task.ParentID)}
+		case errors.Is(err, ErrNotFound):
+			// A dangling link further up cannot lead back to the task
+			return nil
+		case err != nil:
+			return err
+		}
+		ancestor = parent.ParentID
+	}
+	return nil
+}
+
+// maintainHierarchy restores the hierarchy rules after a task changed from
+// before to after; before is nil for creates and after is nil for deletes.
+// The subtasks of a deleted task move up to its parent, and every parent
+// whose subtasks changed is rolled up (see rollUp).
+func maintainHierarchy(tree taskTree, before, after *models.Task) error {
+	if before != nil && after == nil {
+		children, err := tree.children(before.ID)
+		if err != nil {
+			return err
+		}
+		for _, child := range children {
+			child.ParentID = before.ParentID
+			if _, err := tree.update(child); err != nil {
+				return err
+			}
+		}
+	}
+
+	moved := before == nil || after == nil || before.ParentID != after.ParentID
+	if before != nil && moved {
+		if err := rollUp(tree, before.ParentID); err != nil {
+			return err
+		}
+	}
+	if after != nil && (moved || before.Status != after.Status) {
+		return rollUp(tree, after.ParentID)
+	}
+	return nil
+}
+
+// rollUp completes the task with the given ID once all of its subtasks are
+// completed, and reopens it when one of them is not, as far as the workflow
+// allows the transition and no incomplete task blocks it. Tasks without
+// subtasks are left alone. Changing the task rolls up its own parent in turn,
+// through tree.update.
+func rollUp(tree taskTree, id int) error {
+	if id == 0 {
+		return nil
+	}
+	parent, err := tree.get(id)
+	if errors.Is(err, ErrNotFound) {
+		// The parent was deleted by the same write
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	children, err := tree.children(id)
+	if err != nil || len(children) == 0 {
+		return err
+	}
+
+	status := 1
+	for _, child := range children {
+		if child.Status != 1 {
+			status = 0
+			break
+		}
+	}
+	if parent.Status == status {
+		return nil
+	}
+
+	workflow := models.DefaultWorkflow()
+	state := workflow.StateForStatus(parent.State, status)
+	if state == "" || !workflow.CanTransition(parent.State, state) {
+		return nil
+	}
+	parent.State, parent.Status = state, status
+	if _, err = tree.update(parent); isBlocked(err) {
+		return nil
+	}
+	return err
+}
@@ -1,118 +1,802 @@
 package storage
 
 import (
-	"errors"
+	"context"
+	"slices"
+	"sort"
 	"sync"
 	"task-api/internal/models"
 )
 
 // InMemoryStorage implements TaskStorage interface using in-memory storage.
 // It provides thread-safe operations for storing and retrieving tasks.
+// Tasks are copied on the way in and out, so callers never share the
+// pointers held in the map and the mutex fully protects stored data.
+// Operations are quick and never block on I/O, so the context is only checked
+// on entry: a request that is already canceled does no work.
 type InMemoryStorage struct {
 	tasks  map[int]*models.Task // Map of ID to Task
+	index  *searchIndex         // Full-text index over the stored tasks
 	nextID int                  // Auto-incrementing ID counter
+	opts   options              // Clock for timestamps
 	mutex  sync.RWMutex         // Protects concurrent access
 }
 
 // NewInMemoryStorage creates a new in-memory storage instance.
 // Returns a storage implementation ready for use.
-func NewInMemoryStorage() TaskStorage {
+func NewInMemoryStorage(opts ...Option) TaskStorage {
 	return &InMemoryStorage{
 		tasks:  make(map[int]*models.Task),
+		index:  newSearchIndex(),
 		nextID: 1, // Start IDs from 1
+		opts:   newOptions(opts),
 	}
 }
 
 // Create stores a new task and assigns it a unique ID.
 // Returns the task with assigned ID or an error if creation fails.
-func (s *InMemoryStorage) Create(task *models.Task) (*models.Task, error) {
+func (s *InMemoryStorage) Create(ctx context.Context, task *models.Task) (*models.Task, error) {
+	if err := checkContext(ctx, "create", 0); err != nil {
+		return nil, err
+	}
+
 	if task == nil {
-		return nil, errors.New("task cannot be nil")
+		return nil, invalid("create", errNilTask)
 	}
 
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	// Create a copy of the task with assigned ID
-	newTask := &models.Task{
-		ID:     s.nextID,
-		Name:   task.Name,
-		Status: task.Status,
+	var log undoLog
+	created, err := s.applyLocked(BatchOp{Kind: BatchCreate, Task: task}, &log)
+	if err != nil {
+		return nil, err
 	}
+	s.publishLocked(log)
+	return created.Clone(), nil
+}
 
-	// Store the task
-	s.tasks[s.nextID] = newTask
-	s.nextID++
+// GetAll retrieves all tasks from storage ordered by ID.
+// Returns slice of tasks or error if retrieval fails.
+func (s *InMemoryStorage) GetAll(ctx context.Context) ([]*models.Task, error) {
+	if err := checkContext(ctx, "list", 0); err != nil {
+		return nil, err
+	}
 
-	return newTask, nil
+	tasks, _ := s.state()
+	return tasks, nil
 }
 
-// GetAll retrieves all tasks from storage.
-// Returns slice of tasks or error if retrieval fails.
-func (s *InMemoryStorage) GetAll() ([]*models.Task, error) {
+// Query returns the page of tasks selected by q.
+// Returns ErrInvalid if the query itself is malformed.
+func (s *InMemoryStorage) Query(ctx context.Context, q TaskQuery) (*TaskPage, error) {
+	if err := checkContext(ctx, "query", 0); err != nil {
+		return nil, err
+	}
+	if err := q.validate(); err != nil {
+		return nil, invalid("query", err)
+	}
+
 	s.mutex.RLock()
-	defer s.mutex.RUnlock()
+	matched := s.matchLocked(q)
+	s.mutex.RUnlock()
 
-	// Create slice to hold all tasks
-	tasks := make([]*models.Task, 0, len(s.tasks))
+	return pageOf(q, matched), nil
+}
 
-	// Copy all tasks to slice
+// matchLocked returns copies of the tasks matching q's filters, in no particular order.
+// The caller must hold the mutex.
+func (s *InMemoryStorage) matchLocked(q TaskQuery) []*models.Task {
+	matched := make([]*models.Task, 0)
 	for _, task := range s.tasks {
-		tasks = append(tasks, task)
+		if q.Matches(task) {
+			matched = append(matched, task.Clone())
+		}
 	}
+	return matched
+}
 
-	return tasks, nil
+// pageOf sorts the tasks matching q and cuts out the page q asks for.
+func pageOf(q TaskQuery, matched []*models.Task) *TaskPage {
+	sort.Slice(matched, func(i, j int) bool {
+		return q.compare(*CursorAfter(matched[i]), *CursorAfter(matched[j])) < 0
+	})
+	page := &TaskPage{Total: len(matched)}
+
+	// Skip everything up to and including the cursor position, or the offset
+	start := q.Offset
+	if q.After != nil {
+		start = sort.Search(len(matched), func(i int) bool {
+			return q.compare(*CursorAfter(matched[i]), *q.After) > 0
+		})
+	}
+	if start > len(matched) {
+		start = len(matched)
+	}
+	matched = matched[start:]
+
+	if q.Limit > 0 && len(matched) > q.Limit {
+		matched = matched[:q.Limit]
+		page.HasMore = true
+	}
+
+	page.Tasks = matched
+	return page
+}
+
+// Search returns the tasks matching the words of query, most relevant first.
+// At most limit results are returned when limit > 0.
+func (s *InMemoryStorage) Search(ctx context.Context, query string, limit int) ([]*SearchResult, error) {
+	if err := checkContext(ctx, "search", 0); err != nil {
+		return nil, err
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.searchLocked(query, limit), nil
+}
+
+// searchLocked ranks the stored tasks against query. The caller must hold the mutex.
+func (s *InMemoryStorage) searchLocked(query string, limit int) []*SearchResult {
+	ids, scores := rank(s.index.postings, queryTokens(query), len(s.tasks), limit)
+	results := make([]*SearchResult, 0, len(ids))
+	for _, id := range ids {
+		results = append(results, &SearchResult{Task: s.tasks[id].Clone(), Score: scores[id]})
+	}
+	return results
 }
 
 // GetByID retrieves a specific task by its ID.
 // Returns the task or error if not found or retrieval fails.
-func (s *InMemoryStorage) GetByID(id int) (*models.Task, error) {
+func (s *InMemoryStorage) GetByID(ctx context.Context, id int) (*models.Task, error) {
+	if err := checkContext(ctx, "get", id); err != nil {
+		return nil, err
+	}
+
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
+	return s.getLocked(id)
+}
+
+// getLocked returns a copy of the stored task with the given ID.
+// The caller must hold the mutex.
+func (s *InMemoryStorage) getLocked(id int) (*models.Task, error) {
 	task, exists := s.tasks[id]
 	if !exists {
-		return nil, errors.New("task not found")
+		return nil, notFound("get", id)
 	}
 
-	return task, nil
+	return task.Clone(), nil
 }
 
 // Update modifies an existing task in storage.
-// Returns error if task doesn't exist or update fails.
-func (s *InMemoryStorage) Update(task *models.Task) error {
+// Returns the stored task with its new version, or error if the task doesn't
+// exist, its version is stale or update fails.
+func (s *InMemoryStorage) Update(ctx context.Context, task *models.Task) (*models.Task, error) {
 	if task == nil {
-		return errors.New("task cannot be nil")
+		return nil, invalid("update", errNilTask)
+	}
+
+	if err := checkContext(ctx, "update", task.ID); err != nil {
+		return nil, err
 	}
 
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	// Check if task exists
-	_, exists := s.tasks[task.ID]
-	if !exists {
-		return errors.New("task not found")
+	var log undoLog
+	updated, err := s.applyLocked(BatchOp{Kind: BatchUpdate, Task: task}, &log)
+	if err != nil {
+		return nil, err
 	}
+	s.publishLocked(log)
+	return updated.Clone(), nil
+}
 
-	// Update the task
-	s.tasks[task.ID] = task
+// MarkAlerts stores the alerts of a task without bumping its version.
+// Returns the marked task, or error if the task doesn't exist or its version is stale.
+func (s *InMemoryStorage) MarkAlerts(ctx context.Context, id, version int, alerts models.Alerts) (*models.Task, error) {
+	if err := checkContext(ctx, "mark", id); err != nil {
+		return nil, err
+	}
 
-	return nil
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// Only alerts change, so there is nothing to publish
+	var log undoLog
+	marked, err := s.markLocked(id, version, alerts, &log)
+	if err != nil {
+		return nil, err
+	}
+	return marked.Clone(), nil
 }
 
 // Delete removes a task from storage by ID.
 // Returns error if task doesn't exist or deletion fails.
-func (s *InMemoryStorage) Delete(id int) error {
+func (s *InMemoryStorage) Delete(ctx context.Context, id int) error {
+	if err := checkContext(ctx, "delete", id); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var log undoLog
+	if _, err := s.applyLocked(BatchOp{Kind: BatchDelete, ID: id}, &log); err != nil {
+		return err
+	}
+	s.publishLocked(log)
+	return nil
+}
+
+// Batch applies ops in order under a single lock, so no reader observes
+// a partially applied atomic batch. A failed atomic batch is undone before
+// the lock is released.
+func (s *InMemoryStorage) Batch(ctx context.Context, ops []BatchOp, atomic bool) ([]BatchResult, error) {
+	if err := checkContext(ctx, "batch", 0); err != nil {
+		return nil, err
+	}
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	var log undoLog
+	results := s.batchLocked(ops, atomic, &log)
+	s.publishLocked(log)
+	return results, nil
+}
+
+// batchLocked applies ops in order, undoing an atomic batch that fails.
+// The undo steps of applied operations are added to log.
+// The caller must hold the mutex.
+func (s *InMemoryStorage) batchLocked(ops []BatchOp, atomic bool, log *undoLog) []BatchResult {
+	results := make([]BatchResult, len(ops))
+	var applied undoLog
+	for i, op := range ops {
+		task, err := s.applyLocked(op, &applied)
+		if err != nil {
+			results[i].Err = err
+			if atomic {
+				applied.rollback()
+				abortBatch(results, i)
+				return results
+			}
+			continue
+		}
+		results[i] = BatchResult{Task: task.Clone(), Applied: true}
+	}
+
+	*log = append(*log, applied...)
+	return results
+}
+
+// applyLocked applies a single batch operation and returns the stored task,
+// nil for deletes, together with the changes it causes to related tasks (see
+// maintainRelations) and the next occurrence of a recurring task it completes
+// (see advanceRecurrence). The steps undoing all of them are added
+// to log. The caller must hold the mutex.
+func (s *InMemoryStorage) applyLocked(op BatchOp, log *undoLog) (*models.Task, error) {
+	if err := op.check(); err != nil {
+		return nil, err
+	}
+
+	// Remember the stored task before the operation replaces or removes it
+	previous := s.tasks[op.taskID()]
+
+	// Completing a recurring task hands its recurrence on to the next occurrence
+	var next *models.Task
+	if op.Kind == BatchUpdate && previous != nil {
+		op.Task = op.Task.Clone()
+		next = advanceRecurrence(op.Task, previous, s.opts.now())
+	}
+
+	tree := memoryTree{s: s, log: log}
+	if op.Kind == BatchCreate || (op.Kind == BatchUpdate && previous != nil) {
+		if err := checkRelations(tree, string(op.Kind), op.Task, previous); err != nil {
+			return nil, err
+		}
+	}
+
+	var task *models.Task
+	var err error
+	switch op.Kind {
+	case BatchCreate:
+		task = s.createLocked(op.Task)
+	case BatchUpdate:
+		task, err = s.updateLocked(op.Task)
+	default:
+		err = s.deleteLocked(op.ID, op.Version)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if op.Kind == BatchCreate {
+		*log = append(*log, undoStep{id: task.ID, created: true, revert: func() {
+			delete(s.tasks, task.ID)
+			s.index.remove(task.ID)
+		}})
+	} else {
+		*log = append(*log, undoStep{id: previous.ID, revert: func() { s.restoreLocked(previous) }})
+	}
+
+	// Undo the operation too if related tasks cannot follow it
+	mark := len(*log) - 1
+	err = maintainRelations(tree, previous, task)
+	if err == nil && next != nil {
+		_, err = s.applyLocked(BatchOp{Kind: BatchCreate, Task: next}, log)
+	}
+	if err != nil {
+		(*log)[mark:].rollback()
+		*log = (*log)[:mark]
+		return nil, err
+	}
+	return task, nil
+}
+
+// memoryTree is the taskTree of an InMemoryStorage whose mutex is held.
+// Changes are recorded in log so they are undone with the operation causing them.
+type memoryTree struct {
+	s   *InMemoryStorage
+	log *undoLog
+}
+
+func (t memoryTree) get(id int) (*models.Task, error) {
+	return t.s.getLocked(id)
+}
+
+func (t memoryTree) children(id int) ([]*models.Task, error) {
+	var children []*models.Task
+	for _, task := range t.s.tasks {
+		if task.ParentID == id {
+			children = append(children, task.Clone())
+		}
+	}
+	sort.Slice(children, func(i, j int) bool { return children[i].ID < children[j].ID })
+	return children, nil
+}
+
+func (t memoryTree) dependents(id int) ([]*models.Task, error) {
+	var dependents []*models.Task
+	for _, task := range t.s.tasks {
+		if slices.Contains(task.BlockedBy, id) {
+			dependents = append(dependents, task.Clone())
+		}
+	}
+	sort.Slice(dependents, func(i, j int) bool { return dependents[i].ID < dependents[j].ID })
+	return dependents, nil
+}
+
+func (t memoryTree) update(task *models.Task) (*models.Task, error) {
+	return t.s.applyLocked(BatchOp{Kind: BatchUpdate, Task: task}, t.log)
+}
+
+// WithTx runs fn in a transaction holding the write lock for its whole
+// duration, so other callers never observe its changes before it commits.
+// Rolling back replays the undo steps recorded for every change.
+func (s *InMemoryStorage) WithTx(ctx context.Context, fn func(tx TaskStorage) error) error {
+	return s.withTx(ctx, fn, nil)
+}
+
+// View runs fn holding the read lock, so other readers go ahead while
+// writers wait for fn to return.
+func (s *InMemoryStorage) View(ctx context.Context, fn func(tx TaskStorage) error) error {
+	if err := checkContext(ctx, "view", 0); err != nil {
+		return err
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	view := &memoryTx{s: s, readOnly: true}
+	defer func() { view.done = true }()
+	return fn(view)
+}
+
+// withTx runs fn in a transaction. Before committing, commit (if not nil) is
+// called with the changes the transaction made (see changesLocked); if it
+// fails the transaction is rolled back and its error returned. The observer
+// hears of the changes once they are committed.
+func (s *InMemoryStorage) withTx(ctx context.Context, fn func(tx TaskStorage) error, commit func(changes []Change) error) error {
+	if err := checkContext(ctx, "transaction", 0); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx := &memoryTx{s: s}
+	committed := false
+	defer func() {
+		tx.done = true
+		if !committed {
+			tx.log.rollback()
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := checkContext(ctx, "commit", 0); err != nil {
+		return err
+	}
+	if commit != nil {
+		if err := commit(s.changesLocked(tx.log)); err != nil {
+			return err
+		}
+	}
+	committed = true
+	s.publishLocked(tx.log)
+	return nil
+}
+
+// undoStep reverts one change to the task with the given ID.
+type undoStep struct {
+	id      int
+	created bool // Whether the change created the task
+	quiet   bool // Whether the change only stored alerts, which the observer is not told about
+	revert  func()
+}
+
+// undoLog records how to revert changes made under the mutex, oldest first.
+type undoLog []undoStep
+
+// rollback reverts every change in the log, newest first.
+// The caller must hold the mutex.
+func (l undoLog) rollback() {
+	for i := len(l) - 1; i >= 0; i-- {
+		l[i].revert()
+	}
+}
+
+// observed returns the steps of the changes the observer is told about.
+func (l undoLog) observed() undoLog {
+	observed := make(undoLog, 0, len(l))
+	for _, step := range l {
+		if !step.quiet {
+			observed = append(observed, step)
+		}
+	}
+	return observed
+}
+
+// changesLocked returns how the changes recorded in log left every task they
+// touched, in the order the tasks were first touched. The caller must hold
+// the mutex.
+func (s *InMemoryStorage) changesLocked(log undoLog) []Change {
+	seen := make(map[int]bool)
+	var changes []Change
+	for _, step := range log {
+		if seen[step.id] {
+			continue
+		}
+		seen[step.id] = true
+
+		change := Change{Kind: ChangeUpdated, ID: step.id, Task: s.tasks[step.id].Clone()}
+		switch {
+		case step.created && change.Task == nil:
+			continue
+		case step.created:
+			change.Kind = ChangeCreated
+		case change.Task == nil:
+			change.Kind = ChangeDeleted
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// publishLocked hands the changes recorded in log to the observer, leaving
+// out tasks whose alerts were all that changed. The caller must hold the
+// mutex, so the observer hears of writes in the order they were made.
+func (s *InMemoryStorage) publishLocked(log undoLog) {
+	if s.opts.observe != nil {
+		s.opts.notify(s.changesLocked(log.observed()))
+	}
+}
+
+// memoryTx is the TaskStorage handed to an InMemoryStorage transaction.
+// It works on the storage directly, as the transaction already holds the
+// write lock, and records how to undo every change it makes. Views hold the
+// read lock only and cannot write.
+type memoryTx struct {
+	s        *InMemoryStorage
+	log      undoLog
+	done     bool
+	readOnly bool
+}
+
+// check fails once the transaction has finished or ctx is done.
+func (tx *memoryTx) check(ctx context.Context, op string, id int) error {
+	if tx.done {
+		return invalid(op, errTxDone)
+	}
+	return checkContext(ctx, op, id)
+}
+
+// checkWrite is check for operations that write, which also fail in a view.
+func (tx *memoryTx) checkWrite(ctx context.Context, op string, id int) error {
+	if err := tx.check(ctx, op, id); err != nil {
+		return err
+	}
+	if tx.readOnly {
+		return invalid(op, errReadOnly)
+	}
+	return nil
+}
+
+// Create stores a new task within the transaction.
+func (tx *memoryTx) Create(ctx context.Context, task *models.Task) (*models.Task, error) {
+	if err := tx.checkWrite(ctx, "create", 0); err != nil {
+		return nil, err
+	}
+	if task == nil {
+		return nil, invalid("create", errNilTask)
+	}
+
+	created, err := tx.s.applyLocked(BatchOp{Kind: BatchCreate, Task: task}, &tx.log)
+	if err != nil {
+		return nil, err
+	}
+	return created.Clone(), nil
+}
+
+// GetAll retrieves all tasks as seen by the transaction, ordered by ID.
+func (tx *memoryTx) GetAll(ctx context.Context) ([]*models.Task, error) {
+	if err := tx.check(ctx, "list", 0); err != nil {
+		return nil, err
+	}
+	return tx.s.allLocked(), nil
+}
+
+// Query returns the page of tasks selected by q as seen by the transaction.
+func (tx *memoryTx) Query(ctx context.Context, q TaskQuery) (*TaskPage, error) {
+	if err := tx.check(ctx, "query", 0); err != nil {
+		return nil, err
+	}
+	if err := q.validate(); err != nil {
+		return nil, invalid("query", err)
+	}
+	return pageOf(q, tx.s.matchLocked(q)), nil
+}
+
+// Search returns the tasks matching the words of query as seen by the transaction.
+func (tx *memoryTx) Search(ctx context.Context, query string, limit int) ([]*SearchResult, error) {
+	if err := tx.check(ctx, "search", 0); err != nil {
+		return nil, err
+	}
+	return tx.s.searchLocked(query, limit), nil
+}
+
+// GetByID retrieves a task as seen by the transaction.
+func (tx *memoryTx) GetByID(ctx context.Context, id int) (*models.Task, error) {
+	if err := tx.check(ctx, "get", id); err != nil {
+		return nil, err
+	}
+	return tx.s.getLocked(id)
+}
+
+// Update modifies an existing task within the transaction.
+func (tx *memoryTx) Update(ctx context.Context, task *models.Task) (*models.Task, error) {
+	if task == nil {
+		return nil, invalid("update", errNilTask)
+	}
+	if err := tx.checkWrite(ctx, "update", task.ID); err != nil {
+		return nil, err
+	}
+
+	updated, err := tx.s.applyLocked(BatchOp{Kind: BatchUpdate, Task: task}, &tx.log)
+	if err != nil {
+		return nil, err
+	}
+	return updated.Clone(), nil
+}
+
+// MarkAlerts stores the alerts of a task within the transaction.
+func (tx *memoryTx) MarkAlerts(ctx context.Context, id, version int, alerts models.Alerts) (*models.Task, error) {
+	if err := tx.checkWrite(ctx, "mark", id); err != nil {
+		return nil, err
+	}
+
+	marked, err := tx.s.markLocked(id, version, alerts, &tx.log)
+	if err != nil {
+		return nil, err
+	}
+	return marked.Clone(), nil
+}
+
+// Delete removes a task within the transaction.
+func (tx *memoryTx) Delete(ctx context.Context, id int) error {
+	if err := tx.checkWrite(ctx, "delete", id); err != nil {
+		return err
+	}
+
+	_, err := tx.s.applyLocked(BatchOp{Kind: BatchDelete, ID: id}, &tx.log)
+	return err
+}
+
+// Batch applies ops within the transaction. A failed atomic batch is undone
+// without affecting the rest of the transaction.
+func (tx *memoryTx) Batch(ctx context.Context, ops []BatchOp, atomic bool) ([]BatchResult, error) {
+	if err := tx.checkWrite(ctx, "batch", 0); err != nil {
+		return nil, err
+	}
+	return tx.s.batchLocked(ops, atomic, &tx.log), nil
+}
+
+// WithTx runs fn in a nested transaction. If fn fails only the nested
+// transaction's changes are undone; otherwise they become part of tx.
+func (tx *memoryTx) WithTx(ctx context.Context, fn func(tx TaskStorage) error) error {
+	if err := tx.check(ctx, "transaction", 0); err != nil {
+		return err
+	}
+
+	nested := &memoryTx{s: tx.s, readOnly: tx.readOnly}
+	committed := false
+	defer func() {
+		nested.done = true
+		if !committed {
+			nested.log.rollback()
+		}
+	}()
+
+	if err := fn(nested); err != nil {
+		return err
+	}
+	tx.log = append(tx.log, nested.log...)
+	committed = true
+	return nil
+}
+
+// View runs fn with a read-only view of the transaction.
+func (tx *memoryTx) View(ctx context.Context, fn func(tx TaskStorage) error) error {
+	if err := tx.check(ctx, "view", 0); err != nil {
+		return err
+	}
+
+	view := &memoryTx{s: tx.s, readOnly: true}
+	defer func() { view.done = true }()
+	return fn(view)
+}
+
+// createLocked stores a copy of task under the next ID and returns the stored task.
+// The caller must hold the mutex.
+func (s *InMemoryStorage) createLocked(task *models.Task) *models.Task {
+	// Create a copy of the task with assigned ID
+	newTask := task.Clone()
+	newTask.ID = s.nextID
+	newTask.Version = 1
+	stampTimes(newTask, nil, s.opts.now())
+
+	// Store the task
+	s.tasks[s.nextID] = newTask
+	s.index.add(newTask)
+	s.nextID++
+
+	return newTask
+}
+
+// updateLocked replaces a stored task after checking its version and returns the stored task.
+// The caller must hold the mutex.
+func (s *InMemoryStorage) updateLocked(task *models.Task) (*models.Task, error) {
+	// Check if task exists
+	existing, exists := s.tasks[task.ID]
+	if !exists {
+		return nil, notFound("update", task.ID)
+	}
+
+	// Reject updates based on an outdated read
+	if task.Version != existing.Version {
+		return nil, staleVersion("update", task.ID, task.Version, existing.Version)
+	}
+
+	// Update the task
+	updated := task.Clone()
+	updated.Version = existing.Version + 1
+	stampTimes(updated, existing, s.opts.now())
+	s.tasks[task.ID] = updated
+	s.index.add(updated)
+
+	return updated, nil
+}
+
+// markLocked replaces the alerts of a stored task after checking its version,
+// keeping the version and timestamps, and returns the stored task. The undo
+// step is added to log. The caller must hold the mutex.
+func (s *InMemoryStorage) markLocked(id, version int, alerts models.Alerts, log *undoLog) (*models.Task, error) {
+	existing, exists := s.tasks[id]
+	if !exists {
+		return nil, notFound("mark", id)
+	}
+	if version != existing.Version {
+		return nil, staleVersion("mark", id, version, existing.Version)
+	}
+
+	marked := existing.Clone()
+	marked.Alerts = alerts
+	marked = marked.Clone() // Copies the alert times too
+	s.tasks[id] = marked
+	*log = append(*log, undoStep{id: id, quiet: true, revert: func() { s.restoreLocked(existing) }})
+
+	return marked, nil
+}
+
+// deleteLocked removes a stored task, after checking its version unless
+// version is 0. The caller must hold the mutex.
+func (s *InMemoryStorage) deleteLocked(id, version int) error {
 	// Check if task exists
-	_, exists := s.tasks[id]
+	existing, exists := s.tasks[id]
 	if !exists {
-		return errors.New("task not found")
+		return notFound("delete", id)
+	}
+	if version != 0 && version != existing.Version {
+		return staleVersion("delete", id, version, existing.Version)
 	}
 
 	// Delete the task
 	delete(s.tasks, id)
+	s.index.remove(id)
 	return nil
 }
+
+// restoreLocked puts back a task exactly as it was stored before.
+// The caller must hold the mutex.
+func (s *InMemoryStorage) restoreLocked(task *models.Task) {
+	s.tasks[task.ID] = task
+	s.index.add(task)
+}
+
+// put stores task under its existing ID, replacing any previous value.
+// Used to rebuild state from a persisted log.
+// The caller must not hold the mutex.
+func (s *InMemoryStorage) put(task *models.Task) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.tasks[task.ID] = task.Clone()
+	s.index.add(task)
+	if task.ID >= s.nextID {
+		s.nextID = task.ID + 1
+	}
+}
+
+// remove deletes a task without reporting whether it existed.
+// The caller must not hold the mutex.
+func (s *InMemoryStorage) remove(id int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.tasks, id)
+	s.index.remove(id)
+}
+
+// state returns copies of the stored tasks ordered by ID together with the next ID to assign.
+func (s *InMemoryStorage) state() ([]*models.Task, int) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.allLocked(), s.nextID
+}
+
+// allLocked returns copies of the stored tasks ordered by ID. The caller must hold the mutex.
+func (s *InMemoryStorage) allLocked() []*models.Task {
+	tasks := make([]*models.Task, 0, len(s.tasks))
+	for _, task := range s.tasks {
+		tasks = append(tasks, task.Clone())
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].ID < tasks[j].ID })
+	return tasks
+}
+
+// setNextID raises the ID counter so IDs are never reused after a restore.
+func (s *InMemoryStorage) setNextID(nextID int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if nextID > s.nextID {
+		s.nextID = nextID
+	}
+}
@@ -0,0 +1,402 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"task-api/internal/models"
+)
+
+// migration is a single, versioned schema change.
+// Each migration carries the statements for every supported dialect;
+// migrations are applied in order and recorded in schema_migrations.
+// Data that can only be computed in Go is migrated by run, after the statements.
+type migration struct {
+	version  int
+	name     string
+	sqlite   []string
+	postgres []string
+	run      func(s *SQLStorage, tx *sql.Tx) error
+}
+
+// migrations lists every schema change in the order it must be applied.
+// Never edit a migration that has shipped - append a new one instead.
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "create tasks table",
+		// AUTOINCREMENT / SERIAL guarantee IDs are never reused after a delete,
+		// matching the behavior of the in-memory backend.
+		sqlite: []string{
+			`CREATE TABLE IF NOT EXISTS tasks (
+				id     INTEGER PRIMARY KEY AUTOINCREMENT,
+				name   TEXT    NOT NULL,
+				status INTEGER NOT NULL DEFAULT 0
+			)`,
+		},
+		postgres: []string{
+			`CREATE TABLE IF NOT EXISTS tasks (
+				id     SERIAL  PRIMARY KEY,
+				name   TEXT    NOT NULL,
+				status INTEGER NOT NULL DEFAULT 0
+			)`,
+		},
+	},
+	{
+		version: 2,
+		name:    "add task version for optimistic concurrency",
+		sqlite: []string{
+			`ALTER TABLE tasks ADD COLUMN version INTEGER NOT NULL DEFAULT 1`,
+		},
+		postgres: []string{
+			`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1`,
+		},
+	},
+	{
+		version: 3,
+		name:    "index tasks for filtering and sorting",
+		// ID is the tie-breaker of every query order, so it completes each index
+		sqlite: []string{
+			`CREATE INDEX IF NOT EXISTS idx_tasks_status ON tasks (status, id)`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_name ON tasks (name, id)`,
+		},
+		postgres: []string{
+			`CREATE INDEX IF NOT EXISTS idx_tasks_status ON tasks (status, id)`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_name ON tasks (name COLLATE "C", id)`,
+		},
+	},
+	{
+		version: 4,
+		name:    "create full-text search index",
+		// Inverted index: how often each token occurs in each task
+		sqlite: []string{
+			`CREATE TABLE IF NOT EXISTS task_tokens (
+				token   TEXT    NOT NULL,
+				task_id INTEGER NOT NULL REFERENCES tasks (id) ON DELETE CASCADE,
+				count   INTEGER NOT NULL,
+				PRIMARY KEY (token, task_id)
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_task_tokens_task ON task_tokens (task_id)`,
+		},
+		postgres: []string{
+			`CREATE TABLE IF NOT EXISTS task_tokens (
+				token   TEXT    NOT NULL,
+				task_id INTEGER NOT NULL REFERENCES tasks (id) ON DELETE CASCADE,
+				count   INTEGER NOT NULL,
+				PRIMARY KEY (token, task_id)
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_task_tokens_task ON task_tokens (task_id)`,
+		},
+		run: reindexTasks,
+	},
+	{
+		version: 5,
+		name:    "add description, due date, priority, tags and assignee",
+		// Tags are stored as a JSON array for reading and in task_tags for filtering.
+		// Due dates are fixed-width UTC text (see timeLayout); Postgres compares
+		// them byte-wise so they order chronologically.
+		sqlite: []string{
+			`ALTER TABLE tasks ADD COLUMN description TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE tasks ADD COLUMN priority INTEGER NOT NULL DEFAULT 0`,
+			`ALTER TABLE tasks ADD COLUMN due_at TEXT`,
+			`ALTER TABLE tasks ADD COLUMN tags TEXT NOT NULL DEFAULT '[]'`,
+			`ALTER TABLE tasks ADD COLUMN assignee TEXT NOT NULL DEFAULT ''`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_priority ON tasks (priority, id)`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_due_at ON tasks (due_at, id)`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_assignee ON tasks (assignee, id)`,
+			`CREATE TABLE IF NOT EXISTS task_tags (
+				tag     TEXT    NOT NULL,
+				task_id INTEGER NOT NULL REFERENCES tasks (id) ON DELETE CASCADE,
+				PRIMARY KEY (tag, task_id)
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_task_tags_task ON task_tags (task_id)`,
+		},
+		postgres: []string{
+			`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS priority INTEGER NOT NULL DEFAULT 0`,
+			`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS due_at TEXT COLLATE "C"`,
+			`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS tags TEXT NOT NULL DEFAULT '[]'`,
+			`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS assignee TEXT NOT NULL DEFAULT ''`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_priority ON tasks (priority, id)`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_due_at ON tasks (due_at, id)`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_assignee ON tasks (assignee, id)`,
+			`CREATE TABLE IF NOT EXISTS task_tags (
+				tag     TEXT    NOT NULL,
+				task_id INTEGER NOT NULL REFERENCES tasks (id) ON DELETE CASCADE,
+				PRIMARY KEY (tag, task_id)
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_task_tags_task ON task_tags (task_id)`,
+		},
+	},
+	{
+		version: 6,
+		name:    "add created, updated and completed timestamps",
+		// Same fixed-width UTC text as due_at. Existing tasks are stamped with
+		// the time of the migration; when they were completed is unknown.
+		sqlite: []string{
+			`ALTER TABLE tasks ADD COLUMN created_at TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE tasks ADD COLUMN updated_at TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE tasks ADD COLUMN completed_at TEXT`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_created_at ON tasks (created_at, id)`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_updated_at ON tasks (updated_at, id)`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_completed_at ON tasks (completed_at, id)`,
+		},
+		postgres: []string{
+			`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS created_at TEXT COLLATE "C" NOT NULL DEFAULT ''`,
+			`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS updated_at TEXT COLLATE "C" NOT NULL DEFAULT ''`,
+			`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS completed_at TEXT COLLATE "C"`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_created_at ON tasks (created_at, id)`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_updated_at ON tasks (updated_at, id)`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_completed_at ON tasks (completed_at, id)`,
+		},
+		run: stampExistingTasks,
+	},
+	{
+		version: 7,
+		name:    "add workflow state",
+		sqlite: []string{
+			`ALTER TABLE tasks ADD COLUMN state TEXT NOT NULL DEFAULT ''`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_state ON tasks (state, id)`,
+		},
+		postgres: []string{
+			`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS state TEXT NOT NULL DEFAULT ''`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_state ON tasks (state, id)`,
+		},
+		run: stateExistingTasks,
+	},
+	{
+		version: 8,
+		name:    "add parent task",
+		// NULL for top-level tasks. No foreign key: storage moves subtasks up
+		// before deleting their parent, which a cascading constraint would preempt
+		sqlite: []string{
+			`ALTER TABLE tasks ADD COLUMN parent_id INTEGER`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_parent_id ON tasks (parent_id, id)`,
+		},
+		postgres: []string{
+			`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS parent_id INTEGER`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_parent_id ON tasks (parent_id, id)`,
+		},
+	},
+	{
+		version: 9,
+		name:    "add blocking tasks",
+		// The JSON column is what tasks are read from; task_blockers indexes it
+		// by blocker. No foreign keys: storage unblocks the tasks a deleted task blocked
+		sqlite: []string{
+			`ALTER TABLE tasks ADD COLUMN blocked_by TEXT NOT NULL DEFAULT '[]'`,
+			`CREATE TABLE IF NOT EXISTS task_blockers (
+				task_id    INTEGER NOT NULL,
+				blocker_id INTEGER NOT NULL,
+				PRIMARY KEY (task_id, blocker_id)
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_task_blockers_blocker ON task_blockers (blocker_id, task_id)`,
+		},
+		postgres: []string{
+			`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS blocked_by TEXT NOT NULL DEFAULT '[]'`,
+			`CREATE TABLE IF NOT EXISTS task_blockers (
+				task_id    INTEGER NOT NULL,
+				blocker_id INTEGER NOT NULL,
+				PRIMARY KEY (task_id, blocker_id)
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_task_blockers_blocker ON task_blockers (blocker_id, task_id)`,
+		},
+	},
+	{
+		version: 10,
+		name:    "add recurrence",
+		sqlite: []string{
+			`ALTER TABLE tasks ADD COLUMN recurrence TEXT NOT NULL DEFAULT ''`,
+		},
+		postgres: []string{
+			`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS recurrence TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		version: 11,
+		name:    "add scheduler alerts",
+		sqlite: []string{
+			`ALTER TABLE tasks ADD COLUMN reminded_at TEXT`,
+			`ALTER TABLE tasks ADD COLUMN overdue_at TEXT`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_overdue_at ON tasks (overdue_at, id)`,
+		},
+		postgres: []string{
+			`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS reminded_at TEXT COLLATE "C"`,
+			`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS overdue_at TEXT COLLATE "C"`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_overdue_at ON tasks (overdue_at, id)`,
+		},
+	},
+	{
+		version: 12,
+		name:    "add folded name",
+		// The name lower-cased by Go, so name filters fold case exactly like the
+		// in-memory storage whatever the database's own LOWER does
+		sqlite: []string{
+			`ALTER TABLE tasks ADD COLUMN name_folded TEXT NOT NULL DEFAULT ''`,
+		},
+		postgres: []string{
+			`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS name_folded TEXT NOT NULL DEFAULT ''`,
+		},
+		run: foldExistingNames,
+	},
+}
+
+// migrate creates the schema_migrations bookkeeping table and applies every
+// migration not recorded there yet, in order, each in its own transaction.
+func (s *SQLStorage) migrate() error {
+	if _, err := s.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name    TEXT NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("create schema_migrations table: %w", err)
+	}
+
+	applied, err := s.appliedMigrations()
+	if err != nil {
+		return fmt.Errorf("read applied migrations: %w", err)
+	}
+
+	for _, m := range migrations {
+		if applied[m.version] {
+			continue
+		}
+		if err := s.apply(m); err != nil {
+			return fmt.Errorf("apply migration %d (%s): %w", m.version, m.name, err)
+		}
+	}
+
+	return nil
+}
+
+// appliedMigrations returns the versions recorded in schema_migrations.
+func (s *SQLStorage) appliedMigrations() (map[int]bool, error) {
+	rows, err := s.db.Query(`SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+// apply runs one migration and records it atomically.
+func (s *SQLStorage) apply(m migration) error {
+	statements := m.sqlite
+	if s.dialect == dialectPostgres {
+		statements = m.postgres
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck // Rollback after Commit is a no-op
+
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	if m.run != nil {
+		if err := m.run(s, tx); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(
+		s.rebind(`INSERT INTO schema_migrations (version, name) VALUES (?, ?)`),
+		m.version, m.name,
+	); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// reindexTasks rebuilds the full-text index of every existing task.
+func reindexTasks(s *SQLStorage, tx *sql.Tx) error {
+	rows, err := tx.Query(`SELECT id, name, status, version FROM tasks`)
+	if err != nil {
+		return err
+	}
+	var tasks []*models.Task
+	for rows.Next() {
+		task := &models.Task{}
+		if err := rows.Scan(&task.ID, &task.Name, &task.Status, &task.Version); err != nil {
+			rows.Close()
+			return err
+		}
+		tasks = append(tasks, task)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, task := range tasks {
+		if err := s.indexTask(context.Background(), tx, task); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stampExistingTasks sets the creation and update time of tasks stored
+// before timestamps existed to the current time.
+func stampExistingTasks(s *SQLStorage, tx *sql.Tx) error {
+	now := s.opts.now()
+	_, err := tx.Exec(
+		s.rebind(`UPDATE tasks SET created_at = ?, updated_at = ? WHERE created_at = ''`),
+		timeArg(&now), timeArg(&now),
+	)
+	return err
+}
+
+// stateExistingTasks puts tasks stored before workflows existed into the
+// state of the configured workflow matching their status.
+func stateExistingTasks(s *SQLStorage, tx *sql.Tx) error {
+	workflow := models.DefaultWorkflow()
+	_, err := tx.Exec(
+		s.rebind(`UPDATE tasks SET state = CASE WHEN status = 1 THEN ? ELSE ? END WHERE state = ''`),
+		workflow.StateForStatus("", 1), workflow.StateForStatus("", 0),
+	)
+	return err
+}
+
+// foldExistingNames fills in the folded name of tasks stored before it existed.
+func foldExistingNames(s *SQLStorage, tx *sql.Tx) error {
+	rows, err := tx.Query(`SELECT id, name FROM tasks`)
+	if err != nil {
+		return err
+	}
+	names := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			rows.Close()
+			return err
+		}
+		names[id] = name
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, name := range names {
+		if _, err := tx.Exec(
+			s.rebind(`UPDATE tasks SET name_folded = ? WHERE id = ?`), foldName(name), id,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
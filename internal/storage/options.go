@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"task-api/internal/clock"
+	"task-api/internal/models"
+	"time"
+)
+
+// Option configures a storage backend.
+type Option func(*options)
+
+// options collects the settings shared by every backend.
+type options struct {
+	clock   clock.Clock
+	observe func(changes []Change)
+}
+
+// WithClock makes the storage read the time for task timestamps from c
+// instead of the system clock, so tests can control them.
+func WithClock(c clock.Clock) Option {
+	return func(o *options) {
+		o.clock = c
+	}
+}
+
+// WithObserver makes the storage call observe with the changes of every write
+// once it is committed, including the changes it caused to related tasks.
+// Backends without a database call it in commit order while holding their
+// lock, so observe must be quick and must not use the storage; it must not
+// keep or modify the tasks it is given either.
+func WithObserver(observe func(changes []Change)) Option {
+	return func(o *options) {
+		o.observe = observe
+	}
+}
+
+// newOptions applies opts over the defaults.
+func newOptions(opts []Option) options {
+	o := options{clock: clock.System()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// notify hands the changes of a committed write to the observer, if any.
+func (o options) notify(changes []Change) {
+	if o.observe != nil && len(changes) > 0 {
+		o.observe(changes)
+	}
+}
+
+// now reads the configured clock in UTC, without a monotonic reading,
+// so timestamps compare equal after a round trip through any backend.
+func (o options) now() time.Time {
+	return o.clock.Now().UTC()
+}
+
+// stampTimes sets the timestamps storage maintains on task, which is about
+// to replace previous (nil when task is new). CreatedAt never changes after
+// creation, and CompletedAt is kept for as long as the task stays completed.
+// The scheduler's alerts are cleared when the task is new or completed or its
+// due date changed, so that the due date it ends up with is watched afresh.
+func stampTimes(task, previous *models.Task, now time.Time) {
+	if previous == nil || task.Status == 1 || !equalTimes(task.DueAt, previous.DueAt) {
+		task.Alerts = models.Alerts{}
+	}
+
+	task.UpdatedAt = now
+	task.CreatedAt = now
+	if previous != nil {
+		task.CreatedAt = previous.CreatedAt
+	}
+
+	switch {
+	case task.Status != 1:
+		task.CompletedAt = nil
+	case previous != nil && previous.Status == 1 && previous.CompletedAt != nil:
+		completedAt := *previous.CompletedAt
+		task.CompletedAt = &completedAt
+	default:
+		task.CompletedAt = &now
+	}
+}
+
+// equalTimes reports whether a and b are both nil or the same instant.
+func equalTimes(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
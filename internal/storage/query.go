@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"task-api/internal/models"
+	"time"
+)
+
+// SortField names a task field that query results can be ordered by.
+type SortField string
+
+const (
+	SortByID     SortField = "id"
+	SortByName   SortField = "name"
+	SortByStatus SortField = "status"
+
+	SortByCreatedAt SortField = "created_at"
+	SortByUpdatedAt SortField = "updated_at"
+)
+
+// TaskQuery selects, orders and pages through tasks.
+// The zero value matches every task ordered by ascending ID.
+type TaskQuery struct {
+	Status       *int      // Only tasks with this status, nil for any
+	State        string    // Only tasks in this workflow state, "" for any
+	NameContains string    // Only tasks whose name contains this text, case-insensitively
+	Priority     *int      // Only tasks with this priority, nil for any
+	Tags         []string  // Only tasks carrying every one of these (normalized) tags
+	Assignee     *string   // Only tasks assigned to exactly this (normalized) assignee, "" for unassigned; nil for any
+	Parent       *int      // Only subtasks of this task, 0 for top-level tasks; nil for any
+	Overdue      *bool     // Only tasks the scheduler marked overdue (true) or did not (false), nil for any
+	Due          TimeRange // Only tasks due within this range
+	Created      TimeRange // Only tasks created within this range
+	Updated      TimeRange // Only tasks last updated within this range
+	Completed    TimeRange // Only tasks completed within this range
+	SortBy       SortField // Field to order by, SortByID when empty; ties are broken by ID
+	Descending   bool      // Reverse the order
+	Limit        int       // Maximum number of tasks to return, 0 for no limit
+	Offset       int       // Number of matching tasks to skip
+	After        *Cursor   // Only tasks ordered after this position (keyset pagination)
+}
+
+// TimeRange selects the times strictly after After and strictly before Before.
+// A nil bound leaves that side open. Tasks without the time, such as tasks
+// without a due date, only match the unbounded range.
+type TimeRange struct {
+	After  *time.Time
+	Before *time.Time
+}
+
+// contains reports whether t lies within the range.
+func (r TimeRange) contains(t *time.Time) bool {
+	if r.After == nil && r.Before == nil {
+		return true
+	}
+	if t == nil {
+		return false
+	}
+	return (r.After == nil || t.After(*r.After)) && (r.Before == nil || t.Before(*r.Before))
+}
+
+// Cursor marks a position in a query's sort order: the sort key of the last
+// task of a page. Resuming after a cursor is stable under concurrent inserts
+// and deletes, unlike an offset.
+type Cursor struct {
+	ID        int
+	Name      string
+	Status    int
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// CursorAfter returns the cursor positioned at task.
+func CursorAfter(task *models.Task) *Cursor {
+	return &Cursor{ID: task.ID, Name: task.Name, Status: task.Status, CreatedAt: task.CreatedAt, UpdatedAt: task.UpdatedAt}
+}
+
+// TaskPage is one page of query results.
+type TaskPage struct {
+	Tasks   []*models.Task // Tasks on this page, in query order
+	Total   int            // Number of tasks matching the filters, ignoring Limit, Offset and After
+	HasMore bool           // Whether more tasks follow this page
+}
+
+// validate rejects queries no backend can answer.
+func (q TaskQuery) validate() error {
+	switch q.SortBy {
+	case "", SortByID, SortByName, SortByStatus, SortByCreatedAt, SortByUpdatedAt:
+	default:
+		return fmt.Errorf("unknown sort field %q", q.SortBy)
+	}
+	if q.Limit < 0 {
+		return errors.New("limit cannot be negative")
+	}
+	if q.Offset < 0 {
+		return errors.New("offset cannot be negative")
+	}
+	if q.Offset > 0 && q.After != nil {
+		return errors.New("offset and cursor cannot be combined")
+	}
+	return nil
+}
+
+// Matches reports whether task passes the query filters, whatever its order and paging.
+func (q TaskQuery) Matches(task *models.Task) bool {
+	if q.Status != nil && task.Status != *q.Status {
+		return false
+	}
+	if q.State != "" && task.State != q.State {
+		return false
+	}
+	if q.NameContains != "" && !strings.Contains(strings.ToLower(task.Name), strings.ToLower(q.NameContains)) {
+		return false
+	}
+	if q.Priority != nil && task.Priority != *q.Priority {
+		return false
+	}
+	for _, tag := range q.Tags {
+		if !slices.Contains(task.Tags, tag) {
+			return false
+		}
+	}
+	if q.Assignee != nil && task.Assignee != *q.Assignee {
+		return false
+	}
+	if q.Parent != nil && task.ParentID != *q.Parent {
+		return false
+	}
+	if q.Overdue != nil && (task.OverdueAt != nil) != *q.Overdue {
+		return false
+	}
+	return q.Due.contains(task.DueAt) && q.Created.contains(&task.CreatedAt) &&
+		q.Updated.contains(&task.UpdatedAt) && q.Completed.contains(task.CompletedAt)
+}
+
+// compare orders two positions by the query's sort field, then by ID,
+// honoring Descending. It returns a negative number when a comes first.
+func (q TaskQuery) compare(a, b Cursor) int {
+	result := 0
+	switch q.SortBy {
+	case SortByName:
+		result = strings.Compare(a.Name, b.Name)
+	case SortByStatus:
+		result = a.Status - b.Status
+	case SortByCreatedAt:
+		result = a.CreatedAt.Compare(b.CreatedAt)
+	case SortByUpdatedAt:
+		result = a.UpdatedAt.Compare(b.UpdatedAt)
+	}
+	if result == 0 {
+		result = a.ID - b.ID
+	}
+	if q.Descending {
+		result = -result
+	}
+	return result
+}
@@ -0,0 +1,23 @@
+package storage
+
+import (
+	"task-api/internal/models"
+	"time"
+)
+
+// advanceRecurrence hands the recurrence of task on to its next occurrence
+// when an update replacing current completes it at now (see
+// models.Task.NextOccurrence). The occurrence is returned for the caller to
+// create as part of the same write, and the recurrence is cleared from task,
+// so reopening and completing it again does not repeat the series. task must
+// be a copy owned by storage.
+func advanceRecurrence(task, current *models.Task, now time.Time) *models.Task {
+	if task.Status != 1 || current.Status == 1 {
+		return nil
+	}
+	next := task.NextOccurrence(now)
+	if next != nil {
+		task.Recurrence = ""
+	}
+	return next
+}
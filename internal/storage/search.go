@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"task-api/internal/models"
+	"unicode"
+)
+
+// SearchResult is a task matching a full-text search, with its relevance score.
+type SearchResult struct {
+	Task  *models.Task
+	Score float64 // Higher is more relevant
+}
+
+// indexedText returns the text of task that full-text search looks at.
+// New text fields must be added here to become searchable.
+func indexedText(task *models.Task) string {
+	return strings.Join(append([]string{task.Name, task.Description}, task.Tags...), "\n")
+}
+
+// tokenize splits text into lower-case words of letters and digits
+// and counts how often each word occurs.
+func tokenize(text string) map[string]int {
+	counts := make(map[string]int)
+	for _, token := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		counts[token]++
+	}
+	return counts
+}
+
+// queryTokens returns the distinct words of a search query in a stable order.
+func queryTokens(query string) []string {
+	counts := tokenize(query)
+	tokens := make([]string, 0, len(counts))
+	for token := range counts {
+		tokens = append(tokens, token)
+	}
+	sort.Strings(tokens)
+	return tokens
+}
+
+// postings maps a token to the number of times it occurs in each task, by task ID.
+type postings map[string]map[int]int
+
+// rank scores every task appearing in the postings of the query tokens with TF-IDF:
+// each matching token contributes its term frequency weighted by how rare the
+// token is among all taskCount tasks, so tasks matching more and rarer words
+// come first. Ties are ordered by ID. At most limit IDs are returned when limit > 0.
+func rank(index postings, tokens []string, taskCount int, limit int) ([]int, map[int]float64) {
+	scores := make(map[int]float64)
+	for _, token := range tokens {
+		docs := index[token]
+		if len(docs) == 0 {
+			continue
+		}
+		idf := math.Log(1 + float64(taskCount)/float64(len(docs)))
+		for id, tf := range docs {
+			scores[id] += float64(tf) * idf
+		}
+	}
+
+	ids := make([]int, 0, len(scores))
+	for id := range scores {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if scores[ids[i]] != scores[ids[j]] {
+			return scores[ids[i]] > scores[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	if limit > 0 && len(ids) > limit {
+		ids = ids[:limit]
+	}
+	return ids, scores
+}
+
+// searchIndex is an in-memory inverted index from tokens to tasks.
+// It is not safe for concurrent use; InMemoryStorage guards it with its mutex.
+type searchIndex struct {
+	postings postings
+	tokens   map[int]map[string]int // Tokens indexed for each task, for removal
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{
+		postings: make(postings),
+		tokens:   make(map[int]map[string]int),
+	}
+}
+
+// add indexes task, replacing whatever was indexed for its ID before.
+func (idx *searchIndex) add(task *models.Task) {
+	idx.remove(task.ID)
+
+	counts := tokenize(indexedText(task))
+	for token, count := range counts {
+		docs, ok := idx.postings[token]
+		if !ok {
+			docs = make(map[int]int)
+			idx.postings[token] = docs
+		}
+		docs[task.ID] = count
+	}
+	idx.tokens[task.ID] = counts
+}
+
+// remove drops the task with the given ID from the index.
+func (idx *searchIndex) remove(id int) {
+	for token := range idx.tokens[id] {
+		delete(idx.postings[token], id)
+		if len(idx.postings[token]) == 0 {
+			delete(idx.postings, token)
+		}
+	}
+	delete(idx.tokens, id)
+}
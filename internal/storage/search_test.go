@@ -0,0 +1,29 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestTokenize tests splitting text into lower-case words
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected map[string]int
+	}{
+		{"simple", "Buy milk", map[string]int{"buy": 1, "milk": 1}},
+		{"repeated words", "Write, then WRITE again", map[string]int{"write": 2, "then": 1, "again": 1}},
+		{"digits and punctuation", "Fix bug #42 (v2.1)", map[string]int{"fix": 1, "bug": 1, "42": 1, "v2": 1, "1": 1}},
+		{"unicode letters", "Café réservé", map[string]int{"café": 1, "réservé": 1}},
+		{"no words", "  --- !!", map[string]int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tokenize(tt.text); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("tokenize(%q) = %v, expected %v", tt.text, got, tt.expected)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"context"
 	"os"
 	"task-api/internal/models"
 )
@@ -9,30 +10,111 @@ import (
 // This interface allows different storage implementations (in-memory, database, etc.)
 // while keeping the business logic decoupled from storage details.
 //
-// TODO: Future enhancement - Add hybrid storage backend support
-// - Auto-detect storage type based on environment (DATABASE_URL presence)
-// - Use in-memory storage for development/testing (current implementation)
-// - Use database backend for production when DATABASE_URL is configured
+// The backend is auto-detected from the environment (see AutoDetectBackend):
+// in-memory storage for development/testing, durable file storage for small
+// deployments when DATA_DIR is configured, and a SQL database for production
+// when DATABASE_URL is configured.
+//
+// Every method takes a context.Context carrying the request's deadline and
+// cancellation; implementations must stop work and return ctx.Err() (possibly
+// wrapped) once the context is done.
+//
+// Tasks form a hierarchy through their ParentID. Backends keep it consistent
+// as part of every write: a parent must exist and a task can never become
+// its own ancestor (ErrInvalid wrapping ErrParentNotFound or ErrParentCycle),
+// the subtasks of a deleted task move up to its parent, and a parent is
+// completed once all its subtasks are and reopened when one of them is not,
+// as far as the workflow allows.
+//
+// Tasks also wait for each other through their BlockedBy lists, which must
+// form a DAG. Blockers must exist and a task can never wait for itself
+// (ErrInvalid wrapping ErrBlockerNotFound or ErrDependencyCycle), a task
+// cannot be completed while one of its blockers is incomplete (ErrInvalid
+// wrapping a *models.ValidationError, see models.Task.CheckBlockers), and a
+// deleted task is removed from the lists of the tasks it blocked.
+//
+// Completing a recurring task creates its next occurrence (see
+// models.Task.NextOccurrence) and clears the recurrence of the completed task,
+// which is returned without it.
+//
+// These follow-up changes are writes like any other and are applied, or
+// rolled back, together with the write causing them.
 type TaskStorage interface {
-	// Create stores a new task and assigns it a unique ID.
-	// Returns the task with assigned ID or an error if creation fails.
-	Create(task *models.Task) (*models.Task, error)
+	// Create stores a new task and assigns it a unique ID and version 1.
+	// CreatedAt and UpdatedAt are set to the current time, CompletedAt too if
+	// the task is completed, and its alerts are cleared. Returns the task with
+	// assigned ID or an error if creation fails.
+	Create(ctx context.Context, task *models.Task) (*models.Task, error)
 
 	// GetAll retrieves all tasks from storage.
 	// Returns slice of tasks or error if retrieval fails.
-	GetAll() ([]*models.Task, error)
+	GetAll(ctx context.Context) ([]*models.Task, error)
+
+	// Query retrieves the tasks matching q's filters in q's order, one page at a time.
+	// The page also reports how many tasks match in total.
+	// Returns ErrInvalid if the query is malformed.
+	Query(ctx context.Context, q TaskQuery) (*TaskPage, error)
+
+	// Search finds tasks containing any of the words in query, ignoring case,
+	// ranked by relevance. Backends keep an inverted index up to date on every
+	// change instead of scanning all tasks. At most limit results are returned
+	// when limit > 0.
+	Search(ctx context.Context, query string, limit int) ([]*SearchResult, error)
 
 	// GetByID retrieves a specific task by its ID.
 	// Returns the task or error if not found or retrieval fails.
-	GetByID(id int) (*models.Task, error)
+	GetByID(ctx context.Context, id int) (*models.Task, error)
 
 	// Update modifies an existing task in storage.
-	// Returns error if task doesn't exist or update fails.
-	Update(task *models.Task) error
+	// task.Version must equal the stored version, otherwise the update is
+	// rejected with ErrConflict; this prevents silently overwriting a
+	// concurrent change. UpdatedAt is set to the current time and CreatedAt
+	// is kept; CompletedAt is set when the status becomes 1, kept while it
+	// stays 1 and cleared otherwise. The alerts of task are stored as given,
+	// unless it is completed or its due date changes, which clears them.
+	// Returns the stored task with its new version, or error if task doesn't
+	// exist or update fails.
+	Update(ctx context.Context, task *models.Task) (*models.Task, error)
+
+	// MarkAlerts stores the scheduler's alerts for the task with the given ID,
+	// provided it is still at version, otherwise it is rejected with
+	// ErrConflict. Nothing else changes: the version and UpdatedAt are kept
+	// and the observer is not told, since the alerts record what the
+	// scheduler noticed rather than a change anybody made to the task.
+	// Returns the task as stored with the alerts, or error if it doesn't exist.
+	MarkAlerts(ctx context.Context, id, version int, alerts models.Alerts) (*models.Task, error)
 
 	// Delete removes a task from storage by ID.
 	// Returns error if task doesn't exist or deletion fails.
-	Delete(id int) error
+	Delete(ctx context.Context, id int) error
+
+	// Batch applies ops in order and reports the outcome of each of them.
+	// Without atomic, every operation succeeds or fails on its own. With atomic,
+	// either all operations are applied or none is: if any fails, the batch is
+	// rolled back, the failing operation carries its error and the others are
+	// reported as not applied. The returned error is reserved for failures of
+	// the batch as a whole, such as a canceled context.
+	Batch(ctx context.Context, ops []BatchOp, atomic bool) ([]BatchResult, error)
+
+	// WithTx runs fn in a transaction. fn receives a TaskStorage bound to the
+	// transaction: its reads see the transaction's own writes, and nobody else
+	// sees them until fn returns nil and the transaction commits. If fn returns
+	// an error or panics, every change made through tx is rolled back and the
+	// error is returned as is. Calling WithTx on tx starts a nested transaction
+	// that can be rolled back on its own.
+	//
+	// fn must only use tx, never the storage it came from: backends may hold
+	// locks or the only database connection for the duration of the
+	// transaction. tx must not be used after fn returns.
+	WithTx(ctx context.Context, fn func(tx TaskStorage) error) error
+
+	// View runs fn with a read-only view of the storage: every read made
+	// through tx sees the same state, as in a transaction, and writes through
+	// tx fail with ErrInvalid. Unlike WithTx it takes no write lock, so other
+	// readers of in-memory storage go ahead, and databases run it as a
+	// read-only transaction. The error of fn is returned as is; the same
+	// rules as for WithTx apply to using tx.
+	View(ctx context.Context, fn func(tx TaskStorage) error) error
 }
 
 // StoreBackend defines the type of storage backend
@@ -41,25 +123,50 @@ type StoreBackend string
 const (
 	BackendMemory   StoreBackend = "memory"
 	BackendDatabase StoreBackend = "database"
+	BackendFile     StoreBackend = "file"
 )
 
-// AutoDetectBackend automatically detects which backend to use based on environment
+// AutoDetectBackend automatically detects which backend to use based on environment.
+// DATABASE_URL takes precedence over DATA_DIR; without either, tasks are kept in memory.
 func AutoDetectBackend() StoreBackend {
 	if os.Getenv("DATABASE_URL") != "" {
 		return BackendDatabase
 	}
+	if os.Getenv("DATA_DIR") != "" {
+		return BackendFile
+	}
 	return BackendMemory
 }
 
-// NewTaskStorage creates a TaskStorage based on auto-detected backend
-func NewTaskStorage() TaskStorage {
+// NewTaskStorage creates a TaskStorage based on auto-detected backend.
+// Returns an error if the configured backend cannot be initialized
+// (e.g. the database is unreachable or DATABASE_URL is malformed).
+// Backends holding external resources implement io.Closer.
+func NewTaskStorage(opts ...Option) (TaskStorage, error) {
 	backend := AutoDetectBackend()
 
 	switch backend {
 	case BackendDatabase:
-		// TODO: Implement database storage
-		panic("Database backend not implemented yet. Remove DATABASE_URL to use memory backend.")
+		sqlStorage, err := NewSQLStorage(os.Getenv("DATABASE_URL"), opts...)
+		if err != nil {
+			return nil, err
+		}
+		return sqlStorage, nil
+	case BackendFile:
+		fileStorage, err := NewFileStorage(os.Getenv("DATA_DIR"), opts...)
+		if err != nil {
+			return nil, err
+		}
+		return fileStorage, nil
 	default:
-		return NewInMemoryStorage()
+		return NewInMemoryStorage(opts...), nil
+	}
+}
+
+// upgradeState puts a task stored before workflows existed, which has no
+// state, into the state of the current workflow matching its status.
+func upgradeState(task *models.Task) {
+	if task.State == "" {
+		task.State = models.DefaultWorkflow().StateForStatus("", task.Status)
 	}
 }
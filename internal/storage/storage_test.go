@@ -1,269 +1,78 @@
 package storage
 
 import (
-	"os"
-	"task-api/internal/models"
+	"path/filepath"
 	"testing"
 )
 
-// TestTaskStorage_Create tests task creation functionality
-func TestTaskStorage_Create(t *testing.T) {
-	storage := NewTaskStorage()
-
+// TestAutoDetectBackend tests backend selection from the environment
+func TestAutoDetectBackend(t *testing.T) {
 	tests := []struct {
-		name     string
-		taskName string
-		status   int
-		wantErr  bool
+		name        string
+		databaseURL string
+		dataDir     string
+		want        StoreBackend
 	}{
-		{
-			name:     "create valid incomplete task",
-			taskName: "Test task",
-			status:   0,
-			wantErr:  false,
-		},
-		{
-			name:     "create valid completed task",
-			taskName: "Completed task",
-			status:   1,
-			wantErr:  false,
-		},
+		{name: "no configuration", want: BackendMemory},
+		{name: "DATA_DIR only", dataDir: "/var/lib/task-api", want: BackendFile},
+		{name: "DATABASE_URL only", databaseURL: "postgres://localhost/testdb", want: BackendDatabase},
+		{name: "DATABASE_URL wins over DATA_DIR", databaseURL: "postgres://localhost/testdb", dataDir: "/var/lib/task-api", want: BackendDatabase},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// Create task using models.NewTask for validation
-			task, err := models.NewTask(tt.taskName, tt.status)
-			if err != nil {
-				t.Fatalf("Failed to create task model: %v", err)
-			}
-
-			// Store the task
-			result, err := storage.Create(task)
-
-			if tt.wantErr {
-				if err == nil {
-					t.Errorf("Expected error but got none")
-				}
-				return
-			}
+			t.Setenv("DATABASE_URL", tt.databaseURL)
+			t.Setenv("DATA_DIR", tt.dataDir)
 
-			if err != nil {
-				t.Errorf("Unexpected error: %v", err)
-				return
-			}
-
-			// Verify task was assigned an ID
-			if result.ID == 0 {
-				t.Error("Expected task to be assigned an ID, got 0")
-			}
-
-			// Verify task content is preserved
-			if result.Name != tt.taskName {
-				t.Errorf("Expected name %q, got %q", tt.taskName, result.Name)
-			}
-
-			if result.Status != tt.status {
-				t.Errorf("Expected status %d, got %d", tt.status, result.Status)
+			if backend := AutoDetectBackend(); backend != tt.want {
+				t.Errorf("Expected %q backend, got %q", tt.want, backend)
 			}
 		})
 	}
 }
 
-// TestTaskStorage_Create_UniqueIDs tests that created tasks get unique IDs
-func TestTaskStorage_Create_UniqueIDs(t *testing.T) {
-	storage := NewTaskStorage()
-
-	task1, _ := models.NewTask("Task 1", 0)
-	task2, _ := models.NewTask("Task 2", 1)
-
-	result1, err := storage.Create(task1)
-	if err != nil {
-		t.Fatalf("Failed to create first task: %v", err)
-	}
-
-	result2, err := storage.Create(task2)
-	if err != nil {
-		t.Fatalf("Failed to create second task: %v", err)
-	}
-
-	if result1.ID == result2.ID {
-		t.Errorf("Expected unique IDs, but both tasks got ID %d", result1.ID)
-	}
-
-	if result1.ID == 0 || result2.ID == 0 {
-		t.Error("Expected non-zero IDs for both tasks")
-	}
-}
-
-// TestTaskStorage_GetAll tests retrieving all tasks
-func TestTaskStorage_GetAll(t *testing.T) {
-	storage := NewTaskStorage()
+// TestNewTaskStorage_FileBackend tests that DATA_DIR selects the file backend
+func TestNewTaskStorage_FileBackend(t *testing.T) {
+	t.Setenv("DATABASE_URL", "")
+	t.Setenv("DATA_DIR", t.TempDir())
 
-	// Test empty storage
-	tasks, err := storage.GetAll()
+	storage, err := NewTaskStorage()
 	if err != nil {
-		t.Errorf("Unexpected error getting all tasks from empty storage: %v", err)
+		t.Fatalf("Unexpected error creating file storage: %v", err)
 	}
-	if len(tasks) != 0 {
-		t.Errorf("Expected 0 tasks in empty storage, got %d", len(tasks))
-	}
-	// Add some tasks
-	task1, _ := models.NewTask("Task 1", 0)
-	task2, _ := models.NewTask("Task 2", 1)
 
-	_, err = storage.Create(task1)
-	if err != nil {
-		t.Fatalf("Failed to create task1: %v", err)
-	}
-	_, err = storage.Create(task2)
-	if err != nil {
-		t.Fatalf("Failed to create task2: %v", err)
-	}
-
-	// Test with tasks
-	tasks, err = storage.GetAll()
-	if err != nil {
-		t.Errorf("Unexpected error getting all tasks: %v", err)
-	}
-	if len(tasks) != 2 {
-		t.Errorf("Expected 2 tasks, got %d", len(tasks))
+	fileStorage, ok := storage.(*FileStorage)
+	if !ok {
+		t.Fatalf("Expected *FileStorage, got %T", storage)
 	}
+	defer fileStorage.Close()
 }
 
-// TestTaskStorage_GetByID tests retrieving specific tasks by ID
-func TestTaskStorage_GetByID(t *testing.T) {
-	storage := NewTaskStorage()
-
-	// Test non-existent task
-	_, err := storage.GetByID(999)
-	if err == nil {
-		t.Error("Expected error when getting non-existent task")
-	}
-
-	// Create and store a task
-	task, _ := models.NewTask("Test task", 0)
-	created, err := storage.Create(task)
-	if err != nil {
-		t.Fatalf("Failed to create task: %v", err)
-	}
+// TestNewTaskStorage_DatabaseBackend tests that DATABASE_URL selects the SQL backend
+func TestNewTaskStorage_DatabaseBackend(t *testing.T) {
+	t.Setenv("DATABASE_URL", "sqlite://"+filepath.Join(t.TempDir(), "tasks.db"))
 
-	// Test retrieving existing task
-	retrieved, err := storage.GetByID(created.ID)
+	storage, err := NewTaskStorage()
 	if err != nil {
-		t.Errorf("Unexpected error retrieving task: %v", err)
+		t.Fatalf("Unexpected error creating database storage: %v", err)
 	}
 
-	if retrieved.ID != created.ID {
-		t.Errorf("Expected ID %d, got %d", created.ID, retrieved.ID)
-	}
-	if retrieved.Name != created.Name {
-		t.Errorf("Expected name %q, got %q", created.Name, retrieved.Name)
-	}
-	if retrieved.Status != created.Status {
-		t.Errorf("Expected status %d, got %d", created.Status, retrieved.Status)
+	sqlStorage, ok := storage.(*SQLStorage)
+	if !ok {
+		t.Fatalf("Expected *SQLStorage, got %T", storage)
 	}
+	defer sqlStorage.Close()
 }
 
-// TestTaskStorage_Update tests updating existing tasks
-func TestTaskStorage_Update(t *testing.T) {
-	storage := NewTaskStorage()
+// TestNewTaskStorage_InvalidDatabaseURL tests that a bad DATABASE_URL is reported instead of panicking
+func TestNewTaskStorage_InvalidDatabaseURL(t *testing.T) {
+	t.Setenv("DATABASE_URL", "mysql://localhost/testdb")
 
-	// Test updating non-existent task
-	task, _ := models.NewTask("Non-existent", 0)
-	task.ID = 999
-	err := storage.Update(task)
+	storage, err := NewTaskStorage()
 	if err == nil {
-		t.Error("Expected error when updating non-existent task")
-	}
-
-	// Create a task
-	original, _ := models.NewTask("Original task", 0)
-	created, err := storage.Create(original)
-	if err != nil {
-		t.Fatalf("Failed to create task: %v", err)
+		t.Fatal("Expected error for unsupported DATABASE_URL scheme")
 	}
-
-	// Update the task
-	updated, _ := models.NewTask("Updated task", 1)
-	updated.ID = created.ID
-	err = storage.Update(updated)
-	if err != nil {
-		t.Errorf("Unexpected error updating task: %v", err)
-	}
-
-	// Verify update
-	retrieved, err := storage.GetByID(created.ID)
-	if err != nil {
-		t.Fatalf("Failed to retrieve updated task: %v", err)
-	}
-
-	if retrieved.Name != "Updated task" {
-		t.Errorf("Expected updated name %q, got %q", "Updated task", retrieved.Name)
-	}
-	if retrieved.Status != 1 {
-		t.Errorf("Expected updated status 1, got %d", retrieved.Status)
-	}
-}
-
-// TestTaskStorage_Delete tests deleting tasks
-func TestTaskStorage_Delete(t *testing.T) {
-	storage := NewTaskStorage()
-
-	// Test deleting non-existent task
-	err := storage.Delete(999)
-	if err == nil {
-		t.Error("Expected error when deleting non-existent task")
+	if storage != nil {
+		t.Errorf("Expected nil storage on error, got %T", storage)
 	}
-
-	// Create a task
-	task, _ := models.NewTask("Task to delete", 0)
-	created, err := storage.Create(task)
-	if err != nil {
-		t.Fatalf("Failed to create task: %v", err)
-	}
-
-	// Verify task exists
-	_, err = storage.GetByID(created.ID)
-	if err != nil {
-		t.Fatalf("Task should exist before deletion: %v", err)
-	}
-
-	// Delete the task
-	err = storage.Delete(created.ID)
-	if err != nil {
-		t.Errorf("Unexpected error deleting task: %v", err)
-	}
-
-	// Verify task is gone
-	_, err = storage.GetByID(created.ID)
-	if err == nil {
-		t.Error("Expected error when getting deleted task")
-	}
-}
-
-// TestNewTaskStorage_DatabaseBackend tests the database backend detection
-func TestNewTaskStorage_DatabaseBackend(t *testing.T) {
-	// Set DATABASE_URL to trigger database backend detection
-	os.Setenv("DATABASE_URL", "postgres://localhost/testdb")
-
-	// This should panic because database backend is not yet implemented
-	defer func() {
-		if r := recover(); r != nil {
-			// Expected panic - database backend not implemented
-			expectedMessage := "Database backend not implemented yet. Remove DATABASE_URL to use memory backend."
-			if panicMsg, ok := r.(string); ok {
-				if panicMsg != expectedMessage {
-					t.Errorf("Expected panic message %q, got %q", expectedMessage, panicMsg)
-				}
-			} else {
-				t.Errorf("Expected string panic message, got %v", r)
-			}
-		} else {
-			t.Error("Expected panic when DATABASE_URL is set, but no panic occurred")
-		}
-	}()
-
-	// This should panic
-	NewTaskStorage()
 }
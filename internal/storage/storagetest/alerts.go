@@ -0,0 +1,123 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"task-api/internal/clock"
+	"task-api/internal/models"
+	"task-api/internal/storage"
+	"testing"
+	"time"
+)
+
+// testAlerts tests that the scheduler's alerts are stored as given, filter
+// overdue tasks, and are cleared on create, on completion and when the due
+// date changes
+func testAlerts(t *testing.T, s storage.TaskStorage, c *clock.Manual) {
+	due := dueAt(1)
+	remindedAt := c.Now().UTC()
+
+	// Alerts sent with a new task are dropped
+	task := newTask(t, "Submit report", 0)
+	task.DueAt = due
+	task.RemindedAt = &remindedAt
+	created, err := s.Create(context.Background(), task)
+	if err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+	if created.Alerts != (models.Alerts{}) {
+		t.Errorf("Expected a new task without alerts, got %+v", created.Alerts)
+	}
+	other := mustCreateWithDetails(t, s, "Book venue", 0, models.Details{DueAt: due})
+
+	// The scheduler's update keeps them, and so do later unrelated changes
+	c.Advance(time.Hour)
+	overdueAt := c.Now().UTC()
+	created.RemindedAt, created.OverdueAt = &remindedAt, &overdueAt
+	overdue := mustUpdate(t, s, created)
+	if overdue.RemindedAt == nil || !overdue.RemindedAt.Equal(remindedAt) || overdue.OverdueAt == nil || !overdue.OverdueAt.Equal(overdueAt) {
+		t.Errorf("Expected alerts %v and %v to be stored, got %+v", remindedAt, overdueAt, overdue.Alerts)
+	}
+	overdue.Name = "Submit the report"
+	renamed := mustUpdate(t, s, overdue)
+	if !reflect.DeepEqual(renamed.Alerts, overdue.Alerts) {
+		t.Errorf("Expected a rename to keep alerts %+v, got %+v", overdue.Alerts, renamed.Alerts)
+	}
+	if stored := mustGet(t, s, created.ID); !reflect.DeepEqual(stored, renamed) {
+		t.Errorf("Expected GetByID to return %+v, got %+v", renamed, stored)
+	}
+
+	yes, no := true, false
+	if ids := taskIDs(mustQuery(t, s, storage.TaskQuery{Overdue: &yes}).Tasks); !reflect.DeepEqual(ids, []int{created.ID}) {
+		t.Errorf("Expected overdue tasks [%d], got %v", created.ID, ids)
+	}
+	if ids := taskIDs(mustQuery(t, s, storage.TaskQuery{Overdue: &no}).Tasks); !reflect.DeepEqual(ids, []int{other.ID}) {
+		t.Errorf("Expected tasks not overdue [%d], got %v", other.ID, ids)
+	}
+
+	// A new due date is watched afresh
+	renamed.DueAt = dueAt(7)
+	postponed := mustUpdate(t, s, renamed)
+	if postponed.Alerts != (models.Alerts{}) {
+		t.Errorf("Expected a new due date to clear alerts, got %+v", postponed.Alerts)
+	}
+
+	// A completed task is neither overdue nor reminded of
+	postponed.OverdueAt = &overdueAt
+	postponed = mustUpdate(t, s, postponed)
+	if err := postponed.Update(postponed.Name, 1); err != nil {
+		t.Fatalf("Failed to change status: %v", err)
+	}
+	if completed := mustUpdate(t, s, postponed); completed.Alerts != (models.Alerts{}) {
+		t.Errorf("Expected completion to clear alerts, got %+v", completed.Alerts)
+	}
+}
+
+// testMarkAlerts tests that marking alerts stores them without touching the
+// version or update time, checks the version, and is undone with a transaction
+func testMarkAlerts(t *testing.T, s storage.TaskStorage, c *clock.Manual) {
+	ctx := context.Background()
+	task := mustCreateWithDetails(t, s, "Submit report", 0, models.Details{DueAt: dueAt(1)})
+
+	c.Advance(time.Hour)
+	remindedAt := c.Now().UTC()
+	alerts := models.Alerts{RemindedAt: &remindedAt}
+	marked, err := s.MarkAlerts(ctx, task.ID, task.Version, alerts)
+	if err != nil {
+		t.Fatalf("Failed to mark alerts: %v", err)
+	}
+	if marked.RemindedAt == nil || !marked.RemindedAt.Equal(remindedAt) || marked.OverdueAt != nil {
+		t.Errorf("Expected the task reminded at %v, got %+v", remindedAt, marked.Alerts)
+	}
+	if marked.Version != task.Version || !marked.UpdatedAt.Equal(task.UpdatedAt) {
+		t.Errorf("Expected version %d updated at %v, got %d at %v", task.Version, task.UpdatedAt, marked.Version, marked.UpdatedAt)
+	}
+	if stored := mustGet(t, s, task.ID); !reflect.DeepEqual(stored, marked) {
+		t.Errorf("Expected GetByID to return %+v, got %+v", marked, stored)
+	}
+
+	// The version the alerts were worked out from must still be current
+	task.Name = "Submit the report"
+	renamed := mustUpdate(t, s, task)
+	if _, err := s.MarkAlerts(ctx, task.ID, task.Version, models.Alerts{}); !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("Expected ErrConflict for a stale version, got %v", err)
+	}
+	if _, err := s.MarkAlerts(ctx, 999, 1, alerts); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a missing task, got %v", err)
+	}
+
+	// Marks made in a transaction are rolled back with it
+	err = s.WithTx(ctx, func(tx storage.TaskStorage) error {
+		if _, err := tx.MarkAlerts(ctx, renamed.ID, renamed.Version, models.Alerts{OverdueAt: &remindedAt}); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("Expected the transaction to abort, got %v", err)
+	}
+	if stored := mustGet(t, s, task.ID); !reflect.DeepEqual(stored, renamed) {
+		t.Errorf("Expected the rolled back mark undone, got %+v", stored)
+	}
+}
@@ -0,0 +1,207 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"task-api/internal/models"
+	"task-api/internal/storage"
+	"testing"
+)
+
+// mustBatch runs a batch or fails the test
+func mustBatch(t *testing.T, s storage.TaskStorage, ops []storage.BatchOp, atomic bool) []storage.BatchResult {
+	t.Helper()
+
+	results, err := s.Batch(context.Background(), ops, atomic)
+	if err != nil {
+		t.Fatalf("Batch failed: %v", err)
+	}
+	if len(results) != len(ops) {
+		t.Fatalf("Expected %d results, got %d", len(ops), len(results))
+	}
+	return results
+}
+
+// newTask builds a valid task model or fails the test
+func newTask(t *testing.T, name string, status int) *models.Task {
+	t.Helper()
+
+	task, err := models.NewTask(name, status)
+	if err != nil {
+		t.Fatalf("Failed to create task model: %v", err)
+	}
+	return task
+}
+
+// allTasks returns every stored task or fails the test
+func allTasks(t *testing.T, s storage.TaskStorage) []*models.Task {
+	t.Helper()
+
+	tasks, err := s.GetAll(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to retrieve tasks: %v", err)
+	}
+	return tasks
+}
+
+// testBatch tests that operations of a non-atomic batch succeed or fail independently
+func testBatch(t *testing.T, s storage.TaskStorage) {
+	existing := mustCreate(t, s, "Existing", 0)
+	doomed := mustCreate(t, s, "Doomed", 0)
+
+	update := existing.Clone()
+	update.Status = 1
+	stale := existing.Clone()
+	stale.Name = "Stale"
+
+	results := mustBatch(t, s, []storage.BatchOp{
+		{Kind: storage.BatchCreate, Task: newTask(t, "Imported", 0)},
+		{Kind: storage.BatchUpdate, Task: update},
+		{Kind: storage.BatchUpdate, Task: stale}, // Based on the version the previous op replaced
+		{Kind: storage.BatchDelete, ID: doomed.ID},
+		{Kind: storage.BatchDelete, ID: 9999},
+	}, false)
+
+	if !results[0].Applied || results[0].Task == nil || results[0].Task.ID == 0 || results[0].Task.Version != 1 {
+		t.Errorf("Expected create to be applied with an ID, got %+v", results[0])
+	}
+	if !results[1].Applied || results[1].Task == nil || results[1].Task.Version != existing.Version+1 {
+		t.Errorf("Expected update to be applied with a new version, got %+v", results[1])
+	}
+	if results[2].Applied || !errors.Is(results[2].Err, storage.ErrConflict) {
+		t.Errorf("Expected stale update to fail with ErrConflict, got %+v", results[2])
+	}
+	if !results[3].Applied || results[3].Err != nil {
+		t.Errorf("Expected delete to be applied, got %+v", results[3])
+	}
+	if results[4].Applied || !errors.Is(results[4].Err, storage.ErrNotFound) {
+		t.Errorf("Expected delete of missing task to fail with ErrNotFound, got %+v", results[4])
+	}
+
+	expected := []*models.Task{results[1].Task, results[0].Task}
+	if got := allTasks(t, s); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected stored tasks %v, got %v", expected, got)
+	}
+
+	if results := mustBatch(t, s, nil, false); len(results) != 0 {
+		t.Errorf("Expected no results for an empty batch, got %d", len(results))
+	}
+}
+
+// testBatchDeleteVersion tests that a delete naming a version only removes the
+// task while it still has that version
+func testBatchDeleteVersion(t *testing.T, s storage.TaskStorage) {
+	task := mustCreate(t, s, "Seen at version 1", 0)
+	changed := task.Clone()
+	changed.Name = "Changed since"
+	if _, err := s.Update(context.Background(), changed); err != nil {
+		t.Fatalf("Failed to update task: %v", err)
+	}
+
+	results := mustBatch(t, s, []storage.BatchOp{{Kind: storage.BatchDelete, ID: task.ID, Version: task.Version}}, true)
+	if results[0].Applied || !errors.Is(results[0].Err, storage.ErrConflict) {
+		t.Errorf("Expected deleting a stale version to fail with ErrConflict, got %+v", results[0])
+	}
+	if _, err := s.GetByID(context.Background(), task.ID); err != nil {
+		t.Fatalf("Expected the task kept, got %v", err)
+	}
+
+	results = mustBatch(t, s, []storage.BatchOp{{Kind: storage.BatchDelete, ID: task.ID, Version: task.Version + 1}}, true)
+	if !results[0].Applied || results[0].Err != nil {
+		t.Errorf("Expected deleting the current version to be applied, got %+v", results[0])
+	}
+	if _, err := s.GetByID(context.Background(), task.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Expected the task deleted, got %v", err)
+	}
+}
+
+// testBatchAtomic tests that a successful atomic batch applies every operation
+func testBatchAtomic(t *testing.T, s storage.TaskStorage) {
+	existing := mustCreate(t, s, "Existing", 0)
+	update := existing.Clone()
+	update.Name = "Renamed"
+
+	results := mustBatch(t, s, []storage.BatchOp{
+		{Kind: storage.BatchCreate, Task: newTask(t, "First", 0)},
+		{Kind: storage.BatchCreate, Task: newTask(t, "Second", 1)},
+		{Kind: storage.BatchUpdate, Task: update},
+	}, true)
+
+	for i, result := range results {
+		if !result.Applied || result.Err != nil {
+			t.Errorf("Expected operation %d to be applied, got %+v", i, result)
+		}
+	}
+	if results[0].Task.ID == results[1].Task.ID {
+		t.Error("Expected created tasks to have unique IDs")
+	}
+
+	tasks := allTasks(t, s)
+	if len(tasks) != 3 || tasks[0].Name != "Renamed" || tasks[0].Version != 2 {
+		t.Errorf("Expected renamed task and two new tasks, got %v", tasks)
+	}
+}
+
+// testBatchAtomicRollback tests that a failing atomic batch leaves no trace
+func testBatchAtomicRollback(t *testing.T, s storage.TaskStorage) {
+	kept := mustCreate(t, s, "Keep unchanged", 0)
+	other := mustCreate(t, s, "Do not delete", 1)
+	before := allTasks(t, s)
+
+	update := kept.Clone()
+	update.Name = "Should roll back"
+	results := mustBatch(t, s, []storage.BatchOp{
+		{Kind: storage.BatchCreate, Task: newTask(t, "Phantom", 0)},
+		{Kind: storage.BatchUpdate, Task: update},
+		{Kind: storage.BatchDelete, ID: other.ID},
+		{Kind: storage.BatchDelete, ID: 9999},
+		{Kind: storage.BatchCreate, Task: newTask(t, "Never attempted", 0)},
+	}, true)
+
+	if results[3].Applied || !errors.Is(results[3].Err, storage.ErrNotFound) {
+		t.Errorf("Expected failing operation to report ErrNotFound, got %+v", results[3])
+	}
+	for _, i := range []int{0, 1, 2, 4} {
+		if results[i].Applied || results[i].Err != nil || results[i].Task != nil {
+			t.Errorf("Expected operation %d to be reported as not applied, got %+v", i, results[i])
+		}
+	}
+
+	if after := allTasks(t, s); !reflect.DeepEqual(after, before) {
+		t.Errorf("Expected tasks to be unchanged after rollback, got %v, want %v", after, before)
+	}
+	for _, query := range []string{"phantom", "roll"} {
+		if found, _ := s.Search(context.Background(), query, 0); len(found) != 0 {
+			t.Errorf("Expected rolled back text %q to be unindexed, got %d results", query, len(found))
+		}
+	}
+	if found, _ := s.Search(context.Background(), "unchanged", 0); len(found) != 1 {
+		t.Errorf("Expected original text to stay indexed, got %d results", len(found))
+	}
+
+	// The rolled back update must not have consumed the version
+	update.Name = "Applied later"
+	if _, err := s.Update(context.Background(), update); err != nil {
+		t.Errorf("Expected update with the original version to succeed after rollback: %v", err)
+	}
+}
+
+// testBatchInvalidOps tests that malformed operations are rejected with ErrInvalid
+func testBatchInvalidOps(t *testing.T, s storage.TaskStorage) {
+	results := mustBatch(t, s, []storage.BatchOp{
+		{Kind: storage.BatchCreate},
+		{Kind: storage.BatchUpdate},
+		{Kind: "upsert", Task: newTask(t, "Task", 0)},
+		{Kind: storage.BatchCreate, Task: newTask(t, "Valid", 0)},
+	}, false)
+
+	for i := 0; i < 3; i++ {
+		if results[i].Applied || !errors.Is(results[i].Err, storage.ErrInvalid) {
+			t.Errorf("Expected operation %d to fail with ErrInvalid, got %+v", i, results[i])
+		}
+	}
+	if !results[3].Applied {
+		t.Errorf("Expected valid operation to be applied, got %+v", results[3])
+	}
+}
@@ -0,0 +1,234 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"reflect"
+	"sync"
+	"task-api/internal/models"
+	"task-api/internal/storage"
+	"testing"
+	"time"
+)
+
+// changeLog is an observer remembering the changes a storage reported
+type changeLog struct {
+	mutex  sync.Mutex
+	writes [][]storage.Change
+}
+
+func (l *changeLog) observe(changes []storage.Change) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.writes = append(l.writes, changes)
+}
+
+// observeSlowly is observe taking a varying time first, as a busy observer
+// would, giving other writers every chance to report their changes in between
+func (l *changeLog) observeSlowly(changes []storage.Change) {
+	time.Sleep(rand.N(2 * time.Millisecond))
+	l.observe(changes)
+}
+
+// take returns the changes reported so far as "kind id" strings, checks that
+// the stored tasks came with them, and forgets them
+func (l *changeLog) take(t *testing.T) []string {
+	t.Helper()
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	var taken []string
+	for _, changes := range l.writes {
+		for _, change := range changes {
+			if (change.Task == nil) != (change.Kind == storage.ChangeDeleted) || (change.Task != nil && change.Task.ID != change.ID) {
+				t.Errorf("Unexpected task %+v for %s %d", change.Task, change.Kind, change.ID)
+			}
+			taken = append(taken, fmt.Sprintf("%s %d", change.Kind, change.ID))
+		}
+	}
+	l.writes = nil
+	return taken
+}
+
+// testObserver tests that the observer hears of every committed change once,
+// including the changes of related tasks, and of nothing rolled back
+func testObserver(t *testing.T, s storage.TaskStorage, changes *changeLog) {
+	ctx := context.Background()
+	check := func(step string, expected ...string) {
+		t.Helper()
+		if got := changes.take(t); !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s: expected changes %v, got %v", step, expected, got)
+		}
+	}
+
+	epic := mustCreate(t, s, "Epic", 0)
+	check("create", fmt.Sprintf("created %d", epic.ID))
+	story := mustCreateSubtask(t, s, "Story", 0, epic.ID)
+	check("create subtask", fmt.Sprintf("created %d", story.ID))
+
+	if err := complete(t, s, story.ID); err != nil {
+		t.Fatalf("Failed to complete task: %v", err)
+	}
+	check("roll up", fmt.Sprintf("updated %d", story.ID), fmt.Sprintf("updated %d", epic.ID))
+	if stored := mustGet(t, s, epic.ID); stored.Status != 1 {
+		t.Fatalf("Expected the epic to be completed, got %+v", stored)
+	}
+
+	// The scheduler's alerts are not a change anybody else needs to hear of
+	remindedAt := time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC)
+	alerts := models.Alerts{RemindedAt: &remindedAt}
+	if _, err := s.MarkAlerts(ctx, story.ID, mustGet(t, s, story.ID).Version, alerts); err != nil {
+		t.Fatalf("Failed to mark alerts: %v", err)
+	}
+	check("mark alerts")
+	version := mustGet(t, s, epic.ID).Version
+	if err := s.WithTx(ctx, func(tx storage.TaskStorage) error {
+		_, err := tx.MarkAlerts(ctx, epic.ID, version, alerts)
+		return err
+	}); err != nil {
+		t.Fatalf("Failed to mark alerts in a transaction: %v", err)
+	}
+	check("mark alerts in a transaction")
+
+	stale := mustGet(t, s, epic.ID)
+	stale.Version--
+	if _, err := s.Update(ctx, stale); err == nil {
+		t.Fatal("Expected a stale update to fail")
+	}
+	check("failed update")
+
+	// A transaction is reported once, as it ends up
+	var created int
+	err := s.WithTx(ctx, func(tx storage.TaskStorage) error {
+		task, err := tx.Create(ctx, newTask(t, "Draft", 0))
+		if err != nil {
+			return err
+		}
+		created = task.ID
+		task.Name = "Final"
+		if _, err := tx.Update(ctx, task); err != nil {
+			return err
+		}
+		scratch, err := tx.Create(ctx, newTask(t, "Scratch", 0))
+		if err != nil {
+			return err
+		}
+		if err := tx.Delete(ctx, scratch.ID); err != nil {
+			return err
+		}
+		// A failed nested transaction leaves nothing to report
+		if err := tx.WithTx(ctx, func(nested storage.TaskStorage) error {
+			if err := nested.Delete(ctx, epic.ID); err != nil {
+				return err
+			}
+			return errAbort
+		}); err != errAbort {
+			return fmt.Errorf("expected the nested transaction to abort, got %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Transaction failed: %v", err)
+	}
+	changes.mutex.Lock()
+	writes := len(changes.writes)
+	changes.mutex.Unlock()
+	if writes != 1 {
+		t.Errorf("Expected the transaction to be reported at once, got %d writes", writes)
+	}
+	check("transaction", fmt.Sprintf("created %d", created))
+	if task := mustGet(t, s, created); task.Name != "Final" {
+		t.Errorf("Expected the final task to be stored, got %+v", task)
+	}
+
+	if err := s.WithTx(ctx, func(tx storage.TaskStorage) error {
+		if _, err := tx.Create(ctx, newTask(t, "Rolled back", 0)); err != nil {
+			return err
+		}
+		return errAbort
+	}); err != errAbort {
+		t.Fatalf("Expected the transaction to abort, got %v", err)
+	}
+	check("rollback")
+
+	results := mustBatch(t, s, []storage.BatchOp{
+		{Kind: storage.BatchCreate, Task: newTask(t, "Imported", 0)},
+		{Kind: storage.BatchDelete, ID: 999},
+		{Kind: storage.BatchDelete, ID: created},
+	}, false)
+	if !results[0].Applied || results[1].Applied || !results[2].Applied {
+		t.Fatalf("Expected only the second operation to fail, got %+v", results)
+	}
+	check("batch", fmt.Sprintf("created %d", results[0].Task.ID), fmt.Sprintf("deleted %d", created))
+
+	// Deleting the epic moves the story up
+	if err := s.Delete(ctx, epic.ID); err != nil {
+		t.Fatalf("Failed to delete task: %v", err)
+	}
+	check("delete", fmt.Sprintf("deleted %d", epic.ID), fmt.Sprintf("updated %d", story.ID))
+}
+
+// testObserverOrder tests that the observer hears of concurrent writes in the
+// order they were committed: the versions reported for a task only increase
+func testObserverOrder(t *testing.T, s storage.TaskStorage, changes *changeLog) {
+	const workers = 8
+	const perWorker = 10
+	ctx := context.Background()
+
+	shared := mustCreate(t, s, "Shared task", 0)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*perWorker)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				for {
+					current, err := s.GetByID(ctx, shared.ID)
+					if err != nil {
+						errs <- fmt.Errorf("get by id: %w", err)
+						break
+					}
+					current.Name = fmt.Sprintf("Worker %d update %d", w, i)
+					_, err = s.Update(ctx, current)
+					if errors.Is(err, storage.ErrConflict) {
+						continue // Lost the race; re-read and try again
+					}
+					if err != nil {
+						errs <- fmt.Errorf("update: %w", err)
+					}
+					break
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("Unexpected error during concurrent updates: %v", err)
+	}
+
+	changes.mutex.Lock()
+	defer changes.mutex.Unlock()
+
+	var versions []int
+	for _, write := range changes.writes {
+		for _, change := range write {
+			if change.ID == shared.ID && change.Task != nil {
+				versions = append(versions, change.Task.Version)
+			}
+		}
+	}
+	for i := 1; i < len(versions); i++ {
+		if versions[i] <= versions[i-1] {
+			t.Fatalf("Expected the reported versions to increase, got %v", versions)
+		}
+	}
+	if want := workers*perWorker + 1; len(versions) == 0 || versions[len(versions)-1] != want {
+		t.Errorf("Expected version %d reported last, got %v", want, versions)
+	}
+}
@@ -0,0 +1,689 @@
+// Package storagetest provides a conformance suite for storage.TaskStorage.
+// Every backend runs the same suite so they all behave identically:
+//
+//	func TestConformance(t *testing.T) {
+//		storagetest.RunConformance(t, func(t *testing.T, opts ...storage.Option) storage.TaskStorage {
+//			return storage.NewInMemoryStorage(opts...)
+//		})
+//	}
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"task-api/internal/clock"
+	"task-api/internal/models"
+	"task-api/internal/storage"
+	"testing"
+	"time"
+)
+
+// Factory returns a new, empty TaskStorage for a single test, configured with opts.
+// Factories should register any cleanup (closing files, connections) with t.Cleanup.
+type Factory func(t *testing.T, opts ...storage.Option) storage.TaskStorage
+
+// clockStart is the time of the manual clock handed to the timestamp tests.
+// It has a sub-microsecond part and a non-UTC offset to check that backends
+// store times exactly and in UTC.
+var clockStart = time.Date(2030, 6, 1, 14, 0, 0, 123456789, time.FixedZone("CEST", 2*60*60))
+
+// RunConformance runs the full conformance suite, creating a fresh storage for each test.
+func RunConformance(t *testing.T, newStorage Factory) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, s storage.TaskStorage)
+	}{
+		{"Create", testCreate},
+		{"Create_UniqueIDs", testCreateUniqueIDs},
+		{"Create_Nil", testCreateNil},
+		{"GetAll", testGetAll},
+		{"GetAll_OrderedByID", testGetAllOrdered},
+		{"Query_Filters", testQueryFilters},
+		{"Query_Sort", testQuerySort},
+		{"Query_Offset", testQueryOffset},
+		{"Query_Cursor", testQueryCursor},
+		{"Query_Invalid", testQueryInvalid},
+		{"Query_DetailFilters", testQueryDetailFilters},
+		{"Details", testDetails},
+		{"State", testState},
+		{"Hierarchy", testHierarchy},
+		{"Hierarchy_Delete", testHierarchyDelete},
+		{"Hierarchy_Rollup", testHierarchyRollup},
+		{"Dependencies", testDependencies},
+		{"Dependencies_Blocking", testDependenciesBlocking},
+		{"Dependencies_Delete", testDependenciesDelete},
+		{"Batch", testBatch},
+		{"Batch_Atomic", testBatchAtomic},
+		{"Batch_AtomicRollback", testBatchAtomicRollback},
+		{"Batch_InvalidOps", testBatchInvalidOps},
+		{"Batch_DeleteVersion", testBatchDeleteVersion},
+		{"Tx_Commit", testTxCommit},
+		{"Tx_Rollback", testTxRollback},
+		{"Tx_Panic", testTxPanic},
+		{"Tx_FailedOperation", testTxFailedOperation},
+		{"Tx_Nested", testTxNested},
+		{"Tx_Finished", testTxFinished},
+		{"Tx_CanceledContext", testTxCanceledContext},
+		{"View", testView},
+		{"View_CanceledContext", testViewCanceledContext},
+		{"Search", testSearch},
+		{"Search_IndexMaintained", testSearchIndexMaintained},
+		{"Search_CanceledContext", testSearchCanceledContext},
+		{"Search_Details", testSearchDetails},
+		{"GetByID", testGetByID},
+		{"Update", testUpdate},
+		{"Update_NotFound", testUpdateNotFound},
+		{"Update_Nil", testUpdateNil},
+		{"Update_StaleVersion", testUpdateStaleVersion},
+		{"Versioning", testVersioning},
+		{"Delete", testDelete},
+		{"Delete_NotFound", testDeleteNotFound},
+		{"IDsNotReused", testIDsNotReused},
+		{"CopyIsolation_Create", testCopyIsolationCreate},
+		{"CopyIsolation_Reads", testCopyIsolationReads},
+		{"CopyIsolation_Update", testCopyIsolationUpdate},
+		{"Concurrency", testConcurrency},
+		{"Concurrency_MutateResults", testConcurrencyMutateResults},
+		{"CanceledContext", testCanceledContext},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.run(t, newStorage(t))
+		})
+	}
+
+	clockTests := []struct {
+		name string
+		run  func(t *testing.T, s storage.TaskStorage, c *clock.Manual)
+	}{
+		{"Timestamps", testTimestamps},
+		{"Timestamps_Batch", testTimestampsBatch},
+		{"Query_TimeFilters", testQueryTimeFilters},
+		{"Query_SortByTime", testQuerySortByTime},
+		{"Recurrence", testRecurrence},
+		{"Alerts", testAlerts},
+		{"MarkAlerts", testMarkAlerts},
+	}
+
+	for _, tt := range clockTests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := clock.NewManual(clockStart)
+			tt.run(t, newStorage(t, storage.WithClock(c)), c)
+		})
+	}
+
+	t.Run("Observer", func(t *testing.T) {
+		changes := &changeLog{}
+		testObserver(t, newStorage(t, storage.WithObserver(changes.observe)), changes)
+	})
+	t.Run("Observer_Order", func(t *testing.T) {
+		changes := &changeLog{}
+		testObserverOrder(t, newStorage(t, storage.WithObserver(changes.observeSlowly)), changes)
+	})
+}
+
+// mustCreate stores a valid task or fails the test
+func mustCreate(t *testing.T, s storage.TaskStorage, name string, status int) *models.Task {
+	t.Helper()
+	return mustCreateWithDetails(t, s, name, status, models.Details{})
+}
+
+// testCreate tests task creation functionality
+func testCreate(t *testing.T, s storage.TaskStorage) {
+	tests := []struct {
+		name     string
+		taskName string
+		status   int
+	}{
+		{
+			name:     "create valid incomplete task",
+			taskName: "Test task",
+			status:   0,
+		},
+		{
+			name:     "create valid completed task",
+			taskName: "Completed task",
+			status:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := mustCreate(t, s, tt.taskName, tt.status)
+
+			// Verify task was assigned an ID
+			if result.ID == 0 {
+				t.Error("Expected task to be assigned an ID, got 0")
+			}
+
+			// Verify task content is preserved
+			if result.Name != tt.taskName {
+				t.Errorf("Expected name %q, got %q", tt.taskName, result.Name)
+			}
+			if result.Status != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, result.Status)
+			}
+		})
+	}
+}
+
+// testCreateUniqueIDs tests that created tasks get unique IDs
+func testCreateUniqueIDs(t *testing.T, s storage.TaskStorage) {
+	result1 := mustCreate(t, s, "Task 1", 0)
+	result2 := mustCreate(t, s, "Task 2", 1)
+
+	if result1.ID == result2.ID {
+		t.Errorf("Expected unique IDs, but both tasks got ID %d", result1.ID)
+	}
+	if result1.ID == 0 || result2.ID == 0 {
+		t.Error("Expected non-zero IDs for both tasks")
+	}
+}
+
+// testCreateNil tests that a nil task is rejected
+func testCreateNil(t *testing.T, s storage.TaskStorage) {
+	if _, err := s.Create(context.Background(), nil); !errors.Is(err, storage.ErrInvalid) {
+		t.Errorf("Expected ErrInvalid when creating nil task, got %v", err)
+	}
+}
+
+// testGetAll tests retrieving all tasks
+func testGetAll(t *testing.T, s storage.TaskStorage) {
+	// Test empty storage
+	tasks, err := s.GetAll(context.Background())
+	if err != nil {
+		t.Errorf("Unexpected error getting all tasks from empty storage: %v", err)
+	}
+	if tasks == nil {
+		t.Error("Expected empty slice from empty storage, got nil")
+	}
+	if len(tasks) != 0 {
+		t.Errorf("Expected 0 tasks in empty storage, got %d", len(tasks))
+	}
+
+	// Add some tasks
+	mustCreate(t, s, "Task 1", 0)
+	mustCreate(t, s, "Task 2", 1)
+
+	// Test with tasks
+	tasks, err = s.GetAll(context.Background())
+	if err != nil {
+		t.Errorf("Unexpected error getting all tasks: %v", err)
+	}
+	if len(tasks) != 2 {
+		t.Errorf("Expected 2 tasks, got %d", len(tasks))
+	}
+}
+
+// testGetAllOrdered tests that GetAll returns tasks in ascending ID order
+func testGetAllOrdered(t *testing.T, s storage.TaskStorage) {
+	for i := 0; i < 20; i++ {
+		mustCreate(t, s, fmt.Sprintf("Task %d", i), i%2)
+	}
+	// Punch a hole so ordering cannot rely on contiguous IDs
+	tasks, _ := s.GetAll(context.Background())
+	if err := s.Delete(context.Background(), tasks[5].ID); err != nil {
+		t.Fatalf("Failed to delete task: %v", err)
+	}
+
+	tasks, err := s.GetAll(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error getting all tasks: %v", err)
+	}
+	for i := 1; i < len(tasks); i++ {
+		if tasks[i-1].ID >= tasks[i].ID {
+			t.Fatalf("Expected ascending IDs, got %d before %d", tasks[i-1].ID, tasks[i].ID)
+		}
+	}
+}
+
+// testGetByID tests retrieving specific tasks by ID
+func testGetByID(t *testing.T, s storage.TaskStorage) {
+	// Test non-existent task
+	if _, err := s.GetByID(context.Background(), 999); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound when getting non-existent task, got %v", err)
+	}
+
+	// Create and store a task
+	created := mustCreate(t, s, "Test task", 0)
+
+	// Test retrieving existing task
+	retrieved, err := s.GetByID(context.Background(), created.ID)
+	if err != nil {
+		t.Fatalf("Unexpected error retrieving task: %v", err)
+	}
+
+	if retrieved.ID != created.ID {
+		t.Errorf("Expected ID %d, got %d", created.ID, retrieved.ID)
+	}
+	if retrieved.Name != created.Name {
+		t.Errorf("Expected name %q, got %q", created.Name, retrieved.Name)
+	}
+	if retrieved.Status != created.Status {
+		t.Errorf("Expected status %d, got %d", created.Status, retrieved.Status)
+	}
+}
+
+// testUpdate tests updating existing tasks
+func testUpdate(t *testing.T, s storage.TaskStorage) {
+	created := mustCreate(t, s, "Original task", 0)
+
+	// Update the task
+	updated, _ := models.NewTask("Updated task", 1)
+	updated.ID = created.ID
+	updated.Version = created.Version
+	result, err := s.Update(context.Background(), updated)
+	if err != nil {
+		t.Fatalf("Unexpected error updating task: %v", err)
+	}
+	if result.Name != "Updated task" || result.Status != 1 {
+		t.Errorf("Expected Update to return the stored task, got {%s %d}", result.Name, result.Status)
+	}
+
+	// Verify update
+	retrieved, err := s.GetByID(context.Background(), created.ID)
+	if err != nil {
+		t.Fatalf("Failed to retrieve updated task: %v", err)
+	}
+	if retrieved.Name != "Updated task" {
+		t.Errorf("Expected updated name %q, got %q", "Updated task", retrieved.Name)
+	}
+	if retrieved.Status != 1 {
+		t.Errorf("Expected updated status 1, got %d", retrieved.Status)
+	}
+}
+
+// testUpdateNotFound tests updating a task that does not exist
+func testUpdateNotFound(t *testing.T, s storage.TaskStorage) {
+	task, _ := models.NewTask("Non-existent", 0)
+	task.ID = 999
+	if _, err := s.Update(context.Background(), task); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound when updating non-existent task, got %v", err)
+	}
+
+	// A failed update must not create the task
+	if _, err := s.GetByID(context.Background(), 999); err == nil {
+		t.Error("Expected failed update not to create the task")
+	}
+}
+
+// testUpdateNil tests that a nil task is rejected
+func testUpdateNil(t *testing.T, s storage.TaskStorage) {
+	if _, err := s.Update(context.Background(), nil); !errors.Is(err, storage.ErrInvalid) {
+		t.Errorf("Expected ErrInvalid when updating nil task, got %v", err)
+	}
+}
+
+// testUpdateStaleVersion tests that an update based on an outdated read is rejected
+func testUpdateStaleVersion(t *testing.T, s storage.TaskStorage) {
+	created := mustCreate(t, s, "Original", 0)
+
+	// Two clients read the same version
+	first, _ := s.GetByID(context.Background(), created.ID)
+	second, _ := s.GetByID(context.Background(), created.ID)
+
+	first.Name = "First writer"
+	if _, err := s.Update(context.Background(), first); err != nil {
+		t.Fatalf("First update should succeed: %v", err)
+	}
+
+	second.Name = "Second writer"
+	if _, err := s.Update(context.Background(), second); !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("Expected ErrConflict for stale update, got %v", err)
+	}
+
+	retrieved, err := s.GetByID(context.Background(), created.ID)
+	if err != nil {
+		t.Fatalf("Failed to retrieve task: %v", err)
+	}
+	if retrieved.Name != "First writer" {
+		t.Errorf("Expected stale update to be discarded, got name %q", retrieved.Name)
+	}
+}
+
+// testVersioning tests that versions start at 1 and advance by one per update
+func testVersioning(t *testing.T, s storage.TaskStorage) {
+	task, _ := models.NewTask("Versioned", 0)
+	task.Version = 42 // Ignored: storage owns the version
+	created, err := s.Create(context.Background(), task)
+	if err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+	if created.Version != 1 {
+		t.Errorf("Expected new task at version 1, got %d", created.Version)
+	}
+
+	current := created
+	for want := 2; want <= 4; want++ {
+		current, err = s.Update(context.Background(), current)
+		if err != nil {
+			t.Fatalf("Failed to update task: %v", err)
+		}
+		if current.Version != want {
+			t.Errorf("Expected version %d after update, got %d", want, current.Version)
+		}
+	}
+
+	retrieved, err := s.GetByID(context.Background(), created.ID)
+	if err != nil {
+		t.Fatalf("Failed to retrieve task: %v", err)
+	}
+	if retrieved.Version != 4 {
+		t.Errorf("Expected stored version 4, got %d", retrieved.Version)
+	}
+}
+
+// testDelete tests deleting tasks
+func testDelete(t *testing.T, s storage.TaskStorage) {
+	created := mustCreate(t, s, "Task to delete", 0)
+	survivor := mustCreate(t, s, "Task to keep", 0)
+
+	// Verify task exists
+	if _, err := s.GetByID(context.Background(), created.ID); err != nil {
+		t.Fatalf("Task should exist before deletion: %v", err)
+	}
+
+	// Delete the task
+	if err := s.Delete(context.Background(), created.ID); err != nil {
+		t.Errorf("Unexpected error deleting task: %v", err)
+	}
+
+	// Verify task is gone and others are untouched
+	if _, err := s.GetByID(context.Background(), created.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound when getting deleted task, got %v", err)
+	}
+	if _, err := s.GetByID(context.Background(), survivor.ID); err != nil {
+		t.Errorf("Expected other tasks to survive deletion: %v", err)
+	}
+}
+
+// testDeleteNotFound tests deleting a task that does not exist, including a repeat delete
+func testDeleteNotFound(t *testing.T, s storage.TaskStorage) {
+	if err := s.Delete(context.Background(), 999); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound when deleting non-existent task, got %v", err)
+	}
+
+	created := mustCreate(t, s, "Task", 0)
+	if err := s.Delete(context.Background(), created.ID); err != nil {
+		t.Fatalf("Failed to delete task: %v", err)
+	}
+	if err := s.Delete(context.Background(), created.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound when deleting an already deleted task, got %v", err)
+	}
+}
+
+// testIDsNotReused tests that IDs of deleted tasks are never handed out again
+func testIDsNotReused(t *testing.T, s storage.TaskStorage) {
+	first := mustCreate(t, s, "First", 0)
+	if err := s.Delete(context.Background(), first.ID); err != nil {
+		t.Fatalf("Failed to delete task: %v", err)
+	}
+
+	second := mustCreate(t, s, "Second", 0)
+	if second.ID == first.ID {
+		t.Errorf("Expected a new ID after delete, got reused ID %d", second.ID)
+	}
+}
+
+// testCopyIsolationCreate tests that the caller's task is not retained by Create
+func testCopyIsolationCreate(t *testing.T, s storage.TaskStorage) {
+	task, _ := models.NewTask("Original", 0)
+	created, err := s.Create(context.Background(), task)
+	if err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+
+	// Mutating either the input or the returned task must not reach storage
+	task.Name = "Mutated input"
+	created.Name = "Mutated result"
+
+	retrieved, err := s.GetByID(context.Background(), created.ID)
+	if err != nil {
+		t.Fatalf("Failed to retrieve task: %v", err)
+	}
+	if retrieved.Name != "Original" {
+		t.Errorf("Expected stored name %q, got %q", "Original", retrieved.Name)
+	}
+}
+
+// testCopyIsolationReads tests that tasks returned by reads are private copies
+func testCopyIsolationReads(t *testing.T, s storage.TaskStorage) {
+	created := mustCreate(t, s, "Original", 0)
+
+	byID, err := s.GetByID(context.Background(), created.ID)
+	if err != nil {
+		t.Fatalf("Failed to retrieve task: %v", err)
+	}
+	byID.Name = "Mutated via GetByID"
+	byID.Status = 1
+
+	all, err := s.GetAll(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to retrieve tasks: %v", err)
+	}
+	if all[0].Name != "Original" || all[0].Status != 0 {
+		t.Errorf("Expected GetByID result to be a copy, storage now holds {%s %d}", all[0].Name, all[0].Status)
+	}
+	all[0].Name = "Mutated via GetAll"
+
+	retrieved, err := s.GetByID(context.Background(), created.ID)
+	if err != nil {
+		t.Fatalf("Failed to retrieve task: %v", err)
+	}
+	if retrieved.Name != "Original" {
+		t.Errorf("Expected GetAll result to be a copy, storage now holds %q", retrieved.Name)
+	}
+}
+
+// testCopyIsolationUpdate tests that the caller's task is not retained by Update
+func testCopyIsolationUpdate(t *testing.T, s storage.TaskStorage) {
+	created := mustCreate(t, s, "Original", 0)
+
+	update, _ := models.NewTask("Updated", 1)
+	update.ID = created.ID
+	update.Version = created.Version
+	result, err := s.Update(context.Background(), update)
+	if err != nil {
+		t.Fatalf("Failed to update task: %v", err)
+	}
+	update.Name = "Mutated input"
+	result.Name = "Mutated result"
+
+	retrieved, err := s.GetByID(context.Background(), created.ID)
+	if err != nil {
+		t.Fatalf("Failed to retrieve task: %v", err)
+	}
+	if retrieved.Name != "Updated" {
+		t.Errorf("Expected stored name %q, got %q", "Updated", retrieved.Name)
+	}
+}
+
+// testConcurrency tests that concurrent writers and readers neither lose
+// writes nor hand out duplicate IDs. Every worker updates one shared task with
+// a fetch-modify-save loop, retrying on conflict, so the final version proves
+// no update was lost. Run with -race to check for data races.
+func testConcurrency(t *testing.T, s storage.TaskStorage) {
+	const workers = 8
+	const perWorker = 10
+
+	seed := mustCreate(t, s, "Shared task", 0)
+
+	var wg sync.WaitGroup
+	ids := make(chan int, workers*perWorker)
+	errs := make(chan error, workers*perWorker*3)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				task, _ := models.NewTask(fmt.Sprintf("Worker %d task %d", w, i), 0)
+				created, err := s.Create(context.Background(), task)
+				if err != nil {
+					errs <- fmt.Errorf("create: %w", err)
+					continue
+				}
+				ids <- created.ID
+
+				for {
+					current, err := s.GetByID(context.Background(), seed.ID)
+					if err != nil {
+						errs <- fmt.Errorf("get by id: %w", err)
+						break
+					}
+					if err := current.Update(fmt.Sprintf("Worker %d update %d", w, i), i%2); err != nil {
+						errs <- fmt.Errorf("apply update: %w", err)
+						break
+					}
+					_, err = s.Update(context.Background(), current)
+					if errors.Is(err, storage.ErrConflict) {
+						continue // Lost the race; re-read and try again
+					}
+					if err != nil {
+						errs <- fmt.Errorf("update: %w", err)
+					}
+					break
+				}
+				if _, err := s.GetAll(context.Background()); err != nil {
+					errs <- fmt.Errorf("get all: %w", err)
+				}
+			}
+		}(w)
+	}
+
+	wg.Wait()
+	close(ids)
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("Unexpected error during concurrent access: %v", err)
+	}
+
+	seen := make(map[int]bool)
+	for id := range ids {
+		if seen[id] {
+			t.Errorf("ID %d was assigned more than once", id)
+		}
+		seen[id] = true
+	}
+
+	tasks, err := s.GetAll(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get all tasks: %v", err)
+	}
+	if want := workers*perWorker + 1; len(tasks) != want {
+		t.Errorf("Expected %d tasks after concurrent creates, got %d", want, len(tasks))
+	}
+
+	shared, err := s.GetByID(context.Background(), seed.ID)
+	if err != nil {
+		t.Fatalf("Failed to get shared task: %v", err)
+	}
+	if want := workers*perWorker + 1; shared.Version != want {
+		t.Errorf("Expected shared task at version %d after %d updates, got %d", want, workers*perWorker, shared.Version)
+	}
+}
+
+// testConcurrencyMutateResults tests that callers may freely modify the tasks
+// they receive while other goroutines read and update the same task.
+// With shared pointers this is a data race that -race reports.
+func testConcurrencyMutateResults(t *testing.T, s storage.TaskStorage) {
+	const workers = 4
+	const iterations = 25
+
+	seed := mustCreate(t, s, "Shared task", 0)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(2)
+
+		// Readers scribble over every result they get back
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				if task, err := s.GetByID(context.Background(), seed.ID); err == nil {
+					task.Name = fmt.Sprintf("Reader %d scribble %d", w, i)
+					task.Status = -1
+				}
+				if tasks, err := s.GetAll(context.Background()); err == nil {
+					for _, task := range tasks {
+						task.Name = ""
+						task.Version = -1
+					}
+				}
+			}
+		}(w)
+
+		// Writers keep updating the task and modify their input afterwards
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				current, err := s.GetByID(context.Background(), seed.ID)
+				if err != nil {
+					continue
+				}
+				current.Name = fmt.Sprintf("Writer %d update %d", w, i)
+				current.Status = i % 2
+				if updated, err := s.Update(context.Background(), current); err == nil {
+					updated.Name = "Mutated result"
+				}
+				current.Name = "Mutated input"
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	final, err := s.GetByID(context.Background(), seed.ID)
+	if err != nil {
+		t.Fatalf("Failed to retrieve task: %v", err)
+	}
+	if !strings.HasPrefix(final.Name, "Writer ") || final.Status < 0 || final.Status > 1 {
+		t.Errorf("Expected stored task to hold a writer's update, got %+v", final)
+	}
+}
+
+// testCanceledContext tests that every operation honors an already canceled context,
+// reporting it as ErrUnavailable, and that a canceled write leaves storage untouched
+func testCanceledContext(t *testing.T, s storage.TaskStorage) {
+	existing := mustCreate(t, s, "Existing", 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	task, _ := models.NewTask("Canceled", 1)
+	if _, err := s.Create(ctx, task); !errors.Is(err, context.Canceled) || !errors.Is(err, storage.ErrUnavailable) {
+		t.Errorf("Create: expected ErrUnavailable wrapping context.Canceled, got %v", err)
+	}
+	if _, err := s.GetAll(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetAll: expected context.Canceled, got %v", err)
+	}
+	if _, err := s.Batch(ctx, []storage.BatchOp{{Kind: storage.BatchCreate, Task: task}}, true); !errors.Is(err, context.Canceled) {
+		t.Errorf("Batch: expected context.Canceled, got %v", err)
+	}
+	if _, err := s.Query(ctx, storage.TaskQuery{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Query: expected context.Canceled, got %v", err)
+	}
+	if _, err := s.GetByID(ctx, existing.ID); !errors.Is(err, context.Canceled) || !errors.Is(err, storage.ErrUnavailable) {
+		t.Errorf("GetByID: expected ErrUnavailable wrapping context.Canceled, got %v", err)
+	}
+	task.ID = existing.ID
+	task.Version = existing.Version
+	if _, err := s.Update(ctx, task); !errors.Is(err, context.Canceled) {
+		t.Errorf("Update: expected context.Canceled, got %v", err)
+	}
+	if err := s.Delete(ctx, existing.ID); !errors.Is(err, context.Canceled) {
+		t.Errorf("Delete: expected context.Canceled, got %v", err)
+	}
+
+	tasks, err := s.GetAll(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get all tasks: %v", err)
+	}
+	if len(tasks) != 1 || tasks[0].Name != "Existing" || tasks[0].Status != 0 {
+		t.Errorf("Expected canceled operations to leave storage untouched, got %+v", tasks)
+	}
+}
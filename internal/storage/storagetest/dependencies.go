@@ -0,0 +1,151 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"task-api/internal/models"
+	"task-api/internal/storage"
+	"testing"
+)
+
+// mustCreateBlocked stores a valid task waiting for the given tasks or fails the test
+func mustCreateBlocked(t *testing.T, s storage.TaskStorage, name string, blockedBy ...int) *models.Task {
+	t.Helper()
+	return mustCreateWithDetails(t, s, name, 0, models.Details{BlockedBy: blockedBy})
+}
+
+// setBlockers replaces the blockers of a task, returning the update's error
+func setBlockers(t *testing.T, s storage.TaskStorage, id int, blockedBy ...int) error {
+	t.Helper()
+
+	task := mustGet(t, s, id)
+	task.BlockedBy = blockedBy
+	_, err := s.Update(context.Background(), task)
+	return err
+}
+
+// complete marks a task completed, returning the update's error
+func complete(t *testing.T, s storage.TaskStorage, id int) error {
+	t.Helper()
+
+	task := mustGet(t, s, id)
+	if err := task.Update(task.Name, 1); err != nil {
+		t.Fatalf("Failed to change status: %v", err)
+	}
+	_, err := s.Update(context.Background(), task)
+	return err
+}
+
+// testDependencies tests storing blockers and rejecting blockers that do not
+// exist or would make a task wait for itself
+func testDependencies(t *testing.T, s storage.TaskStorage) {
+	ctx := context.Background()
+	design := mustCreate(t, s, "Design", 0)
+	build := mustCreateBlocked(t, s, "Build", design.ID)
+	ship := mustCreateBlocked(t, s, "Ship", build.ID, design.ID)
+
+	if retrieved := mustGet(t, s, ship.ID); !reflect.DeepEqual(retrieved.BlockedBy, []int{design.ID, build.ID}) {
+		t.Errorf("Expected blockers %v, got %v", []int{design.ID, build.ID}, retrieved.BlockedBy)
+	}
+
+	missing := newTask(t, "Missing", 0)
+	missing.BlockedBy = []int{999}
+	if _, err := s.Create(ctx, missing); !errors.Is(err, storage.ErrInvalid) || !errors.Is(err, storage.ErrBlockerNotFound) {
+		t.Errorf("Expected ErrInvalid and ErrBlockerNotFound, got %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		id        int
+		blockedBy []int
+	}{
+		{"itself", design.ID, []int{design.ID}},
+		{"direct cycle", build.ID, []int{design.ID, ship.ID}},
+		{"indirect cycle", design.ID, []int{ship.ID}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := mustGet(t, s, tt.id)
+			if err := setBlockers(t, s, tt.id, tt.blockedBy...); !errors.Is(err, storage.ErrInvalid) || !errors.Is(err, storage.ErrDependencyCycle) {
+				t.Errorf("Expected ErrInvalid and ErrDependencyCycle, got %v", err)
+			}
+			if after := mustGet(t, s, tt.id); after.Version != before.Version {
+				t.Errorf("Expected the rejected update to leave the task unchanged, got %+v", after)
+			}
+		})
+	}
+
+	// Once removed, a dependency can be reversed
+	if err := setBlockers(t, s, build.ID); err != nil {
+		t.Fatalf("Failed to remove blockers: %v", err)
+	}
+	if err := setBlockers(t, s, design.ID, build.ID); err != nil {
+		t.Errorf("Expected reversing the dependency to be allowed, got %v", err)
+	}
+}
+
+// testDependenciesBlocking tests that a task cannot be completed while one of
+// its blockers is incomplete, including by a roll-up from its subtasks
+func testDependenciesBlocking(t *testing.T, s storage.TaskStorage) {
+	design := mustCreate(t, s, "Design", 0)
+	build := mustCreateBlocked(t, s, "Build", design.ID)
+
+	var validationErr *models.ValidationError
+	err := complete(t, s, build.ID)
+	if !errors.Is(err, storage.ErrInvalid) || !errors.As(err, &validationErr) || validationErr.Errors[0].Rule != models.RuleBlocked {
+		t.Fatalf("Expected ErrInvalid with a %s validation error, got %v", models.RuleBlocked, err)
+	}
+	if task := mustGet(t, s, build.ID); task.Status != 0 {
+		t.Errorf("Expected the blocked task to stay incomplete, got status %d", task.Status)
+	}
+
+	completed := newTask(t, "Completed", 1)
+	completed.BlockedBy = []int{design.ID}
+	if _, err := s.Create(context.Background(), completed); !errors.As(err, &validationErr) {
+		t.Errorf("Expected creating a completed blocked task to fail, got %v", err)
+	}
+
+	// Subtasks completing a blocked parent leave it open
+	step := mustCreateSubtask(t, s, "Step", 0, build.ID)
+	if err := complete(t, s, step.ID); err != nil {
+		t.Fatalf("Failed to complete subtask: %v", err)
+	}
+	if task := mustGet(t, s, build.ID); task.Status != 0 {
+		t.Errorf("Expected the roll-up to leave the blocked parent open, got status %d", task.Status)
+	}
+
+	if err := complete(t, s, design.ID); err != nil {
+		t.Fatalf("Failed to complete blocker: %v", err)
+	}
+	if err := complete(t, s, build.ID); err != nil {
+		t.Errorf("Expected completing an unblocked task to succeed, got %v", err)
+	}
+
+	// A completed task cannot gain an incomplete blocker
+	review := mustCreate(t, s, "Review", 0)
+	if err := setBlockers(t, s, build.ID, design.ID, review.ID); !errors.As(err, &validationErr) {
+		t.Errorf("Expected adding an incomplete blocker to a completed task to fail, got %v", err)
+	}
+}
+
+// testDependenciesDelete tests that deleting a task unblocks the tasks it blocked
+func testDependenciesDelete(t *testing.T, s storage.TaskStorage) {
+	design := mustCreate(t, s, "Design", 0)
+	review := mustCreate(t, s, "Review", 0)
+	build := mustCreateBlocked(t, s, "Build", design.ID, review.ID)
+	test := mustCreateBlocked(t, s, "Test", design.ID)
+
+	if err := s.Delete(context.Background(), design.ID); err != nil {
+		t.Fatalf("Failed to delete task: %v", err)
+	}
+	if task := mustGet(t, s, build.ID); !reflect.DeepEqual(task.BlockedBy, []int{review.ID}) || task.Version != 2 {
+		t.Errorf("Expected only blocker %d in version 2, got %v in version %d", review.ID, task.BlockedBy, task.Version)
+	}
+	if task := mustGet(t, s, test.ID); task.BlockedBy != nil {
+		t.Errorf("Expected no blockers left, got %v", task.BlockedBy)
+	}
+	if err := complete(t, s, test.ID); err != nil {
+		t.Errorf("Expected the unblocked task to be completable, got %v", err)
+	}
+}
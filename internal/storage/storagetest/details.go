@@ -0,0 +1,161 @@
+package storagetest
+
+import (
+	"context"
+	"reflect"
+	"task-api/internal/models"
+	"task-api/internal/storage"
+	"testing"
+	"time"
+)
+
+// mustCreateWithDetails stores a valid task with details or fails the test
+func mustCreateWithDetails(t *testing.T, s storage.TaskStorage, name string, status int, details models.Details) *models.Task {
+	t.Helper()
+
+	task, err := models.NewTaskWithDetails(name, status, details)
+	if err != nil {
+		t.Fatalf("Failed to create task model: %v", err)
+	}
+	created, err := s.Create(context.Background(), task)
+	if err != nil {
+		t.Fatalf("Failed to create task %q: %v", name, err)
+	}
+	return created
+}
+
+// dueAt returns a pointer to a UTC time the given number of days into 2030
+func dueAt(days int) *time.Time {
+	due := time.Date(2030, 1, 1, 9, 30, 0, 123456789, time.UTC).AddDate(0, 0, days)
+	return &due
+}
+
+// testDetails tests that every detail field is stored, returned by every
+// read method and replaced or cleared by updates
+func testDetails(t *testing.T, s storage.TaskStorage) {
+	ctx := context.Background()
+	created := mustCreateWithDetails(t, s, "Ship release", 0, models.Details{
+		Description: "## Checklist\n\n- tag\n- publish",
+		DueAt:       dueAt(3),
+		Priority:    models.PriorityHigh,
+		Tags:        []string{"release", "backend"},
+		Assignee:    "alice",
+	})
+
+	retrieved, err := s.GetByID(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("Failed to retrieve task: %v", err)
+	}
+	if !reflect.DeepEqual(retrieved, created) {
+		t.Errorf("Expected GetByID to return %+v, got %+v", created, retrieved)
+	}
+	if all := allTasks(t, s); len(all) != 1 || !reflect.DeepEqual(all[0], created) {
+		t.Errorf("Expected GetAll to return %+v, got %+v", created, all)
+	}
+	if page := mustQuery(t, s, storage.TaskQuery{}); len(page.Tasks) != 1 || !reflect.DeepEqual(page.Tasks[0], created) {
+		t.Errorf("Expected Query to return %+v, got %+v", created, page.Tasks)
+	}
+	if results := mustSearch(t, s, "release", 0); len(results) != 1 || !reflect.DeepEqual(results[0].Task, created) {
+		t.Errorf("Expected Search to return %+v, got %+v", created, results)
+	}
+
+	// Replace some details and clear the others
+	update := created.Clone()
+	update.Description = ""
+	update.DueAt = nil
+	update.Priority = models.PriorityLow
+	update.Tags = []string{"frontend"}
+	update.Assignee = ""
+	updated, err := s.Update(ctx, update)
+	if err != nil {
+		t.Fatalf("Failed to update task: %v", err)
+	}
+
+	retrieved, err = s.GetByID(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("Failed to retrieve task: %v", err)
+	}
+	if !reflect.DeepEqual(retrieved, updated) {
+		t.Errorf("Expected %+v after update, got %+v", updated, retrieved)
+	}
+	if retrieved.DueAt != nil || retrieved.Description != "" || retrieved.Assignee != "" {
+		t.Errorf("Expected cleared details, got %+v", retrieved.Details)
+	}
+}
+
+// testQueryDetailFilters tests filtering by priority, tags, assignee and due date
+func testQueryDetailFilters(t *testing.T, s storage.TaskStorage) {
+	tasks := []*models.Task{
+		mustCreateWithDetails(t, s, "Fix login", 0, models.Details{
+			Priority: models.PriorityHigh, Tags: []string{"backend", "bug"}, Assignee: "alice", DueAt: dueAt(1),
+		}),
+		mustCreateWithDetails(t, s, "Polish UI", 0, models.Details{
+			Priority: models.PriorityLow, Tags: []string{"frontend"}, Assignee: "bob", DueAt: dueAt(5),
+		}),
+		mustCreateWithDetails(t, s, "Fix crash", 0, models.Details{
+			Priority: models.PriorityHigh, Tags: []string{"bug"}, DueAt: dueAt(10),
+		}),
+		mustCreateWithDetails(t, s, "Someday", 0, models.Details{}),
+	}
+	id := func(i int) int { return tasks[i].ID }
+	high, none := models.PriorityHigh, models.PriorityNone
+	alice, unassigned := "alice", ""
+
+	tests := []struct {
+		name     string
+		query    storage.TaskQuery
+		expected []int
+	}{
+		{"priority", storage.TaskQuery{Priority: &high}, []int{id(0), id(2)}},
+		{"no priority", storage.TaskQuery{Priority: &none}, []int{id(3)}},
+		{"one tag", storage.TaskQuery{Tags: []string{"bug"}}, []int{id(0), id(2)}},
+		{"every tag must match", storage.TaskQuery{Tags: []string{"bug", "backend"}}, []int{id(0)}},
+		{"unknown tag", storage.TaskQuery{Tags: []string{"docs"}}, []int{}},
+		{"assignee", storage.TaskQuery{Assignee: &alice}, []int{id(0)}},
+		{"unassigned", storage.TaskQuery{Assignee: &unassigned}, []int{id(2), id(3)}},
+		{"due before", storage.TaskQuery{Due: storage.TimeRange{Before: dueAt(5)}}, []int{id(0)}},
+		{"due after", storage.TaskQuery{Due: storage.TimeRange{After: dueAt(1)}}, []int{id(1), id(2)}},
+		{"due between", storage.TaskQuery{Due: storage.TimeRange{After: dueAt(0), Before: dueAt(10)}}, []int{id(0), id(1)}},
+		{"combined", storage.TaskQuery{Priority: &high, Tags: []string{"bug"}, Due: storage.TimeRange{After: dueAt(2)}}, []int{id(2)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := mustQuery(t, s, tt.query)
+			if got := taskIDs(page.Tasks); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected IDs %v, got %v", tt.expected, got)
+			}
+			if page.Total != len(tt.expected) {
+				t.Errorf("Expected total %d, got %d", len(tt.expected), page.Total)
+			}
+		})
+	}
+
+	// Tag filters follow updates
+	update := tasks[1].Clone()
+	update.Tags = []string{"bug"}
+	if _, err := s.Update(context.Background(), update); err != nil {
+		t.Fatalf("Failed to update task: %v", err)
+	}
+	if got := taskIDs(mustQuery(t, s, storage.TaskQuery{Tags: []string{"bug"}}).Tasks); !reflect.DeepEqual(got, []int{id(0), id(1), id(2)}) {
+		t.Errorf("Expected the retagged task to match, got %v", got)
+	}
+	if got := taskIDs(mustQuery(t, s, storage.TaskQuery{Tags: []string{"frontend"}}).Tasks); len(got) != 0 {
+		t.Errorf("Expected the removed tag to match nothing, got %v", got)
+	}
+}
+
+// testSearchDetails tests that descriptions and tags are searchable
+func testSearchDetails(t *testing.T, s storage.TaskStorage) {
+	task := mustCreateWithDetails(t, s, "Quarterly report", 0, models.Details{
+		Description: "Collect the **revenue** numbers",
+		Tags:        []string{"finance"},
+	})
+	mustCreate(t, s, "Unrelated", 0)
+
+	for _, query := range []string{"revenue", "finance"} {
+		if got := resultIDs(mustSearch(t, s, query, 0)); !reflect.DeepEqual(got, []int{task.ID}) {
+			t.Errorf("Expected search for %q to find %v, got %v", query, []int{task.ID}, got)
+		}
+	}
+}
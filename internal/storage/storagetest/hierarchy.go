@@ -0,0 +1,178 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"task-api/internal/models"
+	"task-api/internal/storage"
+	"testing"
+)
+
+// mustCreateSubtask stores a valid task under the given parent or fails the test
+func mustCreateSubtask(t *testing.T, s storage.TaskStorage, name string, status, parentID int) *models.Task {
+	t.Helper()
+	return mustCreateWithDetails(t, s, name, status, models.Details{ParentID: parentID})
+}
+
+// mustGet retrieves a task or fails the test
+func mustGet(t *testing.T, s storage.TaskStorage, id int) *models.Task {
+	t.Helper()
+
+	task, err := s.GetByID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("Failed to retrieve task %d: %v", id, err)
+	}
+	return task
+}
+
+// testHierarchy tests storing subtasks, filtering by parent and rejecting
+// parents that do not exist or would create a cycle
+func testHierarchy(t *testing.T, s storage.TaskStorage) {
+	ctx := context.Background()
+	epic := mustCreate(t, s, "Epic", 0)
+	design := mustCreateSubtask(t, s, "Design", 0, epic.ID)
+	build := mustCreateSubtask(t, s, "Build", 0, epic.ID)
+	sketch := mustCreateSubtask(t, s, "Sketch", 0, design.ID)
+
+	if retrieved := mustGet(t, s, sketch.ID); retrieved.ParentID != design.ID {
+		t.Errorf("Expected parent %d, got %d", design.ID, retrieved.ParentID)
+	}
+
+	parent := func(id int) *int { return &id }
+	tests := []struct {
+		name     string
+		query    storage.TaskQuery
+		expected []int
+	}{
+		{"children", storage.TaskQuery{Parent: parent(epic.ID)}, []int{design.ID, build.ID}},
+		{"grandchildren", storage.TaskQuery{Parent: parent(design.ID)}, []int{sketch.ID}},
+		{"top-level", storage.TaskQuery{Parent: parent(0)}, []int{epic.ID}},
+		{"leaf", storage.TaskQuery{Parent: parent(sketch.ID)}, []int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := taskIDs(mustQuery(t, s, tt.query).Tasks); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected IDs %v, got %v", tt.expected, got)
+			}
+		})
+	}
+
+	// The parent must exist
+	orphan := newTask(t, "Orphan", 0)
+	orphan.ParentID = 999
+	if _, err := s.Create(ctx, orphan); !errors.Is(err, storage.ErrInvalid) || !errors.Is(err, storage.ErrParentNotFound) {
+		t.Errorf("Expected ErrInvalid and ErrParentNotFound, got %v", err)
+	}
+
+	// A task cannot move below itself or its own subtasks
+	for _, parentID := range []int{epic.ID, sketch.ID} {
+		moved := mustGet(t, s, epic.ID)
+		moved.ParentID = parentID
+		if _, err := s.Update(ctx, moved); !errors.Is(err, storage.ErrInvalid) || !errors.Is(err, storage.ErrParentCycle) {
+			t.Errorf("Expected ErrInvalid and ErrParentCycle moving under %d, got %v", parentID, err)
+		}
+	}
+	if retrieved := mustGet(t, s, epic.ID); retrieved.ParentID != 0 || retrieved.Version != epic.Version {
+		t.Errorf("Expected the rejected moves to leave the task unchanged, got %+v", retrieved)
+	}
+
+	// Moving a subtask to another branch
+	moved := mustGet(t, s, build.ID)
+	moved.ParentID = design.ID
+	if _, err := s.Update(ctx, moved); err != nil {
+		t.Fatalf("Failed to move task: %v", err)
+	}
+	if got := taskIDs(mustQuery(t, s, storage.TaskQuery{Parent: parent(design.ID)}).Tasks); !reflect.DeepEqual(got, []int{build.ID, sketch.ID}) {
+		t.Errorf("Expected both subtasks under the new parent, got %v", got)
+	}
+}
+
+// testHierarchyDelete tests that deleting a task moves its subtasks up to its parent
+func testHierarchyDelete(t *testing.T, s storage.TaskStorage) {
+	ctx := context.Background()
+	epic := mustCreate(t, s, "Epic", 0)
+	story := mustCreateSubtask(t, s, "Story", 0, epic.ID)
+	first := mustCreateSubtask(t, s, "First", 0, story.ID)
+	second := mustCreateSubtask(t, s, "Second", 0, story.ID)
+
+	if err := s.Delete(ctx, story.ID); err != nil {
+		t.Fatalf("Failed to delete task: %v", err)
+	}
+	for _, id := range []int{first.ID, second.ID} {
+		if task := mustGet(t, s, id); task.ParentID != epic.ID || task.Version != 2 {
+			t.Errorf("Expected task %d moved to parent %d in version 2, got parent %d in version %d", id, epic.ID, task.ParentID, task.Version)
+		}
+	}
+
+	if err := s.Delete(ctx, epic.ID); err != nil {
+		t.Fatalf("Failed to delete task: %v", err)
+	}
+	if task := mustGet(t, s, first.ID); task.ParentID != 0 {
+		t.Errorf("Expected a top-level task after deleting the root, got parent %d", task.ParentID)
+	}
+}
+
+// testHierarchyRollup tests that parents are completed once all their
+// subtasks are, and reopened when a subtask is not, up the whole hierarchy
+func testHierarchyRollup(t *testing.T, s storage.TaskStorage) {
+	ctx := context.Background()
+	epic := mustCreate(t, s, "Epic", 0)
+	story := mustCreateSubtask(t, s, "Story", 0, epic.ID)
+	first := mustCreateSubtask(t, s, "First", 0, story.ID)
+	second := mustCreateSubtask(t, s, "Second", 0, story.ID)
+
+	setStatus := func(id, status int) {
+		t.Helper()
+		task := mustGet(t, s, id)
+		if err := task.Update(task.Name, status); err != nil {
+			t.Fatalf("Failed to change status: %v", err)
+		}
+		if _, err := s.Update(ctx, task); err != nil {
+			t.Fatalf("Failed to update task: %v", err)
+		}
+	}
+	expectStatus := func(step string, epicStatus, storyStatus int) {
+		t.Helper()
+		for _, expected := range []struct {
+			id, status int
+		}{{epic.ID, epicStatus}, {story.ID, storyStatus}} {
+			task := mustGet(t, s, expected.id)
+			if task.Status != expected.status || task.State != models.DefaultWorkflow().StateForStatus("", expected.status) ||
+				(task.CompletedAt != nil) != (expected.status == 1) {
+				t.Errorf("%s: expected task %d with status %d, got status %d in %q (completed at %v)",
+					step, expected.id, expected.status, task.Status, task.State, task.CompletedAt)
+			}
+		}
+	}
+
+	setStatus(first.ID, 1)
+	expectStatus("one subtask completed", 0, 0)
+
+	setStatus(second.ID, 1)
+	expectStatus("all subtasks completed", 1, 1)
+
+	setStatus(first.ID, 0)
+	expectStatus("subtask reopened", 0, 0)
+
+	setStatus(first.ID, 1)
+	added := mustCreateSubtask(t, s, "Third", 0, story.ID)
+	expectStatus("open subtask added", 0, 0)
+
+	if err := s.Delete(ctx, added.ID); err != nil {
+		t.Fatalf("Failed to delete task: %v", err)
+	}
+	expectStatus("open subtask deleted", 1, 1)
+
+	// A failed atomic batch undoes the roll-up along with its own changes
+	reopen := mustGet(t, s, first.ID)
+	reopen.Status, reopen.State = 0, models.DefaultWorkflow().StateForStatus(reopen.State, 0)
+	results := mustBatch(t, s, []storage.BatchOp{
+		{Kind: storage.BatchUpdate, Task: reopen},
+		{Kind: storage.BatchDelete, ID: 999},
+	}, true)
+	if results[0].Applied {
+		t.Error("Expected the atomic batch to be rolled back")
+	}
+	expectStatus("rolled back batch", 1, 1)
+}
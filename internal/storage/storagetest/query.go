@@ -0,0 +1,228 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"task-api/internal/models"
+	"task-api/internal/storage"
+	"testing"
+)
+
+// seedQueryTasks stores a fixed set of tasks with duplicate names and statuses,
+// so orderings must fall back to the ID tie-breaker
+func seedQueryTasks(t *testing.T, s storage.TaskStorage) []*models.Task {
+	t.Helper()
+
+	seeds := []struct {
+		name   string
+		status int
+	}{
+		{"Write report", 0},
+		{"buy milk", 1},
+		{"Review 100% of PRs", 0},
+		{"Write tests", 1},
+		{"archive_old", 0},
+		{"Buy Milk", 0},
+		{"Write report", 1},
+	}
+	tasks := make([]*models.Task, 0, len(seeds))
+	for _, seed := range seeds {
+		tasks = append(tasks, mustCreate(t, s, seed.name, seed.status))
+	}
+	return tasks
+}
+
+// taskIDs returns the IDs of tasks in order
+func taskIDs(tasks []*models.Task) []int {
+	ids := make([]int, 0, len(tasks))
+	for _, task := range tasks {
+		ids = append(ids, task.ID)
+	}
+	return ids
+}
+
+// mustQuery runs q or fails the test
+func mustQuery(t *testing.T, s storage.TaskStorage, q storage.TaskQuery) *storage.TaskPage {
+	t.Helper()
+
+	page, err := s.Query(context.Background(), q)
+	if err != nil {
+		t.Fatalf("Query %+v failed: %v", q, err)
+	}
+	return page
+}
+
+// testQueryFilters tests filtering by status and name substring
+func testQueryFilters(t *testing.T, s storage.TaskStorage) {
+	// Plus a name that only folds with Unicode case mapping
+	tasks := append(seedQueryTasks(t, s), mustCreate(t, s, "Fête d'été", 0))
+	id := func(i int) int { return tasks[i].ID }
+	zero, one := 0, 1
+
+	tests := []struct {
+		name     string
+		query    storage.TaskQuery
+		expected []int
+	}{
+		{"no filters", storage.TaskQuery{}, []int{id(0), id(1), id(2), id(3), id(4), id(5), id(6), id(7)}},
+		{"status 0", storage.TaskQuery{Status: &zero}, []int{id(0), id(2), id(4), id(5), id(7)}},
+		{"status 1", storage.TaskQuery{Status: &one}, []int{id(1), id(3), id(6)}},
+		{"name is case-insensitive", storage.TaskQuery{NameContains: "MILK"}, []int{id(1), id(5)}},
+		{"name is case-insensitive beyond ASCII", storage.TaskQuery{NameContains: "ÉTÉ"}, []int{id(7)}},
+		{"name and status", storage.TaskQuery{NameContains: "write", Status: &one}, []int{id(3), id(6)}},
+		{"percent is literal", storage.TaskQuery{NameContains: "100%"}, []int{id(2)}},
+		{"underscore is literal", storage.TaskQuery{NameContains: "e_o"}, []int{id(4)}},
+		{"no match", storage.TaskQuery{NameContains: "nothing like this"}, []int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := mustQuery(t, s, tt.query)
+			if got := taskIDs(page.Tasks); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected IDs %v, got %v", tt.expected, got)
+			}
+			if page.Total != len(tt.expected) {
+				t.Errorf("Expected total %d, got %d", len(tt.expected), page.Total)
+			}
+			if page.HasMore {
+				t.Error("Expected no more pages without a limit")
+			}
+		})
+	}
+}
+
+// testQuerySort tests every sort field in both directions
+func testQuerySort(t *testing.T, s storage.TaskStorage) {
+	tasks := seedQueryTasks(t, s)
+	id := func(i int) int { return tasks[i].ID }
+
+	tests := []struct {
+		name     string
+		query    storage.TaskQuery
+		expected []int
+	}{
+		{"id ascending", storage.TaskQuery{SortBy: storage.SortByID}, []int{id(0), id(1), id(2), id(3), id(4), id(5), id(6)}},
+		{"id descending", storage.TaskQuery{SortBy: storage.SortByID, Descending: true}, []int{id(6), id(5), id(4), id(3), id(2), id(1), id(0)}},
+		// Names compare byte-wise: upper case sorts before lower case
+		{"name ascending", storage.TaskQuery{SortBy: storage.SortByName}, []int{id(5), id(2), id(0), id(6), id(3), id(4), id(1)}},
+		{"name descending", storage.TaskQuery{SortBy: storage.SortByName, Descending: true}, []int{id(1), id(4), id(3), id(6), id(0), id(2), id(5)}},
+		{"status ascending", storage.TaskQuery{SortBy: storage.SortByStatus}, []int{id(0), id(2), id(4), id(5), id(1), id(3), id(6)}},
+		{"status descending", storage.TaskQuery{SortBy: storage.SortByStatus, Descending: true}, []int{id(6), id(3), id(1), id(5), id(4), id(2), id(0)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := mustQuery(t, s, tt.query)
+			if got := taskIDs(page.Tasks); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected IDs %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+// testQueryOffset tests limit/offset paging and the reported total
+func testQueryOffset(t *testing.T, s storage.TaskStorage) {
+	tasks := seedQueryTasks(t, s)
+	zero := 0
+
+	page := mustQuery(t, s, storage.TaskQuery{Limit: 3})
+	if got := taskIDs(page.Tasks); !reflect.DeepEqual(got, taskIDs(tasks[:3])) {
+		t.Errorf("Expected first page %v, got %v", taskIDs(tasks[:3]), got)
+	}
+	if page.Total != len(tasks) || !page.HasMore {
+		t.Errorf("Expected total %d with more pages, got total %d, more %v", len(tasks), page.Total, page.HasMore)
+	}
+
+	page = mustQuery(t, s, storage.TaskQuery{Limit: 3, Offset: 6})
+	if got := taskIDs(page.Tasks); !reflect.DeepEqual(got, taskIDs(tasks[6:])) {
+		t.Errorf("Expected last page %v, got %v", taskIDs(tasks[6:]), got)
+	}
+	if page.HasMore {
+		t.Error("Expected no more pages after the last one")
+	}
+
+	page = mustQuery(t, s, storage.TaskQuery{Limit: 2, Offset: 1, Status: &zero})
+	expected := []int{tasks[2].ID, tasks[4].ID}
+	if got := taskIDs(page.Tasks); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected filtered page %v, got %v", expected, got)
+	}
+	if page.Total != 4 || !page.HasMore {
+		t.Errorf("Expected total 4 with more pages, got total %d, more %v", page.Total, page.HasMore)
+	}
+
+	page = mustQuery(t, s, storage.TaskQuery{Offset: 100})
+	if len(page.Tasks) != 0 || page.Total != len(tasks) {
+		t.Errorf("Expected empty page with total %d past the end, got %d tasks, total %d", len(tasks), len(page.Tasks), page.Total)
+	}
+}
+
+// testQueryCursor tests that following cursors visits every task exactly once
+// in order, for every sort order, even when tasks change between pages
+func testQueryCursor(t *testing.T, s storage.TaskStorage) {
+	seedQueryTasks(t, s)
+
+	for _, sortBy := range []storage.SortField{storage.SortByID, storage.SortByName, storage.SortByStatus} {
+		for _, descending := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s descending=%v", sortBy, descending), func(t *testing.T) {
+				query := storage.TaskQuery{SortBy: sortBy, Descending: descending}
+				expected := taskIDs(mustQuery(t, s, query).Tasks)
+
+				query.Limit = 2
+				var walked []int
+				for pages := 0; ; pages++ {
+					if pages > len(expected) {
+						t.Fatal("Cursor pagination does not terminate")
+					}
+					page := mustQuery(t, s, query)
+					walked = append(walked, taskIDs(page.Tasks)...)
+					if !page.HasMore {
+						break
+					}
+					query.After = storage.CursorAfter(page.Tasks[len(page.Tasks)-1])
+				}
+
+				if !reflect.DeepEqual(walked, expected) {
+					t.Errorf("Expected to walk %v, got %v", expected, walked)
+				}
+			})
+		}
+	}
+
+	t.Run("stable under deletes", func(t *testing.T) {
+		all := mustQuery(t, s, storage.TaskQuery{}).Tasks
+		first := mustQuery(t, s, storage.TaskQuery{Limit: 3})
+
+		// Deleting a task on the page already seen must not shift the next page
+		if err := s.Delete(context.Background(), first.Tasks[0].ID); err != nil {
+			t.Fatalf("Failed to delete task: %v", err)
+		}
+		next := mustQuery(t, s, storage.TaskQuery{Limit: 3, After: storage.CursorAfter(first.Tasks[2])})
+		if got := taskIDs(next.Tasks); !reflect.DeepEqual(got, taskIDs(all[3:6])) {
+			t.Errorf("Expected next page %v, got %v", taskIDs(all[3:6]), got)
+		}
+	})
+}
+
+// testQueryInvalid tests that malformed queries are rejected with ErrInvalid
+func testQueryInvalid(t *testing.T, s storage.TaskStorage) {
+	tests := []struct {
+		name  string
+		query storage.TaskQuery
+	}{
+		{"unknown sort field", storage.TaskQuery{SortBy: "priority"}},
+		{"negative limit", storage.TaskQuery{Limit: -1}},
+		{"negative offset", storage.TaskQuery{Offset: -1}},
+		{"offset with cursor", storage.TaskQuery{Offset: 1, After: &storage.Cursor{ID: 1}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.Query(context.Background(), tt.query)
+			if !errors.Is(err, storage.ErrInvalid) {
+				t.Errorf("Expected ErrInvalid, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package storagetest
+
+import (
+	"context"
+	"task-api/internal/clock"
+	"task-api/internal/models"
+	"task-api/internal/storage"
+	"testing"
+	"time"
+)
+
+// mustUpdate stores a changed task or fails the test
+func mustUpdate(t *testing.T, s storage.TaskStorage, task *models.Task) *models.Task {
+	t.Helper()
+
+	updated, err := s.Update(context.Background(), task)
+	if err != nil {
+		t.Fatalf("Failed to update task %d: %v", task.ID, err)
+	}
+	return updated
+}
+
+// testRecurrence tests that completing a recurring task creates its next
+// occurrence, once, skipping occurrences missed while it was overdue
+func testRecurrence(t *testing.T, s storage.TaskStorage, c *clock.Manual) {
+	// The clock starts on a Saturday; the task is due the Monday after
+	dueAt := time.Date(2030, 6, 3, 9, 0, 0, 0, time.UTC)
+	checklist := mustCreateWithDetails(t, s, "Checklist", 0, models.Details{
+		DueAt: &dueAt, Tags: []string{"ops"}, Recurrence: "FREQ=WEEKLY;BYDAY=MO,TH",
+	})
+
+	// expectNext completes the task and returns the occurrence created for it
+	expectNext := func(step string, id int, expectedDue time.Time) *models.Task {
+		t.Helper()
+		before := allTasks(t, s)
+		if err := complete(t, s, id); err != nil {
+			t.Fatalf("%s: failed to complete task: %v", step, err)
+		}
+		after := allTasks(t, s)
+		if len(after) != len(before)+1 {
+			t.Fatalf("%s: expected one new task, got %d tasks from %d", step, len(after), len(before))
+		}
+
+		completed, next := mustGet(t, s, id), after[len(after)-1]
+		if completed.Status != 1 || completed.Recurrence != "" {
+			t.Errorf("%s: expected a completed task without recurrence, got %+v", step, completed)
+		}
+		if next.Status != 0 || next.Version != 1 || next.Name != completed.Name || next.Recurrence != "FREQ=WEEKLY;BYDAY=MO,TH" ||
+			len(next.Tags) != 1 || next.Tags[0] != "ops" {
+			t.Errorf("%s: expected an open copy of the task, got %+v", step, next)
+		}
+		if next.DueAt == nil || !next.DueAt.Equal(expectedDue) {
+			t.Errorf("%s: expected the next occurrence due %v, got %v", step, expectedDue, next.DueAt)
+		}
+		return next
+	}
+
+	// Completed early, the task is next due on the Thursday after its due date
+	thursday := expectNext("early", checklist.ID, time.Date(2030, 6, 6, 9, 0, 0, 0, time.UTC))
+
+	// Completing the first task again does not repeat the series
+	reopen := mustGet(t, s, checklist.ID)
+	if err := reopen.Update(reopen.Name, 0); err != nil {
+		t.Fatalf("Failed to change status: %v", err)
+	}
+	mustUpdate(t, s, reopen)
+	if err := complete(t, s, checklist.ID); err != nil {
+		t.Fatalf("Failed to complete task: %v", err)
+	}
+	if tasks := allTasks(t, s); len(tasks) != 2 {
+		t.Errorf("Expected no occurrence for a task completed again, got %d tasks", len(tasks))
+	}
+
+	// Completed two weeks late, on a Thursday afternoon, the missed ones are skipped
+	c.Set(time.Date(2030, 6, 20, 15, 0, 0, 0, time.UTC))
+	monday := expectNext("late", thursday.ID, time.Date(2030, 6, 24, 9, 0, 0, 0, time.UTC))
+
+	// A failed atomic batch does not leave an occurrence behind
+	completed := mustGet(t, s, monday.ID)
+	if err := completed.Update(completed.Name, 1); err != nil {
+		t.Fatalf("Failed to change status: %v", err)
+	}
+	results := mustBatch(t, s, []storage.BatchOp{
+		{Kind: storage.BatchUpdate, Task: completed},
+		{Kind: storage.BatchDelete, ID: 999},
+	}, true)
+	if results[0].Applied {
+		t.Error("Expected the atomic batch to be rolled back")
+	}
+	if tasks := allTasks(t, s); len(tasks) != 3 {
+		t.Errorf("Expected the rolled back batch to create no occurrence, got %d tasks", len(tasks))
+	}
+
+	// Once the series ends, nothing follows and the rule stays on the task
+	last := mustCreateWithDetails(t, s, "Last", 0, models.Details{DueAt: &dueAt, Recurrence: "FREQ=DAILY;UNTIL=20300603"})
+	if err := complete(t, s, last.ID); err != nil {
+		t.Fatalf("Failed to complete task: %v", err)
+	}
+	if task := mustGet(t, s, last.ID); task.Recurrence == "" {
+		t.Error("Expected the rule of an ended series to be kept")
+	}
+	if tasks := allTasks(t, s); len(tasks) != 4 {
+		t.Errorf("Expected no occurrence after the series ended, got %d tasks", len(tasks))
+	}
+}
@@ -0,0 +1,118 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"task-api/internal/storage"
+	"testing"
+)
+
+// resultIDs returns the task IDs of search results in rank order
+func resultIDs(results []*storage.SearchResult) []int {
+	ids := make([]int, 0, len(results))
+	for _, result := range results {
+		ids = append(ids, result.Task.ID)
+	}
+	return ids
+}
+
+// mustSearch runs a search or fails the test
+func mustSearch(t *testing.T, s storage.TaskStorage, query string, limit int) []*storage.SearchResult {
+	t.Helper()
+
+	results, err := s.Search(context.Background(), query, limit)
+	if err != nil {
+		t.Fatalf("Search %q failed: %v", query, err)
+	}
+	return results
+}
+
+// testSearch tests tokenized, case-insensitive and ranked matching
+func testSearch(t *testing.T, s storage.TaskStorage) {
+	report := mustCreate(t, s, "Write quarterly report", 0)
+	review := mustCreate(t, s, "Review report draft", 0)
+	milk := mustCreate(t, s, "Buy milk", 1)
+	both := mustCreate(t, s, "Write the report, then write it again", 0)
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []int
+	}{
+		{"single word", "milk", []int{milk.ID}},
+		{"case-insensitive", "MILK", []int{milk.ID}},
+		{"more occurrences rank higher", "write", []int{both.ID, report.ID}},
+		{"more matching words rank higher", "review report", []int{review.ID, report.ID, both.ID}},
+		{"ties are ordered by ID", "report", []int{report.ID, review.ID, both.ID}},
+		{"punctuation is ignored", "draft, milk!", []int{review.ID, milk.ID}},
+		{"no match", "groceries", []int{}},
+		{"no words", "!!!", []int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := mustSearch(t, s, tt.query, 0)
+			if got := resultIDs(results); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+			for i := 1; i < len(results); i++ {
+				if results[i].Score > results[i-1].Score {
+					t.Errorf("Results are not ordered by score: %v before %v", results[i-1].Score, results[i].Score)
+				}
+			}
+		})
+	}
+
+	t.Run("limit", func(t *testing.T) {
+		results := mustSearch(t, s, "write report", 2)
+		if got := resultIDs(results); !reflect.DeepEqual(got, []int{both.ID, report.ID}) {
+			t.Errorf("Expected the 2 best matches, got %v", got)
+		}
+	})
+
+	t.Run("results are full tasks", func(t *testing.T) {
+		results := mustSearch(t, s, "milk", 0)
+		if len(results) != 1 || !reflect.DeepEqual(*results[0].Task, *milk) {
+			t.Errorf("Expected %+v, got %+v", *milk, results)
+		}
+	})
+}
+
+// testSearchIndexMaintained tests that updates and deletes are reflected in search results
+func testSearchIndexMaintained(t *testing.T, s storage.TaskStorage) {
+	task := mustCreate(t, s, "Call plumber", 0)
+
+	task.Name = "Call electrician"
+	updated, err := s.Update(context.Background(), task)
+	if err != nil {
+		t.Fatalf("Failed to update task: %v", err)
+	}
+
+	if results := mustSearch(t, s, "plumber", 0); len(results) != 0 {
+		t.Errorf("Expected old name to be unindexed, got %v", resultIDs(results))
+	}
+	if results := mustSearch(t, s, "electrician", 0); !reflect.DeepEqual(resultIDs(results), []int{task.ID}) {
+		t.Errorf("Expected new name to be indexed, got %v", resultIDs(results))
+	} else if results[0].Task.Version != updated.Version {
+		t.Errorf("Expected search to return version %d, got %d", updated.Version, results[0].Task.Version)
+	}
+
+	if err := s.Delete(context.Background(), task.ID); err != nil {
+		t.Fatalf("Failed to delete task: %v", err)
+	}
+	if results := mustSearch(t, s, "call", 0); len(results) != 0 {
+		t.Errorf("Expected deleted task to be unindexed, got %v", resultIDs(results))
+	}
+}
+
+// testSearchCanceledContext tests that search honors a canceled context
+func testSearchCanceledContext(t *testing.T, s storage.TaskStorage) {
+	mustCreate(t, s, "Searchable", 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := s.Search(ctx, "searchable", 0); !errors.Is(err, context.Canceled) {
+		t.Errorf("Search: expected context.Canceled, got %v", err)
+	}
+}
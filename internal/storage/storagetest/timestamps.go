@@ -0,0 +1,238 @@
+package storagetest
+
+import (
+	"context"
+	"reflect"
+	"task-api/internal/clock"
+	"task-api/internal/models"
+	"task-api/internal/storage"
+	"testing"
+	"time"
+)
+
+// checkTimes fails the test unless task carries the expected timestamps
+func checkTimes(t *testing.T, task *models.Task, created, updated time.Time, completed *time.Time) {
+	t.Helper()
+
+	if !task.CreatedAt.Equal(created) || task.CreatedAt.Location() != time.UTC {
+		t.Errorf("Expected created_at %v in UTC, got %v", created, task.CreatedAt)
+	}
+	if !task.UpdatedAt.Equal(updated) || task.UpdatedAt.Location() != time.UTC {
+		t.Errorf("Expected updated_at %v in UTC, got %v", updated, task.UpdatedAt)
+	}
+	switch {
+	case completed == nil && task.CompletedAt != nil:
+		t.Errorf("Expected no completed_at, got %v", *task.CompletedAt)
+	case completed != nil && (task.CompletedAt == nil || !task.CompletedAt.Equal(*completed)):
+		t.Errorf("Expected completed_at %v, got %v", *completed, task.CompletedAt)
+	}
+}
+
+// testTimestamps tests that Create and Update stamp created_at, updated_at and
+// completed_at from the storage's clock, ignoring whatever the caller sent
+func testTimestamps(t *testing.T, s storage.TaskStorage, c *clock.Manual) {
+	ctx := context.Background()
+	created0 := c.Now()
+
+	task := newTask(t, "Write report", 0)
+	task.CreatedAt = time.Date(1999, 1, 1, 0, 0, 0, 0, time.UTC) // Ignored: storage owns the timestamps
+	created, err := s.Create(ctx, task)
+	if err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+	checkTimes(t, created, created0, created0, nil)
+
+	retrieved, err := s.GetByID(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("Failed to retrieve task: %v", err)
+	}
+	if !reflect.DeepEqual(retrieved, created) {
+		t.Errorf("Expected GetByID to return %+v, got %+v", created, retrieved)
+	}
+
+	update := func(name string, status int) *models.Task {
+		t.Helper()
+		current, err := s.GetByID(ctx, created.ID)
+		if err != nil {
+			t.Fatalf("Failed to retrieve task: %v", err)
+		}
+		current.Name, current.Status = name, status
+		current.CreatedAt = time.Time{}
+		updated, err := s.Update(ctx, current)
+		if err != nil {
+			t.Fatalf("Failed to update task: %v", err)
+		}
+		if stored, err := s.GetByID(ctx, created.ID); err != nil || !reflect.DeepEqual(stored, updated) {
+			t.Errorf("Expected GetByID to return %+v, got %+v (%v)", updated, stored, err)
+		}
+		return updated
+	}
+
+	c.Advance(time.Minute)
+	checkTimes(t, update("Write the report", 0), created0, c.Now(), nil)
+
+	c.Advance(time.Hour)
+	completed := c.Now()
+	checkTimes(t, update("Write the report", 1), created0, completed, &completed)
+
+	// Staying completed keeps the original completion time
+	c.Advance(time.Hour)
+	checkTimes(t, update("Report written", 1), created0, c.Now(), &completed)
+
+	// Reopening clears it
+	c.Advance(time.Hour)
+	checkTimes(t, update("Report written", 0), created0, c.Now(), nil)
+
+	// A task created completed is completed at creation
+	c.Advance(time.Hour)
+	done := mustCreate(t, s, "Already done", 1)
+	now := c.Now()
+	checkTimes(t, done, now, now, &now)
+}
+
+// testTimestampsBatch tests that batches and transactions stamp tasks like single writes
+func testTimestampsBatch(t *testing.T, s storage.TaskStorage, c *clock.Manual) {
+	ctx := context.Background()
+	created0 := c.Now()
+	existing := mustCreate(t, s, "Existing", 0)
+
+	c.Advance(time.Minute)
+	now := c.Now()
+	update := existing.Clone()
+	update.Status = 1
+	results, err := s.Batch(ctx, []storage.BatchOp{
+		{Kind: storage.BatchCreate, Task: newTask(t, "Batched", 0)},
+		{Kind: storage.BatchUpdate, Task: update},
+	}, true)
+	if err != nil {
+		t.Fatalf("Batch failed: %v", err)
+	}
+	checkTimes(t, results[0].Task, now, now, nil)
+	checkTimes(t, results[1].Task, created0, now, &now)
+
+	c.Advance(time.Minute)
+	now = c.Now()
+	err = s.WithTx(ctx, func(tx storage.TaskStorage) error {
+		created, err := tx.Create(ctx, newTask(t, "In tx", 1))
+		if err != nil {
+			return err
+		}
+		checkTimes(t, created, now, now, &now)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithTx failed: %v", err)
+	}
+}
+
+// seedTimedTasks creates three tasks an hour apart, then updates the first
+// and completes the second, so every timestamp orders the tasks differently.
+// It returns the tasks as stored and the time the clock started at.
+func seedTimedTasks(t *testing.T, s storage.TaskStorage, c *clock.Manual) ([]*models.Task, time.Time) {
+	t.Helper()
+	ctx := context.Background()
+	start := c.Now()
+
+	tasks := make([]*models.Task, 3)
+	for i, name := range []string{"First", "Second", "Third"} {
+		tasks[i] = mustCreate(t, s, name, 0)
+		c.Advance(time.Hour)
+	}
+
+	// Updated at start+3h and start+4h
+	for i, status := range []int{0, 1} {
+		update := tasks[i].Clone()
+		update.Status = status
+		update.Name += " changed"
+		updated, err := s.Update(ctx, update)
+		if err != nil {
+			t.Fatalf("Failed to update task: %v", err)
+		}
+		tasks[i] = updated
+		c.Advance(time.Hour)
+	}
+	return tasks, start
+}
+
+// testQueryTimeFilters tests filtering by created, updated and completed times
+func testQueryTimeFilters(t *testing.T, s storage.TaskStorage, c *clock.Manual) {
+	tasks, start := seedTimedTasks(t, s, c)
+	id := func(i int) int { return tasks[i].ID }
+	at := func(hours float64) *time.Time {
+		at := start.Add(time.Duration(hours * float64(time.Hour)))
+		return &at
+	}
+
+	tests := []struct {
+		name     string
+		query    storage.TaskQuery
+		expected []int
+	}{
+		{"created after", storage.TaskQuery{Created: storage.TimeRange{After: at(0)}}, []int{id(1), id(2)}},
+		{"created before", storage.TaskQuery{Created: storage.TimeRange{Before: at(1)}}, []int{id(0)}},
+		{"created between", storage.TaskQuery{Created: storage.TimeRange{After: at(0.5), Before: at(1.5)}}, []int{id(1)}},
+		{"updated after", storage.TaskQuery{Updated: storage.TimeRange{After: at(2.5)}}, []int{id(0), id(1)}},
+		{"updated before", storage.TaskQuery{Updated: storage.TimeRange{Before: at(3)}}, []int{id(2)}},
+		{"completed after", storage.TaskQuery{Completed: storage.TimeRange{After: at(0)}}, []int{id(1)}},
+		{"completed after the completion", storage.TaskQuery{Completed: storage.TimeRange{After: at(4)}}, []int{}},
+		{"combined", storage.TaskQuery{Created: storage.TimeRange{Before: at(2)}, Updated: storage.TimeRange{Before: at(3.5)}}, []int{id(0)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := mustQuery(t, s, tt.query)
+			if got := taskIDs(page.Tasks); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected IDs %v, got %v", tt.expected, got)
+			}
+			if page.Total != len(tt.expected) {
+				t.Errorf("Expected total %d, got %d", len(tt.expected), page.Total)
+			}
+		})
+	}
+}
+
+// testQuerySortByTime tests sorting and cursor paging by created and updated
+// times, including tasks with identical timestamps
+func testQuerySortByTime(t *testing.T, s storage.TaskStorage, c *clock.Manual) {
+	tasks, _ := seedTimedTasks(t, s, c)
+	// Two more tasks created at the same instant
+	tasks = append(tasks, mustCreate(t, s, "Fourth", 0), mustCreate(t, s, "Fifth", 0))
+	id := func(i int) int { return tasks[i].ID }
+
+	tests := []struct {
+		name     string
+		query    storage.TaskQuery
+		expected []int
+	}{
+		{"created ascending", storage.TaskQuery{SortBy: storage.SortByCreatedAt}, []int{id(0), id(1), id(2), id(3), id(4)}},
+		{"created descending", storage.TaskQuery{SortBy: storage.SortByCreatedAt, Descending: true}, []int{id(4), id(3), id(2), id(1), id(0)}},
+		{"updated ascending", storage.TaskQuery{SortBy: storage.SortByUpdatedAt}, []int{id(2), id(0), id(1), id(3), id(4)}},
+		{"updated descending", storage.TaskQuery{SortBy: storage.SortByUpdatedAt, Descending: true}, []int{id(4), id(3), id(1), id(0), id(2)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := taskIDs(mustQuery(t, s, tt.query).Tasks); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected IDs %v, got %v", tt.expected, got)
+			}
+
+			query := tt.query
+			query.Limit = 2
+			var walked []int
+			for pages := 0; ; pages++ {
+				if pages > len(tt.expected) {
+					t.Fatal("Cursor pagination does not terminate")
+				}
+				page := mustQuery(t, s, query)
+				walked = append(walked, taskIDs(page.Tasks)...)
+				if !page.HasMore {
+					break
+				}
+				query.After = storage.CursorAfter(page.Tasks[len(page.Tasks)-1])
+			}
+			if !reflect.DeepEqual(walked, tt.expected) {
+				t.Errorf("Expected to walk %v, got %v", tt.expected, walked)
+			}
+		})
+	}
+}
@@ -0,0 +1,393 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"task-api/internal/models"
+	"task-api/internal/storage"
+	"testing"
+)
+
+// errAbort is returned by transaction functions that want to roll back
+var errAbort = errors.New("abort transaction")
+
+// testTxCommit tests that a committed transaction applies all its changes
+// and that reads inside the transaction see its own writes
+func testTxCommit(t *testing.T, s storage.TaskStorage) {
+	ctx := context.Background()
+	existing := mustCreate(t, s, "Existing", 0)
+	doomed := mustCreate(t, s, "Doomed", 0)
+
+	var created, updated *models.Task
+	err := s.WithTx(ctx, func(tx storage.TaskStorage) error {
+		task, err := tx.Create(ctx, newTask(t, "Created in tx", 0))
+		if err != nil {
+			return err
+		}
+		if _, err := tx.GetByID(ctx, task.ID); err != nil {
+			t.Errorf("Expected to read the created task inside the transaction: %v", err)
+		}
+
+		update := existing.Clone()
+		update.Name = "Updated in tx"
+		changed, err := tx.Update(ctx, update)
+		if err != nil {
+			return err
+		}
+		if err := tx.Delete(ctx, doomed.ID); err != nil {
+			return err
+		}
+
+		all, err := tx.GetAll(ctx)
+		if err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(taskIDs(all), []int{existing.ID, task.ID}) {
+			t.Errorf("Expected the transaction to list %v, got %v", []int{existing.ID, task.ID}, taskIDs(all))
+		}
+		page, err := tx.Query(ctx, storage.TaskQuery{NameContains: "in tx"})
+		if err != nil {
+			return err
+		}
+		if page.Total != 2 {
+			t.Errorf("Expected the transaction to query 2 tasks, got %d", page.Total)
+		}
+		results, err := tx.Search(ctx, "tx", 0)
+		if err != nil {
+			return err
+		}
+		if len(results) != 2 {
+			t.Errorf("Expected the transaction to find 2 tasks, got %d", len(results))
+		}
+		created, updated = task, changed
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithTx failed: %v", err)
+	}
+
+	tasks := allTasks(t, s)
+	if len(tasks) != 2 {
+		t.Fatalf("Expected 2 tasks after commit, got %d", len(tasks))
+	}
+	if !reflect.DeepEqual(tasks[0], updated) || !reflect.DeepEqual(tasks[1], created) {
+		t.Errorf("Expected %v and %v after commit, got %v and %v", updated, created, tasks[0], tasks[1])
+	}
+	if updated.Version != existing.Version+1 {
+		t.Errorf("Expected version %d, got %d", existing.Version+1, updated.Version)
+	}
+}
+
+// testTxRollback tests that an error from the transaction function undoes
+// every change, including the search index, and is returned unchanged
+func testTxRollback(t *testing.T, s storage.TaskStorage) {
+	ctx := context.Background()
+	existing := mustCreate(t, s, "Existing task", 0)
+	doomed := mustCreate(t, s, "Doomed task", 0)
+	before := allTasks(t, s)
+
+	err := s.WithTx(ctx, func(tx storage.TaskStorage) error {
+		if _, err := tx.Create(ctx, newTask(t, "Rolled back", 0)); err != nil {
+			return err
+		}
+		update := existing.Clone()
+		update.Name = "Renamed"
+		if _, err := tx.Update(ctx, update); err != nil {
+			return err
+		}
+		if err := tx.Delete(ctx, doomed.ID); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("Expected the transaction's own error, got %v", err)
+	}
+
+	if after := allTasks(t, s); !reflect.DeepEqual(after, before) {
+		t.Errorf("Expected %v after rollback, got %v", before, after)
+	}
+	for query, expected := range map[string][]int{"renamed": {}, "rolled": {}, "doomed": {doomed.ID}} {
+		if got := resultIDs(mustSearch(t, s, query, 0)); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected search for %q to find %v after rollback, got %v", query, expected, got)
+		}
+	}
+
+	// The storage is fully usable after a rollback
+	updated := existing.Clone()
+	updated.Status = 1
+	if _, err := s.Update(ctx, updated); err != nil {
+		t.Errorf("Expected update after rollback to succeed, got %v", err)
+	}
+}
+
+// testTxPanic tests that a panicking transaction function is rolled back
+// and the panic propagates
+func testTxPanic(t *testing.T, s storage.TaskStorage) {
+	ctx := context.Background()
+	mustCreate(t, s, "Existing", 0)
+	before := allTasks(t, s)
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Expected the panic to propagate")
+			}
+		}()
+		_ = s.WithTx(ctx, func(tx storage.TaskStorage) error {
+			if _, err := tx.Create(ctx, newTask(t, "Half done", 0)); err != nil {
+				return err
+			}
+			panic("boom")
+		})
+	}()
+
+	if after := allTasks(t, s); !reflect.DeepEqual(after, before) {
+		t.Errorf("Expected %v after a panic, got %v", before, after)
+	}
+	mustCreate(t, s, "After panic", 0)
+}
+
+// testTxFailedOperation tests that an operation failing inside a transaction
+// does not spoil it: the transaction can continue and commit its other changes
+func testTxFailedOperation(t *testing.T, s storage.TaskStorage) {
+	ctx := context.Background()
+	existing := mustCreate(t, s, "Existing", 0)
+
+	err := s.WithTx(ctx, func(tx storage.TaskStorage) error {
+		stale := existing.Clone()
+		stale.Version = existing.Version + 5
+		if _, err := tx.Update(ctx, stale); !errors.Is(err, storage.ErrConflict) {
+			t.Errorf("Expected ErrConflict for a stale update, got %v", err)
+		}
+		if err := tx.Delete(ctx, 99999); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound for a missing task, got %v", err)
+		}
+
+		// An atomic batch failing inside the transaction is undone on its own
+		results, err := tx.Batch(ctx, []storage.BatchOp{
+			{Kind: storage.BatchCreate, Task: newTask(t, "Batched", 0)},
+			{Kind: storage.BatchDelete, ID: 99999},
+		}, true)
+		if err != nil {
+			return err
+		}
+		if results[0].Applied || !errors.Is(results[1].Err, storage.ErrNotFound) {
+			t.Errorf("Expected the atomic batch to fail as a whole, got %+v", results)
+		}
+
+		_, err = tx.Create(ctx, newTask(t, "Kept", 0))
+		return err
+	})
+	if err != nil {
+		t.Fatalf("WithTx failed: %v", err)
+	}
+
+	tasks := allTasks(t, s)
+	if len(tasks) != 2 || tasks[0].ID != existing.ID || tasks[1].Name != "Kept" {
+		t.Errorf("Expected the existing and the kept task, got %v", tasks)
+	}
+}
+
+// testTxNested tests that a failing nested transaction only undoes its own
+// changes, while a successful one becomes part of the outer transaction
+func testTxNested(t *testing.T, s storage.TaskStorage) {
+	ctx := context.Background()
+
+	err := s.WithTx(ctx, func(tx storage.TaskStorage) error {
+		if _, err := tx.Create(ctx, newTask(t, "Outer", 0)); err != nil {
+			return err
+		}
+
+		err := tx.WithTx(ctx, func(nested storage.TaskStorage) error {
+			if _, err := nested.Create(ctx, newTask(t, "Inner rolled back", 0)); err != nil {
+				return err
+			}
+			return errAbort
+		})
+		if !errors.Is(err, errAbort) {
+			t.Errorf("Expected the nested transaction's error, got %v", err)
+		}
+
+		return tx.WithTx(ctx, func(nested storage.TaskStorage) error {
+			_, err := nested.Create(ctx, newTask(t, "Inner kept", 0))
+			return err
+		})
+	})
+	if err != nil {
+		t.Fatalf("WithTx failed: %v", err)
+	}
+
+	var names []string
+	for _, task := range allTasks(t, s) {
+		names = append(names, task.Name)
+	}
+	if expected := []string{"Outer", "Inner kept"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected %v, got %v", expected, names)
+	}
+
+	// A committed nested transaction is still undone with its outer transaction
+	err = s.WithTx(ctx, func(tx storage.TaskStorage) error {
+		if err := tx.WithTx(ctx, func(nested storage.TaskStorage) error {
+			_, err := nested.Create(ctx, newTask(t, "Doomed with outer", 0))
+			return err
+		}); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("Expected the transaction's own error, got %v", err)
+	}
+	if tasks := allTasks(t, s); len(tasks) != 2 {
+		t.Errorf("Expected 2 tasks after rolling back the outer transaction, got %d", len(tasks))
+	}
+}
+
+// testTxFinished tests that a transaction cannot be used after it finished
+func testTxFinished(t *testing.T, s storage.TaskStorage) {
+	ctx := context.Background()
+
+	var leaked storage.TaskStorage
+	if err := s.WithTx(ctx, func(tx storage.TaskStorage) error {
+		leaked = tx
+		return nil
+	}); err != nil {
+		t.Fatalf("WithTx failed: %v", err)
+	}
+
+	if _, err := leaked.Create(ctx, newTask(t, "Too late", 0)); !errors.Is(err, storage.ErrInvalid) {
+		t.Errorf("Expected ErrInvalid using a finished transaction, got %v", err)
+	}
+	if _, err := leaked.GetAll(ctx); !errors.Is(err, storage.ErrInvalid) {
+		t.Errorf("Expected ErrInvalid reading through a finished transaction, got %v", err)
+	}
+	if tasks := allTasks(t, s); len(tasks) != 0 {
+		t.Errorf("Expected no tasks, got %d", len(tasks))
+	}
+}
+
+// testTxCanceledContext tests that a transaction is not started with a canceled context
+func testTxCanceledContext(t *testing.T, s storage.TaskStorage) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	called := false
+	err := s.WithTx(ctx, func(tx storage.TaskStorage) error {
+		called = true
+		return nil
+	})
+	if !errors.Is(err, storage.ErrUnavailable) {
+		t.Errorf("Expected ErrUnavailable, got %v", err)
+	}
+	if called {
+		t.Error("Expected the transaction function not to run")
+	}
+}
+
+// testView tests that a view reads like a transaction, cannot write and
+// cannot be used after it finished
+func testView(t *testing.T, s storage.TaskStorage) {
+	ctx := context.Background()
+	existing := mustCreate(t, s, "Existing", 0)
+	before := allTasks(t, s)
+
+	var leaked storage.TaskStorage
+	err := s.View(ctx, func(tx storage.TaskStorage) error {
+		leaked = tx
+		if all, err := tx.GetAll(ctx); err != nil || !reflect.DeepEqual(all, before) {
+			t.Errorf("Expected the view to list %v, got %v (%v)", before, all, err)
+		}
+		if page, err := tx.Query(ctx, storage.TaskQuery{NameContains: "exist"}); err != nil || page.Total != 1 {
+			t.Errorf("Expected the view to query 1 task, got %+v (%v)", page, err)
+		}
+		if results, err := tx.Search(ctx, "existing", 0); err != nil || len(results) != 1 {
+			t.Errorf("Expected the view to find 1 task, got %v (%v)", results, err)
+		}
+
+		writes := map[string]func(tx storage.TaskStorage) error{
+			"create": func(tx storage.TaskStorage) error {
+				_, err := tx.Create(ctx, newTask(t, "Written in a view", 0))
+				return err
+			},
+			"update": func(tx storage.TaskStorage) error {
+				_, err := tx.Update(ctx, existing)
+				return err
+			},
+			"mark alerts": func(tx storage.TaskStorage) error {
+				_, err := tx.MarkAlerts(ctx, existing.ID, existing.Version, models.Alerts{})
+				return err
+			},
+			"delete": func(tx storage.TaskStorage) error {
+				return tx.Delete(ctx, existing.ID)
+			},
+			"batch": func(tx storage.TaskStorage) error {
+				_, err := tx.Batch(ctx, []storage.BatchOp{{Kind: storage.BatchDelete, ID: existing.ID}}, true)
+				return err
+			},
+			"nested transaction": func(tx storage.TaskStorage) error {
+				return tx.WithTx(ctx, func(nested storage.TaskStorage) error {
+					return nested.Delete(ctx, existing.ID)
+				})
+			},
+		}
+		for name, write := range writes {
+			if err := write(tx); !errors.Is(err, storage.ErrInvalid) {
+				t.Errorf("Expected ErrInvalid for %s in a view, got %v", name, err)
+			}
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("Expected the view's own error, got %v", err)
+	}
+
+	if _, err := leaked.GetAll(ctx); !errors.Is(err, storage.ErrInvalid) {
+		t.Errorf("Expected ErrInvalid reading through a finished view, got %v", err)
+	}
+	if after := allTasks(t, s); !reflect.DeepEqual(after, before) {
+		t.Errorf("Expected %v after the view, got %v", before, after)
+	}
+
+	// A view of a transaction sees the transaction's own writes
+	err = s.WithTx(ctx, func(tx storage.TaskStorage) error {
+		created, err := tx.Create(ctx, newTask(t, "Created in tx", 0))
+		if err != nil {
+			return err
+		}
+		return tx.View(ctx, func(view storage.TaskStorage) error {
+			if _, err := view.GetByID(ctx, created.ID); err != nil {
+				t.Errorf("Expected the view to see the task created in the transaction: %v", err)
+			}
+			if err := view.Delete(ctx, created.ID); !errors.Is(err, storage.ErrInvalid) {
+				t.Errorf("Expected ErrInvalid deleting through a view of a transaction, got %v", err)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatalf("Transaction failed: %v", err)
+	}
+	if tasks := allTasks(t, s); len(tasks) != 2 {
+		t.Errorf("Expected the transaction to commit after its view, got %v", tasks)
+	}
+}
+
+// testViewCanceledContext tests that a view is not started with a canceled context
+func testViewCanceledContext(t *testing.T, s storage.TaskStorage) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	called := false
+	err := s.View(ctx, func(tx storage.TaskStorage) error {
+		called = true
+		return nil
+	})
+	if !errors.Is(err, storage.ErrUnavailable) {
+		t.Errorf("Expected ErrUnavailable, got %v", err)
+	}
+	if called {
+		t.Error("Expected the view function not to run")
+	}
+}
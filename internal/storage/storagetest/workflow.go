@@ -0,0 +1,50 @@
+package storagetest
+
+import (
+	"context"
+	"reflect"
+	"task-api/internal/models"
+	"task-api/internal/storage"
+	"testing"
+)
+
+// testState tests that the workflow state is stored with the task, follows
+// updates and can be filtered on
+func testState(t *testing.T, s storage.TaskStorage) {
+	ctx := context.Background()
+
+	open := mustCreate(t, s, "Open", 0)
+	task, err := models.NewTaskInState("Finished", "done", models.Details{})
+	if err != nil {
+		t.Fatalf("Failed to create task model: %v", err)
+	}
+	done, err := s.Create(ctx, task)
+	if err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+	if open.State != "todo" || done.State != "done" || done.Status != 1 {
+		t.Errorf("Expected states todo and done, got %q and %q (status %d)", open.State, done.State, done.Status)
+	}
+
+	if got := taskIDs(mustQuery(t, s, storage.TaskQuery{State: "done"}).Tasks); !reflect.DeepEqual(got, []int{done.ID}) {
+		t.Errorf("Expected the done task, got %v", got)
+	}
+
+	// Reopen the finished task
+	if err := done.UpdateInState(done.Name, "todo", done.Details); err != nil {
+		t.Fatalf("Failed to change state: %v", err)
+	}
+	if _, err := s.Update(ctx, done); err != nil {
+		t.Fatalf("Failed to update task: %v", err)
+	}
+	retrieved, err := s.GetByID(ctx, done.ID)
+	if err != nil {
+		t.Fatalf("Failed to retrieve task: %v", err)
+	}
+	if retrieved.State != "todo" || retrieved.Status != 0 {
+		t.Errorf("Expected the reopened task in todo with status 0, got %q with status %d", retrieved.State, retrieved.Status)
+	}
+	if got := taskIDs(mustQuery(t, s, storage.TaskQuery{State: "todo"}).Tasks); !reflect.DeepEqual(got, []int{open.ID, done.ID}) {
+		t.Errorf("Expected both tasks in todo, got %v", got)
+	}
+}
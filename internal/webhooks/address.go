@@ -0,0 +1,67 @@
+package webhooks
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateAddress is returned when a delivery would reach an address that is
+// not public, unless Config.AllowPrivate is set.
+var ErrPrivateAddress = errors.New("webhook address is not public")
+
+// sharedAddresses is the carrier-grade NAT range, private in all but name
+var sharedAddresses = netip.MustParsePrefix("100.64.0.0/10")
+
+// isPublic reports whether ip is a unicast address on the internet rather
+// than a loopback, private, link-local (such as the 169.254.169.254 metadata
+// service), unspecified or multicast one
+func isPublic(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !sharedAddresses.Contains(ip)
+}
+
+// isPublicHost reports whether the host of a webhook URL may be public. IP
+// addresses and localhost are checked as they are; other names are checked
+// once resolved, when delivering (see dialControl), since they may resolve
+// elsewhere by then
+func isPublicHost(host string) bool {
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return isPublic(ip)
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	return host != "localhost" && !strings.HasSuffix(host, ".localhost")
+}
+
+// dialControl refuses connections to addresses that are not public. It runs
+// after names are resolved, so a webhook cannot reach one by pointing its
+// host name at it after being registered.
+func dialControl(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !isPublic(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, addrPort.Addr())
+	}
+	return nil
+}
+
+// newTransport returns the transport of the default client, dialing only
+// public addresses unless allowPrivate. It goes around any proxy, which would
+// otherwise be the only address checked.
+func newTransport(allowPrivate bool) *http.Transport {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if !allowPrivate {
+		dialer.Control = dialControl
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return transport
+}
@@ -0,0 +1,80 @@
+package webhooks
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// TestDialControl tests which resolved addresses deliveries may connect to
+func TestDialControl(t *testing.T) {
+	tests := []struct {
+		address  string
+		expected bool
+	}{
+		{address: "93.184.215.14:443", expected: true},
+		{address: "[2606:2800:21f:cb07:6820:80da:af6b:8b2c]:443", expected: true},
+		{address: "127.0.0.1:80"},
+		{address: "[::1]:80"},
+		{address: "[::ffff:127.0.0.1]:80"},
+		{address: "10.1.2.3:80"},
+		{address: "172.16.0.1:80"},
+		{address: "192.168.1.1:80"},
+		{address: "100.64.0.1:80"},
+		{address: "169.254.169.254:80"},
+		{address: "[fe80::1]:80"},
+		{address: "[fd00::1]:80"},
+		{address: "0.0.0.0:80"},
+		{address: "224.0.0.1:80"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			err := dialControl("tcp", tt.address, nil)
+
+			if tt.expected && err != nil {
+				t.Errorf("Expected %s allowed, got %v", tt.address, err)
+			}
+			if !tt.expected && !errors.Is(err, ErrPrivateAddress) {
+				t.Errorf("Expected ErrPrivateAddress for %s, got %v", tt.address, err)
+			}
+		})
+	}
+}
+
+// TestNewTransport tests that the default client checks the address it
+// connects to once resolved, whatever the URL names
+func TestNewTransport(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer receiver.Close()
+	parsed, _ := url.Parse(receiver.URL)
+	byName := "http://localhost:" + parsed.Port()
+
+	tests := []struct {
+		name         string
+		url          string
+		allowPrivate bool
+		expectedErr  error
+	}{
+		{name: "loopback address", url: receiver.URL, expectedErr: ErrPrivateAddress},
+		{name: "name resolving to loopback", url: byName, expectedErr: ErrPrivateAddress},
+		{name: "private addresses allowed", url: byName, allowPrivate: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &http.Client{Transport: newTransport(tt.allowPrivate)}
+
+			resp, err := client.Post(tt.url, "application/json", nil)
+			if err == nil {
+				resp.Body.Close()
+			}
+
+			if !errors.Is(err, tt.expectedErr) {
+				t.Errorf("Expected error %v, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,251 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"task-api/internal/events"
+	"time"
+)
+
+// Headers sent with every delivery
+const (
+	HeaderEvent     = "X-Webhook-Event"     // The event type, e.g. task.created
+	HeaderDelivery  = "X-Webhook-Delivery"  // The delivery ID, the same for every attempt
+	HeaderTimestamp = "X-Webhook-Timestamp" // When the attempt was made, in Unix seconds
+	HeaderSignature = "X-Webhook-Signature" // See Sign
+)
+
+// Sign returns the signature of a delivery body sent at timestamp: "sha256="
+// and the hex-encoded HMAC-SHA256, keyed with the webhook secret, of the
+// timestamp in decimal, a dot and the body. Receivers compute it to check that
+// a delivery is genuine, and should refuse old timestamps to stop replays.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Run delivers the events published on the bus until ctx is done, making at
+// most config.Workers attempts at once and one per webhook. Deliveries still
+// pending when Run returns are attempted if it runs again.
+func (s *Service) Run(ctx context.Context) {
+	subscription, _, _ := s.bus.Subscribe(0, false)
+	s.run(ctx, subscription)
+}
+
+// run is Run with the bus subscription already made
+func (s *Service) run(ctx context.Context, subscription *events.Subscription) {
+	defer func() { subscription.Close() }()
+	feed, lastEventID := subscription.Events(), subscription.Start
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	finished := make(chan struct{}, s.config.Workers)
+	inFlight := 0
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		for _, delivery := range s.takeDue(s.config.Workers - inFlight) {
+			inFlight++
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.attempt(ctx, delivery)
+				finished <- struct{}{}
+			}()
+		}
+
+		// Sleep until the next delivery is due, unless every worker is busy
+		timer.Stop()
+		if inFlight < s.config.Workers {
+			if next, ok := s.nextDue(); ok {
+				timer.Reset(max(next.Sub(s.clock.Now()), 0))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-feed:
+			if ok {
+				lastEventID = event.ID
+				s.publish(event)
+				continue
+			}
+			if s.bus.Closed() {
+				feed = nil
+				continue
+			}
+			// Fell behind; catch up from the bus's replay buffer
+			var missed []events.Event
+			var complete bool
+			subscription, missed, complete = s.bus.Subscribe(lastEventID, true)
+			if !complete {
+				log.Printf("Webhooks missed task events after event %d", lastEventID)
+			}
+			for _, event := range missed {
+				s.publish(event)
+			}
+			feed, lastEventID = subscription.Events(), subscription.Start
+		case <-finished:
+			inFlight--
+		case <-s.wake:
+		case <-timer.C:
+		}
+	}
+}
+
+// publish queues a delivery of event to every webhook wanting it
+func (s *Service) publish(event events.Event) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to encode event %d for webhooks: %v", event.ID, err)
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	ids := make([]int, 0, len(s.webhooks))
+	for id, webhook := range s.webhooks {
+		if webhook.wants(event.Type) {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	for _, id := range ids {
+		s.queueLocked(id, event.ID, event.Type, payload)
+	}
+}
+
+// takeDue marks at most n due deliveries as under way and returns them, oldest
+// first. A webhook gets one delivery at a time, so that it receives events in
+// order unless a retry intervenes, and so that a slow webhook cannot hold up
+// the others by taking every worker.
+func (s *Service) takeDue(n int) []*Delivery {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := s.clock.Now()
+	busy := s.busyLocked()
+	var due []*Delivery
+	for _, delivery := range s.pending {
+		if len(due) == n {
+			break
+		}
+		if !busy[delivery.WebhookID] && !delivery.NextAttemptAt.After(now) {
+			delivery.inFlight = true
+			busy[delivery.WebhookID] = true
+			due = append(due, delivery)
+		}
+	}
+	return due
+}
+
+// nextDue returns when the next delivery takeDue may return is due; ok is false if there is none
+func (s *Service) nextDue() (next time.Time, ok bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	busy := s.busyLocked()
+	for _, delivery := range s.pending {
+		if !busy[delivery.WebhookID] && (!ok || delivery.NextAttemptAt.Before(next)) {
+			next, ok = *delivery.NextAttemptAt, true
+		}
+	}
+	return next, ok
+}
+
+// busyLocked returns the webhooks with a delivery under way. The caller must hold the mutex.
+func (s *Service) busyLocked() map[int]bool {
+	busy := make(map[int]bool)
+	for _, delivery := range s.pending {
+		if delivery.inFlight {
+			busy[delivery.WebhookID] = true
+		}
+	}
+	return busy
+}
+
+// attempt makes one attempt at delivery and records how it went: a delivered
+// delivery is done, a failed one is retried after a backoff or, once out of
+// attempts, becomes a dead letter.
+func (s *Service) attempt(ctx context.Context, delivery *Delivery) {
+	s.mutex.Lock()
+	webhook, ok := s.webhooks[delivery.WebhookID]
+	var target, secret string
+	if ok {
+		target, secret = webhook.URL, webhook.Secret
+	}
+	s.mutex.Unlock()
+	if !ok {
+		return // Deleted together with its deliveries
+	}
+
+	at := s.clock.Now().UTC()
+	statusCode, err := s.post(ctx, target, secret, delivery, at)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delivery.inFlight = false
+	if ctx.Err() != nil {
+		return // Interrupted by shutdown, which is not the webhook's fault
+	}
+	record := Attempt{At: at, StatusCode: statusCode}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	delivery.Attempts = append(delivery.Attempts, record)
+
+	switch {
+	case err == nil:
+		s.finishLocked(delivery, DeliveryDelivered)
+	case len(delivery.Attempts) >= s.config.MaxAttempts:
+		s.finishLocked(delivery, DeliveryFailed)
+	default:
+		next := s.clock.Now().UTC().Add(s.config.backoff(len(delivery.Attempts)))
+		delivery.NextAttemptAt = &next
+	}
+}
+
+// post sends delivery to target, signed with secret, returning the status the
+// webhook answered with and an error unless it was a 2xx status
+func (s *Service) post(ctx context.Context, target, secret string, delivery *Delivery, at time.Time) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(delivery.payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := at.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "task-api-webhooks")
+	req.Header.Set(HeaderEvent, string(delivery.EventType))
+	req.Header.Set(HeaderDelivery, strconv.Itoa(delivery.ID))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(secret, timestamp, delivery.payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10)) // Lets the connection be reused
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,308 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync"
+	"task-api/internal/clock"
+	"task-api/internal/events"
+	"task-api/internal/models"
+	"task-api/internal/storage"
+	"testing"
+	"time"
+)
+
+// testConfig retries quickly and reaches the receivers on the loopback address
+var testConfig = Config{MaxAttempts: 3, Backoff: 10 * time.Millisecond, MaxBackoff: 40 * time.Millisecond, Timeout: time.Second, Workers: 2, AllowPrivate: true}
+
+// received is a delivery as a receiver saw it
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+// receiver is a webhook endpoint answering with the statuses it is given in
+// turn, the last one from then on
+type receiver struct {
+	*httptest.Server
+	mutex    sync.Mutex
+	statuses []int
+	requests []received
+}
+
+func newReceiver(t *testing.T, statuses ...int) *receiver {
+	r := &receiver{statuses: statuses}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mutex.Lock()
+		r.requests = append(r.requests, received{header: req.Header.Clone(), body: body})
+		status := r.statuses[0]
+		if len(r.statuses) > 1 {
+			r.statuses = r.statuses[1:]
+		}
+		r.mutex.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+// answer makes the receiver answer every request with status from now on
+func (r *receiver) answer(status int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.statuses = []int{status}
+}
+
+func (r *receiver) received() []received {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]received(nil), r.requests...)
+}
+
+// startService runs a service over a new bus until the test ends
+func startService(t *testing.T, config Config) (*Service, *events.Bus) {
+	t.Helper()
+	bus := events.NewBus(events.DefaultReplaySize)
+	s := New(bus, clock.System(), config, nil)
+	subscription, _, _ := bus.Subscribe(0, false) // Before returning, so no event is missed
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.run(ctx, subscription)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return s, bus
+}
+
+// waitFor polls condition until it holds, failing the test after a while
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// settled waits until no delivery of the webhook is pending and returns them, newest first
+func settled(t *testing.T, s *Service, webhookID, count int) []Delivery {
+	t.Helper()
+	var deliveries []Delivery
+	waitFor(t, strconv.Itoa(count)+" settled deliveries", func() bool {
+		deliveries, _ = s.Deliveries(webhookID)
+		if len(deliveries) != count {
+			return false
+		}
+		for _, delivery := range deliveries {
+			if delivery.Status == DeliveryPending {
+				return false
+			}
+		}
+		return true
+	})
+	return deliveries
+}
+
+// attemptStatuses returns the status codes of the attempts of delivery
+func attemptStatuses(delivery Delivery) []int {
+	var statuses []int
+	for _, attempt := range delivery.Attempts {
+		statuses = append(statuses, attempt.StatusCode)
+	}
+	return statuses
+}
+
+// TestService_Run tests delivering signed events to the webhooks wanting them
+func TestService_Run(t *testing.T) {
+	s, bus := startService(t, testConfig)
+	everything := newReceiver(t, http.StatusOK)
+	deletions := newReceiver(t, http.StatusNoContent)
+	all, _ := s.Register(everything.URL, nil, "all-secret")
+	deleted, _ := s.Register(deletions.URL, []events.Type{events.TaskDeleted}, "deleted-secret")
+
+	bus.Publish([]storage.Change{
+		{Kind: storage.ChangeCreated, ID: 7, Task: &models.Task{ID: 7, Name: "Hooked"}},
+		{Kind: storage.ChangeDeleted, ID: 7},
+	})
+
+	if deliveries := settled(t, s, all.ID, 2); deliveries[0].Status != DeliveryDelivered || deliveries[1].Status != DeliveryDelivered {
+		t.Errorf("Expected both events delivered, got %+v", deliveries)
+	}
+	delivered := settled(t, s, deleted.ID, 1)[0]
+	if delivered.EventType != events.TaskDeleted || !reflect.DeepEqual(attemptStatuses(delivered), []int{http.StatusNoContent}) || delivered.NextAttemptAt != nil {
+		t.Errorf("Expected the deletion delivered at once, got %+v", delivered)
+	}
+
+	for _, tt := range []struct {
+		receiver *receiver
+		secret   string
+		types    []events.Type
+	}{
+		{receiver: everything, secret: "all-secret", types: []events.Type{events.TaskCreated, events.TaskDeleted}},
+		{receiver: deletions, secret: "deleted-secret", types: []events.Type{events.TaskDeleted}},
+	} {
+		requests := tt.receiver.received()
+		if len(requests) != len(tt.types) {
+			t.Fatalf("Expected %d requests, got %d", len(tt.types), len(requests))
+		}
+		for i, request := range requests {
+			timestamp, err := strconv.ParseInt(request.header.Get(HeaderTimestamp), 10, 64)
+			if err != nil {
+				t.Fatalf("Invalid timestamp header: %v", err)
+			}
+			if signature := request.header.Get(HeaderSignature); signature != Sign(tt.secret, timestamp, request.body) {
+				t.Errorf("Invalid signature %q", signature)
+			}
+			if eventType := request.header.Get(HeaderEvent); eventType != string(tt.types[i]) {
+				t.Errorf("Expected event header %s, got %s", tt.types[i], eventType)
+			}
+			if request.header.Get(HeaderDelivery) == "" || request.header.Get("Content-Type") != "application/json" {
+				t.Errorf("Missing headers in %v", request.header)
+			}
+			var event events.Event
+			if err := json.Unmarshal(request.body, &event); err != nil || event.Type != tt.types[i] || event.TaskID != 7 {
+				t.Errorf("Expected the %s event of task 7, got %s (%v)", tt.types[i], request.body, err)
+			}
+		}
+	}
+}
+
+// TestService_Run_Retries tests retrying failed deliveries until they succeed
+func TestService_Run_Retries(t *testing.T) {
+	s, bus := startService(t, testConfig)
+	flaky := newReceiver(t, http.StatusInternalServerError, http.StatusFound, http.StatusOK)
+	webhook, _ := s.Register(flaky.URL, nil, "")
+
+	bus.Publish([]storage.Change{{Kind: storage.ChangeUpdated, ID: 1, Task: &models.Task{ID: 1, Name: "Retried"}}})
+
+	delivery := settled(t, s, webhook.ID, 1)[0]
+	expected := []int{http.StatusInternalServerError, http.StatusFound, http.StatusOK}
+	if delivery.Status != DeliveryDelivered || !reflect.DeepEqual(attemptStatuses(delivery), expected) {
+		t.Errorf("Expected delivery after attempts %v, got %+v", expected, delivery)
+	}
+	if delivery.Attempts[0].Error == "" || delivery.Attempts[2].Error != "" {
+		t.Errorf("Expected only failed attempts to carry an error, got %+v", delivery.Attempts)
+	}
+	if wait := delivery.Attempts[2].At.Sub(delivery.Attempts[1].At); wait < 2*testConfig.Backoff {
+		t.Errorf("Expected the second retry to wait at least %v, waited %v", 2*testConfig.Backoff, wait)
+	}
+
+	// Every attempt carries the same delivery
+	requests := flaky.received()
+	for _, request := range requests[1:] {
+		if request.header.Get(HeaderDelivery) != requests[0].header.Get(HeaderDelivery) || string(request.body) != string(requests[0].body) {
+			t.Errorf("Expected every attempt to send the same delivery")
+		}
+	}
+}
+
+// TestService_Run_DeadLetters tests that deliveries out of attempts become dead letters that can be redelivered
+func TestService_Run_DeadLetters(t *testing.T) {
+	s, bus := startService(t, testConfig)
+	down := newReceiver(t, http.StatusServiceUnavailable)
+	webhook, _ := s.Register(down.URL, nil, "")
+	unreachable, _ := s.Register("http://127.0.0.1:1/hook", []events.Type{events.TaskDeleted}, "")
+
+	bus.Publish([]storage.Change{{Kind: storage.ChangeDeleted, ID: 3}})
+
+	failed := settled(t, s, webhook.ID, 1)[0]
+	if failed.Status != DeliveryFailed || !reflect.DeepEqual(attemptStatuses(failed), []int{503, 503, 503}) {
+		t.Errorf("Expected the delivery to fail after %d attempts, got %+v", testConfig.MaxAttempts, failed)
+	}
+	if never := settled(t, s, unreachable.ID, 1)[0]; never.Status != DeliveryFailed || never.Attempts[0].StatusCode != 0 || never.Attempts[0].Error == "" {
+		t.Errorf("Expected the unreachable delivery to fail without a status, got %+v", never)
+	}
+	deadLetters := s.DeadLetters()
+	if len(deadLetters) != 2 || !reflect.DeepEqual([]int{deadLetters[0].WebhookID, deadLetters[1].WebhookID}, []int{unreachable.ID, webhook.ID}) &&
+		!reflect.DeepEqual([]int{deadLetters[0].WebhookID, deadLetters[1].WebhookID}, []int{webhook.ID, unreachable.ID}) {
+		t.Fatalf("Expected both deliveries as dead letters, got %+v", deadLetters)
+	}
+
+	// Redelivered once the webhook is back
+	down.answer(http.StatusOK)
+	redelivery, err := s.Redeliver(failed.ID)
+	if err != nil {
+		t.Fatalf("Failed to redeliver: %v", err)
+	}
+	if redelivery.ID == failed.ID || redelivery.EventID != failed.EventID || redelivery.Status != DeliveryPending {
+		t.Errorf("Expected a new pending delivery of the same event, got %+v", redelivery)
+	}
+	deliveries := settled(t, s, webhook.ID, 2)
+	if deliveries[0].ID != redelivery.ID || deliveries[0].Status != DeliveryDelivered || deliveries[1].Status != DeliveryFailed {
+		t.Errorf("Expected the redelivery delivered after the failed delivery, got %+v", deliveries)
+	}
+	if requests := down.received(); string(requests[len(requests)-1].body) != string(requests[0].body) {
+		t.Error("Expected the redelivery to carry the original event")
+	}
+	if deadLetters := s.DeadLetters(); len(deadLetters) != 1 || deadLetters[0].WebhookID != unreachable.ID {
+		t.Errorf("Expected only the unreachable delivery left, got %+v", deadLetters)
+	}
+
+	if _, err := s.Redeliver(failed.ID); !errors.Is(err, ErrNotDeadLetter) {
+		t.Errorf("Expected ErrNotDeadLetter redelivering twice, got %v", err)
+	}
+	if _, err := s.Redeliver(redelivery.ID); !errors.Is(err, ErrNotDeadLetter) {
+		t.Errorf("Expected ErrNotDeadLetter for a delivered delivery, got %v", err)
+	}
+	if _, err := s.Redeliver(999); !errors.Is(err, ErrDeliveryNotFound) {
+		t.Errorf("Expected ErrDeliveryNotFound, got %v", err)
+	}
+
+	// Deleting a webhook drops its dead letters
+	if err := s.Delete(unreachable.ID); err != nil {
+		t.Fatalf("Failed to delete webhook: %v", err)
+	}
+	if deadLetters := s.DeadLetters(); len(deadLetters) != 0 {
+		t.Errorf("Expected no dead letters left, got %+v", deadLetters)
+	}
+}
+
+// TestService_Run_MaxPending tests that deliveries to a webhook that never
+// answers stop piling up: the oldest waiting one becomes a dead letter
+func TestService_Run_MaxPending(t *testing.T) {
+	config := testConfig
+	config.Timeout, config.MaxPending = time.Minute, 3
+	s, bus := startService(t, config)
+	release := make(chan struct{})
+	hung := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	t.Cleanup(hung.Close)
+	t.Cleanup(func() { close(release) }) // Before Close, which waits for the hung requests
+	webhook, _ := s.Register(hung.URL, nil, "")
+
+	for id := 1; id <= 10; id++ {
+		bus.Publish([]storage.Change{{Kind: storage.ChangeDeleted, ID: id}})
+	}
+
+	waitFor(t, "7 dead letters", func() bool { return len(s.DeadLetters()) == 7 })
+	deliveries, _ := s.Deliveries(webhook.ID)
+	var pending []uint64
+	for _, delivery := range deliveries {
+		if delivery.Status == DeliveryPending {
+			pending = append(pending, delivery.EventID)
+		}
+	}
+	// The first delivery is under way and stays; the newest ones wait behind it
+	if expected := []uint64{10, 9, 1}; !reflect.DeepEqual(pending, expected) {
+		t.Errorf("Expected pending deliveries of events %v, got %v", expected, pending)
+	}
+	for _, deadLetter := range s.DeadLetters() {
+		if deadLetter.Status != DeliveryFailed || deadLetter.Error == "" || len(deadLetter.Attempts) != 0 {
+			t.Errorf("Expected a dropped delivery without attempts, got %+v", deadLetter)
+		}
+	}
+}
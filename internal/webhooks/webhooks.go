@@ -0,0 +1,396 @@
+// Package webhooks delivers task events to URLs registered by clients. Every
+// delivery is a JSON POST signed with the webhook's secret (see Sign); failed
+// deliveries are retried with exponential backoff and end up in a dead-letter
+// list once they run out of attempts, from where they can be redelivered.
+//
+// Webhooks and their deliveries are kept in memory: they do not survive a
+// restart, and neither do deliveries still waiting for a retry.
+package webhooks
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"task-api/internal/clock"
+	"task-api/internal/events"
+	"task-api/internal/models"
+	"time"
+)
+
+var (
+	ErrNotFound         = errors.New("webhook not found")
+	ErrDeliveryNotFound = errors.New("delivery not found")
+	ErrNotDeadLetter    = errors.New("delivery has not failed")
+)
+
+// RuleURL is the validation rule of webhook URLs: absolute http or https URLs,
+// of public hosts unless Config.AllowPrivate is set.
+const RuleURL = "url"
+
+// Limits on what is remembered
+const (
+	maxHistory     = 100  // Finished deliveries kept per webhook
+	maxDeadLetters = 1000 // Failed deliveries kept for redelivery
+)
+
+// Webhook is a URL told about task events.
+type Webhook struct {
+	ID        int           `json:"id"`
+	URL       string        `json:"url"`
+	Events    []events.Type `json:"events"`           // The event types delivered, all of them if empty
+	Secret    string        `json:"secret,omitempty"` // Key signing the deliveries, only shown when the webhook is created
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+// wants reports whether the webhook is told about events of type t
+func (w *Webhook) wants(t events.Type) bool {
+	return len(w.Events) == 0 || slices.Contains(w.Events, t)
+}
+
+// DeliveryStatus says how far a delivery got.
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"   // Waiting for its first attempt or a retry
+	DeliveryDelivered DeliveryStatus = "delivered" // The webhook answered with a 2xx status
+	DeliveryFailed    DeliveryStatus = "failed"    // Every attempt failed; the delivery is a dead letter
+)
+
+// Delivery is one event on its way to one webhook.
+type Delivery struct {
+	ID            int            `json:"id"`
+	WebhookID     int            `json:"webhook_id"`
+	EventID       uint64         `json:"event_id"`
+	EventType     events.Type    `json:"event_type"`
+	Status        DeliveryStatus `json:"status"`
+	Attempts      []Attempt      `json:"attempts"`
+	NextAttemptAt *time.Time     `json:"next_attempt_at,omitempty"` // When the delivery is attempted next, while pending
+	Error         string         `json:"error,omitempty"`           // Why the delivery failed before running out of attempts
+	CreatedAt     time.Time      `json:"created_at"`
+
+	payload  []byte // The body of every attempt
+	inFlight bool   // An attempt is under way
+}
+
+// clone returns a copy of the delivery sharing no mutable memory with it
+func (d *Delivery) clone() Delivery {
+	clone := *d
+	clone.Attempts = slices.Clone(d.Attempts)
+	if d.NextAttemptAt != nil {
+		next := *d.NextAttemptAt
+		clone.NextAttemptAt = &next
+	}
+	return clone
+}
+
+// Attempt is one try at delivering.
+type Attempt struct {
+	At         time.Time `json:"at"`
+	StatusCode int       `json:"status_code,omitempty"` // Status the webhook answered with, 0 if it did not answer
+	Error      string    `json:"error,omitempty"`       // Why the attempt failed, empty if it succeeded
+}
+
+// Config sets how deliveries are made.
+type Config struct {
+	MaxAttempts int           // Attempts before a delivery becomes a dead letter
+	Backoff     time.Duration // Wait before the first retry, doubling with every further retry
+	MaxBackoff  time.Duration // Longest wait between two attempts
+	Timeout     time.Duration // Longest a webhook may take to answer
+	Workers     int           // Deliveries attempted at once
+	MaxPending  int           // Deliveries waiting for one webhook before the oldest becomes a dead letter, 0 for no limit
+
+	// AllowPrivate lets webhooks point at loopback, private and link-local
+	// addresses, which are otherwise refused so that registering a webhook
+	// cannot reach services only the server can
+	AllowPrivate bool
+}
+
+// DefaultConfig tries a delivery 8 times over about 20 minutes and keeps up
+// to 1000 deliveries waiting for each webhook.
+var DefaultConfig = Config{MaxAttempts: 8, Backoff: 10 * time.Second, MaxBackoff: time.Hour, Timeout: 10 * time.Second, Workers: 4, MaxPending: 1000}
+
+// backoff returns the wait after the given number of failed attempts
+func (c Config) backoff(failed int) time.Duration {
+	wait := c.Backoff
+	for i := 1; i < failed && wait < c.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, c.MaxBackoff)
+}
+
+// Service keeps the registered webhooks and delivers the events published on
+// a bus to them while running (see Run). It is safe for concurrent use.
+type Service struct {
+	bus    *events.Bus
+	clock  clock.Clock
+	config Config
+	client *http.Client
+
+	mutex          sync.Mutex
+	webhooks       map[int]*Webhook
+	lastWebhookID  int
+	deliveries     map[int][]*Delivery // Deliveries of each webhook, oldest first
+	pending        []*Delivery         // Deliveries waiting for an attempt
+	deadLetters    []*Delivery         // Failed deliveries, oldest first
+	lastDeliveryID int
+	wake           chan struct{} // Tells Run to look at the pending deliveries again
+}
+
+// New creates a service delivering the events published on bus with client,
+// or with a client giving up after config.Timeout, following no redirects and
+// connecting to public addresses only unless config.AllowPrivate if nil,
+// reading the time from c.
+func New(bus *events.Bus, c clock.Clock, config Config, client *http.Client) *Service {
+	if client == nil {
+		client = &http.Client{
+			Transport: newTransport(config.AllowPrivate),
+			Timeout:   config.Timeout,
+			// A redirect is an answer like any other: only 2xx counts as delivered
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		}
+	}
+	return &Service{
+		bus:        bus,
+		clock:      c,
+		config:     config,
+		client:     client,
+		webhooks:   make(map[int]*Webhook),
+		deliveries: make(map[int][]*Delivery),
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+// Register adds a webhook for the given URL and event types, all of them if
+// none are given. Without a secret a random one is made up. The returned
+// webhook carries the secret; it is not shown again.
+func (s *Service) Register(rawURL string, eventTypes []events.Type, secret string) (*Webhook, error) {
+	var fieldErrs []models.FieldError
+	if parsed, err := url.Parse(rawURL); rawURL == "" {
+		fieldErrs = append(fieldErrs, models.FieldError{Field: "url", Rule: models.RuleRequired, Message: "webhook url cannot be empty"})
+	} else if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		fieldErrs = append(fieldErrs, models.FieldError{Field: "url", Rule: RuleURL, Message: "webhook url must be an absolute http or https URL"})
+	} else if !s.config.AllowPrivate && !isPublicHost(parsed.Hostname()) {
+		fieldErrs = append(fieldErrs, models.FieldError{Field: "url", Rule: RuleURL, Message: "webhook url must not point at a loopback, private or link-local address"})
+	}
+	types := []events.Type{}
+	for _, t := range eventTypes {
+		switch t {
+		case events.TaskCreated, events.TaskUpdated, events.TaskDeleted:
+			if !slices.Contains(types, t) {
+				types = append(types, t)
+			}
+		default:
+			fieldErrs = append(fieldErrs, models.FieldError{
+				Field: "events", Rule: models.RuleOneOf,
+				Message: fmt.Sprintf("webhook event %q must be one of %s, %s or %s", t, events.TaskCreated, events.TaskUpdated, events.TaskDeleted),
+			})
+		}
+	}
+	if len(fieldErrs) > 0 {
+		return nil, &models.ValidationError{Errors: fieldErrs}
+	}
+	if secret == "" {
+		var err error
+		if secret, err = newSecret(); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.lastWebhookID++
+	webhook := &Webhook{ID: s.lastWebhookID, URL: rawURL, Events: types, Secret: secret, CreatedAt: s.clock.Now().UTC()}
+	s.webhooks[webhook.ID] = webhook
+
+	created := *webhook
+	created.Events = slices.Clone(types)
+	return &created, nil
+}
+
+// newSecret returns a random signing key
+func newSecret() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return hex.EncodeToString(key), nil
+}
+
+// List returns the webhooks in ID order, without their secrets.
+func (s *Service) List() []*Webhook {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	webhooks := make([]*Webhook, 0, len(s.webhooks))
+	for _, webhook := range s.webhooks {
+		webhooks = append(webhooks, public(webhook))
+	}
+	slices.SortFunc(webhooks, func(a, b *Webhook) int { return a.ID - b.ID })
+	return webhooks
+}
+
+// Get returns the webhook with id, without its secret.
+func (s *Service) Get(id int) (*Webhook, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	webhook, ok := s.webhooks[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return public(webhook), nil
+}
+
+// public returns a copy of webhook without its secret
+func public(webhook *Webhook) *Webhook {
+	copied := *webhook
+	copied.Events = slices.Clone(webhook.Events)
+	copied.Secret = ""
+	return &copied
+}
+
+// Delete removes the webhook with id, with its pending deliveries, history and dead letters.
+func (s *Service) Delete(id int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.webhooks[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.webhooks, id)
+	delete(s.deliveries, id)
+	ofWebhook := func(d *Delivery) bool { return d.WebhookID == id }
+	s.pending = slices.DeleteFunc(s.pending, ofWebhook)
+	s.deadLetters = slices.DeleteFunc(s.deadLetters, ofWebhook)
+	return nil
+}
+
+// Deliveries returns the recent deliveries to the webhook with id, newest first.
+func (s *Service) Deliveries(id int) ([]Delivery, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.webhooks[id]; !ok {
+		return nil, ErrNotFound
+	}
+	return newestFirst(s.deliveries[id]), nil
+}
+
+// DeadLetters returns the deliveries that failed every attempt, newest first.
+func (s *Service) DeadLetters() []Delivery {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return newestFirst(s.deadLetters)
+}
+
+// newestFirst copies deliveries in reverse order
+func newestFirst(deliveries []*Delivery) []Delivery {
+	copies := make([]Delivery, 0, len(deliveries))
+	for i := len(deliveries) - 1; i >= 0; i-- {
+		copies = append(copies, deliveries[i].clone())
+	}
+	return copies
+}
+
+// Redeliver queues the event of a dead letter for delivery again, as a new
+// delivery with a fresh set of attempts, and removes it from the dead letters.
+func (s *Service) Redeliver(deliveryID int) (*Delivery, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	i := slices.IndexFunc(s.deadLetters, func(d *Delivery) bool { return d.ID == deliveryID })
+	if i < 0 {
+		for _, deliveries := range s.deliveries {
+			if slices.ContainsFunc(deliveries, func(d *Delivery) bool { return d.ID == deliveryID }) {
+				return nil, ErrNotDeadLetter
+			}
+		}
+		return nil, ErrDeliveryNotFound
+	}
+	failed := s.deadLetters[i]
+	s.deadLetters = slices.Delete(s.deadLetters, i, i+1)
+
+	delivery := s.queueLocked(failed.WebhookID, failed.EventID, failed.EventType, failed.payload)
+	copied := delivery.clone()
+	return &copied, nil
+}
+
+// queueLocked creates a delivery due right away. If the webhook already has
+// config.MaxPending deliveries waiting, the oldest one not under way becomes
+// a dead letter, so a webhook that never answers cannot pile up deliveries
+// without end. The caller must hold the mutex.
+func (s *Service) queueLocked(webhookID int, eventID uint64, eventType events.Type, payload []byte) *Delivery {
+	if s.config.MaxPending > 0 {
+		var waiting []*Delivery
+		for _, delivery := range s.pending {
+			if delivery.WebhookID == webhookID {
+				waiting = append(waiting, delivery)
+			}
+		}
+		if len(waiting) >= s.config.MaxPending {
+			if i := slices.IndexFunc(waiting, func(d *Delivery) bool { return !d.inFlight }); i >= 0 {
+				waiting[i].Error = fmt.Sprintf("dropped: %d deliveries were waiting for the webhook", len(waiting))
+				s.finishLocked(waiting[i], DeliveryFailed)
+			}
+		}
+	}
+
+	now := s.clock.Now().UTC()
+	s.lastDeliveryID++
+	delivery := &Delivery{
+		ID:            s.lastDeliveryID,
+		WebhookID:     webhookID,
+		EventID:       eventID,
+		EventType:     eventType,
+		Status:        DeliveryPending,
+		Attempts:      []Attempt{},
+		NextAttemptAt: &now,
+		CreatedAt:     now,
+		payload:       payload,
+	}
+	s.pending = append(s.pending, delivery)
+
+	// Forget the oldest finished deliveries of the webhook
+	history := append(s.deliveries[webhookID], delivery)
+	for excess := len(history) - maxHistory; excess > 0; excess-- {
+		i := slices.IndexFunc(history, func(d *Delivery) bool { return d.Status != DeliveryPending })
+		if i < 0 {
+			break
+		}
+		history = slices.Delete(history, i, i+1)
+	}
+	s.deliveries[webhookID] = history
+
+	s.signal()
+	return delivery
+}
+
+// finishLocked ends a pending delivery with status, keeping it among the dead
+// letters if it failed and its webhook still exists. The caller must hold the mutex.
+func (s *Service) finishLocked(delivery *Delivery, status DeliveryStatus) {
+	delivery.Status = status
+	delivery.NextAttemptAt = nil
+	s.pending = slices.DeleteFunc(s.pending, func(d *Delivery) bool { return d == delivery })
+
+	if _, ok := s.webhooks[delivery.WebhookID]; ok && status == DeliveryFailed {
+		s.deadLetters = append(s.deadLetters, delivery)
+		if excess := len(s.deadLetters) - maxDeadLetters; excess > 0 {
+			s.deadLetters = slices.Delete(s.deadLetters, 0, excess)
+		}
+	}
+}
+
+// signal wakes Run up without waiting
+func (s *Service) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
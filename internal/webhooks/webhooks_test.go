@@ -0,0 +1,153 @@
+package webhooks
+
+import (
+	"errors"
+	"reflect"
+	"task-api/internal/clock"
+	"task-api/internal/events"
+	"task-api/internal/models"
+	"testing"
+	"time"
+)
+
+// TestService_Register tests validating and registering webhooks
+func TestService_Register(t *testing.T) {
+	tests := []struct {
+		name           string
+		url            string
+		events         []events.Type
+		secret         string
+		allowPrivate   bool
+		expectedEvents []events.Type
+		expectedRules  []string
+	}{
+		{name: "every event", url: "https://example.com/hook", expectedEvents: []events.Type{}},
+		{
+			name: "some events", url: "http://hooks.example.com:9000/hook", secret: "s3cret",
+			events:         []events.Type{events.TaskDeleted, events.TaskCreated, events.TaskDeleted},
+			expectedEvents: []events.Type{events.TaskDeleted, events.TaskCreated},
+		},
+		{name: "missing url", expectedRules: []string{models.RuleRequired}},
+		{name: "relative url", url: "/hook", expectedRules: []string{RuleURL}},
+		{name: "other scheme", url: "ftp://example.com/hook", expectedRules: []string{RuleURL}},
+		{name: "public address", url: "http://93.184.215.14/hook", expectedEvents: []events.Type{}},
+		{name: "loopback address", url: "http://127.0.0.1:9000/hook", expectedRules: []string{RuleURL}},
+		{name: "IPv6 loopback address", url: "http://[::1]:9000/hook", expectedRules: []string{RuleURL}},
+		{name: "mapped loopback address", url: "http://[::ffff:127.0.0.1]/hook", expectedRules: []string{RuleURL}},
+		{name: "localhost", url: "http://localhost:9000/hook", expectedRules: []string{RuleURL}},
+		{name: "private address", url: "http://10.0.0.5/hook", expectedRules: []string{RuleURL}},
+		{name: "metadata service", url: "http://169.254.169.254/latest/meta-data", expectedRules: []string{RuleURL}},
+		{name: "unspecified address", url: "http://0.0.0.0/hook", expectedRules: []string{RuleURL}},
+		{name: "private addresses allowed", url: "http://localhost:9000/hook", allowPrivate: true, expectedEvents: []events.Type{}},
+		{
+			name: "unknown event", url: "https://example.com/hook",
+			events:        []events.Type{events.TaskCreated, "task.reminder"},
+			expectedRules: []string{models.RuleOneOf},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig
+			config.AllowPrivate = tt.allowPrivate
+			s := New(events.NewBus(0), clock.System(), config, nil)
+
+			webhook, err := s.Register(tt.url, tt.events, tt.secret)
+
+			if tt.expectedRules != nil {
+				var validationErr *models.ValidationError
+				if !errors.As(err, &validationErr) {
+					t.Fatalf("Expected a validation error, got %v", err)
+				}
+				var rules []string
+				for _, fieldErr := range validationErr.Errors {
+					rules = append(rules, fieldErr.Rule)
+				}
+				if !reflect.DeepEqual(rules, tt.expectedRules) {
+					t.Errorf("Expected rules %v, got %v", tt.expectedRules, rules)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to register webhook: %v", err)
+			}
+			if webhook.ID != 1 || webhook.URL != tt.url || !reflect.DeepEqual(webhook.Events, tt.expectedEvents) {
+				t.Errorf("Unexpected webhook %+v", webhook)
+			}
+			switch {
+			case tt.secret != "" && webhook.Secret != tt.secret:
+				t.Errorf("Expected secret %q, got %q", tt.secret, webhook.Secret)
+			case tt.secret == "" && len(webhook.Secret) != 64:
+				t.Errorf("Expected a generated secret, got %q", webhook.Secret)
+			}
+
+			// The secret is not shown again
+			stored, err := s.Get(webhook.ID)
+			if err != nil || stored.Secret != "" || stored.URL != tt.url {
+				t.Errorf("Expected the webhook without its secret, got %+v (%v)", stored, err)
+			}
+		})
+	}
+}
+
+// TestService_Delete tests listing and removing webhooks
+func TestService_Delete(t *testing.T) {
+	s := New(events.NewBus(0), clock.System(), DefaultConfig, nil)
+	first, _ := s.Register("https://example.com/first", nil, "")
+	second, _ := s.Register("https://example.com/second", nil, "")
+
+	if list := s.List(); len(list) != 2 || list[0].ID != first.ID || list[1].ID != second.ID || list[0].Secret != "" {
+		t.Fatalf("Expected both webhooks without secrets, got %+v", list)
+	}
+
+	if err := s.Delete(first.ID); err != nil {
+		t.Fatalf("Failed to delete webhook: %v", err)
+	}
+	if err := s.Delete(first.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting again, got %v", err)
+	}
+	if _, err := s.Get(first.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if _, err := s.Deliveries(first.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for the deliveries, got %v", err)
+	}
+	if list := s.List(); len(list) != 1 || list[0].ID != second.ID {
+		t.Errorf("Expected only the second webhook, got %+v", list)
+	}
+}
+
+// TestSign tests the delivery signature
+func TestSign(t *testing.T) {
+	body := []byte(`{"id":1}`)
+	expected := "sha256=3dd1b9aef568d75f6790a84bd2e5dfa1f44409eef3cbdbd3f10b837376100c11"
+
+	if signature := Sign("secret", 1700000000, body); signature != expected {
+		t.Errorf("Expected %s, got %s", expected, signature)
+	}
+	if Sign("other", 1700000000, body) == expected || Sign("secret", 1700000001, body) == expected {
+		t.Error("Expected the signature to depend on the secret and the timestamp")
+	}
+}
+
+// TestConfig_backoff tests that retries wait twice as long each time, up to the limit
+func TestConfig_backoff(t *testing.T) {
+	config := Config{Backoff: 10 * time.Second, MaxBackoff: time.Minute}
+
+	tests := []struct {
+		failed   int
+		expected time.Duration
+	}{
+		{failed: 1, expected: 10 * time.Second},
+		{failed: 2, expected: 20 * time.Second},
+		{failed: 3, expected: 40 * time.Second},
+		{failed: 4, expected: time.Minute},
+		{failed: 50, expected: time.Minute},
+	}
+
+	for _, tt := range tests {
+		if wait := config.backoff(tt.failed); wait != tt.expected {
+			t.Errorf("Expected to wait %v after %d failed attempts, got %v", tt.expected, tt.failed, wait)
+		}
+	}
+}
@@ -145,6 +145,39 @@ func TestTaskAPI_E2E(t *testing.T) {
 		}
 	})
 
+	// Test 5b: Get a single task and revalidate it with its ETag
+	t.Run("GET /tasks/{id} - get task", func(t *testing.T) {
+		resp, err := http.Get(fmt.Sprintf("%s/tasks/%d", baseURL, task1.ID))
+		if err != nil {
+			t.Fatalf("Failed to GET /tasks/%d: %v", task1.ID, err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected status 200, got %d", resp.StatusCode)
+		}
+
+		var fetched models.Task
+		if err := json.NewDecoder(resp.Body).Decode(&fetched); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if fetched.ID != task1.ID || fetched.Name != task1.Name {
+			t.Errorf("Expected task %+v, got %+v", *task1, fetched)
+		}
+
+		req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/tasks/%d", baseURL, task1.ID), nil)
+		req.Header.Set("If-None-Match", resp.Header.Get("ETag"))
+		cached, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to revalidate /tasks/%d: %v", task1.ID, err)
+		}
+		defer cached.Body.Close()
+
+		if cached.StatusCode != http.StatusNotModified {
+			t.Errorf("Expected status 304, got %d", cached.StatusCode)
+		}
+	})
+
 	// Test 6: Update first task
 	t.Run("PUT /tasks/{id} - update task", func(t *testing.T) {
 		updateData := map[string]interface{}{
@@ -276,6 +309,18 @@ func TestTaskAPI_ErrorCases_E2E(t *testing.T) {
 		}
 	})
 
+	t.Run("GET /tasks/999 - non-existent task", func(t *testing.T) {
+		resp, err := http.Get(baseURL + "/tasks/999")
+		if err != nil {
+			t.Fatalf("Failed to GET /tasks/999: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("Expected status 404, got %d", resp.StatusCode)
+		}
+	})
+
 	t.Run("DELETE /tasks/999 - non-existent task", func(t *testing.T) {
 		client := &http.Client{}
 		req, _ := http.NewRequest(http.MethodDelete, baseURL+"/tasks/999", nil)